@@ -0,0 +1,10 @@
+package api
+
+import (
+	_ "embed" // solely for its side effects (embedding)
+)
+
+// OpenAPI is the OpenAPI 3 document of every route of the service.
+//
+//go:embed openapi.json
+var OpenAPI []byte
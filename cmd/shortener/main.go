@@ -1,12 +1,16 @@
 package main
 
 import (
+	"os"
+
 	"github.com/tmitry/shorturl/internal/app"
 	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/logger"
 )
 
 func main() {
-	cfg := configs.NewConfig()
+	cfg, log := configs.NewConfig()
+	logger.SetDefault(log)
 
-	app.StartServer(cfg)
+	os.Exit(app.StartServer(cfg, log))
 }
@@ -0,0 +1,49 @@
+package configs
+
+import "github.com/tmitry/shorturl/internal/app/logger"
+
+/*
+AdminConfig grants the admin role. A request is made by an admin when its user ID is listed in UserIDs
+or it is authenticated by an API key listed in APIKeyIDs.
+No admin is configured by default, so the admin API is unreachable.
+
+AdminConfig uses the following precedence order. Each item takes precedence over the item below it:
+- Env
+- YAML
+- Default.
+*/
+type AdminConfig struct {
+	UserIDs   []string `env:"ADMIN_USER_IDS" yaml:"user_ids"`
+	APIKeyIDs []string `env:"ADMIN_API_KEY_IDS" yaml:"api_key_ids"`
+}
+
+func NewAdminConfig(userIDs, apiKeyIDs []string) *AdminConfig {
+	return &AdminConfig{
+		UserIDs:   userIDs,
+		APIKeyIDs: apiKeyIDs,
+	}
+}
+
+func NewDefaultAdminConfig() *AdminConfig {
+	return NewAdminConfig(nil, nil)
+}
+
+func GetAdminConfig(flagConfig *FlagConfig, log *logger.Logger) (*AdminConfig, Sources) {
+	adminCfg := NewAdminConfig(nil, nil)
+
+	defaultAdminLayer := newDefaultLayer(NewDefaultAdminConfig())
+
+	envAdminLayer, err := newEnvLayer(NewAdminConfig(nil, nil))
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "admin", logger.KeyError, err)
+	}
+
+	yamlAdminLayer, err := newYAMLLayer(NewAdminConfig(nil, nil), flagConfig.AdminConfigPath)
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "admin", logger.KeyError, err)
+	}
+
+	priorityLayers := []*layer[AdminConfig]{envAdminLayer, yamlAdminLayer, defaultAdminLayer}
+
+	return adminCfg, buildConfig(adminCfg, priorityLayers)
+}
@@ -1,12 +1,6 @@
 package configs
 
-import (
-	"log"
-	"os"
-
-	"github.com/caarlos0/env/v6"
-	"gopkg.in/yaml.v3"
-)
+import "github.com/tmitry/shorturl/internal/app/logger"
 
 const (
 	hashSalt                   = "_X@kQePA8dmBiZVBHax*zUUi"
@@ -14,6 +8,15 @@ const (
 	fileStoragePath            = ""
 	deletionBufferMaxSize      = 500
 	deletionBufferClearTimeout = 5 // Idle time (in seconds) after which buffer will be cleared.
+	deletionWorkers            = 4
+	deletionQueueSize          = 1000
+	deletionRetryMaxAttempts   = 3
+	deletionRetryBackoff       = 1  // Delay (in seconds) before the second attempt, it doubles with every attempt.
+	deletionRetryMaxBackoff    = 30 // Upper bound (in seconds) of the delay between attempts.
+	environment                = EnvironmentDevelopment
+
+	EnvironmentDevelopment = "development"
+	EnvironmentProduction  = "production" // Refuses to start with the default secrets.
 )
 
 /*
@@ -24,11 +27,17 @@ AppConfig uses the following precedence order. Each item takes precedence over t
 - Default.
 */
 type AppConfig struct {
-	HashSalt                   string `env:"APP_HASH_SALT" yaml:"hash_salt"`
+	HashSalt                   string `env:"APP_HASH_SALT" yaml:"hash_salt" secret:"true"`
 	HashMinLength              int    `env:"APP_HASH_MIN_LENGTH" yaml:"hash_min_length"`
-	FileStoragePath            string `env:"FILE_STORAGE_PATH" yaml:"file_storage_path"`
+	FileStoragePath            string `env:"FILE_STORAGE_PATH" yaml:"file_storage_path" flag:"file_path"`
 	DeletionBufferMaxSize      int    `env:"APP_DELETION_BUFFER_MAX_SIZE" yaml:"deletion_buffer_max_size"`
 	DeletionBufferClearTimeout int    `env:"APP_DELETION_CLEAR_TIMEOUT" yaml:"deletion_buffer_clear_timeout"`
+	DeletionWorkers            int    `env:"APP_DELETION_WORKERS" yaml:"deletion_workers"`
+	DeletionQueueSize          int    `env:"APP_DELETION_QUEUE_SIZE" yaml:"deletion_queue_size"`
+	DeletionRetryMaxAttempts   int    `env:"APP_DELETION_RETRY_MAX_ATTEMPTS" yaml:"deletion_retry_max_attempts"`
+	DeletionRetryBackoff       int    `env:"APP_DELETION_RETRY_BACKOFF" yaml:"deletion_retry_backoff"`
+	DeletionRetryMaxBackoff    int    `env:"APP_DELETION_RETRY_MAX_BACKOFF" yaml:"deletion_retry_max_backoff"`
+	Environment                string `env:"APP_ENV" yaml:"environment"` // development or production.
 }
 
 func NewAppConfig(
@@ -37,6 +46,12 @@ func NewAppConfig(
 	fileStoragePath string,
 	deletionBufferMaxSize int,
 	deletionBufferClearTimeout int,
+	deletionWorkers int,
+	deletionQueueSize int,
+	deletionRetryMaxAttempts int,
+	deletionRetryBackoff int,
+	deletionRetryMaxBackoff int,
+	environment string,
 ) *AppConfig {
 	return &AppConfig{
 		HashSalt:                   hashSalt,
@@ -44,43 +59,49 @@ func NewAppConfig(
 		FileStoragePath:            fileStoragePath,
 		DeletionBufferMaxSize:      deletionBufferMaxSize,
 		DeletionBufferClearTimeout: deletionBufferClearTimeout,
+		DeletionWorkers:            deletionWorkers,
+		DeletionQueueSize:          deletionQueueSize,
+		DeletionRetryMaxAttempts:   deletionRetryMaxAttempts,
+		DeletionRetryBackoff:       deletionRetryBackoff,
+		DeletionRetryMaxBackoff:    deletionRetryMaxBackoff,
+		Environment:                environment,
 	}
 }
 
 func NewDefaultAppConfig() *AppConfig {
-	return NewAppConfig(hashSalt, hashMinLength, fileStoragePath, deletionBufferMaxSize, deletionBufferClearTimeout)
+	return NewAppConfig(
+		hashSalt,
+		hashMinLength,
+		fileStoragePath,
+		deletionBufferMaxSize,
+		deletionBufferClearTimeout,
+		deletionWorkers,
+		deletionQueueSize,
+		deletionRetryMaxAttempts,
+		deletionRetryBackoff,
+		deletionRetryMaxBackoff,
+		environment,
+	)
 }
 
-func GetAppConfig(flagConfig *FlagConfig) *AppConfig {
-	appCfg := NewAppConfig("", 0, "", 0, 0)
+func GetAppConfig(flagConfig *FlagConfig, log *logger.Logger) (*AppConfig, Sources) {
+	appCfg := NewAppConfig("", 0, "", 0, 0, 0, 0, 0, 0, 0, "")
 
-	defaultAppCfg := NewDefaultAppConfig()
+	defaultAppLayer := newDefaultLayer(NewDefaultAppConfig())
 
-	envAppCfg := NewAppConfig("", 0, "", 0, 0)
-	if err := env.Parse(envAppCfg); err != nil {
-		log.Panic(err)
+	envAppLayer, err := newEnvLayer(NewAppConfig("", 0, "", 0, 0, 0, 0, 0, 0, 0, ""))
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "app", logger.KeyError, err)
 	}
 
-	flagAppCfg := NewAppConfig("", 0, flagConfig.FileStoragePath, 0, 0)
-
-	yamlAppCfg := NewAppConfig("", 0, "", 0, 0)
-
-	if flagConfig.AppConfigPath != "" {
-		file, err := os.Open(flagConfig.AppConfigPath)
-		if err != nil {
-			log.Panic(err)
-		}
-
-		decoder := yaml.NewDecoder(file)
-
-		if err = decoder.Decode(yamlAppCfg); err != nil {
-			log.Panic(err)
-		}
+	yamlAppLayer, err := newYAMLLayer(NewAppConfig("", 0, "", 0, 0, 0, 0, 0, 0, 0, ""), flagConfig.AppConfigPath)
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "app", logger.KeyError, err)
 	}
 
-	priorityConfigs := []*AppConfig{flagAppCfg, envAppCfg, yamlAppCfg, defaultAppCfg}
+	flagAppLayer := newFlagLayer(NewAppConfig("", 0, flagConfig.FileStoragePath, 0, 0, 0, 0, 0, 0, 0, ""), flagConfig)
 
-	buildConfig(appCfg, priorityConfigs)
+	priorityLayers := []*layer[AppConfig]{flagAppLayer, envAppLayer, yamlAppLayer, defaultAppLayer}
 
-	return appCfg
+	return appCfg, buildConfig(appCfg, priorityLayers)
 }
@@ -1,78 +1,169 @@
 package configs
 
 import (
-	"reflect"
+	"os"
 
 	flag "github.com/spf13/pflag"
+	"github.com/tmitry/shorturl/internal/app/logger"
 )
 
+const messageFailedToLoadConfig = "failed to load config"
+
 type ConfigInterface interface {
-	ServerConfig | AppConfig | DatabaseConfig
+	ServerConfig | AppConfig | DatabaseConfig | PolicyConfig | RateLimitConfig | QuotaConfig | JWTConfig |
+		AdminConfig | WebhookConfig | MetricsConfig | LogConfig
 }
 
 type Config struct {
-	App      *AppConfig
-	Server   *ServerConfig
-	Database *DatabaseConfig
+	App       *AppConfig
+	Server    *ServerConfig
+	Database  *DatabaseConfig
+	Policy    *PolicyConfig
+	RateLimit *RateLimitConfig
+	Quota     *QuotaConfig
+	JWT       *JWTConfig
+	Admin     *AdminConfig
+	Webhook   *WebhookConfig
+	Metrics   *MetricsConfig
+	Log       *LogConfig
+
+	// Sources maps sections, named as their YAML files, to sources of their fields.
+	Sources map[string]Sources
 }
 
-func NewConfig() *Config {
+/*
+NewConfig loads the config and builds the logger described by its log section.
+Failures of loading the log section are reported by the default logger, the rest by the built logger.
+The loaded config is validated, all of its problems are reported at once.
+With --print-config the config is printed along with sources of its values and the process exits.
+*/
+func NewConfig() (*Config, *logger.Logger) {
 	flagConfig := NewFlagConfig()
 
-	return &Config{
-		App:      GetAppConfig(flagConfig),
-		Server:   GetServerConfig(flagConfig),
-		Database: GetDatabaseConfig(flagConfig),
-	}
-}
+	logCfg, logSources := GetLogConfig(flagConfig, logger.Default())
 
-func NewDefaultConfig() *Config {
-	return &Config{
-		App:      NewDefaultAppConfig(),
-		Server:   NewDefaultServerConfig(),
-		Database: NewDefaultDatabaseConfig(),
+	log, err := NewLogger(logCfg)
+	if err != nil {
+		logger.Default().Panic(messageFailedToLoadConfig, "section", "log", logger.KeyError, err)
 	}
-}
 
-func buildConfig[C ConfigInterface](config *C, precedenceConfigs []*C) {
-	configValue := reflect.ValueOf(config).Elem()
+	cfg := &Config{
+		App:       nil,
+		Server:    nil,
+		Database:  nil,
+		Policy:    nil,
+		RateLimit: nil,
+		Quota:     nil,
+		JWT:       nil,
+		Admin:     nil,
+		Webhook:   nil,
+		Metrics:   nil,
+		Log:       logCfg,
+		Sources:   map[string]Sources{"log": logSources},
+	}
 
-	for _, priorityConfig := range precedenceConfigs {
-		priorityConfigValue := reflect.ValueOf(priorityConfig).Elem()
+	cfg.App, cfg.Sources["app"] = GetAppConfig(flagConfig, log)
+	cfg.Server, cfg.Sources["server"] = GetServerConfig(flagConfig, log)
+	cfg.Database, cfg.Sources["database"] = GetDatabaseConfig(flagConfig, log)
+	cfg.Policy, cfg.Sources["policy"] = GetPolicyConfig(flagConfig, log)
+	cfg.RateLimit, cfg.Sources["rate_limit"] = GetRateLimitConfig(flagConfig, log)
+	cfg.Quota, cfg.Sources["quota"] = GetQuotaConfig(flagConfig, log)
+	cfg.JWT, cfg.Sources["jwt"] = GetJWTConfig(flagConfig, log)
+	cfg.Admin, cfg.Sources["admin"] = GetAdminConfig(flagConfig, log)
+	cfg.Webhook, cfg.Sources["webhook"] = GetWebhookConfig(flagConfig, log)
+	cfg.Metrics, cfg.Sources["metrics"] = GetMetricsConfig(flagConfig, log)
 
-		for index := 0; index < configValue.NumField(); index++ {
-			if reflect.Zero(configValue.Field(index).Type()).Interface() != configValue.Field(index).Interface() {
-				continue
-			}
+	if flagConfig.PrintConfig {
+		if err = PrintConfig(os.Stdout, cfg); err != nil {
+			log.Fatal("failed to print config", logger.KeyError, err)
+		}
 
-			if priorityConfigValue.Field(index).Interface() != reflect.Zero(configValue.Field(index).Type()).Interface() {
-				configValue.Field(index).Set(priorityConfigValue.Field(index))
-			}
+		if err = Validate(cfg); err != nil {
+			log.Fatal(messageFailedToLoadConfig, logger.KeyError, err)
 		}
+
+		os.Exit(0)
+	}
+
+	if err = Validate(cfg); err != nil {
+		log.Panic(messageFailedToLoadConfig, logger.KeyError, err)
+	}
+
+	return cfg, log
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		App:       NewDefaultAppConfig(),
+		Server:    NewDefaultServerConfig(),
+		Database:  NewDefaultDatabaseConfig(),
+		Policy:    NewDefaultPolicyConfig(),
+		RateLimit: NewDefaultRateLimitConfig(),
+		Quota:     NewDefaultQuotaConfig(),
+		JWT:       NewDefaultJWTConfig(),
+		Admin:     NewDefaultAdminConfig(),
+		Webhook:   NewDefaultWebhookConfig(),
+		Metrics:   NewDefaultMetricsConfig(),
+		Log:       NewDefaultLogConfig(),
+		Sources:   nil,
 	}
 }
 
 type FlagConfig struct {
-	Address            string
-	BaseURL            string
-	FileStoragePath    string
-	ServerConfigPath   string
-	AppConfigPath      string
-	DatabaseConfigPath string
-	JWTSignatureKey    string
-	DatabaseDSN        string
+	Address             string
+	BaseURL             string
+	FileStoragePath     string
+	ServerConfigPath    string
+	AppConfigPath       string
+	DatabaseConfigPath  string
+	PolicyConfigPath    string
+	RateLimitConfigPath string
+	QuotaConfigPath     string
+	JWTConfigPath       string
+	AdminConfigPath     string
+	WebhookConfigPath   string
+	MetricsConfigPath   string
+	LogConfigPath       string
+	JWTSignatureKey     string
+	DatabaseDSN         string
+	TrustedSubnet       string
+	TLSCertFile         string
+	TLSKeyFile          string
+	TLSSelfSigned       bool
+	TLSMinVersion       string
+	HTTPRedirectAddress string
+	PrintConfig         bool
+
+	// Changed lists names of the flags set on the command line, so flags set to zero values take precedence too.
+	Changed map[string]bool
 }
 
 func NewFlagConfig() *FlagConfig {
 	flagConfig := &FlagConfig{
-		Address:            "",
-		BaseURL:            "",
-		FileStoragePath:    "",
-		ServerConfigPath:   "",
-		AppConfigPath:      "",
-		DatabaseConfigPath: "",
-		JWTSignatureKey:    "",
-		DatabaseDSN:        "",
+		Address:             "",
+		BaseURL:             "",
+		FileStoragePath:     "",
+		ServerConfigPath:    "",
+		AppConfigPath:       "",
+		DatabaseConfigPath:  "",
+		PolicyConfigPath:    "",
+		RateLimitConfigPath: "",
+		QuotaConfigPath:     "",
+		JWTConfigPath:       "",
+		AdminConfigPath:     "",
+		WebhookConfigPath:   "",
+		MetricsConfigPath:   "",
+		LogConfigPath:       "",
+		JWTSignatureKey:     "",
+		DatabaseDSN:         "",
+		TrustedSubnet:       "",
+		TLSCertFile:         "",
+		TLSKeyFile:          "",
+		TLSSelfSigned:       false,
+		TLSMinVersion:       "",
+		HTTPRedirectAddress: "",
+		PrintConfig:         false,
+		Changed:             map[string]bool{},
 	}
 
 	flag.StringVarP(&flagConfig.Address, "server_address", "a", "", "Server address")
@@ -81,9 +172,28 @@ func NewFlagConfig() *FlagConfig {
 	flag.StringVar(&flagConfig.ServerConfigPath, "server_config_path", "", "Server config path")
 	flag.StringVar(&flagConfig.AppConfigPath, "app_config_path", "", "App config path")
 	flag.StringVar(&flagConfig.DatabaseConfigPath, "database_config_path", "", "Database config path")
+	flag.StringVar(&flagConfig.PolicyConfigPath, "policy_config_path", "", "URL policy config path")
+	flag.StringVar(&flagConfig.RateLimitConfigPath, "rate_limit_config_path", "", "Rate limit config path")
+	flag.StringVar(&flagConfig.QuotaConfigPath, "quota_config_path", "", "Quota config path")
+	flag.StringVar(&flagConfig.JWTConfigPath, "jwt_config_path", "", "JWT config path")
+	flag.StringVar(&flagConfig.AdminConfigPath, "admin_config_path", "", "Admin config path")
+	flag.StringVar(&flagConfig.WebhookConfigPath, "webhook_config_path", "", "Webhook config path")
+	flag.StringVar(&flagConfig.MetricsConfigPath, "metrics_config_path", "", "Metrics config path")
+	flag.StringVar(&flagConfig.LogConfigPath, "log_config_path", "", "Log config path")
 	flag.StringVar(&flagConfig.JWTSignatureKey, "jwt_signature_key", "", "JWT Signature key")
 	flag.StringVarP(&flagConfig.DatabaseDSN, "database_dsn", "d", "", "Database DSN")
+	flag.StringVarP(&flagConfig.TrustedSubnet, "trusted_subnet", "t", "", "Trusted subnet (CIDR) for internal statistics")
+	flag.StringVar(&flagConfig.TLSCertFile, "tls_cert_file", "", "TLS certificate file (PEM)")
+	flag.StringVar(&flagConfig.TLSKeyFile, "tls_key_file", "", "TLS private key file (PEM)")
+	flag.BoolVar(&flagConfig.TLSSelfSigned, "tls_self_signed", false, "Serve HTTPS with a self-signed certificate (development)")
+	flag.StringVar(&flagConfig.TLSMinVersion, "tls_min_version", "", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3")
+	flag.StringVar(&flagConfig.HTTPRedirectAddress, "http_redirect_address", "", "Address of the HTTP to HTTPS redirect listener")
+	flag.BoolVar(&flagConfig.PrintConfig, "print-config", false, "Print the config with sources of its values and exit")
 	flag.Parse()
 
+	flag.Visit(func(changedFlag *flag.Flag) {
+		flagConfig.Changed[changedFlag.Name] = true
+	})
+
 	return flagConfig
 }
@@ -1,12 +1,6 @@
 package configs
 
-import (
-	"log"
-	"os"
-
-	"github.com/caarlos0/env/v6"
-	"gopkg.in/yaml.v3"
-)
+import "github.com/tmitry/shorturl/internal/app/logger"
 
 const (
 	dsn = ""
@@ -20,7 +14,7 @@ DatabaseConfig uses the following precedence order. Each item takes precedence o
 - Default.
 */
 type DatabaseConfig struct {
-	DSN string `env:"DATABASE_DSN" yaml:"dsn"`
+	DSN string `env:"DATABASE_DSN" yaml:"dsn" flag:"database_dsn" secret:"true"`
 }
 
 func NewDatabaseConfig(dsn string) *DatabaseConfig {
@@ -33,36 +27,29 @@ func NewDefaultDatabaseConfig() *DatabaseConfig {
 	return NewDatabaseConfig(dsn)
 }
 
-func GetDatabaseConfig(flagConfig *FlagConfig) *DatabaseConfig {
+func GetDatabaseConfig(flagConfig *FlagConfig, log *logger.Logger) (*DatabaseConfig, Sources) {
 	databaseCfg := NewDatabaseConfig("")
 
-	defaultDatabaseCfg := NewDefaultDatabaseConfig()
+	defaultDatabaseLayer := newDefaultLayer(NewDefaultDatabaseConfig())
 
-	envDatabaseCfg := NewDatabaseConfig("")
-	if err := env.Parse(envDatabaseCfg); err != nil {
-		log.Panic(err)
+	envDatabaseLayer, err := newEnvLayer(NewDatabaseConfig(""))
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "database", logger.KeyError, err)
 	}
 
-	flagDatabaseCfg := NewDatabaseConfig(flagConfig.DatabaseDSN)
-
-	yamlDatabaseCfg := NewDatabaseConfig("")
-
-	if flagConfig.DatabaseConfigPath != "" {
-		file, err := os.Open(flagConfig.DatabaseConfigPath)
-		if err != nil {
-			log.Panic(err)
-		}
-
-		decoder := yaml.NewDecoder(file)
-
-		if err = decoder.Decode(yamlDatabaseCfg); err != nil {
-			log.Panic(err)
-		}
+	yamlDatabaseLayer, err := newYAMLLayer(NewDatabaseConfig(""), flagConfig.DatabaseConfigPath)
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "database", logger.KeyError, err)
 	}
 
-	priorityConfigs := []*DatabaseConfig{flagDatabaseCfg, envDatabaseCfg, yamlDatabaseCfg, defaultDatabaseCfg}
+	flagDatabaseLayer := newFlagLayer(NewDatabaseConfig(flagConfig.DatabaseDSN), flagConfig)
 
-	buildConfig(databaseCfg, priorityConfigs)
+	priorityLayers := []*layer[DatabaseConfig]{
+		flagDatabaseLayer,
+		envDatabaseLayer,
+		yamlDatabaseLayer,
+		defaultDatabaseLayer,
+	}
 
-	return databaseCfg
+	return databaseCfg, buildConfig(databaseCfg, priorityLayers)
 }
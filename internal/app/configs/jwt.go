@@ -0,0 +1,78 @@
+package configs
+
+import "github.com/tmitry/shorturl/internal/app/logger"
+
+const (
+	jwtKeyID          = "1"
+	jwtLifetime       = 30 * 24 * 60 * 60
+	jwtRenewBefore    = 7 * 24 * 60 * 60
+	jwtCookieHTTPOnly = true
+	jwtCookieSameSite = "lax"
+)
+
+/*
+JWTConfig describes tokens issued to users. Tokens are signed by ServerConfig.JWTSignatureKey under KeyID.
+VerificationKeys keeps retired keys as "kid:key" pairs, so tokens signed by them are still accepted
+(and re-signed by the current key) after rotation.
+A token is renewed when it expires within RenewBefore seconds.
+Legacy tokens (issued without expiration) are accepted and renewed only before LegacyTokensUntil (RFC 3339),
+an empty value rejects them.
+
+JWTConfig uses the following precedence order. Each item takes precedence over the item below it:
+- Env
+- YAML
+- Default.
+*/
+type JWTConfig struct {
+	KeyID             string   `env:"JWT_KEY_ID" yaml:"key_id"`
+	VerificationKeys  []string `env:"JWT_VERIFICATION_KEYS" yaml:"verification_keys" secret:"true"`
+	Lifetime          int      `env:"JWT_LIFETIME" yaml:"lifetime"`
+	RenewBefore       int      `env:"JWT_RENEW_BEFORE" yaml:"renew_before"`
+	CookieHTTPOnly    bool     `env:"JWT_COOKIE_HTTP_ONLY" yaml:"cookie_http_only"`
+	CookieSecure      bool     `env:"JWT_COOKIE_SECURE" yaml:"cookie_secure"`
+	CookieSameSite    string   `env:"JWT_COOKIE_SAME_SITE" yaml:"cookie_same_site"` // lax, strict or none.
+	LegacyTokensUntil string   `env:"JWT_LEGACY_TOKENS_UNTIL" yaml:"legacy_tokens_until"`
+}
+
+func NewJWTConfig(
+	keyID string,
+	verificationKeys []string,
+	lifetime, renewBefore int,
+	cookieHTTPOnly, cookieSecure bool,
+	cookieSameSite, legacyTokensUntil string,
+) *JWTConfig {
+	return &JWTConfig{
+		KeyID:             keyID,
+		VerificationKeys:  verificationKeys,
+		Lifetime:          lifetime,
+		RenewBefore:       renewBefore,
+		CookieHTTPOnly:    cookieHTTPOnly,
+		CookieSecure:      cookieSecure,
+		CookieSameSite:    cookieSameSite,
+		LegacyTokensUntil: legacyTokensUntil,
+	}
+}
+
+func NewDefaultJWTConfig() *JWTConfig {
+	return NewJWTConfig(jwtKeyID, nil, jwtLifetime, jwtRenewBefore, jwtCookieHTTPOnly, false, jwtCookieSameSite, "")
+}
+
+func GetJWTConfig(flagConfig *FlagConfig, log *logger.Logger) (*JWTConfig, Sources) {
+	jwtCfg := NewJWTConfig("", nil, 0, 0, false, false, "", "")
+
+	defaultJWTLayer := newDefaultLayer(NewDefaultJWTConfig())
+
+	envJWTLayer, err := newEnvLayer(NewJWTConfig("", nil, 0, 0, false, false, "", ""))
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "jwt", logger.KeyError, err)
+	}
+
+	yamlJWTLayer, err := newYAMLLayer(NewJWTConfig("", nil, 0, 0, false, false, "", ""), flagConfig.JWTConfigPath)
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "jwt", logger.KeyError, err)
+	}
+
+	priorityLayers := []*layer[JWTConfig]{envJWTLayer, yamlJWTLayer, defaultJWTLayer}
+
+	return jwtCfg, buildConfig(jwtCfg, priorityLayers)
+}
@@ -0,0 +1,72 @@
+package configs
+
+import (
+	"os"
+
+	"github.com/tmitry/shorturl/internal/app/logger"
+)
+
+const (
+	logLevel  = "info"
+	logFormat = logger.FormatJSON
+)
+
+/*
+LogConfig describes the structured log written to stderr. Level is one of debug, info, warn or error,
+Format is json or logfmt.
+
+LogConfig uses the following precedence order. Each item takes precedence over the item below it:
+- Env
+- YAML
+- Default.
+*/
+type LogConfig struct {
+	Level  string `env:"LOG_LEVEL" yaml:"level"`
+	Format string `env:"LOG_FORMAT" yaml:"format"`
+}
+
+func NewLogConfig(level, format string) *LogConfig {
+	return &LogConfig{
+		Level:  level,
+		Format: format,
+	}
+}
+
+func NewDefaultLogConfig() *LogConfig {
+	return NewLogConfig(logLevel, logFormat)
+}
+
+func GetLogConfig(flagConfig *FlagConfig, log *logger.Logger) (*LogConfig, Sources) {
+	logCfg := NewLogConfig("", "")
+
+	defaultLogLayer := newDefaultLayer(NewDefaultLogConfig())
+
+	envLogLayer, err := newEnvLayer(NewLogConfig("", ""))
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "log", logger.KeyError, err)
+	}
+
+	yamlLogLayer, err := newYAMLLayer(NewLogConfig("", ""), flagConfig.LogConfigPath)
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "log", logger.KeyError, err)
+	}
+
+	priorityLayers := []*layer[LogConfig]{envLogLayer, yamlLogLayer, defaultLogLayer}
+
+	return logCfg, buildConfig(logCfg, priorityLayers)
+}
+
+// NewLogger builds the logger described by the config.
+func NewLogger(logCfg *LogConfig) (*logger.Logger, error) {
+	level, err := logger.ParseLevel(logCfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := logger.ParseFormat(logCfg.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	return logger.New(os.Stderr, level, format), nil
+}
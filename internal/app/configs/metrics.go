@@ -0,0 +1,46 @@
+package configs
+
+import "github.com/tmitry/shorturl/internal/app/logger"
+
+/*
+MetricsConfig describes the /metrics endpoint. TrustedSubnet is the CIDR allowed to scrape the metrics,
+empty value denies access to everybody as TRUSTED_SUBNET does. 0.0.0.0/0 opens the endpoint to every IPv4 client.
+
+MetricsConfig uses the following precedence order. Each item takes precedence over the item below it:
+- Env
+- YAML
+- Default.
+*/
+type MetricsConfig struct {
+	TrustedSubnet string `env:"METRICS_TRUSTED_SUBNET" yaml:"trusted_subnet"`
+}
+
+func NewMetricsConfig(trustedSubnet string) *MetricsConfig {
+	return &MetricsConfig{
+		TrustedSubnet: trustedSubnet,
+	}
+}
+
+func NewDefaultMetricsConfig() *MetricsConfig {
+	return NewMetricsConfig("")
+}
+
+func GetMetricsConfig(flagConfig *FlagConfig, log *logger.Logger) (*MetricsConfig, Sources) {
+	metricsCfg := NewMetricsConfig("")
+
+	defaultMetricsLayer := newDefaultLayer(NewDefaultMetricsConfig())
+
+	envMetricsLayer, err := newEnvLayer(NewMetricsConfig(""))
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "metrics", logger.KeyError, err)
+	}
+
+	yamlMetricsLayer, err := newYAMLLayer(NewMetricsConfig(""), flagConfig.MetricsConfigPath)
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "metrics", logger.KeyError, err)
+	}
+
+	priorityLayers := []*layer[MetricsConfig]{envMetricsLayer, yamlMetricsLayer, defaultMetricsLayer}
+
+	return metricsCfg, buildConfig(metricsCfg, priorityLayers)
+}
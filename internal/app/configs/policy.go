@@ -0,0 +1,115 @@
+package configs
+
+import "github.com/tmitry/shorturl/internal/app/logger"
+
+const (
+	maxURLLength            = 2048
+	blocklistPath           = ""
+	blocklistReloadInterval = 10 // Interval (in seconds) between blocklist file modification checks.
+	blocklistAction         = BlocklistActionBlock
+
+	BlocklistActionBlock = "block" // Blocked links answer 451 Unavailable For Legal Reasons.
+	BlocklistActionWarn  = "warn"  // Blocked links answer with a warning page instead of redirecting.
+)
+
+func defaultAllowedSchemes() []string {
+	return []string{"http", "https"}
+}
+
+func defaultTrackingParams() []string {
+	return []string{"utm_*", "fbclid", "gclid", "dclid", "msclkid", "yclid", "mc_cid", "mc_eid", "_ga"}
+}
+
+/*
+PolicyConfig describes which destinations can be shortened and how they are canonicalized before deduplication.
+AllowedHosts and DeniedHosts accept exact host names and suffix wildcards ("*.example.com").
+BlocklistPath points to an abuse blocklist which is re-read on change or SIGHUP.
+TrackingParams accept exact query parameter names and prefix wildcards ("utm_*").
+
+PolicyConfig uses the following precedence order. Each item takes precedence over the item below it:
+- Env
+- YAML
+- Default.
+*/
+type PolicyConfig struct {
+	AllowedSchemes       []string `env:"POLICY_ALLOWED_SCHEMES" yaml:"allowed_schemes"`
+	AllowedHosts         []string `env:"POLICY_ALLOWED_HOSTS" yaml:"allowed_hosts"`
+	DeniedHosts          []string `env:"POLICY_DENIED_HOSTS" yaml:"denied_hosts"`
+	AllowPrivateNetworks bool     `env:"POLICY_ALLOW_PRIVATE_NETWORKS" yaml:"allow_private_networks"`
+	MaxURLLength         int      `env:"POLICY_MAX_URL_LENGTH" yaml:"max_url_length"`
+
+	BlocklistPath           string `env:"POLICY_BLOCKLIST_PATH" yaml:"blocklist_path"`
+	BlocklistReloadInterval int    `env:"POLICY_BLOCKLIST_RELOAD_INTERVAL" yaml:"blocklist_reload_interval"`
+	BlocklistAction         string `env:"POLICY_BLOCKLIST_ACTION" yaml:"blocklist_action"`
+
+	StripTrackingParams bool     `env:"POLICY_STRIP_TRACKING_PARAMS" yaml:"strip_tracking_params"`
+	TrackingParams      []string `env:"POLICY_TRACKING_PARAMS" yaml:"tracking_params"`
+	StripFragment       bool     `env:"POLICY_STRIP_FRAGMENT" yaml:"strip_fragment"`
+}
+
+func NewPolicyConfig(
+	allowedSchemes, allowedHosts, deniedHosts []string,
+	allowPrivateNetworks bool,
+	maxURLLength int,
+	blocklistPath string,
+	blocklistReloadInterval int,
+	blocklistAction string,
+	stripTrackingParams bool,
+	trackingParams []string,
+	stripFragment bool,
+) *PolicyConfig {
+	return &PolicyConfig{
+		AllowedSchemes:       allowedSchemes,
+		AllowedHosts:         allowedHosts,
+		DeniedHosts:          deniedHosts,
+		AllowPrivateNetworks: allowPrivateNetworks,
+		MaxURLLength:         maxURLLength,
+
+		BlocklistPath:           blocklistPath,
+		BlocklistReloadInterval: blocklistReloadInterval,
+		BlocklistAction:         blocklistAction,
+
+		StripTrackingParams: stripTrackingParams,
+		TrackingParams:      trackingParams,
+		StripFragment:       stripFragment,
+	}
+}
+
+func NewDefaultPolicyConfig() *PolicyConfig {
+	return NewPolicyConfig(
+		defaultAllowedSchemes(),
+		nil,
+		nil,
+		false,
+		maxURLLength,
+		blocklistPath,
+		blocklistReloadInterval,
+		blocklistAction,
+		false,
+		defaultTrackingParams(),
+		false,
+	)
+}
+
+func GetPolicyConfig(flagConfig *FlagConfig, log *logger.Logger) (*PolicyConfig, Sources) {
+	policyCfg := NewPolicyConfig(nil, nil, nil, false, 0, "", 0, "", false, nil, false)
+
+	defaultPolicyLayer := newDefaultLayer(NewDefaultPolicyConfig())
+
+	envPolicyLayer, err := newEnvLayer(NewPolicyConfig(nil, nil, nil, false, 0, "", 0, "", false, nil, false))
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "policy", logger.KeyError, err)
+	}
+
+	yamlPolicyLayer, err := newYAMLLayer(
+		NewPolicyConfig(nil, nil, nil, false, 0, "", 0, "", false, nil, false),
+		flagConfig.PolicyConfigPath,
+	)
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "policy", logger.KeyError, err)
+	}
+
+	priorityLayers := []*layer[PolicyConfig]{envPolicyLayer, yamlPolicyLayer, defaultPolicyLayer}
+
+	return policyCfg, buildConfig(policyCfg, priorityLayers)
+}
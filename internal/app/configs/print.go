@@ -0,0 +1,140 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"text/tabwriter"
+)
+
+const maskedSecret = "******"
+
+/*
+PrintConfig writes every field of the config as section.key, its value in JSON and the source of the value.
+Nested values are keyed like in the YAML files.
+Secrets are masked, only whether they are set is shown. Fields without a known source come from defaults.
+*/
+func PrintConfig(writer io.Writer, cfg *Config) error {
+	sections := []struct {
+		name   string
+		config any
+	}{
+		{name: "server", config: cfg.Server},
+		{name: "app", config: cfg.App},
+		{name: "database", config: cfg.Database},
+		{name: "policy", config: cfg.Policy},
+		{name: "rate_limit", config: cfg.RateLimit},
+		{name: "quota", config: cfg.Quota},
+		{name: "jwt", config: cfg.JWT},
+		{name: "admin", config: cfg.Admin},
+		{name: "webhook", config: cfg.Webhook},
+		{name: "metrics", config: cfg.Metrics},
+		{name: "log", config: cfg.Log},
+	}
+
+	tabWriter := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+
+	if _, err := fmt.Fprintln(tabWriter, "FIELD\tVALUE\tSOURCE"); err != nil {
+		return err
+	}
+
+	for _, section := range sections {
+		configValue := reflect.ValueOf(section.config).Elem()
+
+		for index := 0; index < configValue.NumField(); index++ {
+			field := configValue.Type().Field(index)
+			key := tagName(field, "yaml")
+
+			value, err := formatValue(field, configValue.Field(index))
+			if err != nil {
+				return fmt.Errorf("failed to format %s.%s: %w", section.name, key, err)
+			}
+
+			source, ok := cfg.Sources[section.name][key]
+			if !ok {
+				source = SourceDefault
+			}
+
+			if _, err = fmt.Fprintf(tabWriter, "%s.%s\t%s\t%s\n", section.name, key, value, source); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tabWriter.Flush()
+}
+
+func formatValue(field reflect.StructField, value reflect.Value) (string, error) {
+	var plain any = maskedSecret
+	if !isSecret(field) || value.IsZero() {
+		plain = yamlKeyed(value)
+	}
+
+	encoded, err := json.Marshal(plain)
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded), nil
+}
+
+// yamlKeyed converts nested structs to maps keyed by YAML tags, so they are printed with the keys of the config file.
+func yamlKeyed(value reflect.Value) any {
+	switch value.Kind() {
+	case reflect.Struct:
+		keyed := make(map[string]any, value.NumField())
+
+		for index := 0; index < value.NumField(); index++ {
+			field := value.Type().Field(index)
+
+			key := tagName(field, "yaml")
+			if key == "" || key == "-" {
+				continue
+			}
+
+			if isSecret(field) && !value.Field(index).IsZero() {
+				keyed[key] = maskedSecret
+
+				continue
+			}
+
+			keyed[key] = yamlKeyed(value.Field(index))
+		}
+
+		return keyed
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+
+		keyed := make(map[string]any, value.Len())
+
+		iter := value.MapRange()
+		for iter.Next() {
+			keyed[fmt.Sprint(iter.Key().Interface())] = yamlKeyed(iter.Value())
+		}
+
+		return keyed
+	case reflect.Slice:
+		if value.IsNil() {
+			return nil
+		}
+
+		keyed := make([]any, value.Len())
+
+		for index := 0; index < value.Len(); index++ {
+			keyed[index] = yamlKeyed(value.Index(index))
+		}
+
+		return keyed
+	case reflect.Pointer:
+		if value.IsNil() {
+			return nil
+		}
+
+		return yamlKeyed(value.Elem())
+	default:
+		return value.Interface()
+	}
+}
@@ -0,0 +1,77 @@
+package configs
+
+import "github.com/tmitry/shorturl/internal/app/logger"
+
+const (
+	defaultPlan    = "default"
+	quotaBatchMode = QuotaBatchModeAtomic
+
+	QuotaBatchModeAtomic  = "atomic"  // Batch exceeding the quota is rejected as a whole.
+	QuotaBatchModePartial = "partial" // Batch items within the quota are created, the rest are rejected.
+)
+
+// PlanConfig describes limits of a plan. Zero value of a limit means no limit.
+type PlanConfig struct {
+	MaxLinks     int `yaml:"max_links"`
+	MaxBatchSize int `yaml:"max_batch_size"`
+	MaxTTL       int `yaml:"max_ttl"` // Maximum lifetime (in seconds) of a link.
+}
+
+func defaultPlans() map[string]PlanConfig {
+	return map[string]PlanConfig{
+		defaultPlan: {MaxLinks: 0, MaxBatchSize: 0, MaxTTL: 0},
+	}
+}
+
+/*
+QuotaConfig defines plans and assigns them to users. Users without assignment get DefaultPlan.
+Plans and UserPlans (user ID to plan name) can be set only in YAML.
+
+QuotaConfig uses the following precedence order. Each item takes precedence over the item below it:
+- Env
+- YAML
+- Default.
+*/
+type QuotaConfig struct {
+	DefaultPlan string                `env:"QUOTA_DEFAULT_PLAN" yaml:"default_plan"`
+	BatchMode   string                `env:"QUOTA_BATCH_MODE" yaml:"batch_mode"`
+	Plans       map[string]PlanConfig `yaml:"plans"`
+	UserPlans   map[string]string     `yaml:"user_plans"`
+}
+
+func NewQuotaConfig(
+	defaultPlan, batchMode string,
+	plans map[string]PlanConfig,
+	userPlans map[string]string,
+) *QuotaConfig {
+	return &QuotaConfig{
+		DefaultPlan: defaultPlan,
+		BatchMode:   batchMode,
+		Plans:       plans,
+		UserPlans:   userPlans,
+	}
+}
+
+func NewDefaultQuotaConfig() *QuotaConfig {
+	return NewQuotaConfig(defaultPlan, quotaBatchMode, defaultPlans(), nil)
+}
+
+func GetQuotaConfig(flagConfig *FlagConfig, log *logger.Logger) (*QuotaConfig, Sources) {
+	quotaCfg := NewQuotaConfig("", "", nil, nil)
+
+	defaultQuotaLayer := newDefaultLayer(NewDefaultQuotaConfig())
+
+	envQuotaLayer, err := newEnvLayer(NewQuotaConfig("", "", nil, nil))
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "quota", logger.KeyError, err)
+	}
+
+	yamlQuotaLayer, err := newYAMLLayer(NewQuotaConfig("", "", nil, nil), flagConfig.QuotaConfigPath)
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "quota", logger.KeyError, err)
+	}
+
+	priorityLayers := []*layer[QuotaConfig]{envQuotaLayer, yamlQuotaLayer, defaultQuotaLayer}
+
+	return quotaCfg, buildConfig(quotaCfg, priorityLayers)
+}
@@ -0,0 +1,69 @@
+package configs
+
+import "github.com/tmitry/shorturl/internal/app/logger"
+
+const (
+	rateLimitPeriod = 60 // Period (in seconds) during which a bucket is fully refilled.
+	rateLimitLogin  = 10 // Login and register attempts, limited by default against password guessing.
+)
+
+/*
+RateLimitConfig holds token bucket limits per route class.
+Each limit is the number of requests allowed per period for one user and, separately, for one client IP.
+Zero limit disables rate limiting for the route class.
+Login and register attempts are limited per username instead of per user.
+Shared state in the database is used when Shared is set and the database is configured.
+
+RateLimitConfig uses the following precedence order. Each item takes precedence over the item below it:
+- Env
+- YAML
+- Default.
+*/
+type RateLimitConfig struct {
+	Period        int  `env:"RATE_LIMIT_PERIOD" yaml:"period"`
+	CreateLimit   int  `env:"RATE_LIMIT_CREATE" yaml:"create_limit"`
+	BatchLimit    int  `env:"RATE_LIMIT_BATCH" yaml:"batch_limit"`
+	DeleteLimit   int  `env:"RATE_LIMIT_DELETE" yaml:"delete_limit"`
+	RedirectLimit int  `env:"RATE_LIMIT_REDIRECT" yaml:"redirect_limit"`
+	LoginLimit    int  `env:"RATE_LIMIT_LOGIN" yaml:"login_limit"`
+	Shared        bool `env:"RATE_LIMIT_SHARED" yaml:"shared"`
+}
+
+func NewRateLimitConfig(
+	period, createLimit, batchLimit, deleteLimit, redirectLimit, loginLimit int,
+	shared bool,
+) *RateLimitConfig {
+	return &RateLimitConfig{
+		Period:        period,
+		CreateLimit:   createLimit,
+		BatchLimit:    batchLimit,
+		DeleteLimit:   deleteLimit,
+		RedirectLimit: redirectLimit,
+		LoginLimit:    loginLimit,
+		Shared:        shared,
+	}
+}
+
+func NewDefaultRateLimitConfig() *RateLimitConfig {
+	return NewRateLimitConfig(rateLimitPeriod, 0, 0, 0, 0, rateLimitLogin, false)
+}
+
+func GetRateLimitConfig(flagConfig *FlagConfig, log *logger.Logger) (*RateLimitConfig, Sources) {
+	rateLimitCfg := NewRateLimitConfig(0, 0, 0, 0, 0, 0, false)
+
+	defaultRateLimitLayer := newDefaultLayer(NewDefaultRateLimitConfig())
+
+	envRateLimitLayer, err := newEnvLayer(NewRateLimitConfig(0, 0, 0, 0, 0, 0, false))
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "rate_limit", logger.KeyError, err)
+	}
+
+	yamlRateLimitLayer, err := newYAMLLayer(NewRateLimitConfig(0, 0, 0, 0, 0, 0, false), flagConfig.RateLimitConfigPath)
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "rate_limit", logger.KeyError, err)
+	}
+
+	priorityLayers := []*layer[RateLimitConfig]{envRateLimitLayer, yamlRateLimitLayer, defaultRateLimitLayer}
+
+	return rateLimitCfg, buildConfig(rateLimitCfg, priorityLayers)
+}
@@ -0,0 +1,40 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// secretFileSuffix names the variable holding a path to the file of a secret, e.g. JWT_SIGNATURE_KEY_FILE.
+const secretFileSuffix = "_FILE"
+
+/*
+readSecretFile sets the secret from the file named by the <envName>_FILE variable, so secrets mounted as files
+(Docker and Kubernetes secrets) stay out of the environment. The secret must not be set by both variables.
+It reports whether the secret is read from the file.
+*/
+func readSecretFile(secret *string, envName string) (bool, error) {
+	path := os.Getenv(envName + secretFileSuffix)
+	if path == "" {
+		return false, nil
+	}
+
+	if *secret != "" {
+		return false, fmt.Errorf("%s and %s%s must not be set together", envName, envName, secretFileSuffix)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s%s: %w", envName, secretFileSuffix, err)
+	}
+
+	value := strings.TrimRight(string(content), "\r\n")
+	if value == "" {
+		return false, fmt.Errorf("%s%s points to an empty file", envName, secretFileSuffix)
+	}
+
+	*secret = value
+
+	return true, nil
+}
@@ -1,21 +1,32 @@
 package configs
 
 import (
-	"log"
-	"os"
-
-	"github.com/caarlos0/env/v6"
-	"gopkg.in/yaml.v3"
+	"crypto/tls"
+	"github.com/tmitry/shorturl/internal/app/logger"
 )
 
 const (
-	address           = "localhost:8080"
-	baseURL           = "http://localhost:8080"
-	readHeaderTimeout = 2
-	compressionLevel  = 5
-	jwtSignatureKey   = "sRhs-tWB!Kq7RLCHYek6QFks"
+	address            = "localhost:8080"
+	baseURL            = "http://localhost:8080"
+	readHeaderTimeout  = 2
+	shutdownTimeout    = 10
+	shutdownDrainDelay = 0
+	readinessTimeout   = 2
+	tlsMinVersion      = "1.2"
+	tlsReloadInterval  = 10
+	compressionLevel   = 5
+	maxRequestBodySize = 1 << 20
+	jwtSignatureKey    = "sRhs-tWB!Kq7RLCHYek6QFks"
 )
 
+// TLSVersions maps versions accepted by the config to versions of the crypto/tls package.
+var TLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
 /*
 ServerConfig uses the following precedence order. Each item takes precedence over the item below it:
 - Flag
@@ -24,57 +35,148 @@ ServerConfig uses the following precedence order. Each item takes precedence ove
 - Default.
 */
 type ServerConfig struct {
-	Address           string `env:"SERVER_ADDRESS" yaml:"address"`
-	BaseURL           string `env:"BASE_URL" yaml:"base_url"`
+	Address string `env:"SERVER_ADDRESS" yaml:"address" flag:"server_address"`
+
+	// BaseURL prefixes short URLs. Empty value makes them relative to the host of the service, e.g. /abc.
+	BaseURL           string `env:"BASE_URL" yaml:"base_url" flag:"base_url"`
 	ReadHeaderTimeout int    `env:"SERVER_READ_HEADER_TIMEOUT" yaml:"read_header_timeout"`
 	CompressionLevel  int    `env:"SERVER_COMPRESSION_LEVEL" yaml:"compression_level"`
-	JWTSignatureKey   string `env:"JWT_SIGNATURE_KEY" yaml:"jwt_signature_key"`
+	JWTSignatureKey   string `env:"JWT_SIGNATURE_KEY" yaml:"jwt_signature_key" flag:"jwt_signature_key" secret:"true"`
+
+	// ShutdownTimeout is the number of seconds in-flight requests and background work get to finish on shutdown.
+	ShutdownTimeout int `env:"SERVER_SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout"`
+
+	/*
+		ShutdownDrainDelay is the number of seconds the server keeps serving with the failing readiness probe
+		before it stops, so load balancers notice it. Behind a load balancer it should cover a few probe periods.
+	*/
+	ShutdownDrainDelay int `env:"SERVER_SHUTDOWN_DRAIN_DELAY" yaml:"shutdown_drain_delay"`
+
+	// ReadinessTimeout is the number of seconds every component check of the readiness probe gets to answer.
+	ReadinessTimeout int `env:"SERVER_READINESS_TIMEOUT" yaml:"readiness_timeout"`
+
+	// MaxRequestBodySize limits a JSON request body in bytes, a compressed body is limited before and after decoding.
+	MaxRequestBodySize int `env:"SERVER_MAX_REQUEST_BODY_SIZE" yaml:"max_request_body_size"`
+
+	// TrustedProxies lists CIDRs of proxies whose X-Forwarded-For and X-Real-IP headers are trusted.
+	TrustedProxies []string `env:"SERVER_TRUSTED_PROXIES" yaml:"trusted_proxies"`
+
+	// TrustedSubnet is the CIDR allowed to read internal statistics. Empty value denies access to everybody.
+	TrustedSubnet string `env:"TRUSTED_SUBNET" yaml:"trusted_subnet" flag:"trusted_subnet"`
+
+	/*
+		TLSCertFile and TLSKeyFile point to PEM files the server serves HTTPS with. The files are re-read
+		when they change, checked every TLSReloadInterval seconds. TLSSelfSigned serves a certificate generated
+		on start instead, it is meant for development only. TLSMinVersion is one of 1.0, 1.1, 1.2 and 1.3.
+	*/
+	TLSCertFile       string `env:"SERVER_TLS_CERT_FILE" yaml:"tls_cert_file" flag:"tls_cert_file"`
+	TLSKeyFile        string `env:"SERVER_TLS_KEY_FILE" yaml:"tls_key_file" flag:"tls_key_file"`
+	TLSSelfSigned     bool   `env:"SERVER_TLS_SELF_SIGNED" yaml:"tls_self_signed" flag:"tls_self_signed"`
+	TLSMinVersion     string `env:"SERVER_TLS_MIN_VERSION" yaml:"tls_min_version" flag:"tls_min_version"`
+	TLSReloadInterval int    `env:"SERVER_TLS_RELOAD_INTERVAL" yaml:"tls_reload_interval"`
+
+	// HTTPRedirectAddress is the address of the plain HTTP listener which redirects to HTTPS. Empty value disables it.
+	HTTPRedirectAddress string `env:"SERVER_HTTP_REDIRECT_ADDRESS" yaml:"http_redirect_address" flag:"http_redirect_address"`
 }
 
-func NewServerConfig(address, baseURL, jwtSignatureKey string, readHeaderTimeout, compressionLevel int) *ServerConfig {
+func NewServerConfig(
+	address, baseURL, jwtSignatureKey string,
+	readHeaderTimeout, shutdownTimeout, shutdownDrainDelay, readinessTimeout, compressionLevel int,
+	maxRequestBodySize int,
+	trustedProxies []string,
+	trustedSubnet string,
+	tlsCertFile, tlsKeyFile string,
+	tlsSelfSigned bool,
+	tlsMinVersion string,
+	tlsReloadInterval int,
+	httpRedirectAddress string,
+) *ServerConfig {
 	return &ServerConfig{
-		Address:           address,
-		BaseURL:           baseURL,
-		ReadHeaderTimeout: readHeaderTimeout,
-		CompressionLevel:  compressionLevel,
-		JWTSignatureKey:   jwtSignatureKey,
+		Address:            address,
+		BaseURL:            baseURL,
+		ReadHeaderTimeout:  readHeaderTimeout,
+		ShutdownTimeout:    shutdownTimeout,
+		ShutdownDrainDelay: shutdownDrainDelay,
+		ReadinessTimeout:   readinessTimeout,
+		CompressionLevel:   compressionLevel,
+		JWTSignatureKey:    jwtSignatureKey,
+		MaxRequestBodySize: maxRequestBodySize,
+		TrustedProxies:     trustedProxies,
+		TrustedSubnet:      trustedSubnet,
+
+		TLSCertFile:       tlsCertFile,
+		TLSKeyFile:        tlsKeyFile,
+		TLSSelfSigned:     tlsSelfSigned,
+		TLSMinVersion:     tlsMinVersion,
+		TLSReloadInterval: tlsReloadInterval,
+
+		HTTPRedirectAddress: httpRedirectAddress,
 	}
 }
 
 func NewDefaultServerConfig() *ServerConfig {
-	return NewServerConfig(address, baseURL, jwtSignatureKey, readHeaderTimeout, compressionLevel)
+	return NewServerConfig(
+		address,
+		baseURL,
+		jwtSignatureKey,
+		readHeaderTimeout,
+		shutdownTimeout,
+		shutdownDrainDelay,
+		readinessTimeout,
+		compressionLevel,
+		maxRequestBodySize,
+		nil,
+		"",
+		"",
+		"",
+		false,
+		tlsMinVersion,
+		tlsReloadInterval,
+		"",
+	)
 }
 
-func GetServerConfig(flagConfig *FlagConfig) *ServerConfig {
-	serverCfg := NewServerConfig("", "", "", 0, 0)
+func GetServerConfig(flagConfig *FlagConfig, log *logger.Logger) (*ServerConfig, Sources) {
+	serverCfg := NewServerConfig("", "", "", 0, 0, 0, 0, 0, 0, nil, "", "", "", false, "", 0, "")
 
-	defaultServerCfg := NewDefaultServerConfig()
+	defaultServerLayer := newDefaultLayer(NewDefaultServerConfig())
 
-	envServerCfg := NewServerConfig("", "", "", 0, 0)
-	if err := env.Parse(envServerCfg); err != nil {
-		log.Panic(err)
+	envServerLayer, err := newEnvLayer(NewServerConfig("", "", "", 0, 0, 0, 0, 0, 0, nil, "", "", "", false, "", 0, ""))
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "server", logger.KeyError, err)
 	}
 
-	yamlServerCfg := NewServerConfig("", "", "", 0, 0)
-
-	if flagConfig.ServerConfigPath != "" {
-		file, err := os.Open(flagConfig.ServerConfigPath)
-		if err != nil {
-			log.Panic(err)
-		}
-
-		decoder := yaml.NewDecoder(file)
-
-		if err = decoder.Decode(yamlServerCfg); err != nil {
-			log.Panic(err)
-		}
+	yamlServerLayer, err := newYAMLLayer(
+		NewServerConfig("", "", "", 0, 0, 0, 0, 0, 0, nil, "", "", "", false, "", 0, ""),
+		flagConfig.ServerConfigPath,
+	)
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "server", logger.KeyError, err)
 	}
 
-	flagServerCfg := NewServerConfig(flagConfig.Address, flagConfig.BaseURL, flagConfig.JWTSignatureKey, 0, 0)
-
-	priorityConfigs := []*ServerConfig{flagServerCfg, envServerCfg, yamlServerCfg, defaultServerCfg}
-
-	buildConfig(serverCfg, priorityConfigs)
-
-	return serverCfg
+	flagServerCfg := NewServerConfig(
+		flagConfig.Address,
+		flagConfig.BaseURL,
+		flagConfig.JWTSignatureKey,
+		0,
+		0,
+		0,
+		0,
+		0,
+		0,
+		nil,
+		flagConfig.TrustedSubnet,
+		flagConfig.TLSCertFile,
+		flagConfig.TLSKeyFile,
+		flagConfig.TLSSelfSigned,
+		flagConfig.TLSMinVersion,
+		0,
+		flagConfig.HTTPRedirectAddress,
+	)
+
+	flagServerLayer := newFlagLayer(flagServerCfg, flagConfig)
+
+	priorityLayers := []*layer[ServerConfig]{flagServerLayer, envServerLayer, yamlServerLayer, defaultServerLayer}
+
+	return serverCfg, buildConfig(serverCfg, priorityLayers)
 }
@@ -0,0 +1,186 @@
+package configs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/caarlos0/env/v6"
+	"gopkg.in/yaml.v3"
+)
+
+// Source names where the value of a field comes from.
+type Source string
+
+const (
+	SourceFlag    Source = "flag"
+	SourceEnv     Source = "env"
+	SourceYAML    Source = "yaml"
+	SourceDefault Source = "default"
+)
+
+// Sources maps fields of a section, named by their YAML keys, to the sources their values come from.
+type Sources map[string]Source
+
+/*
+layer is a section loaded from one source with the fields the source explicitly sets, named by Go field names.
+A field is set by a changed flag, a present env variable (even an empty one) or a present YAML key,
+so zero and false values can be set explicitly.
+*/
+type layer[C ConfigInterface] struct {
+	source Source
+	config *C
+	fields map[string]bool
+}
+
+// newFlagLayer takes the section filled from flags, fields are bound to flags by the flag tag.
+func newFlagLayer[C ConfigInterface](config *C, flagConfig *FlagConfig) *layer[C] {
+	fields := make(map[string]bool)
+
+	forEachField(config, func(field reflect.StructField, _ reflect.Value) {
+		if name := tagName(field, "flag"); name != "" && flagConfig.Changed[name] {
+			fields[field.Name] = true
+		}
+	})
+
+	return &layer[C]{source: SourceFlag, config: config, fields: fields}
+}
+
+// newEnvLayer parses env variables into the section, secrets are read from files named by <NAME>_FILE variables.
+func newEnvLayer[C ConfigInterface](config *C) (*layer[C], error) {
+	if err := env.Parse(config); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]bool)
+
+	var err error
+
+	forEachField(config, func(field reflect.StructField, value reflect.Value) {
+		name := tagName(field, "env")
+		if name == "" || err != nil {
+			return
+		}
+
+		if _, ok := os.LookupEnv(name); ok {
+			fields[field.Name] = true
+		}
+
+		if !isSecret(field) || value.Kind() != reflect.String {
+			return
+		}
+
+		secret := value.String()
+
+		var isRead bool
+
+		if isRead, err = readSecretFile(&secret, name); isRead {
+			value.SetString(secret)
+			fields[field.Name] = true
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &layer[C]{source: SourceEnv, config: config, fields: fields}, nil
+}
+
+// newYAMLLayer decodes the YAML file into the section, no field is set when the path is empty.
+func newYAMLLayer[C ConfigInterface](config *C, path string) (*layer[C], error) {
+	fields := make(map[string]bool)
+
+	if path == "" {
+		return &layer[C]{source: SourceYAML, config: config, fields: fields}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var document yaml.Node
+
+	if err = yaml.NewDecoder(file).Decode(&document); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if len(document.Content) == 0 {
+		return &layer[C]{source: SourceYAML, config: config, fields: fields}, nil
+	}
+
+	if err = document.Decode(config); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool)
+
+	if mapping := document.Content[0]; mapping.Kind == yaml.MappingNode {
+		for index := 0; index < len(mapping.Content); index += 2 {
+			keys[mapping.Content[index].Value] = true
+		}
+	}
+
+	forEachField(config, func(field reflect.StructField, _ reflect.Value) {
+		if keys[tagName(field, "yaml")] {
+			fields[field.Name] = true
+		}
+	})
+
+	return &layer[C]{source: SourceYAML, config: config, fields: fields}, nil
+}
+
+// newDefaultLayer sets every field of the section, so it must be the last layer.
+func newDefaultLayer[C ConfigInterface](config *C) *layer[C] {
+	fields := make(map[string]bool)
+
+	forEachField(config, func(field reflect.StructField, _ reflect.Value) {
+		fields[field.Name] = true
+	})
+
+	return &layer[C]{source: SourceDefault, config: config, fields: fields}
+}
+
+// buildConfig sets every field of the config from the first layer setting it, layers go in the precedence order.
+func buildConfig[C ConfigInterface](config *C, layers []*layer[C]) Sources {
+	sources := make(Sources)
+
+	forEachField(config, func(field reflect.StructField, value reflect.Value) {
+		for _, layer := range layers {
+			if !layer.fields[field.Name] {
+				continue
+			}
+
+			value.Set(reflect.ValueOf(layer.config).Elem().FieldByName(field.Name))
+			sources[tagName(field, "yaml")] = layer.source
+
+			return
+		}
+	})
+
+	return sources
+}
+
+func forEachField[C ConfigInterface](config *C, callback func(field reflect.StructField, value reflect.Value)) {
+	configValue := reflect.ValueOf(config).Elem()
+
+	for index := 0; index < configValue.NumField(); index++ {
+		callback(configValue.Type().Field(index), configValue.Field(index))
+	}
+}
+
+// tagName returns the name of the tag without options, e.g. "url" of `yaml:"url,omitempty"`.
+func tagName(field reflect.StructField, key string) string {
+	name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+
+	return name
+}
+
+// isSecret reports fields marked by `secret:"true"`, their values are masked when the config is printed.
+func isSecret(field reflect.StructField) bool {
+	return field.Tag.Get("secret") == "true"
+}
@@ -0,0 +1,229 @@
+package configs
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tmitry/shorturl/internal/app/logger"
+)
+
+// ValidationError lists every problem of the config, so all of them can be fixed at once.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config: " + strings.Join(e.Problems, "; ")
+}
+
+// validator collects problems of fields named by their env variables.
+type validator struct {
+	problems []string
+}
+
+func (v *validator) check(isValid bool, field, format string, args ...any) {
+	if !isValid {
+		v.problems = append(v.problems, field+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *validator) positive(value int, field string) {
+	v.check(value > 0, field, "must be positive, got %d", value)
+}
+
+func (v *validator) notNegative(value int, field string) {
+	v.check(value >= 0, field, "must not be negative, got %d", value)
+}
+
+func (v *validator) oneOf(value, field string, allowed ...string) {
+	for _, allowedValue := range allowed {
+		if value == allowedValue {
+			return
+		}
+	}
+
+	v.check(false, field, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+}
+
+func (v *validator) cidr(value, field string) {
+	if value == "" {
+		return
+	}
+
+	_, _, err := net.ParseCIDR(value)
+	v.check(err == nil, field, "must be a CIDR, got %q", value)
+}
+
+/*
+Validate checks the loaded config before the application starts. Production environment refuses the secrets
+shipped in the source, they are public and let anybody forge tokens and guess short URLs.
+*/
+func Validate(cfg *Config) error {
+	valid := &validator{problems: nil}
+
+	validateServer(valid, cfg.Server)
+	validateApp(valid, cfg.App)
+	validateJWT(valid, cfg.JWT)
+	validatePolicy(valid, cfg.Policy)
+	validateRateLimit(valid, cfg.RateLimit)
+	validateQuota(valid, cfg.Quota)
+	validateWebhook(valid, cfg.Webhook)
+	validateLog(valid, cfg.Log)
+
+	valid.cidr(cfg.Metrics.TrustedSubnet, "METRICS_TRUSTED_SUBNET")
+
+	if cfg.App.Environment == EnvironmentProduction {
+		valid.check(
+			cfg.Server.JWTSignatureKey != jwtSignatureKey,
+			"JWT_SIGNATURE_KEY", "the default key is not allowed in production",
+		)
+		valid.check(cfg.App.HashSalt != hashSalt, "APP_HASH_SALT", "the default salt is not allowed in production")
+	}
+
+	if len(valid.problems) > 0 {
+		return &ValidationError{Problems: valid.problems}
+	}
+
+	return nil
+}
+
+func validateServer(valid *validator, serverCfg *ServerConfig) {
+	_, _, err := net.SplitHostPort(serverCfg.Address)
+	valid.check(err == nil, "SERVER_ADDRESS", "must be host:port, got %q", serverCfg.Address)
+
+	if serverCfg.BaseURL != "" {
+		baseURL, err := url.Parse(serverCfg.BaseURL)
+		valid.check(
+			err == nil && (baseURL.Scheme == "http" || baseURL.Scheme == "https") && baseURL.Host != "",
+			"BASE_URL", "must be empty or an absolute http or https URL, got %q", serverCfg.BaseURL,
+		)
+	}
+
+	valid.positive(serverCfg.ReadHeaderTimeout, "SERVER_READ_HEADER_TIMEOUT")
+	valid.positive(serverCfg.ShutdownTimeout, "SERVER_SHUTDOWN_TIMEOUT")
+	valid.notNegative(serverCfg.ShutdownDrainDelay, "SERVER_SHUTDOWN_DRAIN_DELAY")
+	valid.positive(serverCfg.ReadinessTimeout, "SERVER_READINESS_TIMEOUT")
+	valid.positive(serverCfg.MaxRequestBodySize, "SERVER_MAX_REQUEST_BODY_SIZE")
+	valid.check(
+		serverCfg.CompressionLevel >= -2 && serverCfg.CompressionLevel <= 9,
+		"SERVER_COMPRESSION_LEVEL", "must be between -2 and 9, got %d", serverCfg.CompressionLevel,
+	)
+	valid.check(serverCfg.JWTSignatureKey != "", "JWT_SIGNATURE_KEY", "must not be empty")
+
+	for _, proxy := range serverCfg.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			valid.cidr(proxy, "SERVER_TRUSTED_PROXIES")
+		}
+	}
+
+	valid.cidr(serverCfg.TrustedSubnet, "TRUSTED_SUBNET")
+
+	_, ok := TLSVersions[serverCfg.TLSMinVersion]
+	valid.check(ok, "SERVER_TLS_MIN_VERSION", "must be one of 1.0, 1.1, 1.2 or 1.3, got %q", serverCfg.TLSMinVersion)
+	valid.positive(serverCfg.TLSReloadInterval, "SERVER_TLS_RELOAD_INTERVAL")
+
+	isFileCertificate := serverCfg.TLSCertFile != "" || serverCfg.TLSKeyFile != ""
+	valid.check(
+		!isFileCertificate || serverCfg.TLSCertFile != "" && serverCfg.TLSKeyFile != "",
+		"SERVER_TLS_CERT_FILE", "must be set together with SERVER_TLS_KEY_FILE",
+	)
+	valid.check(
+		!isFileCertificate || !serverCfg.TLSSelfSigned,
+		"SERVER_TLS_SELF_SIGNED", "must not be set together with SERVER_TLS_CERT_FILE",
+	)
+	valid.check(
+		serverCfg.HTTPRedirectAddress == "" || isFileCertificate || serverCfg.TLSSelfSigned,
+		"SERVER_HTTP_REDIRECT_ADDRESS", "requires TLS",
+	)
+}
+
+func validateApp(valid *validator, appCfg *AppConfig) {
+	valid.oneOf(appCfg.Environment, "APP_ENV", EnvironmentDevelopment, EnvironmentProduction)
+	valid.check(appCfg.HashSalt != "", "APP_HASH_SALT", "must not be empty")
+	valid.notNegative(appCfg.HashMinLength, "APP_HASH_MIN_LENGTH")
+	valid.positive(appCfg.DeletionBufferMaxSize, "APP_DELETION_BUFFER_MAX_SIZE")
+	valid.positive(appCfg.DeletionBufferClearTimeout, "APP_DELETION_CLEAR_TIMEOUT")
+	valid.positive(appCfg.DeletionWorkers, "APP_DELETION_WORKERS")
+	valid.positive(appCfg.DeletionQueueSize, "APP_DELETION_QUEUE_SIZE")
+	valid.positive(appCfg.DeletionRetryMaxAttempts, "APP_DELETION_RETRY_MAX_ATTEMPTS")
+	valid.notNegative(appCfg.DeletionRetryBackoff, "APP_DELETION_RETRY_BACKOFF")
+	valid.check(
+		appCfg.DeletionRetryMaxBackoff >= appCfg.DeletionRetryBackoff,
+		"APP_DELETION_RETRY_MAX_BACKOFF", "must not be less than APP_DELETION_RETRY_BACKOFF, got %d",
+		appCfg.DeletionRetryMaxBackoff,
+	)
+}
+
+func validateJWT(valid *validator, jwtCfg *JWTConfig) {
+	valid.check(jwtCfg.KeyID != "", "JWT_KEY_ID", "must not be empty")
+	valid.positive(jwtCfg.Lifetime, "JWT_LIFETIME")
+	valid.check(
+		jwtCfg.RenewBefore >= 0 && jwtCfg.RenewBefore < jwtCfg.Lifetime,
+		"JWT_RENEW_BEFORE", "must be between 0 and JWT_LIFETIME, got %d", jwtCfg.RenewBefore,
+	)
+	valid.oneOf(strings.ToLower(jwtCfg.CookieSameSite), "JWT_COOKIE_SAME_SITE", "", "lax", "strict", "none")
+	valid.check(
+		!strings.EqualFold(jwtCfg.CookieSameSite, "none") || jwtCfg.CookieSecure,
+		"JWT_COOKIE_SAME_SITE", "none requires JWT_COOKIE_SECURE",
+	)
+
+	if jwtCfg.LegacyTokensUntil != "" {
+		_, err := time.Parse(time.RFC3339, jwtCfg.LegacyTokensUntil)
+		valid.check(
+			err == nil,
+			"JWT_LEGACY_TOKENS_UNTIL", "must be an RFC 3339 time, got %q", jwtCfg.LegacyTokensUntil,
+		)
+	}
+}
+
+func validatePolicy(valid *validator, policyCfg *PolicyConfig) {
+	valid.positive(policyCfg.MaxURLLength, "POLICY_MAX_URL_LENGTH")
+	valid.positive(policyCfg.BlocklistReloadInterval, "POLICY_BLOCKLIST_RELOAD_INTERVAL")
+	valid.oneOf(policyCfg.BlocklistAction, "POLICY_BLOCKLIST_ACTION", BlocklistActionBlock, BlocklistActionWarn)
+}
+
+func validateRateLimit(valid *validator, rateLimitCfg *RateLimitConfig) {
+	valid.positive(rateLimitCfg.Period, "RATE_LIMIT_PERIOD")
+	valid.notNegative(rateLimitCfg.CreateLimit, "RATE_LIMIT_CREATE")
+	valid.notNegative(rateLimitCfg.BatchLimit, "RATE_LIMIT_BATCH")
+	valid.notNegative(rateLimitCfg.DeleteLimit, "RATE_LIMIT_DELETE")
+	valid.notNegative(rateLimitCfg.RedirectLimit, "RATE_LIMIT_REDIRECT")
+	valid.notNegative(rateLimitCfg.LoginLimit, "RATE_LIMIT_LOGIN")
+}
+
+func validateQuota(valid *validator, quotaCfg *QuotaConfig) {
+	valid.oneOf(quotaCfg.BatchMode, "QUOTA_BATCH_MODE", QuotaBatchModeAtomic, QuotaBatchModePartial)
+
+	_, ok := quotaCfg.Plans[quotaCfg.DefaultPlan]
+	valid.check(ok, "QUOTA_DEFAULT_PLAN", "unknown plan %q", quotaCfg.DefaultPlan)
+
+	for name, plan := range quotaCfg.Plans {
+		valid.check(
+			plan.MaxLinks >= 0 && plan.MaxBatchSize >= 0 && plan.MaxTTL >= 0,
+			"plans", "limits of plan %q must not be negative", name,
+		)
+	}
+}
+
+func validateWebhook(valid *validator, webhookCfg *WebhookConfig) {
+	valid.positive(webhookCfg.MaxAttempts, "WEBHOOK_MAX_ATTEMPTS")
+	valid.notNegative(webhookCfg.RetryBackoff, "WEBHOOK_RETRY_BACKOFF")
+	valid.check(
+		webhookCfg.MaxRetryBackoff >= webhookCfg.RetryBackoff,
+		"WEBHOOK_MAX_RETRY_BACKOFF", "must not be less than WEBHOOK_RETRY_BACKOFF, got %d", webhookCfg.MaxRetryBackoff,
+	)
+	valid.positive(webhookCfg.Timeout, "WEBHOOK_TIMEOUT")
+	valid.positive(webhookCfg.RelayInterval, "WEBHOOK_RELAY_INTERVAL")
+	valid.positive(webhookCfg.RelayBatchSize, "WEBHOOK_RELAY_BATCH_SIZE")
+}
+
+func validateLog(valid *validator, logCfg *LogConfig) {
+	_, err := logger.ParseLevel(logCfg.Level)
+	valid.check(err == nil, "LOG_LEVEL", "must be one of debug, info, warn or error, got %q", logCfg.Level)
+
+	_, err = logger.ParseFormat(logCfg.Format)
+	valid.check(err == nil, "LOG_FORMAT", "must be json or logfmt, got %q", logCfg.Format)
+}
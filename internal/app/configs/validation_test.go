@@ -0,0 +1,161 @@
+package configs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/logger"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	// test case 2
+	cfg2 := configs.NewDefaultConfig()
+	cfg2.Server.BaseURL = "localhost:8080"
+	cfg2.App.DeletionBufferMaxSize = -1
+	cfg2.App.DeletionQueueSize = -10
+
+	// test case 3
+	cfg3 := configs.NewDefaultConfig()
+	cfg3.App.Environment = configs.EnvironmentProduction
+
+	// test case 4
+	cfg4 := configs.NewDefaultConfig()
+	cfg4.App.Environment = configs.EnvironmentProduction
+	cfg4.App.HashSalt = "production salt"
+	cfg4.Server.JWTSignatureKey = "production key"
+
+	// test case 5
+	cfg5 := configs.NewDefaultConfig()
+	cfg5.Server.TLSKeyFile = "server.key"
+	cfg5.Server.TLSMinVersion = "1.4"
+	cfg5.JWT.RenewBefore = cfg5.JWT.Lifetime
+	cfg5.JWT.LegacyTokensUntil = "2026-01-01"
+	cfg5.Log.Format = "xml"
+
+	// test case 6
+	cfg6 := configs.NewDefaultConfig()
+	cfg6.Server.BaseURL = ""
+
+	tests := []struct {
+		name     string
+		cfg      *configs.Config
+		problems []string
+	}{
+		{
+			name:     "test case 1: default config",
+			cfg:      configs.NewDefaultConfig(),
+			problems: nil,
+		},
+		{
+			name: "test case 2: incorrect base URL and negative buffer sizes",
+			cfg:  cfg2,
+			problems: []string{
+				`BASE_URL: must be empty or an absolute http or https URL, got "localhost:8080"`,
+				"APP_DELETION_BUFFER_MAX_SIZE: must be positive, got -1",
+				"APP_DELETION_QUEUE_SIZE: must be positive, got -10",
+			},
+		},
+		{
+			name: "test case 3: default secrets in production",
+			cfg:  cfg3,
+			problems: []string{
+				"JWT_SIGNATURE_KEY: the default key is not allowed in production",
+				"APP_HASH_SALT: the default salt is not allowed in production",
+			},
+		},
+		{
+			name:     "test case 4: own secrets in production",
+			cfg:      cfg4,
+			problems: nil,
+		},
+		{
+			name: "test case 5: incorrect TLS, JWT and log settings",
+			cfg:  cfg5,
+			problems: []string{
+				`SERVER_TLS_MIN_VERSION: must be one of 1.0, 1.1, 1.2 or 1.3, got "1.4"`,
+				"SERVER_TLS_CERT_FILE: must be set together with SERVER_TLS_KEY_FILE",
+				"JWT_RENEW_BEFORE: must be between 0 and JWT_LIFETIME, got 2592000",
+				`JWT_LEGACY_TOKENS_UNTIL: must be an RFC 3339 time, got "2026-01-01"`,
+				`LOG_FORMAT: must be json or logfmt, got "xml"`,
+			},
+		},
+		{
+			name:     "test case 6: empty base URL for relative short URLs",
+			cfg:      cfg6,
+			problems: nil,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := configs.Validate(testCase.cfg)
+
+			if testCase.problems == nil {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			var validationErr *configs.ValidationError
+
+			require.True(t, errors.As(err, &validationErr))
+			assert.Equal(t, testCase.problems, validationErr.Problems)
+		})
+	}
+}
+
+func TestGetDatabaseConfig_SecretFile(t *testing.T) {
+	secretPath := filepath.Join(t.TempDir(), "dsn")
+	require.NoError(t, os.WriteFile(secretPath, []byte("postgres://localhost/shorturl\n"), 0o600))
+
+	t.Setenv("DATABASE_DSN_FILE", secretPath)
+
+	databaseCfg, sources := configs.GetDatabaseConfig(newFlagConfig(), logger.NewNop())
+
+	assert.Equal(t, "postgres://localhost/shorturl", databaseCfg.DSN)
+	assert.Equal(t, configs.SourceEnv, sources["dsn"])
+
+	t.Setenv("DATABASE_DSN", "postgres://localhost/other")
+
+	assert.Panics(t, func() {
+		configs.GetDatabaseConfig(newFlagConfig(), logger.NewNop())
+	})
+}
+
+func newFlagConfig() *configs.FlagConfig {
+	return &configs.FlagConfig{
+		Address:             "",
+		BaseURL:             "",
+		FileStoragePath:     "",
+		ServerConfigPath:    "",
+		AppConfigPath:       "",
+		DatabaseConfigPath:  "",
+		PolicyConfigPath:    "",
+		RateLimitConfigPath: "",
+		QuotaConfigPath:     "",
+		JWTConfigPath:       "",
+		AdminConfigPath:     "",
+		WebhookConfigPath:   "",
+		MetricsConfigPath:   "",
+		LogConfigPath:       "",
+		JWTSignatureKey:     "",
+		DatabaseDSN:         "",
+		TrustedSubnet:       "",
+		TLSCertFile:         "",
+		TLSKeyFile:          "",
+		TLSSelfSigned:       false,
+		TLSMinVersion:       "",
+		HTTPRedirectAddress: "",
+		PrintConfig:         false,
+		Changed:             map[string]bool{},
+	}
+}
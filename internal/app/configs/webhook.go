@@ -0,0 +1,87 @@
+package configs
+
+import "github.com/tmitry/shorturl/internal/app/logger"
+
+const (
+	webhookMaxAttempts     = 5
+	webhookRetryBackoff    = 1
+	webhookMaxRetryBackoff = 5 * 60
+	webhookTimeout         = 5
+	webhookRelayInterval   = 1
+	webhookRelayBatchSize  = 100
+
+	webhookAllowPrivateNetworks = false
+)
+
+/*
+WebhookConfig describes delivery of webhook events.
+A failed delivery is retried after RetryBackoff seconds, the delay doubles with every attempt up to MaxRetryBackoff.
+After MaxAttempts failed attempts the delivery is dead-lettered.
+Timeout (in seconds) limits a single attempt.
+Link changes are recorded in the outbox, the relay publishes up to RelayBatchSize events every RelayInterval seconds.
+Webhooks can not point to private networks unless AllowPrivateNetworks is set, e.g. for a receiver on localhost.
+
+WebhookConfig uses the following precedence order. Each item takes precedence over the item below it:
+- Env
+- YAML
+- Default.
+*/
+type WebhookConfig struct {
+	MaxAttempts     int `env:"WEBHOOK_MAX_ATTEMPTS" yaml:"max_attempts"`
+	RetryBackoff    int `env:"WEBHOOK_RETRY_BACKOFF" yaml:"retry_backoff"`
+	MaxRetryBackoff int `env:"WEBHOOK_MAX_RETRY_BACKOFF" yaml:"max_retry_backoff"`
+	Timeout         int `env:"WEBHOOK_TIMEOUT" yaml:"timeout"`
+	RelayInterval   int `env:"WEBHOOK_RELAY_INTERVAL" yaml:"relay_interval"`
+	RelayBatchSize  int `env:"WEBHOOK_RELAY_BATCH_SIZE" yaml:"relay_batch_size"`
+
+	AllowPrivateNetworks bool `env:"WEBHOOK_ALLOW_PRIVATE_NETWORKS" yaml:"allow_private_networks"`
+}
+
+func NewWebhookConfig(
+	maxAttempts, retryBackoff, maxRetryBackoff, timeout int,
+	relayInterval, relayBatchSize int,
+	allowPrivateNetworks bool,
+) *WebhookConfig {
+	return &WebhookConfig{
+		MaxAttempts:     maxAttempts,
+		RetryBackoff:    retryBackoff,
+		MaxRetryBackoff: maxRetryBackoff,
+		Timeout:         timeout,
+		RelayInterval:   relayInterval,
+		RelayBatchSize:  relayBatchSize,
+
+		AllowPrivateNetworks: allowPrivateNetworks,
+	}
+}
+
+func NewDefaultWebhookConfig() *WebhookConfig {
+	return NewWebhookConfig(
+		webhookMaxAttempts,
+		webhookRetryBackoff,
+		webhookMaxRetryBackoff,
+		webhookTimeout,
+		webhookRelayInterval,
+		webhookRelayBatchSize,
+		webhookAllowPrivateNetworks,
+	)
+}
+
+func GetWebhookConfig(flagConfig *FlagConfig, log *logger.Logger) (*WebhookConfig, Sources) {
+	webhookCfg := NewWebhookConfig(0, 0, 0, 0, 0, 0, false)
+
+	defaultWebhookLayer := newDefaultLayer(NewDefaultWebhookConfig())
+
+	envWebhookLayer, err := newEnvLayer(NewWebhookConfig(0, 0, 0, 0, 0, 0, false))
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "webhook", logger.KeyError, err)
+	}
+
+	yamlWebhookLayer, err := newYAMLLayer(NewWebhookConfig(0, 0, 0, 0, 0, 0, false), flagConfig.WebhookConfigPath)
+	if err != nil {
+		log.Panic(messageFailedToLoadConfig, "section", "webhook", logger.KeyError, err)
+	}
+
+	priorityLayers := []*layer[WebhookConfig]{envWebhookLayer, yamlWebhookLayer, defaultWebhookLayer}
+
+	return webhookCfg, buildConfig(webhookCfg, priorityLayers)
+}
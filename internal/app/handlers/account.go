@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	MessageIncorrectUsername    = "username must be 3-64 characters: letters, digits, '.', '_' or '-'"
+	MessageIncorrectPassword    = "password must be 8-72 bytes long"
+	MessageUsernameTaken        = "username is taken"
+	MessageIncorrectCredentials = "incorrect username or password"
+	MessageLoginRequired        = "login required"
+	MessageNothingToMerge       = "nothing to merge"
+
+	passwordMinLength = 8
+	passwordMaxLength = 72 // bcrypt ignores the rest.
+
+	// anonymousCookieSuffix names the cookie which keeps the anonymous identity for a merge after login.
+	anonymousCookieSuffix = "_anonymous"
+	mergeWindow           = time.Hour
+
+	// dummyPasswordHash is compared with passwords of unknown usernames, so they take as long as wrong passwords.
+	dummyPasswordHash = "$2a$10$2xhd2HxLu9x.zfTGbvoe9uJjJ07HfXZug4jkeAVaxMeKphbVo.jBe"
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9._-]{3,64}$`)
+
+type credentialsRequestJSON struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func NewAccountResponseJSON(user *models.User, mergeableLinks int) interface{} {
+	response := struct {
+		UserID         uuid.UUID `json:"user_id"`
+		Username       string    `json:"username"`
+		MergeableLinks int       `json:"mergeable_links"`
+	}{UserID: user.ID, Username: user.Username, MergeableLinks: mergeableLinks}
+
+	return &response
+}
+
+func NewMergeResponseJSON(moved, conflicts int) interface{} {
+	response := struct {
+		MergedLinks      int `json:"merged_links"`
+		ConflictingLinks int `json:"conflicting_links"`
+	}{MergedLinks: moved, ConflictingLinks: conflicts}
+
+	return &response
+}
+
+/*
+AccountHandler registers users and logs them in. A registered user keeps the user ID in the JWT cookie,
+so links are available from any browser after login.
+Logging in from a browser with anonymous links keeps the anonymous identity in a separate short-lived cookie,
+so the links can be merged into the account afterwards.
+*/
+type AccountHandler struct {
+	rep                 repositories.Repository
+	userRep             repositories.UserRepository
+	quotaManager        utils.QuotaManager
+	keyRing             *middlewares.JWTKeyRing
+	jwtOptions          *middlewares.JWTOptions
+	anonymousJWTOptions *middlewares.JWTOptions
+	contextKeyUserID    middlewares.ContextKey
+	passwordHashCost    int
+	log                 *logger.Logger
+}
+
+func NewAccountHandler(
+	rep repositories.Repository,
+	userRep repositories.UserRepository,
+	quotaManager utils.QuotaManager,
+	keyRing *middlewares.JWTKeyRing,
+	jwtOptions *middlewares.JWTOptions,
+	contextKeyUserID middlewares.ContextKey,
+	log *logger.Logger,
+) *AccountHandler {
+	anonymousJWTOptions := *jwtOptions
+	anonymousJWTOptions.CookieName += anonymousCookieSuffix
+	anonymousJWTOptions.Lifetime = mergeWindow
+
+	return &AccountHandler{
+		rep:                 rep,
+		userRep:             userRep,
+		quotaManager:        quotaManager,
+		keyRing:             keyRing,
+		jwtOptions:          jwtOptions,
+		anonymousJWTOptions: &anonymousJWTOptions,
+		contextKeyUserID:    contextKeyUserID,
+		passwordHashCost:    bcrypt.DefaultCost,
+		log:                 log,
+	}
+}
+
+func (h AccountHandler) Register(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
+	if !ok {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
+
+		return
+	}
+
+	credentials, ok := h.readCredentials(writer, request)
+	if !ok {
+		return
+	}
+
+	if !usernamePattern.MatchString(credentials.Username) {
+		writeFieldError(writer, request, MessageIncorrectUsername, "/username")
+
+		return
+	}
+
+	if len(credentials.Password) < passwordMinLength || len(credentials.Password) > passwordMaxLength {
+		writeFieldError(writer, request, MessageIncorrectPassword, "/password")
+
+		return
+	}
+
+	// An anonymous user keeps the links by turning the current identity into the account.
+	isRegistered, err := h.isRegistered(request, userID)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	if isRegistered {
+		userID = uuid.New()
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(credentials.Password), h.passwordHashCost)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	user := models.NewUser(userID, credentials.Username, string(passwordHash))
+
+	if err := h.userRep.SaveUser(request.Context(), user); err != nil {
+		if errors.Is(err, repositories.ErrUsernameTaken) {
+			utils.WriteError(writer, request, utils.NewProblem(
+				http.StatusConflict,
+				utils.ProblemCodeConflict,
+				MessageUsernameTaken,
+			))
+
+			return
+		}
+
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	if err := h.writeJWT(writer, h.jwtOptions, user.ID); err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writeJSON(writer, request, http.StatusCreated, NewAccountResponseJSON(user, 0), h.log)
+}
+
+func (h AccountHandler) Login(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
+	if !ok {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
+
+		return
+	}
+
+	credentials, ok := h.readCredentials(writer, request)
+	if !ok {
+		return
+	}
+
+	user, err := h.userRep.FindUserByUsername(request.Context(), credentials.Username)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	passwordHash := dummyPasswordHash
+	if err == nil {
+		passwordHash = user.PasswordHash
+	}
+
+	isCorrect := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(credentials.Password)) == nil
+	if err != nil || !isCorrect {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusUnauthorized,
+			utils.ProblemCodeUnauthorized,
+			MessageIncorrectCredentials,
+		))
+
+		return
+	}
+
+	mergeableLinks, err := h.countMergeableLinks(request, userID, user.ID)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	if mergeableLinks > 0 {
+		if err := h.writeJWT(writer, h.anonymousJWTOptions, userID); err != nil {
+			utils.WriteError(writer, request, utils.NewInternalProblem())
+			h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+			return
+		}
+	}
+
+	if err := h.writeJWT(writer, h.jwtOptions, user.ID); err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writeJSON(writer, request, http.StatusOK, NewAccountResponseJSON(user, mergeableLinks), h.log)
+}
+
+// Merge moves links of the anonymous identity remembered at login into the account.
+func (h AccountHandler) Merge(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
+	if !ok {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
+
+		return
+	}
+
+	isRegistered, err := h.isRegistered(request, userID)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	if !isRegistered {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusForbidden,
+			utils.ProblemCodeForbidden,
+			MessageLoginRequired,
+		))
+
+		return
+	}
+
+	anonymousJWT, err := middlewares.ReadJWTCookie(request, h.keyRing, h.anonymousJWTOptions.CookieName, time.Now())
+	if err != nil {
+		if errors.Is(err, middlewares.ErrNoCorrectJWT) {
+			utils.WriteError(writer, request, utils.NewProblem(
+				http.StatusBadRequest,
+				utils.ProblemCodeBadRequest,
+				MessageNothingToMerge,
+			))
+
+			return
+		}
+
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	if !refuseBannedSource(writer, request, h.rep, anonymousJWT.Payload.UserID, h.log) {
+		return
+	}
+
+	moved, conflicts, err := h.rep.ReassignUserID(
+		request.Context(),
+		anonymousJWT.Payload.UserID,
+		userID,
+		h.quotaManager.GetPlan(userID).MaxLinks,
+	)
+	if err != nil {
+		if errors.Is(err, repositories.ErrQuotaExceeded) {
+			writeUserQuotaExceeded(writer, request, h.quotaManager, userID, h.log)
+
+			return
+		}
+
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	middlewares.ClearJWTCookie(writer, h.anonymousJWTOptions)
+
+	writeJSON(writer, request, http.StatusOK, NewMergeResponseJSON(moved, conflicts), h.log)
+}
+
+func (h AccountHandler) Logout(writer http.ResponseWriter, _ *http.Request) {
+	middlewares.ClearJWTCookie(writer, h.jwtOptions)
+	middlewares.ClearJWTCookie(writer, h.anonymousJWTOptions)
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h AccountHandler) readCredentials(writer http.ResponseWriter, request *http.Request) (*credentialsRequestJSON, bool) {
+	reader, err := getRequestReader(request)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return nil, false
+	}
+
+	defer func(reader io.ReadCloser) {
+		err := reader.Close()
+		if err != nil {
+			h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+		}
+	}(reader)
+
+	credentials := &credentialsRequestJSON{Username: "", Password: ""}
+	if err := json.NewDecoder(reader).Decode(credentials); err != nil {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeIncorrectJSON,
+			MessageIncorrectJSON,
+		))
+
+		return nil, false
+	}
+
+	credentials.Username = strings.ToLower(strings.TrimSpace(credentials.Username))
+
+	return credentials, true
+}
+
+func (h AccountHandler) isRegistered(request *http.Request, userID uuid.UUID) (bool, error) {
+	_, err := h.userRep.FindUserByID(request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	return true, nil
+}
+
+// countMergeableLinks counts links of the anonymous user which logs in as accountID.
+func (h AccountHandler) countMergeableLinks(request *http.Request, userID, accountID uuid.UUID) (int, error) {
+	if userID == accountID {
+		return 0, nil
+	}
+
+	isRegistered, err := h.isRegistered(request, userID)
+	if err != nil || isRegistered {
+		return 0, err
+	}
+
+	shortURLs, err := h.rep.FindAllByUserID(request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("failed to find links: %w", err)
+	}
+
+	return len(shortURLs), nil
+}
+
+func (h AccountHandler) writeJWT(writer http.ResponseWriter, options *middlewares.JWTOptions, userID uuid.UUID) error {
+	jwt := middlewares.NewJWT(userID, time.Now(), options.Lifetime)
+
+	if err := middlewares.WriteJWTCookie(writer, h.keyRing, options, jwt); err != nil {
+		return fmt.Errorf("failed to write jwt: %w", err)
+	}
+
+	return nil
+}
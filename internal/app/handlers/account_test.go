@@ -0,0 +1,247 @@
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/handlers"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/mocks"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newAccountJWTOptions() *middlewares.JWTOptions {
+	return middlewares.NewJWTOptions("jwt", time.Hour, time.Minute, true, false, http.SameSiteLaxMode)
+}
+
+func TestAccountHandler_Register(t *testing.T) {
+	t.Parallel()
+
+	const contextKeyUserID middlewares.ContextKey = "userID"
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	keyRing := middlewares.NewJWTKeyRing("1", "signature_key", nil, time.Time{})
+
+	// test case 3
+	userID3 := uuid.New()
+	userRep3 := mocks.NewMockUserRepository(ctrl)
+	userRep3.EXPECT().FindUserByID(gomock.Any(), userID3).Return(nil, repositories.ErrNotFound)
+	userRep3.EXPECT().SaveUser(gomock.Any(), gomock.Any()).Return(repositories.ErrUsernameTaken)
+
+	// test case 4
+	userID4 := uuid.New()
+	userRep4 := mocks.NewMockUserRepository(ctrl)
+	userRep4.EXPECT().FindUserByID(gomock.Any(), userID4).Return(nil, repositories.ErrNotFound)
+	userRep4.EXPECT().SaveUser(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, user *models.User) error {
+			assert.Equal(t, userID4, user.ID)
+			assert.Equal(t, "alice", user.Username)
+			assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("correct horse")))
+
+			return nil
+		},
+	)
+
+	tests := []struct {
+		name       string
+		userRep    repositories.UserRepository
+		userID     uuid.UUID
+		body       string
+		statusCode int
+		message    string
+	}{
+		{
+			name:       "test case 1: incorrect username",
+			userRep:    mocks.NewMockUserRepository(ctrl),
+			userID:     uuid.New(),
+			body:       `{"username":"a b","password":"correct horse"}`,
+			statusCode: http.StatusBadRequest,
+			message:    handlers.MessageInvalidRequestBody + ": /username: " + handlers.MessageIncorrectUsername,
+		},
+		{
+			name:       "test case 2: short password",
+			userRep:    mocks.NewMockUserRepository(ctrl),
+			userID:     uuid.New(),
+			body:       `{"username":"alice","password":"short"}`,
+			statusCode: http.StatusBadRequest,
+			message:    handlers.MessageInvalidRequestBody + ": /password: " + handlers.MessageIncorrectPassword,
+		},
+		{
+			name:       "test case 3: username is taken",
+			userRep:    userRep3,
+			userID:     userID3,
+			body:       `{"username":"alice","password":"correct horse"}`,
+			statusCode: http.StatusConflict,
+			message:    handlers.MessageUsernameTaken,
+		},
+		{
+			name:       "test case 4: registered with the anonymous user id",
+			userRep:    userRep4,
+			userID:     userID4,
+			body:       `{"username":"Alice","password":"correct horse"}`,
+			statusCode: http.StatusCreated,
+			message:    "",
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			rep := mocks.NewMockRepository(ctrl)
+			handler := handlers.NewAccountHandler(
+				rep,
+				testCase.userRep,
+				utils.NewConfigQuotaManager(configs.NewDefaultQuotaConfig(), rep, logger.NewNop()),
+				keyRing,
+				newAccountJWTOptions(),
+				contextKeyUserID,
+				logger.NewNop(),
+			)
+
+			request := httptest.NewRequest(http.MethodPost, "/api/user/register", strings.NewReader(testCase.body))
+			request = request.WithContext(context.WithValue(request.Context(), contextKeyUserID, testCase.userID))
+
+			recorder := httptest.NewRecorder()
+			handler.Register(recorder, request)
+			result := recorder.Result()
+
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.statusCode, result.StatusCode)
+
+			if testCase.statusCode != http.StatusCreated {
+				assert.Equal(
+					t,
+					fmt.Sprintf("%s: %s", http.StatusText(testCase.statusCode), testCase.message),
+					strings.TrimSuffix(string(body), "\n"),
+				)
+
+				return
+			}
+
+			assert.Contains(t, string(body), testCase.userID.String())
+
+			cookies := result.Cookies()
+			require.Len(t, cookies, 1)
+
+			jwt, err := keyRing.Verify(cookies[0].Value, time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, testCase.userID, jwt.Payload.UserID)
+		})
+	}
+}
+
+func TestAccountHandler_LoginAndMerge(t *testing.T) {
+	t.Parallel()
+
+	const contextKeyUserID middlewares.ContextKey = "userID"
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	keyRing := middlewares.NewJWTKeyRing("1", "signature_key", nil, time.Time{})
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	anonymousUserID := uuid.New()
+	user := models.NewUser(uuid.New(), "alice", string(passwordHash))
+
+	userRep := mocks.NewMockUserRepository(ctrl)
+	userRep.EXPECT().FindUserByUsername(gomock.Any(), "bob").Return(nil, repositories.ErrNotFound)
+	userRep.EXPECT().FindUserByUsername(gomock.Any(), "alice").Return(user, nil).Times(2)
+	userRep.EXPECT().FindUserByID(gomock.Any(), anonymousUserID).Return(nil, repositories.ErrNotFound)
+	userRep.EXPECT().FindUserByID(gomock.Any(), user.ID).Return(user, nil)
+
+	rep := mocks.NewMockRepository(ctrl)
+	rep.EXPECT().FindAllByUserID(gomock.Any(), anonymousUserID).Return([]*models.ShortURL{
+		models.NewShortURL(1, "https://example.com/", "abc", anonymousUserID),
+		models.NewShortURL(2, "https://example.org/", "abd", anonymousUserID),
+	}, nil)
+	rep.EXPECT().FindBanByUserID(gomock.Any(), anonymousUserID).Return(nil, repositories.ErrNotFound)
+	rep.EXPECT().ReassignUserID(gomock.Any(), anonymousUserID, user.ID, 0).Return(1, 1, nil)
+
+	handler := handlers.NewAccountHandler(
+		rep,
+		userRep,
+		utils.NewConfigQuotaManager(configs.NewDefaultQuotaConfig(), rep, logger.NewNop()),
+		keyRing,
+		newAccountJWTOptions(),
+		contextKeyUserID,
+		logger.NewNop(),
+	)
+
+	login := func(body string) *http.Response {
+		request := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(body))
+		request = request.WithContext(context.WithValue(request.Context(), contextKeyUserID, anonymousUserID))
+
+		recorder := httptest.NewRecorder()
+		handler.Login(recorder, request)
+
+		return recorder.Result()
+	}
+
+	// Unknown user and incorrect password are indistinguishable.
+	for _, body := range []string{
+		`{"username":"bob","password":"correct horse"}`,
+		`{"username":"alice","password":"incorrect horse"}`,
+	} {
+		result := login(body)
+		require.NoError(t, result.Body.Close())
+		assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+		assert.Empty(t, result.Cookies())
+	}
+
+	result := login(`{"username":"alice","password":"correct horse"}`)
+	body, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	require.NoError(t, result.Body.Close())
+
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Contains(t, string(body), `"mergeable_links":2`)
+
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range result.Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+
+	require.Contains(t, cookies, "jwt")
+	require.Contains(t, cookies, "jwt_anonymous")
+
+	request := httptest.NewRequest(http.MethodPost, "/api/user/merge", nil)
+	request.AddCookie(cookies["jwt_anonymous"])
+	request = request.WithContext(context.WithValue(request.Context(), contextKeyUserID, user.ID))
+
+	recorder := httptest.NewRecorder()
+	handler.Merge(recorder, request)
+	result = recorder.Result()
+
+	body, err = io.ReadAll(result.Body)
+	require.NoError(t, err)
+	require.NoError(t, result.Body.Close())
+
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.JSONEq(t, `{"merged_links":1,"conflicting_links":1}`, string(body))
+	require.Len(t, result.Cookies(), 1)
+	assert.Equal(t, -1, result.Cookies()[0].MaxAge)
+}
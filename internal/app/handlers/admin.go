@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+const (
+	ParameterNameAdminUserID = "user_id"
+
+	MessageIncorrectSearch = "incorrect search parameters"
+	MessageUserIsNotBanned = "user is not banned"
+
+	adminSearchDefaultLimit = 100
+	adminSearchMaxLimit     = 1000
+)
+
+type adminShortURLResponseJSON struct {
+	UID         models.UID `json:"uid"`
+	ShortURL    models.URL `json:"short_url"`
+	OriginalURL models.URL `json:"original_url"`
+	UserID      uuid.UUID  `json:"user_id"`
+	IsDeleted   bool       `json:"is_deleted"`
+	IsDisabled  bool       `json:"is_disabled"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+func NewAdminShortURLsResponseJSON(shortURLs []*models.ShortURL, baseURL string) interface{} {
+	response := make([]adminShortURLResponseJSON, 0, len(shortURLs))
+
+	for _, shortURL := range shortURLs {
+		item := adminShortURLResponseJSON{
+			UID:         shortURL.UID,
+			ShortURL:    shortURL.GetShortURL(baseURL),
+			OriginalURL: shortURL.URL,
+			UserID:      shortURL.UserID,
+			IsDeleted:   shortURL.IsDeleted,
+			IsDisabled:  shortURL.IsDisabled,
+			ExpiresAt:   nil,
+		}
+
+		if !shortURL.ExpiresAt.IsZero() {
+			expiresAt := shortURL.ExpiresAt
+			item.ExpiresAt = &expiresAt
+		}
+
+		response = append(response, item)
+	}
+
+	return &response
+}
+
+func NewGlobalCountsResponseJSON(counts *models.GlobalCounts) interface{} {
+	response := struct {
+		Links         int `json:"links"`
+		ActiveLinks   int `json:"active_links"`
+		DeletedLinks  int `json:"deleted_links"`
+		DisabledLinks int `json:"disabled_links"`
+		Users         int `json:"users"`
+		BannedUsers   int `json:"banned_users"`
+		Clicks        int `json:"clicks"`
+	}{
+		Links:         counts.Links,
+		ActiveLinks:   counts.ActiveLinks,
+		DeletedLinks:  counts.DeletedLinks,
+		DisabledLinks: counts.DisabledLinks,
+		Users:         counts.Users,
+		BannedUsers:   counts.BannedUsers,
+		Clicks:        counts.Clicks,
+	}
+
+	return &response
+}
+
+// AdminHandler moderates links of all users. Access is restricted by the AdminOnly middleware.
+type AdminHandler struct {
+	cfg      *configs.Config
+	rep      repositories.Repository
+	webhooks utils.WebhookDispatcher
+	log      *logger.Logger
+}
+
+func NewAdminHandler(
+	cfg *configs.Config,
+	rep repositories.Repository,
+	webhooks utils.WebhookDispatcher,
+	log *logger.Logger,
+) *AdminHandler {
+	return &AdminHandler{
+		cfg:      cfg,
+		rep:      rep,
+		webhooks: webhooks,
+		log:      log,
+	}
+}
+
+// Search finds links by a substring of the original URL (query) and the owner (user_id).
+func (h AdminHandler) Search(writer http.ResponseWriter, request *http.Request) {
+	filter, err := newShortURLFilter(request)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeBadRequest,
+			MessageIncorrectSearch,
+		))
+
+		return
+	}
+
+	shortURLs, err := h.rep.SearchShortURLs(request.Context(), filter)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			writer.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	responseJSON := NewAdminShortURLsResponseJSON(shortURLs, h.cfg.Server.BaseURL)
+
+	writeJSON(writer, request, http.StatusOK, responseJSON, h.log)
+}
+
+func (h AdminHandler) Disable(writer http.ResponseWriter, request *http.Request) {
+	h.setDisabled(writer, request, true)
+}
+
+func (h AdminHandler) Enable(writer http.ResponseWriter, request *http.Request) {
+	h.setDisabled(writer, request, false)
+}
+
+func (h AdminHandler) setDisabled(writer http.ResponseWriter, request *http.Request, isDisabled bool) {
+	uid := models.UID(chi.URLParam(request, ParameterNameUID))
+
+	if err := h.rep.SetDisabled(request.Context(), uid, isDisabled); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			utils.WriteError(writer, request, utils.NewProblem(
+				http.StatusNotFound,
+				utils.ProblemCodeNotFound,
+				MessageURLNotFound,
+			))
+
+			return
+		}
+
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	// The link is changed already, so a failure to notify its owner does not fail the request.
+	shortURL, err := h.rep.FindOneByUID(request.Context(), uid)
+	if err != nil {
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+	} else {
+		h.webhooks.Emit(request.Context(), models.NewWebhookEvent(models.WebhookEventLinkUpdated, shortURL))
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h AdminHandler) Ban(writer http.ResponseWriter, request *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(request, ParameterNameAdminUserID))
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeBadRequest,
+			MessageIncorrectUserID,
+		))
+
+		return
+	}
+
+	requestJSON := &struct {
+		Reason string `json:"reason"`
+	}{Reason: ""}
+
+	reader, err := getRequestReader(request)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	defer func(reader io.ReadCloser) {
+		err := reader.Close()
+		if err != nil {
+			h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+		}
+	}(reader)
+
+	// The reason is optional, so an empty body is accepted.
+	if err := json.NewDecoder(reader).Decode(requestJSON); err != nil && !errors.Is(err, io.EOF) {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeIncorrectJSON,
+			MessageIncorrectJSON,
+		))
+
+		return
+	}
+
+	if err := h.rep.SaveBan(request.Context(), models.NewBan(userID, requestJSON.Reason)); err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h AdminHandler) Unban(writer http.ResponseWriter, request *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(request, ParameterNameAdminUserID))
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeBadRequest,
+			MessageIncorrectUserID,
+		))
+
+		return
+	}
+
+	if err := h.rep.DeleteBan(request.Context(), userID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			utils.WriteError(writer, request, utils.NewProblem(
+				http.StatusNotFound,
+				utils.ProblemCodeNotFound,
+				MessageUserIsNotBanned,
+			))
+
+			return
+		}
+
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (h AdminHandler) Counts(writer http.ResponseWriter, request *http.Request) {
+	counts, err := h.rep.CountAll(request.Context())
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writeJSON(writer, request, http.StatusOK, NewGlobalCountsResponseJSON(counts), h.log)
+}
+
+func newShortURLFilter(request *http.Request) (*models.ShortURLFilter, error) {
+	query := request.URL.Query()
+
+	filter := models.NewShortURLFilter(query.Get("query"), uuid.UUID{}, adminSearchDefaultLimit, 0)
+
+	if userID := query.Get("user_id"); userID != "" {
+		id, err := uuid.Parse(userID)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect user_id: %w", err)
+		}
+
+		filter.UserID = id
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > adminSearchMaxLimit {
+			return nil, fmt.Errorf("incorrect limit: %q", limit)
+		}
+
+		filter.Limit = value
+	}
+
+	if offset := query.Get("offset"); offset != "" {
+		value, err := strconv.Atoi(offset)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("incorrect offset: %q", offset)
+		}
+
+		filter.Offset = value
+	}
+
+	return filter, nil
+}
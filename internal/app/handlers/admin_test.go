@@ -0,0 +1,243 @@
+package handlers_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/handlers"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/mocks"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+func TestAdminHandler_Search(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	webhooks := mocks.NewMockWebhookDispatcher(ctrl)
+	webhooks.EXPECT().Emit(gomock.Any(), gomock.Any()).AnyTimes()
+
+	cfg := configs.NewDefaultConfig()
+	userID := uuid.New()
+
+	// test case 2
+	rep2 := mocks.NewMockRepository(ctrl)
+	rep2.EXPECT().SearchShortURLs(gomock.Any(), models.NewShortURLFilter("nothing", uuid.UUID{}, 100, 0)).
+		Return(nil, repositories.ErrNotFound)
+
+	// test case 3
+	shortURL3 := models.NewShortURL(1, "https://example.com/", "abc", userID)
+	shortURL3.IsDisabled = true
+	rep3 := mocks.NewMockRepository(ctrl)
+	rep3.EXPECT().SearchShortURLs(gomock.Any(), models.NewShortURLFilter("example", userID, 10, 20)).
+		Return([]*models.ShortURL{shortURL3}, nil)
+
+	tests := []struct {
+		name       string
+		rep        repositories.Repository
+		query      string
+		statusCode int
+		body       string
+	}{
+		{
+			name:       "test case 1: incorrect limit",
+			rep:        mocks.NewMockRepository(ctrl),
+			query:      "?limit=0",
+			statusCode: http.StatusBadRequest,
+			body:       http.StatusText(http.StatusBadRequest) + ": " + handlers.MessageIncorrectSearch,
+		},
+		{
+			name:       "test case 2: nothing found",
+			rep:        rep2,
+			query:      "?query=nothing",
+			statusCode: http.StatusNoContent,
+			body:       "",
+		},
+		{
+			name:       "test case 3: found",
+			rep:        rep3,
+			query:      "?query=example&user_id=" + userID.String() + "&limit=10&offset=20",
+			statusCode: http.StatusOK,
+			body: `[{"uid":"abc","short_url":"` + cfg.Server.BaseURL + `/abc","original_url":"https://example.com/",` +
+				`"user_id":"` + userID.String() + `","is_deleted":false,"is_disabled":true,"expires_at":null}]`,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := handlers.NewAdminHandler(cfg, testCase.rep, webhooks, logger.NewNop())
+
+			request := httptest.NewRequest(http.MethodGet, "/api/admin/urls"+testCase.query, nil)
+
+			recorder := httptest.NewRecorder()
+			handler.Search(recorder, request)
+			result := recorder.Result()
+
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.statusCode, result.StatusCode)
+			assert.Equal(t, testCase.body, strings.TrimSuffix(string(body), "\n"))
+		})
+	}
+}
+
+func TestAdminHandler_Ban(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	webhooks := mocks.NewMockWebhookDispatcher(ctrl)
+	webhooks.EXPECT().Emit(gomock.Any(), gomock.Any()).AnyTimes()
+
+	// test case 2
+	userID2 := uuid.New()
+	rep2 := mocks.NewMockRepository(ctrl)
+	rep2.EXPECT().SaveBan(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, ban *models.Ban) error {
+		assert.Equal(t, userID2, ban.UserID)
+		assert.Equal(t, "spam", ban.Reason)
+
+		return nil
+	})
+
+	// test case 3
+	userID3 := uuid.New()
+	rep3 := mocks.NewMockRepository(ctrl)
+	rep3.EXPECT().SaveBan(gomock.Any(), gomock.Any()).Return(nil)
+
+	tests := []struct {
+		name       string
+		rep        repositories.Repository
+		userID     string
+		body       string
+		statusCode int
+	}{
+		{
+			name:       "test case 1: incorrect user id",
+			rep:        mocks.NewMockRepository(ctrl),
+			userID:     "bad-id",
+			body:       "",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "test case 2: banned with reason",
+			rep:        rep2,
+			userID:     userID2.String(),
+			body:       `{"reason":"spam"}`,
+			statusCode: http.StatusNoContent,
+		},
+		{
+			name:       "test case 3: banned without reason",
+			rep:        rep3,
+			userID:     userID3.String(),
+			body:       "",
+			statusCode: http.StatusNoContent,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := handlers.NewAdminHandler(configs.NewDefaultConfig(), testCase.rep, webhooks, logger.NewNop())
+
+			routeContext := chi.NewRouteContext()
+			routeContext.URLParams.Add(handlers.ParameterNameAdminUserID, testCase.userID)
+
+			request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(testCase.body))
+			request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, routeContext))
+
+			recorder := httptest.NewRecorder()
+			handler.Ban(recorder, request)
+			result := recorder.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.statusCode, result.StatusCode)
+		})
+	}
+}
+
+func TestAdminHandler_Disable(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	// test case 1
+	rep1 := mocks.NewMockRepository(ctrl)
+	rep1.EXPECT().SetDisabled(gomock.Any(), models.UID("unknown"), true).Return(repositories.ErrNotFound)
+
+	// test case 2
+	shortURL2 := models.NewShortURL(1, "https://example.com/", "abc", uuid.New())
+	shortURL2.IsDisabled = true
+	rep2 := mocks.NewMockRepository(ctrl)
+	rep2.EXPECT().SetDisabled(gomock.Any(), models.UID("abc"), true).Return(nil)
+	rep2.EXPECT().FindOneByUID(gomock.Any(), models.UID("abc")).Return(shortURL2, nil)
+	webhooks2 := mocks.NewMockWebhookDispatcher(ctrl)
+	webhooks2.EXPECT().Emit(gomock.Any(), gomock.Any()).Do(func(_ context.Context, event *models.WebhookEvent) {
+		assert.Equal(t, models.WebhookEventLinkUpdated, event.Type)
+		assert.Equal(t, *shortURL2, event.ShortURL)
+	})
+
+	tests := []struct {
+		name       string
+		rep        repositories.Repository
+		webhooks   utils.WebhookDispatcher
+		uid        string
+		statusCode int
+	}{
+		{
+			name:       "test case 1: not found",
+			rep:        rep1,
+			webhooks:   mocks.NewMockWebhookDispatcher(ctrl),
+			uid:        "unknown",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "test case 2: disabled",
+			rep:        rep2,
+			webhooks:   webhooks2,
+			uid:        "abc",
+			statusCode: http.StatusNoContent,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := handlers.NewAdminHandler(configs.NewDefaultConfig(), testCase.rep, testCase.webhooks, logger.NewNop())
+
+			routeContext := chi.NewRouteContext()
+			routeContext.URLParams.Add(handlers.ParameterNameUID, testCase.uid)
+
+			request := httptest.NewRequest(http.MethodPost, "/", nil)
+			request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, routeContext))
+
+			recorder := httptest.NewRecorder()
+			handler.Disable(recorder, request)
+			result := recorder.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.statusCode, result.StatusCode)
+		})
+	}
+}
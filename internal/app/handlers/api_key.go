@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+const (
+	ParameterNameAPIKeyID = "id"
+
+	MessageIncorrectScope    = "incorrect scope"
+	MessageIncorrectAPIKeyID = "incorrect API key ID"
+	MessageAPIKeyNotFound    = "API key not found"
+)
+
+type createAPIKeyRequestJSON struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type apiKeyResponseJSON struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Key       string     `json:"key,omitempty"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// NewAPIKeyResponseJSON builds a response for apiKey. The key itself is known only right after creation.
+func NewAPIKeyResponseJSON(apiKey *models.APIKey, key string) interface{} {
+	response := apiKeyResponseJSON{
+		ID:        apiKey.ID,
+		Name:      apiKey.Name,
+		Key:       key,
+		Prefix:    apiKey.Prefix,
+		Scopes:    apiKey.Scopes,
+		CreatedAt: apiKey.CreatedAt,
+		RevokedAt: nil,
+	}
+
+	if response.Scopes == nil {
+		response.Scopes = []string{}
+	}
+
+	if apiKey.IsRevoked() {
+		revokedAt := apiKey.RevokedAt
+		response.RevokedAt = &revokedAt
+	}
+
+	return &response
+}
+
+func NewAPIKeysResponseJSON(apiKeys []*models.APIKey) interface{} {
+	response := make([]interface{}, 0, len(apiKeys))
+
+	for _, apiKey := range apiKeys {
+		response = append(response, NewAPIKeyResponseJSON(apiKey, ""))
+	}
+
+	return &response
+}
+
+type APIKeyHandler struct {
+	rep              repositories.APIKeyRepository
+	contextKeyUserID middlewares.ContextKey
+	log              *logger.Logger
+}
+
+func NewAPIKeyHandler(
+	rep repositories.APIKeyRepository,
+	contextKeyUserID middlewares.ContextKey,
+	log *logger.Logger,
+) *APIKeyHandler {
+	return &APIKeyHandler{
+		rep:              rep,
+		contextKeyUserID: contextKeyUserID,
+		log:              log,
+	}
+}
+
+func (h APIKeyHandler) Create(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
+	if !ok {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
+
+		return
+	}
+
+	reader, err := getRequestReader(request)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	defer func(reader io.ReadCloser) {
+		err := reader.Close()
+		if err != nil {
+			utils.WriteError(writer, request, utils.NewInternalProblem())
+			h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+		}
+	}(reader)
+
+	requestJSON := createAPIKeyRequestJSON{Name: "", Scopes: nil}
+	if err := json.NewDecoder(reader).Decode(&requestJSON); err != nil {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeIncorrectJSON,
+			MessageIncorrectJSON,
+		))
+
+		return
+	}
+
+	for index, scope := range requestJSON.Scopes {
+		if scope != models.APIKeyScopeRead && scope != models.APIKeyScopeWrite {
+			writeFieldError(
+				writer,
+				request,
+				fmt.Sprintf("%s %q", MessageIncorrectScope, scope),
+				fmt.Sprintf("/scopes/%d", index),
+			)
+
+			return
+		}
+	}
+
+	key, prefix, err := utils.GenerateAPIKey()
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	apiKey := models.NewAPIKey(userID, requestJSON.Name, prefix, utils.HashAPIKey(key), requestJSON.Scopes)
+
+	if err := h.rep.SaveAPIKey(request.Context(), apiKey); err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writeJSON(writer, request, http.StatusCreated, NewAPIKeyResponseJSON(apiKey, key), h.log)
+}
+
+func (h APIKeyHandler) List(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
+	if !ok {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
+
+		return
+	}
+
+	apiKeys, err := h.rep.FindAllAPIKeysByUserID(request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			writer.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writeJSON(writer, request, http.StatusOK, NewAPIKeysResponseJSON(apiKeys), h.log)
+}
+
+func (h APIKeyHandler) Revoke(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
+	if !ok {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
+
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(request, ParameterNameAPIKeyID))
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeBadRequest,
+			MessageIncorrectAPIKeyID,
+		))
+
+		return
+	}
+
+	if err := h.rep.RevokeAPIKey(request.Context(), userID, id); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			utils.WriteError(writer, request, utils.NewProblem(
+				http.StatusNotFound,
+				utils.ProblemCodeNotFound,
+				MessageAPIKeyNotFound,
+			))
+
+			return
+		}
+
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
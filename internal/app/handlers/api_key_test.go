@@ -0,0 +1,180 @@
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/handlers"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/mocks"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+)
+
+func TestAPIKeyHandler_Create(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		body   string
+		userID any
+	}
+
+	type response struct {
+		statusCode int
+		body       string
+	}
+
+	const contextKeyUserID middlewares.ContextKey = "userID"
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	// test case 3
+	rep3 := mocks.NewMockAPIKeyRepository(ctrl)
+	rep3.EXPECT().SaveAPIKey(gomock.Any(), gomock.Any()).Return(nil)
+
+	tests := []struct {
+		name     string
+		rep      repositories.APIKeyRepository
+		request  request
+		response response
+	}{
+		{
+			name:    "test case 1: incorrect json",
+			rep:     mocks.NewMockAPIKeyRepository(ctrl),
+			request: request{body: `bad json`, userID: uuid.New()},
+			response: response{
+				statusCode: http.StatusBadRequest,
+				body:       fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), handlers.MessageIncorrectJSON),
+			},
+		},
+		{
+			name:    "test case 2: incorrect scope",
+			rep:     mocks.NewMockAPIKeyRepository(ctrl),
+			request: request{body: `{"name":"ci","scopes":["admin"]}`, userID: uuid.New()},
+			response: response{
+				statusCode: http.StatusBadRequest,
+				body: fmt.Sprintf(
+					"%s: %s: /scopes/0: %s %q",
+					http.StatusText(http.StatusBadRequest),
+					handlers.MessageInvalidRequestBody,
+					handlers.MessageIncorrectScope,
+					"admin",
+				),
+			},
+		},
+		{
+			name:     "test case 3: created",
+			rep:      rep3,
+			request:  request{body: `{"name":"ci","scopes":["read"]}`, userID: uuid.New()},
+			response: response{statusCode: http.StatusCreated, body: ""},
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := handlers.NewAPIKeyHandler(testCase.rep, contextKeyUserID, logger.NewNop())
+
+			request := httptest.NewRequest(http.MethodPost, "/api/user/api-keys", strings.NewReader(testCase.request.body))
+			request = request.WithContext(context.WithValue(request.Context(), contextKeyUserID, testCase.request.userID))
+
+			recorder := httptest.NewRecorder()
+			handler.Create(recorder, request)
+			result := recorder.Result()
+
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.response.statusCode, result.StatusCode)
+
+			if testCase.response.statusCode == http.StatusCreated {
+				assert.Equal(t, handlers.ContentTypeJSON, handlers.GetContentType(result))
+				assert.Contains(t, string(body), `"key":"sk_`)
+			} else {
+				assert.Equal(t, testCase.response.body, strings.TrimSuffix(string(body), "\n"))
+			}
+		})
+	}
+}
+
+func TestAPIKeyHandler_Revoke(t *testing.T) {
+	t.Parallel()
+
+	const contextKeyUserID middlewares.ContextKey = "userID"
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	userID := uuid.New()
+
+	// test case 2
+	id2 := uuid.New()
+	rep2 := mocks.NewMockAPIKeyRepository(ctrl)
+	rep2.EXPECT().RevokeAPIKey(gomock.Any(), userID, id2).Return(repositories.ErrNotFound)
+
+	// test case 3
+	id3 := uuid.New()
+	rep3 := mocks.NewMockAPIKeyRepository(ctrl)
+	rep3.EXPECT().RevokeAPIKey(gomock.Any(), userID, id3).Return(nil)
+
+	tests := []struct {
+		name       string
+		rep        repositories.APIKeyRepository
+		id         string
+		statusCode int
+	}{
+		{
+			name:       "test case 1: incorrect id",
+			rep:        mocks.NewMockAPIKeyRepository(ctrl),
+			id:         "bad-id",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "test case 2: not found",
+			rep:        rep2,
+			id:         id2.String(),
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "test case 3: revoked",
+			rep:        rep3,
+			id:         id3.String(),
+			statusCode: http.StatusNoContent,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := handlers.NewAPIKeyHandler(testCase.rep, contextKeyUserID, logger.NewNop())
+
+			routeContext := chi.NewRouteContext()
+			routeContext.URLParams.Add(handlers.ParameterNameAPIKeyID, testCase.id)
+
+			request := httptest.NewRequest(http.MethodDelete, "/api/user/api-keys/"+testCase.id, nil)
+			ctx := context.WithValue(request.Context(), chi.RouteCtxKey, routeContext)
+			request = request.WithContext(context.WithValue(ctx, contextKeyUserID, userID))
+
+			recorder := httptest.NewRecorder()
+			handler.Revoke(recorder, request)
+			result := recorder.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.statusCode, result.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+const (
+	MessageIncorrectChangeFeedParameters = "incorrect change feed parameters"
+
+	changeFeedDefaultLimit = 100
+	changeFeedMaxLimit     = 1000
+	changeFeedMaxWait      = 60 * time.Second
+)
+
+type changeFeedQuery struct {
+	since int64
+	limit int
+	wait  time.Duration
+}
+
+func NewChangesResponseJSON(changes []*models.Change, baseURL string) interface{} {
+	type changeResponseJSON struct {
+		Seq         int64      `json:"seq"`
+		Type        string     `json:"type"`
+		ChangedAt   time.Time  `json:"changed_at"`
+		UID         models.UID `json:"uid"`
+		ShortURL    models.URL `json:"short_url"`
+		OriginalURL models.URL `json:"original_url"`
+		UserID      uuid.UUID  `json:"user_id"`
+		IsDeleted   bool       `json:"is_deleted"`
+		IsDisabled  bool       `json:"is_disabled"`
+		ExpiresAt   *time.Time `json:"expires_at"`
+	}
+
+	response := make([]changeResponseJSON, 0, len(changes))
+
+	for _, change := range changes {
+		item := changeResponseJSON{
+			Seq:         change.Seq,
+			Type:        change.Type,
+			ChangedAt:   change.ChangedAt,
+			UID:         change.ShortURL.UID,
+			ShortURL:    change.ShortURL.GetShortURL(baseURL),
+			OriginalURL: change.ShortURL.URL,
+			UserID:      change.ShortURL.UserID,
+			IsDeleted:   change.ShortURL.IsDeleted,
+			IsDisabled:  change.ShortURL.IsDisabled,
+			ExpiresAt:   nil,
+		}
+
+		if !change.ShortURL.ExpiresAt.IsZero() {
+			expiresAt := change.ShortURL.ExpiresAt
+			item.ExpiresAt = &expiresAt
+		}
+
+		response = append(response, item)
+	}
+
+	return &response
+}
+
+/*
+ChangeFeedHandler lets downstream systems follow changes of links. A consumer passes Seq of the last change
+it has applied as since. With wait the request is held until a change appears or wait expires,
+the repository is polled every pollInterval meanwhile. Shutdown ends held requests with 204 No Content,
+so they do not delay the shutdown of the server.
+*/
+type ChangeFeedHandler struct {
+	cfg          *configs.Config
+	rep          repositories.ChangeFeedRepository
+	pollInterval time.Duration
+	closing      chan struct{}
+	closeOnce    *sync.Once
+	log          *logger.Logger
+}
+
+func NewChangeFeedHandler(
+	cfg *configs.Config,
+	rep repositories.ChangeFeedRepository,
+	pollInterval time.Duration,
+	log *logger.Logger,
+) *ChangeFeedHandler {
+	return &ChangeFeedHandler{
+		cfg:          cfg,
+		rep:          rep,
+		pollInterval: pollInterval,
+		closing:      make(chan struct{}),
+		closeOnce:    &sync.Once{},
+		log:          log,
+	}
+}
+
+// Shutdown ends held requests and makes the following ones return without waiting, it is safe to call it twice.
+func (h ChangeFeedHandler) Shutdown() {
+	h.closeOnce.Do(func() {
+		close(h.closing)
+	})
+}
+
+func (h ChangeFeedHandler) Changes(writer http.ResponseWriter, request *http.Request) {
+	query, err := newChangeFeedQuery(request)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeBadRequest,
+			MessageIncorrectChangeFeedParameters,
+		))
+
+		return
+	}
+
+	deadline := time.Now().Add(query.wait)
+
+	for {
+		changes, err := h.rep.FindChanges(request.Context(), query.since, query.limit)
+		if err == nil {
+			responseJSON := NewChangesResponseJSON(changes, h.cfg.Server.BaseURL)
+
+			writeJSON(writer, request, http.StatusOK, responseJSON, h.log)
+
+			return
+		}
+
+		if !errors.Is(err, repositories.ErrNotFound) {
+			utils.WriteError(writer, request, utils.NewInternalProblem())
+			h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+			return
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			writer.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+
+		if remaining > h.pollInterval {
+			remaining = h.pollInterval
+		}
+
+		timer := time.NewTimer(remaining)
+
+		select {
+		case <-request.Context().Done():
+			timer.Stop()
+
+			return
+		case <-h.closing:
+			timer.Stop()
+			writer.WriteHeader(http.StatusNoContent)
+
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func newChangeFeedQuery(request *http.Request) (*changeFeedQuery, error) {
+	values := request.URL.Query()
+
+	query := &changeFeedQuery{since: 0, limit: changeFeedDefaultLimit, wait: 0}
+
+	if since := values.Get("since"); since != "" {
+		value, err := strconv.ParseInt(since, 10, 64)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("incorrect since: %q", since)
+		}
+
+		query.since = value
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > changeFeedMaxLimit {
+			return nil, fmt.Errorf("incorrect limit: %q", limit)
+		}
+
+		query.limit = value
+	}
+
+	// wait is given in seconds and capped, so a consumer can not hold a connection forever.
+	if wait := values.Get("wait"); wait != "" {
+		value, err := strconv.Atoi(wait)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("incorrect wait: %q", wait)
+		}
+
+		query.wait = time.Duration(value) * time.Second
+		if query.wait > changeFeedMaxWait {
+			query.wait = changeFeedMaxWait
+		}
+	}
+
+	return query, nil
+}
@@ -0,0 +1,254 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/handlers"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/mocks"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+)
+
+func TestChangeFeedHandler_Changes(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	cfg := configs.NewDefaultConfig()
+	userID := uuid.New()
+	changedAt := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// test case 2
+	rep2 := mocks.NewMockChangeFeedRepository(ctrl)
+	rep2.EXPECT().FindChanges(gomock.Any(), int64(0), 100).Return(nil, repositories.ErrNotFound)
+
+	// test case 3
+	change3 := models.NewChange(models.ChangeTypeDelete, models.NewShortURL(1, "https://example.com/", "abc", userID))
+	change3.Seq = 8
+	change3.ShortURL.IsDeleted = true
+	change3.ChangedAt = changedAt
+	rep3 := mocks.NewMockChangeFeedRepository(ctrl)
+	rep3.EXPECT().FindChanges(gomock.Any(), int64(7), 10).Return([]*models.Change{change3}, nil)
+
+	// test case 4
+	rep4 := mocks.NewMockChangeFeedRepository(ctrl)
+	rep4.EXPECT().FindChanges(gomock.Any(), int64(0), 100).Return(nil, errors.New("failure"))
+
+	tests := []struct {
+		name       string
+		rep        repositories.ChangeFeedRepository
+		query      string
+		statusCode int
+		body       string
+	}{
+		{
+			name:       "test case 1: incorrect since",
+			rep:        mocks.NewMockChangeFeedRepository(ctrl),
+			query:      "?since=-1",
+			statusCode: http.StatusBadRequest,
+			body:       http.StatusText(http.StatusBadRequest) + ": " + handlers.MessageIncorrectChangeFeedParameters,
+		},
+		{
+			name:       "test case 2: no changes",
+			rep:        rep2,
+			query:      "",
+			statusCode: http.StatusNoContent,
+			body:       "",
+		},
+		{
+			name:       "test case 3: changes since seq",
+			rep:        rep3,
+			query:      "?since=7&limit=10",
+			statusCode: http.StatusOK,
+			body: `[{"seq":8,"type":"delete","changed_at":"2022-01-02T03:04:05Z","uid":"abc",` +
+				`"short_url":"` + cfg.Server.BaseURL + `/abc","original_url":"https://example.com/",` +
+				`"user_id":"` + userID.String() + `","is_deleted":true,"is_disabled":false,"expires_at":null}]`,
+		},
+		{
+			name:       "test case 4: repository failure",
+			rep:        rep4,
+			query:      "",
+			statusCode: http.StatusInternalServerError,
+			body:       http.StatusText(http.StatusInternalServerError),
+		},
+		{
+			name:       "test case 5: incorrect limit",
+			rep:        mocks.NewMockChangeFeedRepository(ctrl),
+			query:      "?limit=1001",
+			statusCode: http.StatusBadRequest,
+			body:       http.StatusText(http.StatusBadRequest) + ": " + handlers.MessageIncorrectChangeFeedParameters,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := handlers.NewChangeFeedHandler(cfg, testCase.rep, time.Millisecond, logger.NewNop())
+
+			request := httptest.NewRequest(http.MethodGet, "/api/admin/changes"+testCase.query, nil)
+
+			recorder := httptest.NewRecorder()
+			handler.Changes(recorder, request)
+			result := recorder.Result()
+
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.statusCode, result.StatusCode)
+			assert.Equal(t, testCase.body, strings.TrimSuffix(string(body), "\n"))
+		})
+	}
+}
+
+func TestChangeFeedHandler_LongPolling(t *testing.T) {
+	t.Parallel()
+
+	backends := map[string]func(t *testing.T) repositoryWithChangeFeed{
+		"memory": func(t *testing.T) repositoryWithChangeFeed {
+			t.Helper()
+
+			return repositories.NewMemoryRepository()
+		},
+		"file": func(t *testing.T) repositoryWithChangeFeed {
+			t.Helper()
+
+			return repositories.NewFileRepository(filepath.Join(t.TempDir(), "storage"), logger.NewNop())
+		},
+	}
+
+	for name, newRepository := range backends {
+		newRepository := newRepository
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rep := newRepository(t)
+			handler := handlers.NewChangeFeedHandler(configs.NewDefaultConfig(), rep, time.Millisecond, logger.NewNop())
+			userID := uuid.New()
+			ctx := context.Background()
+
+			shortURL := models.NewShortURL(0, "https://example.com/", "abc", userID)
+			require.NoError(t, rep.Save(ctx, shortURL, 0))
+			require.NoError(t, rep.SetDisabled(ctx, "abc", true))
+
+			changes := getChanges(t, handler, "?since=0")
+			require.Len(t, changes, 2)
+			assert.Equal(t, int64(1), changes[0].Seq)
+			assert.Equal(t, models.ChangeTypeCreate, changes[0].Type)
+			assert.False(t, changes[0].IsDisabled)
+			assert.Equal(t, int64(2), changes[1].Seq)
+			assert.Equal(t, models.ChangeTypeUpdate, changes[1].Type)
+			assert.True(t, changes[1].IsDisabled)
+
+			received := make(chan []changeJSON)
+
+			go func() {
+				received <- getChanges(t, handler, "?since=2&wait=10")
+			}()
+
+			select {
+			case <-received:
+				t.Fatal("long polling returned before a change")
+			case <-time.After(20 * time.Millisecond):
+			}
+
+			require.NoError(t, rep.BatchDelete(ctx, []*models.ShortURL{shortURL}))
+
+			changes = <-received
+			require.Len(t, changes, 1)
+			assert.Equal(t, int64(3), changes[0].Seq)
+			assert.Equal(t, models.ChangeTypeDelete, changes[0].Type)
+			assert.True(t, changes[0].IsDeleted)
+		})
+	}
+}
+
+type repositoryWithChangeFeed interface {
+	repositories.Repository
+	repositories.ChangeFeedRepository
+}
+
+type changeJSON struct {
+	Seq        int64  `json:"seq"`
+	Type       string `json:"type"`
+	IsDeleted  bool   `json:"is_deleted"`
+	IsDisabled bool   `json:"is_disabled"`
+}
+
+func TestChangeFeedHandler_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	handler := handlers.NewChangeFeedHandler(
+		configs.NewDefaultConfig(),
+		repositories.NewMemoryRepository(),
+		time.Millisecond,
+		logger.NewNop(),
+	)
+
+	statusCodes := make(chan int)
+
+	go func() {
+		recorder := httptest.NewRecorder()
+		handler.Changes(recorder, httptest.NewRequest(http.MethodGet, "/api/admin/changes?wait=60", nil))
+		statusCodes <- recorder.Code
+	}()
+
+	select {
+	case <-statusCodes:
+		t.Fatal("long polling returned before shutdown")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	handler.Shutdown()
+	handler.Shutdown()
+
+	select {
+	case statusCode := <-statusCodes:
+		assert.Equal(t, http.StatusNoContent, statusCode)
+	case <-time.After(time.Second):
+		t.Fatal("long polling is not ended by shutdown")
+	}
+
+	// Requests after the shutdown do not wait.
+	recorder := httptest.NewRecorder()
+	handler.Changes(recorder, httptest.NewRequest(http.MethodGet, "/api/admin/changes?wait=60", nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+}
+
+func getChanges(t *testing.T, handler *handlers.ChangeFeedHandler, query string) []changeJSON {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/admin/changes"+query, nil)
+
+	recorder := httptest.NewRecorder()
+	handler.Changes(recorder, request)
+	result := recorder.Result()
+
+	defer func() {
+		assert.NoError(t, result.Body.Close())
+	}()
+
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+
+	var changes []changeJSON
+	assert.NoError(t, json.NewDecoder(result.Body).Decode(&changes))
+
+	return changes
+}
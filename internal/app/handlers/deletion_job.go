@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+const (
+	ParameterNameDeletionJobID = "id"
+
+	MessageIncorrectDeletionJobID = "incorrect deletion job ID"
+	MessageDeletionJobNotFound    = "deletion job not found"
+)
+
+// NewDeletionJobAcceptedResponseJSON builds a response for the accepted job with the URL to poll its status.
+func NewDeletionJobAcceptedResponseJSON(job *models.DeletionJob, baseURL string) interface{} {
+	response := struct {
+		ID        uuid.UUID `json:"id"`
+		Status    string    `json:"status"`
+		StatusURL string    `json:"status_url"`
+	}{
+		ID:        job.ID,
+		Status:    job.Status(),
+		StatusURL: baseURL + "/api/user/deletions/" + job.ID.String(),
+	}
+
+	return &response
+}
+
+// NewDeletionJobResponseJSON lists UIDs of the job by the status of their items, failed items come with reasons.
+func NewDeletionJobResponseJSON(job *models.DeletionJob) interface{} {
+	type failedItemResponseJSON struct {
+		UID    models.UID `json:"uid"`
+		Reason string     `json:"reason"`
+	}
+
+	response := struct {
+		ID        uuid.UUID                `json:"id"`
+		Status    string                   `json:"status"`
+		Pending   []models.UID             `json:"pending"`
+		Completed []models.UID             `json:"completed"`
+		Failed    []failedItemResponseJSON `json:"failed"`
+		CreatedAt time.Time                `json:"created_at"`
+		UpdatedAt time.Time                `json:"updated_at"`
+	}{
+		ID:        job.ID,
+		Status:    job.Status(),
+		Pending:   []models.UID{},
+		Completed: []models.UID{},
+		Failed:    []failedItemResponseJSON{},
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	}
+
+	for _, item := range job.Items {
+		switch item.Status {
+		case models.DeletionStatusPending:
+			response.Pending = append(response.Pending, item.UID)
+		case models.DeletionStatusCompleted:
+			response.Completed = append(response.Completed, item.UID)
+		case models.DeletionStatusFailed:
+			response.Failed = append(response.Failed, failedItemResponseJSON{UID: item.UID, Reason: item.Reason})
+		}
+	}
+
+	return &response
+}
+
+// DeletionJobHandler reports the progress of deletion jobs to their owners.
+type DeletionJobHandler struct {
+	rep              repositories.DeletionJobRepository
+	contextKeyUserID middlewares.ContextKey
+	log              *logger.Logger
+}
+
+func NewDeletionJobHandler(
+	rep repositories.DeletionJobRepository,
+	contextKeyUserID middlewares.ContextKey,
+	log *logger.Logger,
+) *DeletionJobHandler {
+	return &DeletionJobHandler{
+		rep:              rep,
+		contextKeyUserID: contextKeyUserID,
+		log:              log,
+	}
+}
+
+func (h DeletionJobHandler) Get(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
+	if !ok {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
+
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(request, ParameterNameDeletionJobID))
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeBadRequest,
+			MessageIncorrectDeletionJobID,
+		))
+
+		return
+	}
+
+	job, err := h.rep.FindDeletionJobByID(request.Context(), id)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	// Jobs of other users are reported as missing, so their IDs can not be probed.
+	if err != nil || job.UserID != userID {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusNotFound,
+			utils.ProblemCodeNotFound,
+			MessageDeletionJobNotFound,
+		))
+
+		return
+	}
+
+	writeJSON(writer, request, http.StatusOK, NewDeletionJobResponseJSON(job), h.log)
+}
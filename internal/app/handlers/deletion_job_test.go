@@ -0,0 +1,146 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/handlers"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/mocks"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+)
+
+func TestDeletionJobHandler_Get(t *testing.T) {
+	t.Parallel()
+
+	const contextKeyUserID middlewares.ContextKey = "userID"
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	userID := uuid.New()
+
+	// test case 2
+	id2 := uuid.New()
+	rep2 := mocks.NewMockDeletionJobRepository(ctrl)
+	rep2.EXPECT().FindDeletionJobByID(gomock.Any(), id2).Return(nil, repositories.ErrNotFound)
+
+	// test case 3
+	job3 := models.NewDeletionJob(uuid.New(), []models.UID{"abc"})
+	rep3 := mocks.NewMockDeletionJobRepository(ctrl)
+	rep3.EXPECT().FindDeletionJobByID(gomock.Any(), job3.ID).Return(job3, nil)
+
+	// test case 4
+	id4 := uuid.New()
+	rep4 := mocks.NewMockDeletionJobRepository(ctrl)
+	rep4.EXPECT().FindDeletionJobByID(gomock.Any(), id4).Return(nil, errors.New("connection refused"))
+
+	// test case 5
+	job5 := models.NewDeletionJob(userID, []models.UID{"abc", "def", "ghi"})
+	job5.Resolve([]models.UID{"abc"}, models.DeletionStatusCompleted, "")
+	job5.Resolve([]models.UID{"def"}, models.DeletionStatusFailed, "URL not found")
+	rep5 := mocks.NewMockDeletionJobRepository(ctrl)
+	rep5.EXPECT().FindDeletionJobByID(gomock.Any(), job5.ID).Return(job5, nil)
+
+	tests := []struct {
+		name       string
+		rep        repositories.DeletionJobRepository
+		id         string
+		statusCode int
+		body       string
+	}{
+		{
+			name:       "test case 1: incorrect id",
+			rep:        mocks.NewMockDeletionJobRepository(ctrl),
+			id:         "bad-id",
+			statusCode: http.StatusBadRequest,
+			body:       "",
+		},
+		{
+			name:       "test case 2: not found",
+			rep:        rep2,
+			id:         id2.String(),
+			statusCode: http.StatusNotFound,
+			body:       "",
+		},
+		{
+			name:       "test case 3: job of another user",
+			rep:        rep3,
+			id:         job3.ID.String(),
+			statusCode: http.StatusNotFound,
+			body:       "",
+		},
+		{
+			name:       "test case 4: repository error",
+			rep:        rep4,
+			id:         id4.String(),
+			statusCode: http.StatusInternalServerError,
+			body:       "",
+		},
+		{
+			name:       "test case 5: found",
+			rep:        rep5,
+			id:         job5.ID.String(),
+			statusCode: http.StatusOK,
+			body: `{
+				"id": "` + job5.ID.String() + `",
+				"status": "pending",
+				"pending": ["ghi"],
+				"completed": ["abc"],
+				"failed": [{"uid": "def", "reason": "URL not found"}],
+				"created_at": ` + marshalTime(t, job5.CreatedAt.MarshalJSON) + `,
+				"updated_at": ` + marshalTime(t, job5.UpdatedAt.MarshalJSON) + `
+			}`,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := handlers.NewDeletionJobHandler(testCase.rep, contextKeyUserID, logger.NewNop())
+
+			routeContext := chi.NewRouteContext()
+			routeContext.URLParams.Add(handlers.ParameterNameDeletionJobID, testCase.id)
+
+			request := httptest.NewRequest(http.MethodGet, "/api/user/deletions/"+testCase.id, nil)
+			ctx := context.WithValue(request.Context(), chi.RouteCtxKey, routeContext)
+			request = request.WithContext(context.WithValue(ctx, contextKeyUserID, userID))
+
+			recorder := httptest.NewRecorder()
+			handler.Get(recorder, request)
+			result := recorder.Result()
+
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.statusCode, result.StatusCode)
+
+			if testCase.statusCode == http.StatusOK {
+				assert.Equal(t, handlers.ContentTypeJSON, handlers.GetContentType(result))
+				assert.JSONEq(t, testCase.body, string(body))
+			}
+		})
+	}
+}
+
+func marshalTime(t *testing.T, marshal func() ([]byte, error)) string {
+	t.Helper()
+
+	data, err := marshal()
+	require.NoError(t, err)
+
+	return string(data)
+}
@@ -1,11 +1,20 @@
 package handlers
 
 import (
+	"bytes"
 	"compress/gzip"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
 )
 
 const (
@@ -16,7 +25,27 @@ const (
 	MessageIncorrectUserID = "incorrect user ID"
 	MessageURLWasDeleted   = "URL was deleted"
 
+	MessageURLPolicyViolation = "URL policy violation"
+	MessageURLIsBlocked       = "URL is blocked"
+
+	MessageURLHasExpired   = "URL has expired"
+	MessageIncorrectTTL    = "incorrect TTL"
+	MessageQuotaExceeded   = "link quota exceeded"
+	MessageBatchIsTooLarge = "batch is too large"
+
+	MessageDeletionQueueFull = "deletion queue is full"
+
+	MessageURLIsDisabled = "URL is disabled"
+
+	MessageSourceUserIsBanned = "links of a banned user can't be moved"
+
+	MessageURLIsShortened     = "URL is shortened already"
+	MessageInvalidRequestBody = "invalid request body"
+
+	messageRequestFailed = "request failed"
+
 	ContentTypeText = "text/plain"
+	ContentTypeHTML = "text/html"
 	ContentTypeJSON = "application/json"
 	ContentTypeGZIP = "application/x-gzip"
 
@@ -44,3 +73,131 @@ func getRequestReader(request *http.Request) (io.ReadCloser, error) {
 
 	return request.Body, nil
 }
+
+/*
+refuseBannedSource rejects the request if the user whose links it moves is banned, moving the links would lift the ban
+from them. It reports whether the links may be moved.
+*/
+func refuseBannedSource(
+	writer http.ResponseWriter,
+	request *http.Request,
+	rep repositories.Repository,
+	fromUserID uuid.UUID,
+	log *logger.Logger,
+) bool {
+	_, err := rep.FindBanByUserID(request.Context(), fromUserID)
+	if err == nil {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusForbidden,
+			utils.ProblemCodeUserIsBanned,
+			MessageSourceUserIsBanned,
+		))
+
+		return false
+	}
+
+	if !errors.Is(err, repositories.ErrNotFound) {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return false
+	}
+
+	return true
+}
+
+func writeURLPolicyError(
+	writer http.ResponseWriter,
+	request *http.Request,
+	err error,
+	pointer string,
+	log *logger.Logger,
+) {
+	var policyErr *utils.URLPolicyError
+	if !errors.As(err, &policyErr) {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	problem := utils.NewProblem(
+		http.StatusBadRequest,
+		utils.ProblemCodeURLPolicyViolation,
+		fmt.Sprintf("%s (%s): %s", MessageURLPolicyViolation, policyErr.Rule, policyErr.Message),
+	)
+
+	if pointer != "" {
+		problem.Errors = []utils.ProblemField{{Pointer: pointer, Detail: policyErr.Message}}
+	}
+
+	utils.WriteError(writer, request, problem)
+}
+
+func writeQuotaExceeded(writer http.ResponseWriter, request *http.Request, quota *models.Quota) {
+	utils.WriteError(writer, request, utils.NewProblem(
+		http.StatusForbidden,
+		utils.ProblemCodeQuotaExceeded,
+		fmt.Sprintf(
+			"%s: plan %q allows %d active links, %d used",
+			MessageQuotaExceeded,
+			quota.Plan.Name,
+			quota.Plan.MaxLinks,
+			quota.UsedLinks,
+		),
+	))
+}
+
+// writeUserQuotaExceeded rejects the request with the current quota of the user.
+func writeUserQuotaExceeded(
+	writer http.ResponseWriter,
+	request *http.Request,
+	quotaManager utils.QuotaManager,
+	userID uuid.UUID,
+	log *logger.Logger,
+) {
+	quota, err := quotaManager.GetQuota(request.Context(), userID)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writeQuotaExceeded(writer, request, quota)
+}
+
+// writeFieldError rejects the request because of the invalid member of the request body at pointer.
+func writeFieldError(writer http.ResponseWriter, request *http.Request, message, pointer string) {
+	utils.WriteError(
+		writer,
+		request,
+		utils.NewValidationProblem(MessageInvalidRequestBody, []utils.FieldError{{Pointer: pointer, Message: message}}),
+	)
+}
+
+func writeJSON(
+	writer http.ResponseWriter,
+	request *http.Request,
+	statusCode int,
+	responseJSON interface{},
+	log *logger.Logger,
+) {
+	var buf bytes.Buffer
+	jsonEncoder := json.NewEncoder(&buf)
+	jsonEncoder.SetEscapeHTML(false)
+
+	if err := jsonEncoder.Encode(responseJSON); err != nil {
+		utils.WriteProblem(writer, request, utils.NewInternalProblem())
+		log.Ctx(request.Context()).Error("failed to encode response", logger.KeyError, err)
+
+		return
+	}
+
+	writer.Header().Set("Content-Type", ContentTypeJSON)
+	writer.WriteHeader(statusCode)
+
+	if _, err := buf.WriteTo(writer); err != nil {
+		log.Ctx(request.Context()).Error("failed to write response", logger.KeyError, err)
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+/*
+HealthHandler serves probes of the orchestrator. They are served outside authentication,
+so probing never mints a JWT cookie.
+*/
+type HealthHandler struct {
+	checker *utils.HealthChecker
+	log     *logger.Logger
+}
+
+func NewHealthHandler(checker *utils.HealthChecker, log *logger.Logger) *HealthHandler {
+	return &HealthHandler{
+		checker: checker,
+		log:     log,
+	}
+}
+
+// Liveness answers while the process serves requests, it does not depend on other components.
+func (h HealthHandler) Liveness(writer http.ResponseWriter, request *http.Request) {
+	report := &utils.HealthReport{
+		Status: utils.HealthStatusOK,
+		Checks: map[string]utils.HealthCheckReport{},
+	}
+
+	writeJSON(writer, request, http.StatusOK, report, h.log)
+}
+
+// Readiness reports checks of the components, 503 Service Unavailable is returned if any of them fails.
+func (h HealthHandler) Readiness(writer http.ResponseWriter, request *http.Request) {
+	report := h.checker.Check(request.Context())
+
+	if !report.IsOK() {
+		h.log.Ctx(request.Context()).Warn("service is not ready", "checks", report.Checks)
+		writeJSON(writer, request, http.StatusServiceUnavailable, report, h.log)
+
+		return
+	}
+
+	writeJSON(writer, request, http.StatusOK, report, h.log)
+}
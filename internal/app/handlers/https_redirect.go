@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const httpsDefaultPort = "443"
+
+// HTTPSRedirectHandler redirects plain HTTP requests to the same URL on the HTTPS listener.
+type HTTPSRedirectHandler struct {
+	httpsPort string
+}
+
+// NewHTTPSRedirectHandler takes the address of the HTTPS listener, only its port is used.
+func NewHTTPSRedirectHandler(httpsAddress string) *HTTPSRedirectHandler {
+	_, port, err := net.SplitHostPort(httpsAddress)
+	if err != nil {
+		port = httpsDefaultPort
+	}
+
+	return &HTTPSRedirectHandler{
+		httpsPort: port,
+	}
+}
+
+func (h HTTPSRedirectHandler) Redirect(writer http.ResponseWriter, request *http.Request) {
+	host := request.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	host = strings.Trim(host, "[]")
+
+	if h.httpsPort != httpsDefaultPort {
+		host = net.JoinHostPort(host, h.httpsPort)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	target := url.URL{
+		Scheme:   "https",
+		Host:     host,
+		Path:     request.URL.Path,
+		RawPath:  request.URL.RawPath,
+		RawQuery: request.URL.RawQuery,
+	}
+
+	http.Redirect(writer, request, target.String(), http.StatusPermanentRedirect)
+}
@@ -0,0 +1,64 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/handlers"
+)
+
+func TestHTTPSRedirectHandler_Redirect(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		httpsAddress string
+		target       string
+		host         string
+		location     string
+	}{
+		{
+			name:         "test case 1: default port",
+			httpsAddress: ":443",
+			target:       "/abcde?utm=1",
+			host:         "example.com",
+			location:     "https://example.com/abcde?utm=1",
+		},
+		{
+			name:         "test case 2: custom port replaces the port of the request",
+			httpsAddress: "localhost:8443",
+			target:       "/api/user/urls",
+			host:         "localhost:8080",
+			location:     "https://localhost:8443/api/user/urls",
+		},
+		{
+			name:         "test case 3: IPv6 host",
+			httpsAddress: "[::1]:8443",
+			target:       "/",
+			host:         "[::1]:8080",
+			location:     "https://[::1]:8443/",
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := handlers.NewHTTPSRedirectHandler(testCase.httpsAddress)
+
+			request := httptest.NewRequest(http.MethodPost, testCase.target, nil)
+			request.Host = testCase.host
+
+			recorder := httptest.NewRecorder()
+			handler.Redirect(recorder, request)
+			result := recorder.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, http.StatusPermanentRedirect, result.StatusCode)
+			assert.Equal(t, testCase.location, result.Header.Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/metrics"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+// MetricsHandler serves metrics in the Prometheus text format. Access may be restricted by the TrustedSubnet middleware.
+type MetricsHandler struct {
+	registry *metrics.Registry
+	log      *logger.Logger
+}
+
+func NewMetricsHandler(registry *metrics.Registry, log *logger.Logger) *MetricsHandler {
+	return &MetricsHandler{
+		registry: registry,
+		log:      log,
+	}
+}
+
+func (h MetricsHandler) Metrics(writer http.ResponseWriter, request *http.Request) {
+	var buf bytes.Buffer
+
+	if err := h.registry.WriteText(&buf); err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writer.Header().Set("Content-Type", metrics.ContentTypeText)
+	writer.WriteHeader(http.StatusOK)
+
+	if _, err := buf.WriteTo(writer); err != nil {
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+	}
+}
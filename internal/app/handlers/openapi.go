@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/tmitry/shorturl/internal/app/logger"
+)
+
+// OpenAPIHandler serves the OpenAPI document of the service.
+type OpenAPIHandler struct {
+	document []byte
+	log      *logger.Logger
+}
+
+func NewOpenAPIHandler(document []byte, log *logger.Logger) *OpenAPIHandler {
+	return &OpenAPIHandler{
+		document: document,
+		log:      log,
+	}
+}
+
+func (h OpenAPIHandler) Document(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", ContentTypeJSON)
+	writer.WriteHeader(http.StatusOK)
+
+	if _, err := writer.Write(h.document); err != nil {
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+	}
+}
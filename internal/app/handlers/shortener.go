@@ -3,13 +3,16 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"html/template"
 	"io"
-	"log"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/metrics"
 	"github.com/tmitry/shorturl/internal/app/middlewares"
 	"github.com/tmitry/shorturl/internal/app/models"
 	"github.com/tmitry/shorturl/internal/app/repositories"
@@ -18,42 +21,76 @@ import (
 
 const (
 	ParameterNameUID = "uid"
+
+	blockedURLWarningPage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Warning</title></head>
+<body>
+<h1>This link may be unsafe</h1>
+<p>The destination of this short link matches our abuse blocklist.</p>
+<p>If you trust it, you can continue to <a href="{{.}}" rel="noopener noreferrer nofollow">{{.}}</a>.</p>
+</body>
+</html>
+`
 )
 
 type ShortenerHandler struct {
 	cfg              *configs.Config
 	uidGenerator     utils.UIDGenerator
+	urlNormalizer    utils.URLNormalizer
+	urlPolicy        utils.URLPolicy
+	blocklist        utils.Blocklist
+	quotaManager     utils.QuotaManager
 	rep              repositories.Repository
 	contextKeyUserID middlewares.ContextKey
+	warningTemplate  *template.Template
+	webhooks         utils.WebhookDispatcher
+	metrics          *metrics.Metrics
+	log              *logger.Logger
 }
 
 func NewShortenerHandler(
 	cfg *configs.Config,
 	uidGenerator utils.UIDGenerator,
+	urlNormalizer utils.URLNormalizer,
+	urlPolicy utils.URLPolicy,
+	blocklist utils.Blocklist,
+	quotaManager utils.QuotaManager,
 	rep repositories.Repository,
 	contextKeyUserID middlewares.ContextKey,
+	webhooks utils.WebhookDispatcher,
+	appMetrics *metrics.Metrics,
+	log *logger.Logger,
 ) *ShortenerHandler {
 	return &ShortenerHandler{
 		cfg:              cfg,
 		uidGenerator:     uidGenerator,
+		urlNormalizer:    urlNormalizer,
+		urlPolicy:        urlPolicy,
+		blocklist:        blocklist,
+		quotaManager:     quotaManager,
 		rep:              rep,
 		contextKeyUserID: contextKeyUserID,
+		warningTemplate:  template.Must(template.New("warning").Parse(blockedURLWarningPage)),
+		webhooks:         webhooks,
+		metrics:          appMetrics,
+		log:              log,
 	}
 }
 
 func (h ShortenerHandler) Shorten(writer http.ResponseWriter, request *http.Request) {
 	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
 	if !ok {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(MessageIncorrectUserID)
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
 
 		return
 	}
 
 	reader, err := getRequestReader(request)
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
@@ -61,44 +98,74 @@ func (h ShortenerHandler) Shorten(writer http.ResponseWriter, request *http.Requ
 	defer func(reader io.ReadCloser) {
 		err := reader.Close()
 		if err != nil {
-			http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			log.Println(err.Error())
+			utils.WriteError(writer, request, utils.NewInternalProblem())
+			h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 		}
 	}(reader)
 
 	body, err := io.ReadAll(reader)
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
 
 	url := models.URL(body)
 	if !url.IsValid() {
-		http.Error(writer, fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), MessageIncorrectURL),
-			http.StatusBadRequest)
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeValidationFailed,
+			MessageIncorrectURL,
+		))
+
+		return
+	}
+
+	canonicalURL, err := h.urlNormalizer.Normalize(url)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeValidationFailed,
+			MessageIncorrectURL,
+		))
 
 		return
 	}
 
+	if err := h.urlPolicy.Check(url, canonicalURL); err != nil {
+		writeURLPolicyError(writer, request, err, "", h.log)
+
+		return
+	}
+
+	plan := h.quotaManager.GetPlan(userID)
+
 	statusCode := http.StatusCreated
 
 	uid, err := h.uidGenerator.Generate()
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
 
 	shortURL := models.NewShortURL(0, url, uid, userID)
+	shortURL.CanonicalURL = canonicalURL
+	shortURL.ExpiresAt = plan.ExpiresAt(0, time.Now())
 
-	err = h.rep.Save(request.Context(), shortURL)
+	err = h.rep.Save(request.Context(), shortURL, plan.MaxLinks)
 	if err != nil {
+		if errors.Is(err, repositories.ErrQuotaExceeded) {
+			writeUserQuotaExceeded(writer, request, h.quotaManager, userID, h.log)
+
+			return
+		}
+
 		if !errors.Is(err, repositories.ErrURLDuplicate) {
-			http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			log.Println(err.Error())
+			utils.WriteError(writer, request, utils.NewInternalProblem())
+			h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 			return
 		}
@@ -111,8 +178,8 @@ func (h ShortenerHandler) Shorten(writer http.ResponseWriter, request *http.Requ
 
 	_, err = writer.Write([]byte(shortURL.GetShortURL(h.cfg.Server.BaseURL)))
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
@@ -123,18 +190,20 @@ func (h ShortenerHandler) Redirect(writer http.ResponseWriter, request *http.Req
 
 	isValid, err := h.uidGenerator.IsValid(uid)
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		h.metrics.ObserveRedirect(metrics.RedirectResultError)
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
 
 	if !isValid {
-		http.Error(
-			writer,
-			fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), MessageIncorrectUID),
+		h.metrics.ObserveRedirect(metrics.RedirectResultNotFound)
+		utils.WriteError(writer, request, utils.NewProblem(
 			http.StatusBadRequest,
-		)
+			utils.ProblemCodeBadRequest,
+			MessageIncorrectUID,
+		))
 
 		return
 	}
@@ -142,40 +211,122 @@ func (h ShortenerHandler) Redirect(writer http.ResponseWriter, request *http.Req
 	shortURL, err := h.rep.FindOneByUID(request.Context(), uid)
 	if err != nil {
 		if errors.Is(err, repositories.ErrNotFound) {
-			http.Error(
-				writer,
-				fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), MessageURLNotFound),
+			h.metrics.ObserveRedirect(metrics.RedirectResultNotFound)
+			utils.WriteError(writer, request, utils.NewProblem(
 				http.StatusBadRequest,
-			)
+				utils.ProblemCodeNotFound,
+				MessageURLNotFound,
+			))
 
 			return
 		}
 
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		h.metrics.ObserveRedirect(metrics.RedirectResultError)
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
 
 	if shortURL.IsDeleted {
-		http.Error(
-			writer,
-			fmt.Sprintf("%s: %s", http.StatusText(http.StatusGone), MessageURLWasDeleted),
-			http.StatusGone,
-		)
+		h.metrics.ObserveRedirect(metrics.RedirectResultGone)
+		utils.WriteError(writer, request, utils.NewProblem(http.StatusGone, utils.ProblemCodeNotFound, MessageURLWasDeleted))
+
+		return
+	}
+
+	if shortURL.IsExpired(time.Now()) {
+		h.metrics.ObserveRedirect(metrics.RedirectResultGone)
+		utils.WriteError(writer, request, utils.NewProblem(http.StatusGone, utils.ProblemCodeNotFound, MessageURLHasExpired))
+
+		return
+	}
+
+	// Links of banned users are disabled as a whole, the ban is lifted without touching the links.
+	isBanned, err := h.isOwnerBanned(request, shortURL)
+	if err != nil {
+		h.metrics.ObserveRedirect(metrics.RedirectResultError)
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	if shortURL.IsDisabled || isBanned {
+		if isBanned {
+			h.metrics.ObserveRedirect(metrics.RedirectResultBanned)
+		} else {
+			h.metrics.ObserveRedirect(metrics.RedirectResultDisabled)
+		}
+
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusForbidden,
+			utils.ProblemCodeForbidden,
+			MessageURLIsDisabled,
+		))
+
+		return
+	}
+
+	if _, ok := h.blocklist.Match(shortURL.URL); ok {
+		h.writeBlocked(writer, request, shortURL)
 
 		return
 	}
 
+	// A failure to count the click must not break the redirect.
+	if err := h.rep.RegisterClick(request.Context(), shortURL.UID); err != nil {
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+	}
+
+	h.webhooks.Emit(request.Context(), models.NewWebhookEvent(models.WebhookEventLinkClicked, shortURL))
+
+	h.metrics.ObserveRedirect(metrics.RedirectResultServed)
+
 	writer.Header().Set("Location", shortURL.URL.String())
 	writer.Header().Set("Content-Type", ContentTypeText)
 	writer.WriteHeader(http.StatusTemporaryRedirect)
 }
 
+func (h ShortenerHandler) isOwnerBanned(request *http.Request, shortURL *models.ShortURL) (bool, error) {
+	_, err := h.rep.FindBanByUserID(request.Context(), shortURL.UserID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to find ban: %w", err)
+	}
+
+	return true, nil
+}
+
+func (h ShortenerHandler) writeBlocked(writer http.ResponseWriter, request *http.Request, shortURL *models.ShortURL) {
+	if h.cfg.Policy.BlocklistAction != configs.BlocklistActionWarn {
+		h.metrics.ObserveRedirect(metrics.RedirectResultBlocked)
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusUnavailableForLegalReasons,
+			utils.ProblemCodeURLPolicyViolation,
+			MessageURLIsBlocked,
+		))
+
+		return
+	}
+
+	h.metrics.ObserveRedirect(metrics.RedirectResultWarned)
+
+	writer.Header().Set("Content-Type", ContentTypeHTML)
+	writer.WriteHeader(http.StatusOK)
+
+	if err := h.warningTemplate.Execute(writer, shortURL.URL.String()); err != nil {
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+	}
+}
+
 func (h ShortenerHandler) Ping(writer http.ResponseWriter, request *http.Request) {
 	if err := h.rep.Ping(request.Context()); err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
@@ -2,15 +2,18 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/logger"
 	"github.com/tmitry/shorturl/internal/app/middlewares"
 	"github.com/tmitry/shorturl/internal/app/models"
 	"github.com/tmitry/shorturl/internal/app/repositories"
@@ -19,11 +22,13 @@ import (
 
 type shortenRequestJSON struct {
 	URL models.URL `json:"url"`
+	TTL int        `json:"ttl"` // Requested lifetime (in seconds) of the link, capped by the plan.
 }
 
 func newShortenRequestJSON() *shortenRequestJSON {
 	return &shortenRequestJSON{
 		URL: "",
+		TTL: 0,
 	}
 }
 
@@ -54,21 +59,70 @@ func NewUserUrlsResponseJSON(userShortURLs []*models.ShortURL, baseURL string) i
 type shortenBatchItemRequestJSON struct {
 	CorrelationID string     `json:"correlation_id"`
 	OriginalURL   models.URL `json:"original_url"`
+	TTL           int        `json:"ttl"`
 }
 
 type ShortenBatchRequestJSON []shortenBatchItemRequestJSON
 
+type shortenBatchItemResponseJSON struct {
+	CorrelationID string     `json:"correlation_id"`
+	ShortURL      models.URL `json:"short_url,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
 func NewShortenBatchResponseJSON(shortURLs []*models.ShortURL, correlationIDs []string, baseURL string) interface{} {
-	response := make([]struct {
-		CorrelationID string     `json:"correlation_id"`
-		ShortURL      models.URL `json:"short_url"`
-	}, 0, len(shortURLs))
+	return NewPartialShortenBatchResponseJSON(shortURLs, correlationIDs, nil, "", baseURL)
+}
+
+// NewPartialShortenBatchResponseJSON builds a response for a batch whose items over the quota were rejected.
+func NewPartialShortenBatchResponseJSON(
+	shortURLs []*models.ShortURL,
+	correlationIDs []string,
+	rejectedCorrelationIDs []string,
+	reason string,
+	baseURL string,
+) interface{} {
+	response := make([]shortenBatchItemResponseJSON, 0, len(shortURLs)+len(rejectedCorrelationIDs))
 
 	for index, userShortURL := range shortURLs {
-		response = append(response, struct {
-			CorrelationID string     `json:"correlation_id"`
-			ShortURL      models.URL `json:"short_url"`
-		}{CorrelationID: correlationIDs[index], ShortURL: userShortURL.GetShortURL(baseURL)})
+		response = append(response, shortenBatchItemResponseJSON{
+			CorrelationID: correlationIDs[index],
+			ShortURL:      userShortURL.GetShortURL(baseURL),
+			Error:         "",
+		})
+	}
+
+	for _, correlationID := range rejectedCorrelationIDs {
+		response = append(response, shortenBatchItemResponseJSON{
+			CorrelationID: correlationID,
+			ShortURL:      "",
+			Error:         reason,
+		})
+	}
+
+	return &response
+}
+
+func NewQuotaResponseJSON(quota *models.Quota) interface{} {
+	response := struct {
+		Plan           string `json:"plan"`
+		MaxLinks       int    `json:"max_links"`
+		MaxBatchSize   int    `json:"max_batch_size"`
+		MaxTTL         int    `json:"max_ttl"`
+		UsedLinks      int    `json:"used_links"`
+		RemainingLinks *int   `json:"remaining_links"`
+	}{
+		Plan:           quota.Plan.Name,
+		MaxLinks:       quota.Plan.MaxLinks,
+		MaxBatchSize:   quota.Plan.MaxBatchSize,
+		MaxTTL:         int(quota.Plan.MaxTTL / time.Second),
+		UsedLinks:      quota.UsedLinks,
+		RemainingLinks: nil,
+	}
+
+	// Null remaining links means that the plan has no limit.
+	if remainingLinks := quota.RemainingLinks(); remainingLinks >= 0 {
+		response.RemainingLinks = &remainingLinks
 	}
 
 	return &response
@@ -77,40 +131,52 @@ func NewShortenBatchResponseJSON(shortURLs []*models.ShortURL, correlationIDs []
 type ShortenerAPIHandler struct {
 	cfg              *configs.Config
 	uidGenerator     utils.UIDGenerator
+	urlNormalizer    utils.URLNormalizer
+	urlPolicy        utils.URLPolicy
+	quotaManager     utils.QuotaManager
 	rep              repositories.Repository
 	contextKeyUserID middlewares.ContextKey
 	deletionBuffer   utils.DeletionBuffer
+	log              *logger.Logger
 }
 
 func NewShortenerAPIHandler(
 	cfg *configs.Config,
 	uidGenerator utils.UIDGenerator,
+	urlNormalizer utils.URLNormalizer,
+	urlPolicy utils.URLPolicy,
+	quotaManager utils.QuotaManager,
 	rep repositories.Repository,
 	contextKeyUserID middlewares.ContextKey,
 	deletionBuffer utils.DeletionBuffer,
+	log *logger.Logger,
 ) *ShortenerAPIHandler {
 	return &ShortenerAPIHandler{
 		cfg:              cfg,
 		uidGenerator:     uidGenerator,
+		urlNormalizer:    urlNormalizer,
+		urlPolicy:        urlPolicy,
+		quotaManager:     quotaManager,
 		rep:              rep,
 		contextKeyUserID: contextKeyUserID,
 		deletionBuffer:   deletionBuffer,
+		log:              log,
 	}
 }
 
 func (h ShortenerAPIHandler) Shorten(writer http.ResponseWriter, request *http.Request) {
 	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
 	if !ok {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(MessageIncorrectUserID)
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
 
 		return
 	}
 
 	reader, err := getRequestReader(request)
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
@@ -118,51 +184,95 @@ func (h ShortenerAPIHandler) Shorten(writer http.ResponseWriter, request *http.R
 	defer func(reader io.ReadCloser) {
 		err := reader.Close()
 		if err != nil {
-			http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			log.Println(err.Error())
+			utils.WriteError(writer, request, utils.NewInternalProblem())
+			h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 		}
 	}(reader)
 
 	requestJSON := newShortenRequestJSON()
 	if err := json.NewDecoder(reader).Decode(requestJSON); err != nil {
-		http.Error(writer, fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), MessageIncorrectJSON),
-			http.StatusBadRequest)
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeIncorrectJSON,
+			MessageIncorrectJSON,
+		))
 
 		return
 	}
 
 	if !requestJSON.URL.IsValid() {
-		http.Error(writer, fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), MessageIncorrectURL),
-			http.StatusBadRequest)
+		writeFieldError(writer, request, MessageIncorrectURL, "/url")
+
+		return
+	}
+
+	if requestJSON.TTL < 0 {
+		writeFieldError(writer, request, MessageIncorrectTTL, "/ttl")
+
+		return
+	}
+
+	canonicalURL, err := h.urlNormalizer.Normalize(requestJSON.URL)
+	if err != nil {
+		writeFieldError(writer, request, MessageIncorrectURL, "/url")
+
+		return
+	}
+
+	if err := h.urlPolicy.Check(requestJSON.URL, canonicalURL); err != nil {
+		writeURLPolicyError(writer, request, err, "/url", h.log)
 
 		return
 	}
 
-	statusCode := http.StatusCreated
+	plan := h.quotaManager.GetPlan(userID)
 
 	uid, err := h.uidGenerator.Generate()
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
 
 	shortURL := models.NewShortURL(0, requestJSON.URL, uid, userID)
+	shortURL.CanonicalURL = canonicalURL
+	shortURL.ExpiresAt = plan.ExpiresAt(time.Duration(requestJSON.TTL)*time.Second, time.Now())
+
+	if err := h.rep.Save(request.Context(), shortURL, plan.MaxLinks); err != nil {
+		if errors.Is(err, repositories.ErrQuotaExceeded) {
+			writeUserQuotaExceeded(writer, request, h.quotaManager, userID, h.log)
+
+			return
+		}
 
-	if err := h.rep.Save(request.Context(), shortURL); err != nil {
 		if !errors.Is(err, repositories.ErrURLDuplicate) {
-			http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			log.Println(err.Error())
+			utils.WriteError(writer, request, utils.NewInternalProblem())
+			h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 			return
 		}
 
-		statusCode = http.StatusConflict
+		// Save replaces shortURL with the existing link.
+		existingShortURL := shortURL.GetShortURL(h.cfg.Server.BaseURL)
+
+		// Clients which do not ask for problem details get the existing short URL as before.
+		if !utils.IsProblemDetails(request.Context()) {
+			writeJSON(writer, request, http.StatusConflict, NewShortenResponseJSON(existingShortURL), h.log)
+
+			return
+		}
+
+		problem := utils.NewProblem(http.StatusConflict, utils.ProblemCode(err), MessageURLIsShortened)
+		problem.ShortURL = existingShortURL
+		problem.Result = existingShortURL
+		utils.WriteProblem(writer, request, problem)
+
+		return
 	}
 
 	writer.Header().Set("Content-Type", ContentTypeJSON)
-	writer.WriteHeader(statusCode)
+	writer.WriteHeader(http.StatusCreated)
 
 	responseJSON := NewShortenResponseJSON(shortURL.GetShortURL(h.cfg.Server.BaseURL))
 
@@ -172,16 +282,16 @@ func (h ShortenerAPIHandler) Shorten(writer http.ResponseWriter, request *http.R
 
 	err = jsonEncoder.Encode(responseJSON)
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
 
 	_, err = buf.WriteTo(writer)
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
@@ -190,8 +300,8 @@ func (h ShortenerAPIHandler) Shorten(writer http.ResponseWriter, request *http.R
 func (h ShortenerAPIHandler) UserUrls(writer http.ResponseWriter, request *http.Request) {
 	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
 	if !ok {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(MessageIncorrectUserID)
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
 
 		return
 	}
@@ -199,13 +309,13 @@ func (h ShortenerAPIHandler) UserUrls(writer http.ResponseWriter, request *http.
 	userShortURLs, err := h.rep.FindAllByUserID(request.Context(), userID)
 	if err != nil {
 		if errors.Is(err, repositories.ErrNotFound) {
-			http.Error(writer, http.StatusText(http.StatusNoContent), http.StatusNoContent)
+			writer.WriteHeader(http.StatusNoContent)
 
 			return
 		}
 
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
@@ -220,16 +330,16 @@ func (h ShortenerAPIHandler) UserUrls(writer http.ResponseWriter, request *http.
 
 	err = jsonEncoder.Encode(responseJSON)
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
 
 	_, err = buf.WriteTo(writer)
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
@@ -238,16 +348,16 @@ func (h ShortenerAPIHandler) UserUrls(writer http.ResponseWriter, request *http.
 func (h ShortenerAPIHandler) ShortenBatch(writer http.ResponseWriter, request *http.Request) {
 	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
 	if !ok {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(MessageIncorrectUserID)
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
 
 		return
 	}
 
 	reader, err := getRequestReader(request)
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
@@ -255,49 +365,127 @@ func (h ShortenerAPIHandler) ShortenBatch(writer http.ResponseWriter, request *h
 	defer func(reader io.ReadCloser) {
 		err := reader.Close()
 		if err != nil {
-			http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			log.Println(err.Error())
+			utils.WriteError(writer, request, utils.NewInternalProblem())
+			h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 		}
 	}(reader)
 
 	var requestJSON ShortenBatchRequestJSON
 
 	if err := json.NewDecoder(reader).Decode(&requestJSON); err != nil {
-		http.Error(writer, fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), MessageIncorrectJSON),
-			http.StatusBadRequest)
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeIncorrectJSON,
+			MessageIncorrectJSON,
+		))
+
+		return
+	}
+
+	plan := h.quotaManager.GetPlan(userID)
+	if plan.MaxBatchSize > 0 && len(requestJSON) > plan.MaxBatchSize {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeBatchTooLarge,
+			fmt.Sprintf("%s: plan %q allows %d items", MessageBatchIsTooLarge, plan.Name, plan.MaxBatchSize),
+		))
 
 		return
 	}
 
-	shortURLs := make([]*models.ShortURL, 0, len(requestJSON))
-	correlationIDs := make([]string, 0, len(requestJSON))
+	canonicalURLs := make([]models.URL, 0, len(requestJSON))
 
-	for _, item := range requestJSON {
+	for index, item := range requestJSON {
 		if !item.OriginalURL.IsValid() {
-			http.Error(writer, fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), MessageIncorrectURL),
-				http.StatusBadRequest)
+			writeFieldError(writer, request, MessageIncorrectURL, fmt.Sprintf("/%d/original_url", index))
+
+			return
+		}
+
+		if item.TTL < 0 {
+			writeFieldError(writer, request, MessageIncorrectTTL, fmt.Sprintf("/%d/ttl", index))
+
+			return
+		}
+
+		canonicalURL, err := h.urlNormalizer.Normalize(item.OriginalURL)
+		if err != nil {
+			writeFieldError(writer, request, MessageIncorrectURL, fmt.Sprintf("/%d/original_url", index))
+
+			return
+		}
+
+		if err := h.urlPolicy.Check(item.OriginalURL, canonicalURL); err != nil {
+			writeURLPolicyError(writer, request, err, fmt.Sprintf("/%d/original_url", index), h.log)
 
 			return
 		}
 
+		canonicalURLs = append(canonicalURLs, canonicalURL)
+	}
+
+	isOverQuota, quota, err := h.findOverQuota(request.Context(), userID, plan, canonicalURLs)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	rejectedItems := 0
+
+	for _, isRejected := range isOverQuota {
+		if isRejected {
+			rejectedItems++
+		}
+	}
+
+	if rejectedItems > 0 &&
+		(rejectedItems == len(requestJSON) || h.cfg.Quota.BatchMode != configs.QuotaBatchModePartial) {
+		writeQuotaExceeded(writer, request, quota)
+
+		return
+	}
+
+	shortURLs := make([]*models.ShortURL, 0, len(requestJSON)-rejectedItems)
+	correlationIDs := make([]string, 0, len(requestJSON)-rejectedItems)
+	rejectedCorrelationIDs := make([]string, 0, rejectedItems)
+	now := time.Now()
+
+	for index, item := range requestJSON {
+		if rejectedItems > 0 && isOverQuota[index] {
+			rejectedCorrelationIDs = append(rejectedCorrelationIDs, item.CorrelationID)
+
+			continue
+		}
+
 		uid, err := h.uidGenerator.Generate()
 		if err != nil {
-			http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			log.Println(err.Error())
+			utils.WriteError(writer, request, utils.NewInternalProblem())
+			h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 			return
 		}
 
 		shortURL := models.NewShortURL(0, item.OriginalURL, uid, userID)
+		shortURL.CanonicalURL = canonicalURLs[index]
+		shortURL.ExpiresAt = plan.ExpiresAt(time.Duration(item.TTL)*time.Second, now)
 
 		shortURLs = append(shortURLs, shortURL)
 		correlationIDs = append(correlationIDs, item.CorrelationID)
 	}
 
-	err = h.rep.BatchSave(request.Context(), shortURLs)
+	// The quota counted above is rechecked atomically, concurrent requests may have used it up.
+	err = h.rep.BatchSave(request.Context(), shortURLs, plan.MaxLinks)
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		if errors.Is(err, repositories.ErrQuotaExceeded) {
+			writeUserQuotaExceeded(writer, request, h.quotaManager, userID, h.log)
+
+			return
+		}
+
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
@@ -305,7 +493,13 @@ func (h ShortenerAPIHandler) ShortenBatch(writer http.ResponseWriter, request *h
 	writer.Header().Set("Content-Type", ContentTypeJSON)
 	writer.WriteHeader(http.StatusCreated)
 
-	responseJSON := NewShortenBatchResponseJSON(shortURLs, correlationIDs, h.cfg.Server.BaseURL)
+	responseJSON := NewPartialShortenBatchResponseJSON(
+		shortURLs,
+		correlationIDs,
+		rejectedCorrelationIDs,
+		MessageQuotaExceeded,
+		h.cfg.Server.BaseURL,
+	)
 
 	var buf bytes.Buffer
 	jsonEncoder := json.NewEncoder(&buf)
@@ -313,34 +507,80 @@ func (h ShortenerAPIHandler) ShortenBatch(writer http.ResponseWriter, request *h
 
 	err = jsonEncoder.Encode(responseJSON)
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
 
 	_, err = buf.WriteTo(writer)
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
 }
 
+/*
+findOverQuota reports which of canonicalURLs do not fit into the remaining quota of the user, nil if the plan
+has no limit. Like BatchSave, it counts only URLs which the user has not shortened yet, repeated ones once.
+*/
+func (h ShortenerAPIHandler) findOverQuota(
+	ctx context.Context,
+	userID uuid.UUID,
+	plan *models.Plan,
+	canonicalURLs []models.URL,
+) ([]bool, *models.Quota, error) {
+	if plan.MaxLinks <= 0 {
+		return nil, nil, nil
+	}
+
+	quota, err := h.quotaManager.GetQuota(ctx, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get quota: %w", err)
+	}
+
+	userShortURLs, err := h.rep.FindAllByUserID(ctx, userID)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return nil, nil, fmt.Errorf("failed to find links: %w", err)
+	}
+
+	isShortened := make(map[models.URL]bool, len(userShortURLs)+len(canonicalURLs))
+	for _, userShortURL := range userShortURLs {
+		isShortened[userShortURL.CanonicalURL] = true
+	}
+
+	remainingLinks := quota.RemainingLinks()
+	isOverQuota := make([]bool, len(canonicalURLs))
+
+	for index, canonicalURL := range canonicalURLs {
+		switch {
+		case isShortened[canonicalURL]:
+		case remainingLinks == 0:
+			isOverQuota[index] = true
+		default:
+			isShortened[canonicalURL] = true
+			remainingLinks--
+		}
+	}
+
+	return isOverQuota, quota, nil
+}
+
 func (h ShortenerAPIHandler) DeleteUserUrls(writer http.ResponseWriter, request *http.Request) {
 	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
 	if !ok {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(MessageIncorrectUserID)
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
 
 		return
 	}
 
 	reader, err := getRequestReader(request)
 	if err != nil {
-		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		log.Println(err.Error())
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
@@ -348,25 +588,64 @@ func (h ShortenerAPIHandler) DeleteUserUrls(writer http.ResponseWriter, request
 	defer func(reader io.ReadCloser) {
 		err := reader.Close()
 		if err != nil {
-			http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			log.Println(err.Error())
+			utils.WriteError(writer, request, utils.NewInternalProblem())
+			h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 		}
 	}(reader)
 
 	var uids []models.UID
 
 	if err := json.NewDecoder(reader).Decode(&uids); err != nil {
-		http.Error(
-			writer,
-			fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), MessageIncorrectJSON),
+		utils.WriteError(writer, request, utils.NewProblem(
 			http.StatusBadRequest,
-		)
+			utils.ProblemCodeIncorrectJSON,
+			MessageIncorrectJSON,
+		))
+
+		return
+	}
+
+	job, err := h.deletionBuffer.Push(request.Context(), uids, userID)
+	if errors.Is(err, utils.ErrDeletionQueueFull) {
+		// The queue has room again after workers take the next jobs, which happens within a flush.
+		writer.Header().Set("Retry-After", strconv.Itoa(h.cfg.App.DeletionBufferClearTimeout))
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusServiceUnavailable,
+			utils.ProblemCodeUnavailable,
+			MessageDeletionQueueFull,
+		))
+
+		return
+	}
+
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 		return
 	}
 
-	h.deletionBuffer.Push(uids, userID)
+	responseJSON := NewDeletionJobAcceptedResponseJSON(job, h.cfg.Server.BaseURL)
+
+	writeJSON(writer, request, http.StatusAccepted, responseJSON, h.log)
+}
+
+func (h ShortenerAPIHandler) Quota(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
+	if !ok {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
+
+		return
+	}
+
+	quota, err := h.quotaManager.GetQuota(request.Context(), userID)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
 
-	writer.Header().Set("Content-Type", ContentTypeText)
-	writer.WriteHeader(http.StatusAccepted)
+	writeJSON(writer, request, http.StatusOK, NewQuotaResponseJSON(quota), h.log)
 }
@@ -3,11 +3,14 @@ package handlers_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -16,6 +19,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/tmitry/shorturl/internal/app/configs"
 	"github.com/tmitry/shorturl/internal/app/handlers"
+	"github.com/tmitry/shorturl/internal/app/logger"
 	"github.com/tmitry/shorturl/internal/app/middlewares"
 	"github.com/tmitry/shorturl/internal/app/mocks"
 	"github.com/tmitry/shorturl/internal/app/models"
@@ -78,7 +82,7 @@ func TestShortenerAPIHandler_Shorten(t *testing.T) {
 	body4 := fmt.Sprintf(`{"url":"%s"}`, url4)
 	userID4 := uuid.New()
 	shortURL4 := models.NewShortURL(0, models.URL(url4), uid4, userID4)
-	rep4.EXPECT().Save(gomock.Any(), shortURL4).Return(nil)
+	rep4.EXPECT().Save(gomock.Any(), shortURL4, 0).Return(nil)
 
 	deletionBuffer4 := mocks.NewMockDeletionBuffer(ctrl)
 	json4, err := json.Marshal(handlers.NewShortenResponseJSON(shortURL4.GetShortURL(cfg4.Server.BaseURL)))
@@ -96,12 +100,64 @@ func TestShortenerAPIHandler_Shorten(t *testing.T) {
 	body5 := fmt.Sprintf(`{"url":"%s"}`, url5)
 	userID5 := uuid.New()
 	shortURL5 := models.NewShortURL(0, models.URL(url5), uid5, userID5)
-	rep5.EXPECT().Save(gomock.Any(), shortURL5).Return(repositories.ErrURLDuplicate)
+	rep5.EXPECT().Save(gomock.Any(), shortURL5, 0).Return(repositories.ErrURLDuplicate)
 
 	deletionBuffer5 := mocks.NewMockDeletionBuffer(ctrl)
 	json5, err := json.Marshal(handlers.NewShortenResponseJSON(shortURL5.GetShortURL(cfg5.Server.BaseURL)))
 	require.NoError(t, err)
 
+	// test case 6
+	cfg6 := configs.NewDefaultConfig()
+	cfg6.Server.BaseURL = "base-url"
+	uid6 := models.UID("CaNoNi")
+	uidGenerator6 := mocks.NewMockUIDGenerator(ctrl)
+	uidGenerator6.EXPECT().Generate().Return(uid6, nil)
+
+	rep6 := mocks.NewMockRepository(ctrl)
+	url6 := "HTTP://Example-Site.com:80/a?b=1&a=2"
+	body6 := fmt.Sprintf(`{"url":"%s"}`, url6)
+	userID6 := uuid.New()
+	shortURL6 := models.NewShortURL(0, models.URL(url6), uid6, userID6)
+	shortURL6.CanonicalURL = "http://example-site.com/a?a=2&b=1"
+	rep6.EXPECT().Save(gomock.Any(), shortURL6, 0).Return(nil)
+
+	deletionBuffer6 := mocks.NewMockDeletionBuffer(ctrl)
+	json6, err := json.Marshal(handlers.NewShortenResponseJSON(shortURL6.GetShortURL(cfg6.Server.BaseURL)))
+	require.NoError(t, err)
+
+	// test case 7
+	cfg7 := configs.NewDefaultConfig()
+	cfg7.Quota.Plans["free"] = configs.PlanConfig{MaxLinks: 2, MaxBatchSize: 0, MaxTTL: 0}
+	cfg7.Quota.DefaultPlan = "free"
+	uidGenerator7 := mocks.NewMockUIDGenerator(ctrl)
+	uidGenerator7.EXPECT().Generate().Return(models.UID("QuOtA7"), nil)
+
+	rep7 := mocks.NewMockRepository(ctrl)
+	userID7 := uuid.New()
+	rep7.EXPECT().Save(gomock.Any(), gomock.Any(), 2).Return(repositories.ErrQuotaExceeded)
+	rep7.EXPECT().CountActiveByUserID(gomock.Any(), userID7).Return(2, nil)
+
+	deletionBuffer7 := mocks.NewMockDeletionBuffer(ctrl)
+
+	// test case 8
+	cfg8 := configs.NewDefaultConfig()
+	cfg8.Server.BaseURL = "base-url"
+	cfg8.Quota.Plans["free"] = configs.PlanConfig{MaxLinks: 2, MaxBatchSize: 0, MaxTTL: 0}
+	cfg8.Quota.DefaultPlan = "free"
+	uid8 := models.UID("DuPlIc")
+	uidGenerator8 := mocks.NewMockUIDGenerator(ctrl)
+	uidGenerator8.EXPECT().Generate().Return(uid8, nil)
+
+	rep8 := mocks.NewMockRepository(ctrl)
+	url8 := "https://example-site.com/at-quota"
+	userID8 := uuid.New()
+	shortURL8 := models.NewShortURL(0, models.URL(url8), uid8, userID8)
+	rep8.EXPECT().Save(gomock.Any(), shortURL8, 2).Return(repositories.ErrURLDuplicate)
+
+	deletionBuffer8 := mocks.NewMockDeletionBuffer(ctrl)
+	json8, err := json.Marshal(handlers.NewShortenResponseJSON(shortURL8.GetShortURL(cfg8.Server.BaseURL)))
+	require.NoError(t, err)
+
 	tests := []struct {
 		name     string
 		fields   fields
@@ -166,7 +222,12 @@ func TestShortenerAPIHandler_Shorten(t *testing.T) {
 			response: response{
 				statusCode:  http.StatusBadRequest,
 				contentType: handlers.ContentTypeText,
-				body:        fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), handlers.MessageIncorrectURL),
+				body: fmt.Sprintf(
+					"%s: %s: /url: %s",
+					http.StatusText(http.StatusBadRequest),
+					handlers.MessageInvalidRequestBody,
+					handlers.MessageIncorrectURL,
+				),
 			},
 		},
 		{
@@ -207,6 +268,67 @@ func TestShortenerAPIHandler_Shorten(t *testing.T) {
 				body:        string(json5),
 			},
 		},
+		{
+			name: "test case 6: canonicalized before save",
+			fields: fields{
+				cfg:              cfg6,
+				uidGenerator:     uidGenerator6,
+				rep:              rep6,
+				contextKeyUserID: "userId",
+				deletionBuffer:   deletionBuffer6,
+			},
+			request: request{
+				body:   body6,
+				userID: userID6,
+			},
+			response: response{
+				statusCode:  http.StatusCreated,
+				contentType: handlers.ContentTypeJSON,
+				body:        string(json6),
+			},
+		},
+		{
+			name: "test case 7: quota exceeded",
+			fields: fields{
+				cfg:              cfg7,
+				uidGenerator:     uidGenerator7,
+				rep:              rep7,
+				contextKeyUserID: "userId",
+				deletionBuffer:   deletionBuffer7,
+			},
+			request: request{
+				body:   `{"url":"https://example7.com/"}`,
+				userID: userID7,
+			},
+			response: response{
+				statusCode:  http.StatusForbidden,
+				contentType: handlers.ContentTypeText,
+				body: fmt.Sprintf(
+					"%s: %s: plan \"free\" allows 2 active links, 2 used",
+					http.StatusText(http.StatusForbidden),
+					handlers.MessageQuotaExceeded,
+				),
+			},
+		},
+		{
+			name: "test case 8: duplicate at quota",
+			fields: fields{
+				cfg:              cfg8,
+				uidGenerator:     uidGenerator8,
+				rep:              rep8,
+				contextKeyUserID: "userId",
+				deletionBuffer:   deletionBuffer8,
+			},
+			request: request{
+				body:   fmt.Sprintf(`{"url":"%s"}`, url8),
+				userID: userID8,
+			},
+			response: response{
+				statusCode:  http.StatusConflict,
+				contentType: handlers.ContentTypeJSON,
+				body:        string(json8),
+			},
+		},
 	}
 	for _, testCase := range tests {
 		testCase := testCase
@@ -216,9 +338,17 @@ func TestShortenerAPIHandler_Shorten(t *testing.T) {
 			shortenerAPIHandler := handlers.NewShortenerAPIHandler(
 				testCase.fields.cfg,
 				testCase.fields.uidGenerator,
+				utils.NewConfigurableURLNormalizer(testCase.fields.cfg.Policy),
+				utils.NewConfigurableURLPolicy(
+					testCase.fields.cfg.Policy,
+					testCase.fields.cfg.Server.BaseURL,
+					utils.NewFileBlocklist("", 0, logger.NewNop()),
+				),
+				utils.NewConfigQuotaManager(testCase.fields.cfg.Quota, testCase.fields.rep, logger.NewNop()),
 				testCase.fields.rep,
 				testCase.fields.contextKeyUserID,
 				testCase.fields.deletionBuffer,
+				logger.NewNop(),
 			)
 
 			requestAPIShorten := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(testCase.request.body))
@@ -245,6 +375,73 @@ func TestShortenerAPIHandler_Shorten(t *testing.T) {
 	}
 }
 
+func TestShortenerAPIHandler_Shorten_ConcurrentQuota(t *testing.T) {
+	t.Parallel()
+
+	const requests = 10
+
+	cfg := configs.NewDefaultConfig()
+	cfg.Quota.Plans["free"] = configs.PlanConfig{MaxLinks: 2, MaxBatchSize: 0, MaxTTL: 0}
+	cfg.Quota.DefaultPlan = "free"
+
+	rep := repositories.NewMemoryRepository()
+	userID := uuid.New()
+	contextKeyUserID := middlewares.ContextKey("userId")
+
+	shortenerAPIHandler := handlers.NewShortenerAPIHandler(
+		cfg,
+		utils.NewHashidsUIDGenerator(cfg.App.HashMinLength, cfg.App.HashSalt, logger.NewNop()),
+		utils.NewConfigurableURLNormalizer(cfg.Policy),
+		utils.NewConfigurableURLPolicy(cfg.Policy, cfg.Server.BaseURL, utils.NewFileBlocklist("", 0, logger.NewNop())),
+		utils.NewConfigQuotaManager(cfg.Quota, rep, logger.NewNop()),
+		rep,
+		contextKeyUserID,
+		mocks.NewMockDeletionBuffer(gomock.NewController(t)),
+		logger.NewNop(),
+	)
+
+	statusCodes := make(chan int, requests)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			body := fmt.Sprintf(`{"url":"https://example.com/%d"}`, i)
+			request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+			request = request.WithContext(context.WithValue(request.Context(), contextKeyUserID, userID))
+
+			recorder := httptest.NewRecorder()
+			shortenerAPIHandler.Shorten(recorder, request)
+			statusCodes <- recorder.Code
+		}(i)
+	}
+
+	wg.Wait()
+	close(statusCodes)
+
+	created := 0
+
+	for statusCode := range statusCodes {
+		if statusCode == http.StatusCreated {
+			created++
+
+			continue
+		}
+
+		assert.Equal(t, http.StatusForbidden, statusCode)
+	}
+
+	activeLinks, err := rep.CountActiveByUserID(context.Background(), userID)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, created)
+	assert.Equal(t, 2, activeLinks)
+}
+
 func TestShortenerAPIHandler_UserUrls(t *testing.T) {
 	t.Parallel()
 
@@ -345,8 +542,8 @@ func TestShortenerAPIHandler_UserUrls(t *testing.T) {
 			},
 			response: response{
 				statusCode:  http.StatusNoContent,
-				contentType: handlers.ContentTypeText,
-				body:        http.StatusText(http.StatusNoContent),
+				contentType: "",
+				body:        "",
 			},
 		},
 		{
@@ -376,9 +573,17 @@ func TestShortenerAPIHandler_UserUrls(t *testing.T) {
 			shortenerAPIHandler := handlers.NewShortenerAPIHandler(
 				testCase.fields.cfg,
 				testCase.fields.uidGenerator,
+				utils.NewConfigurableURLNormalizer(testCase.fields.cfg.Policy),
+				utils.NewConfigurableURLPolicy(
+					testCase.fields.cfg.Policy,
+					testCase.fields.cfg.Server.BaseURL,
+					utils.NewFileBlocklist("", 0, logger.NewNop()),
+				),
+				utils.NewConfigQuotaManager(testCase.fields.cfg.Quota, testCase.fields.rep, logger.NewNop()),
 				testCase.fields.rep,
 				testCase.fields.contextKeyUserID,
 				testCase.fields.deletionBuffer,
+				logger.NewNop(),
 			)
 
 			request := httptest.NewRequest(http.MethodPost, "/api/user/urls", nil)
@@ -476,7 +681,9 @@ func TestShortenerAPIHandler_ShortenBatch(t *testing.T) {
 		models.NewShortURL(0, urls[0], uid41, userID4),
 		models.NewShortURL(0, urls[1], uid42, userID4),
 	}
-	rep4.EXPECT().BatchSave(gomock.Any(), shortURLs).Return(nil)
+	shortURLs[0].CanonicalURL = "https://mysite.com/?id=u1"
+	shortURLs[1].CanonicalURL = "https://mysite.com/?id=u2"
+	rep4.EXPECT().BatchSave(gomock.Any(), shortURLs, 0).Return(nil)
 
 	deletionBuffer4 := mocks.NewMockDeletionBuffer(ctrl)
 	json4, err := json.Marshal(handlers.NewShortenBatchResponseJSON(
@@ -486,6 +693,43 @@ func TestShortenerAPIHandler_ShortenBatch(t *testing.T) {
 	)
 	require.NoError(t, err)
 
+	// test case 5
+	cfg5 := configs.NewDefaultConfig()
+	cfg5.Server.BaseURL = "localhost"
+	cfg5.Quota.Plans["free"] = configs.PlanConfig{MaxLinks: 3, MaxBatchSize: 0, MaxTTL: 0}
+	cfg5.Quota.DefaultPlan = "free"
+	cfg5.Quota.BatchMode = configs.QuotaBatchModePartial
+	uid5 := models.UID("PaRtIa")
+	uidGenerator5 := mocks.NewMockUIDGenerator(ctrl)
+	uidGenerator5.EXPECT().Generate().Return(uid5, nil)
+
+	rep5 := mocks.NewMockRepository(ctrl)
+	userID5 := uuid.New()
+	rep5.EXPECT().CountActiveByUserID(gomock.Any(), userID5).Return(2, nil)
+	rep5.EXPECT().FindAllByUserID(gomock.Any(), userID5).Return(nil, repositories.ErrNotFound)
+
+	shortURLs5 := []*models.ShortURL{models.NewShortURL(0, urls[0], uid5, userID5)}
+	shortURLs5[0].CanonicalURL = "https://mysite.com/?id=u1"
+	rep5.EXPECT().BatchSave(gomock.Any(), shortURLs5, 3).Return(nil)
+
+	deletionBuffer5 := mocks.NewMockDeletionBuffer(ctrl)
+	json5, err := json.Marshal(handlers.NewPartialShortenBatchResponseJSON(
+		shortURLs5,
+		correlationIDs[:1],
+		correlationIDs[1:],
+		handlers.MessageQuotaExceeded,
+		cfg5.Server.BaseURL,
+	))
+	require.NoError(t, err)
+
+	// test case 6
+	cfg6 := configs.NewDefaultConfig()
+	cfg6.Quota.Plans["free"] = configs.PlanConfig{MaxLinks: 0, MaxBatchSize: 1, MaxTTL: 0}
+	cfg6.Quota.DefaultPlan = "free"
+	uidGenerator6 := mocks.NewMockUIDGenerator(ctrl)
+	rep6 := mocks.NewMockRepository(ctrl)
+	deletionBuffer6 := mocks.NewMockDeletionBuffer(ctrl)
+
 	tests := []struct {
 		name     string
 		fields   fields
@@ -548,8 +792,13 @@ func TestShortenerAPIHandler_ShortenBatch(t *testing.T) {
 				userID: uuid.New(),
 			},
 			response: response{
-				statusCode:  http.StatusBadRequest,
-				body:        fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), handlers.MessageIncorrectURL),
+				statusCode: http.StatusBadRequest,
+				body: fmt.Sprintf(
+					"%s: %s: /0/original_url: %s",
+					http.StatusText(http.StatusBadRequest),
+					handlers.MessageInvalidRequestBody,
+					handlers.MessageIncorrectURL,
+				),
 				contentType: handlers.ContentTypeText,
 			},
 		},
@@ -572,6 +821,48 @@ func TestShortenerAPIHandler_ShortenBatch(t *testing.T) {
 				contentType: handlers.ContentTypeJSON,
 			},
 		},
+		{
+			name: "test case 5: partially created over quota",
+			fields: fields{
+				cfg:              cfg5,
+				uidGenerator:     uidGenerator5,
+				rep:              rep5,
+				contextKeyUserID: "userID",
+				deletionBuffer:   deletionBuffer5,
+			},
+			request: request{
+				body:   body4,
+				userID: userID5,
+			},
+			response: response{
+				statusCode:  http.StatusCreated,
+				body:        string(json5),
+				contentType: handlers.ContentTypeJSON,
+			},
+		},
+		{
+			name: "test case 6: batch is too large",
+			fields: fields{
+				cfg:              cfg6,
+				uidGenerator:     uidGenerator6,
+				rep:              rep6,
+				contextKeyUserID: "userID",
+				deletionBuffer:   deletionBuffer6,
+			},
+			request: request{
+				body:   body4,
+				userID: uuid.New(),
+			},
+			response: response{
+				statusCode: http.StatusBadRequest,
+				body: fmt.Sprintf(
+					"%s: %s: plan \"free\" allows 1 items",
+					http.StatusText(http.StatusBadRequest),
+					handlers.MessageBatchIsTooLarge,
+				),
+				contentType: handlers.ContentTypeText,
+			},
+		},
 	}
 
 	for _, testCase := range tests {
@@ -582,9 +873,17 @@ func TestShortenerAPIHandler_ShortenBatch(t *testing.T) {
 			shortenerAPIHandler := handlers.NewShortenerAPIHandler(
 				testCase.fields.cfg,
 				testCase.fields.uidGenerator,
+				utils.NewConfigurableURLNormalizer(testCase.fields.cfg.Policy),
+				utils.NewConfigurableURLPolicy(
+					testCase.fields.cfg.Policy,
+					testCase.fields.cfg.Server.BaseURL,
+					utils.NewFileBlocklist("", 0, logger.NewNop()),
+				),
+				utils.NewConfigQuotaManager(testCase.fields.cfg.Quota, testCase.fields.rep, logger.NewNop()),
 				testCase.fields.rep,
 				testCase.fields.contextKeyUserID,
 				testCase.fields.deletionBuffer,
+				logger.NewNop(),
 			)
 
 			requestShortenAPIBatch := httptest.NewRequest(
@@ -615,6 +914,168 @@ func TestShortenerAPIHandler_ShortenBatch(t *testing.T) {
 	}
 }
 
+func TestShortenerAPIHandler_ShortenBatch_QuotaWithExistingLinks(t *testing.T) {
+	t.Parallel()
+
+	const body = `[
+    {"correlation_id": "existing", "original_url": "https://mysite.com/existing"},
+    {"correlation_id": "new", "original_url": "https://mysite.com/new"},
+    {"correlation_id": "repeated", "original_url": "https://MYSITE.com/new"},
+    {"correlation_id": "over", "original_url": "https://mysite.com/over"}
+]`
+
+	tests := []struct {
+		name           string
+		batchMode      string
+		maxLinks       int
+		body           string
+		statusCode     int
+		correlationIDs []string
+		rejected       []string
+		activeLinks    int
+	}{
+		{
+			name:           "test case 1: atomic batch of links the user already has at quota",
+			batchMode:      configs.QuotaBatchModeAtomic,
+			maxLinks:       1,
+			body:           `[{"correlation_id": "a", "original_url": "https://mysite.com/existing"}]`,
+			statusCode:     http.StatusCreated,
+			correlationIDs: []string{"a"},
+			rejected:       nil,
+			activeLinks:    1,
+		},
+		{
+			name:           "test case 2: atomic batch with more new links than the quota allows",
+			batchMode:      configs.QuotaBatchModeAtomic,
+			maxLinks:       2,
+			body:           body,
+			statusCode:     http.StatusForbidden,
+			correlationIDs: nil,
+			rejected:       nil,
+			activeLinks:    1,
+		},
+		{
+			name:           "test case 3: atomic batch whose new links fit into the quota",
+			batchMode:      configs.QuotaBatchModeAtomic,
+			maxLinks:       3,
+			body:           body,
+			statusCode:     http.StatusCreated,
+			correlationIDs: []string{"existing", "new", "repeated", "over"},
+			rejected:       nil,
+			activeLinks:    3,
+		},
+		{
+			name:           "test case 4: partial batch rejects only new links over the quota",
+			batchMode:      configs.QuotaBatchModePartial,
+			maxLinks:       2,
+			body:           body,
+			statusCode:     http.StatusCreated,
+			correlationIDs: []string{"existing", "new", "repeated"},
+			rejected:       []string{"over"},
+			activeLinks:    2,
+		},
+		{
+			name:           "test case 5: partial batch of links the user already has at quota",
+			batchMode:      configs.QuotaBatchModePartial,
+			maxLinks:       1,
+			body:           `[{"correlation_id": "a", "original_url": "https://mysite.com/existing"}]`,
+			statusCode:     http.StatusCreated,
+			correlationIDs: []string{"a"},
+			rejected:       nil,
+			activeLinks:    1,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := configs.NewDefaultConfig()
+			cfg.Server.BaseURL = "localhost"
+			cfg.Quota.Plans["free"] = configs.PlanConfig{MaxLinks: testCase.maxLinks, MaxBatchSize: 0, MaxTTL: 0}
+			cfg.Quota.DefaultPlan = "free"
+			cfg.Quota.BatchMode = testCase.batchMode
+
+			rep := repositories.NewMemoryRepository()
+			userID := uuid.New()
+			contextKeyUserID := middlewares.ContextKey("userId")
+
+			existing := models.NewShortURL(0, "https://mysite.com/existing", "ExIsTs", userID)
+			existing.CanonicalURL = "https://mysite.com/existing"
+			require.NoError(t, rep.Save(context.Background(), existing, 0))
+
+			shortenerAPIHandler := handlers.NewShortenerAPIHandler(
+				cfg,
+				utils.NewHashidsUIDGenerator(cfg.App.HashMinLength, cfg.App.HashSalt, logger.NewNop()),
+				utils.NewConfigurableURLNormalizer(cfg.Policy),
+				utils.NewConfigurableURLPolicy(
+					cfg.Policy,
+					cfg.Server.BaseURL,
+					utils.NewFileBlocklist("", 0, logger.NewNop()),
+				),
+				utils.NewConfigQuotaManager(cfg.Quota, rep, logger.NewNop()),
+				rep,
+				contextKeyUserID,
+				mocks.NewMockDeletionBuffer(gomock.NewController(t)),
+				logger.NewNop(),
+			)
+
+			request := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(testCase.body))
+			request = request.WithContext(context.WithValue(request.Context(), contextKeyUserID, userID))
+
+			recorder := httptest.NewRecorder()
+			shortenerAPIHandler.ShortenBatch(recorder, request)
+
+			assert.Equal(t, testCase.statusCode, recorder.Code)
+
+			if testCase.statusCode == http.StatusCreated {
+				var items []struct {
+					CorrelationID string `json:"correlation_id"`
+					ShortURL      string `json:"short_url"`
+					Error         string `json:"error"`
+				}
+
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &items))
+
+				shortURLs := map[string]string{}
+
+				var correlationIDs, rejected []string
+
+				for _, item := range items {
+					if item.Error != "" {
+						rejected = append(rejected, item.CorrelationID)
+
+						continue
+					}
+
+					correlationIDs = append(correlationIDs, item.CorrelationID)
+					shortURLs[item.CorrelationID] = item.ShortURL
+				}
+
+				assert.Equal(t, testCase.correlationIDs, correlationIDs)
+				assert.Equal(t, testCase.rejected, rejected)
+
+				// Links the user already has and repeated URLs are answered with the existing short URLs.
+				if shortURL, ok := shortURLs["existing"]; ok {
+					assert.Equal(t, existing.GetShortURL(cfg.Server.BaseURL).String(), shortURL)
+				}
+
+				if shortURL, ok := shortURLs["a"]; ok {
+					assert.Equal(t, existing.GetShortURL(cfg.Server.BaseURL).String(), shortURL)
+				}
+
+				if shortURL, ok := shortURLs["repeated"]; ok {
+					assert.Equal(t, shortURLs["new"], shortURL)
+				}
+			}
+
+			activeLinks, err := rep.CountActiveByUserID(context.Background(), userID)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.activeLinks, activeLinks)
+		})
+	}
+}
+
 func TestShortenerAPIHandler_DeleteUserUrls(t *testing.T) {
 	t.Parallel()
 
@@ -634,6 +1095,7 @@ func TestShortenerAPIHandler_DeleteUserUrls(t *testing.T) {
 	type response struct {
 		statusCode  int
 		contentType string
+		body        string
 	}
 
 	ctrl := gomock.NewController(t)
@@ -659,7 +1121,26 @@ func TestShortenerAPIHandler_DeleteUserUrls(t *testing.T) {
 	body3 := fmt.Sprintf(`["%s", "%s"]`, uids[0], uids[1])
 	userID3 := uuid.New()
 	deletionBuffer3 := mocks.NewMockDeletionBuffer(ctrl)
-	deletionBuffer3.EXPECT().Push(uids, userID3)
+	job3 := models.NewDeletionJob(userID3, uids)
+	deletionBuffer3.EXPECT().Push(gomock.Any(), uids, userID3).Return(job3, nil)
+	json3, err := json.Marshal(handlers.NewDeletionJobAcceptedResponseJSON(job3, cfg3.Server.BaseURL))
+	require.NoError(t, err)
+
+	// test case 4
+	cfg4 := configs.NewDefaultConfig()
+	uidGenerator4 := mocks.NewMockUIDGenerator(ctrl)
+	rep4 := mocks.NewMockRepository(ctrl)
+	userID4 := uuid.New()
+	deletionBuffer4 := mocks.NewMockDeletionBuffer(ctrl)
+	deletionBuffer4.EXPECT().Push(gomock.Any(), uids, userID4).Return(nil, errors.New("failed to save deletion job"))
+
+	// test case 5
+	cfg5 := configs.NewDefaultConfig()
+	uidGenerator5 := mocks.NewMockUIDGenerator(ctrl)
+	rep5 := mocks.NewMockRepository(ctrl)
+	userID5 := uuid.New()
+	deletionBuffer5 := mocks.NewMockDeletionBuffer(ctrl)
+	deletionBuffer5.EXPECT().Push(gomock.Any(), uids, userID5).Return(nil, utils.ErrDeletionQueueFull)
 
 	tests := []struct {
 		name     string
@@ -683,6 +1164,7 @@ func TestShortenerAPIHandler_DeleteUserUrls(t *testing.T) {
 			response: response{
 				statusCode:  http.StatusInternalServerError,
 				contentType: handlers.ContentTypeText,
+				body:        "Internal Server Error\n",
 			},
 		},
 		{
@@ -701,6 +1183,7 @@ func TestShortenerAPIHandler_DeleteUserUrls(t *testing.T) {
 			response: response{
 				statusCode:  http.StatusBadRequest,
 				contentType: handlers.ContentTypeText,
+				body:        "Bad Request: incorrect JSON\n",
 			},
 		},
 		{
@@ -718,7 +1201,46 @@ func TestShortenerAPIHandler_DeleteUserUrls(t *testing.T) {
 			},
 			response: response{
 				statusCode:  http.StatusAccepted,
+				contentType: handlers.ContentTypeJSON,
+				body:        string(json3) + "\n",
+			},
+		},
+		{
+			name: "test case 4: job is not saved",
+			fields: fields{
+				cfg:              cfg4,
+				uidGenerator:     uidGenerator4,
+				rep:              rep4,
+				contextKeyUserID: "jwt",
+				deletionBuffer:   deletionBuffer4,
+			},
+			request: request{
+				body:   body3,
+				userID: userID4,
+			},
+			response: response{
+				statusCode:  http.StatusInternalServerError,
+				contentType: handlers.ContentTypeText,
+				body:        "Internal Server Error\n",
+			},
+		},
+		{
+			name: "test case 5: deletion queue is full",
+			fields: fields{
+				cfg:              cfg5,
+				uidGenerator:     uidGenerator5,
+				rep:              rep5,
+				contextKeyUserID: "jwt",
+				deletionBuffer:   deletionBuffer5,
+			},
+			request: request{
+				body:   body3,
+				userID: userID5,
+			},
+			response: response{
+				statusCode:  http.StatusServiceUnavailable,
 				contentType: handlers.ContentTypeText,
+				body:        "Service Unavailable: deletion queue is full\n",
 			},
 		},
 	}
@@ -731,9 +1253,17 @@ func TestShortenerAPIHandler_DeleteUserUrls(t *testing.T) {
 			shortenerAPIHandler := handlers.NewShortenerAPIHandler(
 				testCase.fields.cfg,
 				testCase.fields.uidGenerator,
+				utils.NewConfigurableURLNormalizer(testCase.fields.cfg.Policy),
+				utils.NewConfigurableURLPolicy(
+					testCase.fields.cfg.Policy,
+					testCase.fields.cfg.Server.BaseURL,
+					utils.NewFileBlocklist("", 0, logger.NewNop()),
+				),
+				utils.NewConfigQuotaManager(testCase.fields.cfg.Quota, testCase.fields.rep, logger.NewNop()),
 				testCase.fields.rep,
 				testCase.fields.contextKeyUserID,
 				testCase.fields.deletionBuffer,
+				logger.NewNop(),
 			)
 
 			requestShortenAPIBatch := httptest.NewRequest(
@@ -754,8 +1284,133 @@ func TestShortenerAPIHandler_DeleteUserUrls(t *testing.T) {
 			assert.Equal(t, testCase.response.statusCode, result.StatusCode)
 			assert.Equal(t, testCase.response.contentType, handlers.GetContentType(result))
 
-			err := result.Body.Close()
+			body, err := io.ReadAll(result.Body)
 			require.NoError(t, err)
+			assert.Equal(t, testCase.response.body, string(body))
+
+			if testCase.response.statusCode == http.StatusServiceUnavailable {
+				assert.Equal(t, strconv.Itoa(testCase.fields.cfg.App.DeletionBufferClearTimeout), result.Header.Get("Retry-After"))
+			}
+
+			err = result.Body.Close()
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestShortenerAPIHandler_ProblemDetails(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	contextKeyUserID := middlewares.ContextKey("userId")
+
+	// test case 1
+	cfg1 := configs.NewDefaultConfig()
+	cfg1.Server.BaseURL = "base-url"
+	uidGenerator1 := mocks.NewMockUIDGenerator(ctrl)
+	uidGenerator1.EXPECT().Generate().Return(models.UID("ExIsTs"), nil)
+
+	rep1 := mocks.NewMockRepository(ctrl)
+	rep1.EXPECT().Save(gomock.Any(), gomock.Any(), 0).Return(repositories.ErrURLDuplicate)
+
+	problem1 := utils.NewProblem(http.StatusConflict, utils.ProblemCodeURLDuplicate, handlers.MessageURLIsShortened)
+	problem1.ShortURL = "base-url/ExIsTs"
+	problem1.Result = "base-url/ExIsTs"
+
+	// test case 2
+	cfg2 := configs.NewDefaultConfig()
+	uidGenerator2 := mocks.NewMockUIDGenerator(ctrl)
+	uidGenerator2.EXPECT().Generate().Return(models.UID("VaLiDd"), nil).AnyTimes()
+
+	problem2 := utils.NewValidationProblem(
+		handlers.MessageInvalidRequestBody,
+		[]utils.FieldError{{Pointer: "/1/original_url", Message: handlers.MessageIncorrectURL}},
+	)
+
+	// test case 3
+	cfg3 := configs.NewDefaultConfig()
+
+	problem3 := utils.NewProblem(http.StatusBadRequest, utils.ProblemCodeIncorrectJSON, handlers.MessageIncorrectJSON)
+
+	tests := []struct {
+		name         string
+		cfg          *configs.Config
+		uidGenerator utils.UIDGenerator
+		rep          repositories.Repository
+		path         string
+		body         string
+		problem      *utils.Problem
+	}{
+		{
+			name:         "test case 1: conflict with existing short url",
+			cfg:          cfg1,
+			uidGenerator: uidGenerator1,
+			rep:          rep1,
+			path:         "/api/shorten",
+			body:         `{"url":"https://example-site.com/conflict"}`,
+			problem:      problem1,
+		},
+		{
+			name:         "test case 2: pointer to invalid batch item",
+			cfg:          cfg2,
+			uidGenerator: uidGenerator2,
+			rep:          mocks.NewMockRepository(ctrl),
+			path:         "/api/shorten/batch",
+			body:         `[{"correlation_id":"1","original_url":"https://a.com/"},{"correlation_id":"2","original_url":"bad"}]`,
+			problem:      problem2,
+		},
+		{
+			name:         "test case 3: incorrect json",
+			cfg:          cfg3,
+			uidGenerator: mocks.NewMockUIDGenerator(ctrl),
+			rep:          mocks.NewMockRepository(ctrl),
+			path:         "/api/shorten/batch",
+			body:         `bad json`,
+			problem:      problem3,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			shortenerAPIHandler := handlers.NewShortenerAPIHandler(
+				testCase.cfg,
+				testCase.uidGenerator,
+				utils.NewConfigurableURLNormalizer(testCase.cfg.Policy),
+				utils.NewConfigurableURLPolicy(testCase.cfg.Policy, testCase.cfg.Server.BaseURL, utils.NewFileBlocklist("", 0, logger.NewNop())),
+				utils.NewConfigQuotaManager(testCase.cfg.Quota, testCase.rep, logger.NewNop()),
+				testCase.rep,
+				contextKeyUserID,
+				mocks.NewMockDeletionBuffer(ctrl),
+				logger.NewNop(),
+			)
+
+			request := httptest.NewRequest(http.MethodPost, testCase.path, strings.NewReader(testCase.body))
+			ctx := context.WithValue(request.Context(), contextKeyUserID, uuid.New())
+			request = request.WithContext(utils.WithProblemDetails(ctx))
+
+			recorder := httptest.NewRecorder()
+
+			if testCase.path == "/api/shorten" {
+				shortenerAPIHandler.Shorten(recorder, request)
+			} else {
+				shortenerAPIHandler.ShortenBatch(recorder, request)
+			}
+
+			result := recorder.Result()
+
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			expected, err := json.Marshal(testCase.problem)
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.problem.Status, result.StatusCode)
+			assert.Equal(t, utils.ContentTypeProblemJSON, result.Header.Get("Content-Type"))
+			assert.JSONEq(t, string(expected), string(body))
 		})
 	}
 }
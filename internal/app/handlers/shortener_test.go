@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/golang/mock/gomock"
@@ -17,6 +18,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/tmitry/shorturl/internal/app/configs"
 	"github.com/tmitry/shorturl/internal/app/handlers"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/metrics"
 	"github.com/tmitry/shorturl/internal/app/middlewares"
 	"github.com/tmitry/shorturl/internal/app/mocks"
 	"github.com/tmitry/shorturl/internal/app/models"
@@ -53,6 +56,9 @@ func TestShortenerHandler_Shorten(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	t.Cleanup(ctrl.Finish)
 
+	webhooks := mocks.NewMockWebhookDispatcher(ctrl)
+	webhooks.EXPECT().Emit(gomock.Any(), gomock.Any()).AnyTimes()
+
 	// test case 1
 	cfg1 := configs.NewDefaultConfig()
 	uidGenerator1 := mocks.NewMockUIDGenerator(ctrl)
@@ -87,6 +93,7 @@ func TestShortenerHandler_Shorten(t *testing.T) {
 	rep4.EXPECT().Save(
 		gomock.Any(),
 		shortURL4,
+		0,
 	).Return(nil)
 
 	// test case 5
@@ -103,8 +110,25 @@ func TestShortenerHandler_Shorten(t *testing.T) {
 	rep5.EXPECT().Save(
 		gomock.Any(),
 		shortURL5,
+		0,
 	).Return(repositories.ErrURLDuplicate)
 
+	// test case 6
+	cfg6 := configs.NewDefaultConfig()
+	uidGenerator6 := mocks.NewMockUIDGenerator(ctrl)
+	rep6 := mocks.NewMockRepository(ctrl)
+
+	// test case 7
+	cfg7 := configs.NewDefaultConfig()
+	uidGenerator7 := mocks.NewMockUIDGenerator(ctrl)
+	rep7 := mocks.NewMockRepository(ctrl)
+
+	// test case 8
+	cfg8 := configs.NewDefaultConfig()
+	cfg8.Server.BaseURL = "http://short.example.com"
+	uidGenerator8 := mocks.NewMockUIDGenerator(ctrl)
+	rep8 := mocks.NewMockRepository(ctrl)
+
 	tests := []struct {
 		name     string
 		fields   fields
@@ -205,6 +229,78 @@ func TestShortenerHandler_Shorten(t *testing.T) {
 				body:        string(shortURL5.GetShortURL(cfg5.Server.BaseURL)),
 			},
 		},
+		{
+			name: "test case 6: scheme is not allowed",
+			fields: fields{
+				cfg:              cfg6,
+				uidGenerator:     uidGenerator6,
+				rep:              rep6,
+				contextKeyUserID: "userID",
+			},
+			request: request{
+				body:   "javascript:alert(1)",
+				userID: uuid.New(),
+			},
+			response: response{
+				statusCode:  http.StatusBadRequest,
+				contentType: handlers.ContentTypeText,
+				body: fmt.Sprintf(
+					"%s: %s (%s): %s",
+					http.StatusText(http.StatusBadRequest),
+					handlers.MessageURLPolicyViolation,
+					utils.URLPolicyRuleScheme,
+					`scheme "javascript" is not allowed`,
+				),
+			},
+		},
+		{
+			name: "test case 7: private network",
+			fields: fields{
+				cfg:              cfg7,
+				uidGenerator:     uidGenerator7,
+				rep:              rep7,
+				contextKeyUserID: "userID",
+			},
+			request: request{
+				body:   "http://127.0.0.1:5432/",
+				userID: uuid.New(),
+			},
+			response: response{
+				statusCode:  http.StatusBadRequest,
+				contentType: handlers.ContentTypeText,
+				body: fmt.Sprintf(
+					"%s: %s (%s): %s",
+					http.StatusText(http.StatusBadRequest),
+					handlers.MessageURLPolicyViolation,
+					utils.URLPolicyRulePrivateNetwork,
+					`host "127.0.0.1" belongs to a private network`,
+				),
+			},
+		},
+		{
+			name: "test case 8: self reference",
+			fields: fields{
+				cfg:              cfg8,
+				uidGenerator:     uidGenerator8,
+				rep:              rep8,
+				contextKeyUserID: "userID",
+			},
+			request: request{
+				body:   "http://SHORT.example.com:80/AbCdE",
+				userID: uuid.New(),
+			},
+			response: response{
+				statusCode:  http.StatusBadRequest,
+				contentType: handlers.ContentTypeText,
+				body: fmt.Sprintf(
+					"%s: %s (%s): %s",
+					http.StatusText(http.StatusBadRequest),
+					handlers.MessageURLPolicyViolation,
+					utils.URLPolicyRuleSelfReference,
+					"URL points to this shortener",
+				),
+			},
+		},
 	}
 
 	for _, testCase := range tests {
@@ -215,8 +311,19 @@ func TestShortenerHandler_Shorten(t *testing.T) {
 			shortenerHandler := handlers.NewShortenerHandler(
 				testCase.fields.cfg,
 				testCase.fields.uidGenerator,
+				utils.NewConfigurableURLNormalizer(testCase.fields.cfg.Policy),
+				utils.NewConfigurableURLPolicy(
+					testCase.fields.cfg.Policy,
+					testCase.fields.cfg.Server.BaseURL,
+					utils.NewFileBlocklist("", 0, logger.NewNop()),
+				),
+				utils.NewFileBlocklist("", 0, logger.NewNop()),
+				utils.NewConfigQuotaManager(testCase.fields.cfg.Quota, testCase.fields.rep, logger.NewNop()),
 				testCase.fields.rep,
 				testCase.fields.contextKeyUserID,
+				webhooks,
+				metrics.NewMetrics(),
+				logger.NewNop(),
 			)
 
 			requestShorten := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(testCase.request.body))
@@ -252,6 +359,7 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 	type fields struct {
 		cfg              *configs.Config
 		uidGenerator     utils.UIDGenerator
+		blocklist        utils.Blocklist
 		rep              repositories.Repository
 		contextKeyUserID middlewares.ContextKey
 	}
@@ -265,11 +373,15 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 		contentType string
 		body        string
 		location    string
+		redirect    string // Result counted by the redirect metric, empty if nothing is counted.
 	}
 
 	ctrl := gomock.NewController(t)
 	t.Cleanup(ctrl.Finish)
 
+	webhooks := mocks.NewMockWebhookDispatcher(ctrl)
+	webhooks.EXPECT().Emit(gomock.Any(), gomock.Any()).AnyTimes()
+
 	// test case 1
 	cfg1 := configs.NewDefaultConfig()
 	uid1 := models.UID("abc")
@@ -277,6 +389,7 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 	uidGenerator1.EXPECT().IsValid(uid1).Return(false, nil)
 
 	rep1 := mocks.NewMockRepository(ctrl)
+	blocklist1 := mocks.NewMockBlocklist(ctrl)
 
 	// test case 2
 	cfg2 := configs.NewDefaultConfig()
@@ -285,6 +398,7 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 	uidGenerator2.EXPECT().IsValid(uid2).Return(true, nil)
 
 	rep2 := mocks.NewMockRepository(ctrl)
+	blocklist2 := mocks.NewMockBlocklist(ctrl)
 	rep2.EXPECT().FindOneByUID(gomock.Any(), uid2).Return(nil, repositories.ErrNotFound)
 
 	// test case 3
@@ -294,9 +408,13 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 	uidGenerator3.EXPECT().IsValid(uid3).Return(true, nil)
 
 	rep3 := mocks.NewMockRepository(ctrl)
+	blocklist3 := mocks.NewMockBlocklist(ctrl)
 	url3 := "https://example.com/"
 	shortURL3 := models.NewShortURL(1, models.URL(url3), uid3, uuid.New())
 	rep3.EXPECT().FindOneByUID(gomock.Any(), uid3).Return(shortURL3, nil)
+	rep3.EXPECT().FindBanByUserID(gomock.Any(), shortURL3.UserID).Return(nil, repositories.ErrNotFound)
+	rep3.EXPECT().RegisterClick(gomock.Any(), uid3).Return(nil)
+	blocklist3.EXPECT().Match(shortURL3.URL).Return("", false)
 
 	// test case 4
 	cfg4 := configs.NewDefaultConfig()
@@ -305,11 +423,78 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 	uidGenerator4.EXPECT().IsValid(uid3).Return(true, nil)
 
 	rep4 := mocks.NewMockRepository(ctrl)
+	blocklist4 := mocks.NewMockBlocklist(ctrl)
 	url4 := "https://example.com/"
 	shortURL4 := models.NewShortURL(1, models.URL(url4), uid4, uuid.New())
 	shortURL4.IsDeleted = true
 	rep4.EXPECT().FindOneByUID(gomock.Any(), uid4).Return(shortURL4, nil)
 
+	// test case 5
+	cfg5 := configs.NewDefaultConfig()
+	uid5 := models.UID("BlOcKeD")
+	uidGenerator5 := mocks.NewMockUIDGenerator(ctrl)
+	uidGenerator5.EXPECT().IsValid(uid5).Return(true, nil)
+
+	rep5 := mocks.NewMockRepository(ctrl)
+	shortURL5 := models.NewShortURL(1, "https://phishing.example.com/login", uid5, uuid.New())
+	rep5.EXPECT().FindOneByUID(gomock.Any(), uid5).Return(shortURL5, nil)
+	rep5.EXPECT().FindBanByUserID(gomock.Any(), shortURL5.UserID).Return(nil, repositories.ErrNotFound)
+
+	blocklist5 := mocks.NewMockBlocklist(ctrl)
+	blocklist5.EXPECT().Match(shortURL5.URL).Return("*.example.com", true)
+
+	// test case 6
+	cfg6 := configs.NewDefaultConfig()
+	cfg6.Policy.BlocklistAction = configs.BlocklistActionWarn
+	uid6 := models.UID("WaRnInG")
+	uidGenerator6 := mocks.NewMockUIDGenerator(ctrl)
+	uidGenerator6.EXPECT().IsValid(uid6).Return(true, nil)
+
+	rep6 := mocks.NewMockRepository(ctrl)
+	shortURL6 := models.NewShortURL(1, "https://phishing.example.com/login", uid6, uuid.New())
+	rep6.EXPECT().FindOneByUID(gomock.Any(), uid6).Return(shortURL6, nil)
+	rep6.EXPECT().FindBanByUserID(gomock.Any(), shortURL6.UserID).Return(nil, repositories.ErrNotFound)
+
+	blocklist6 := mocks.NewMockBlocklist(ctrl)
+	blocklist6.EXPECT().Match(shortURL6.URL).Return("*.example.com", true)
+
+	// test case 7
+	cfg7 := configs.NewDefaultConfig()
+	uid7 := models.UID("ExPiReD")
+	uidGenerator7 := mocks.NewMockUIDGenerator(ctrl)
+	uidGenerator7.EXPECT().IsValid(uid7).Return(true, nil)
+
+	rep7 := mocks.NewMockRepository(ctrl)
+	blocklist7 := mocks.NewMockBlocklist(ctrl)
+	shortURL7 := models.NewShortURL(1, "https://example.com/", uid7, uuid.New())
+	shortURL7.ExpiresAt = time.Now().Add(-time.Minute)
+	rep7.EXPECT().FindOneByUID(gomock.Any(), uid7).Return(shortURL7, nil)
+
+	// test case 8
+	cfg8 := configs.NewDefaultConfig()
+	uid8 := models.UID("DiSaBlEd")
+	uidGenerator8 := mocks.NewMockUIDGenerator(ctrl)
+	uidGenerator8.EXPECT().IsValid(uid8).Return(true, nil)
+
+	rep8 := mocks.NewMockRepository(ctrl)
+	blocklist8 := mocks.NewMockBlocklist(ctrl)
+	shortURL8 := models.NewShortURL(1, "https://example.com/", uid8, uuid.New())
+	shortURL8.IsDisabled = true
+	rep8.EXPECT().FindOneByUID(gomock.Any(), uid8).Return(shortURL8, nil)
+	rep8.EXPECT().FindBanByUserID(gomock.Any(), shortURL8.UserID).Return(nil, repositories.ErrNotFound)
+
+	// test case 9
+	cfg9 := configs.NewDefaultConfig()
+	uid9 := models.UID("BaNnEd")
+	uidGenerator9 := mocks.NewMockUIDGenerator(ctrl)
+	uidGenerator9.EXPECT().IsValid(uid9).Return(true, nil)
+
+	rep9 := mocks.NewMockRepository(ctrl)
+	blocklist9 := mocks.NewMockBlocklist(ctrl)
+	shortURL9 := models.NewShortURL(1, "https://example.com/", uid9, uuid.New())
+	rep9.EXPECT().FindOneByUID(gomock.Any(), uid9).Return(shortURL9, nil)
+	rep9.EXPECT().FindBanByUserID(gomock.Any(), shortURL9.UserID).Return(models.NewBan(shortURL9.UserID, "spam"), nil)
+
 	tests := []struct {
 		name     string
 		fields   fields
@@ -321,6 +506,7 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 			fields: fields{
 				cfg:              cfg1,
 				uidGenerator:     uidGenerator1,
+				blocklist:        blocklist1,
 				rep:              rep1,
 				contextKeyUserID: "userID",
 			},
@@ -336,6 +522,7 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 					handlers.MessageIncorrectUID,
 				),
 				location: "",
+				redirect: metrics.RedirectResultNotFound,
 			},
 		},
 		{
@@ -343,6 +530,7 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 			fields: fields{
 				cfg:              cfg2,
 				uidGenerator:     uidGenerator2,
+				blocklist:        blocklist2,
 				rep:              rep2,
 				contextKeyUserID: "userID",
 			},
@@ -358,6 +546,7 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 					handlers.MessageURLNotFound,
 				),
 				location: "",
+				redirect: metrics.RedirectResultNotFound,
 			},
 		},
 		{
@@ -365,6 +554,7 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 			fields: fields{
 				cfg:              cfg3,
 				uidGenerator:     uidGenerator3,
+				blocklist:        blocklist3,
 				rep:              rep3,
 				contextKeyUserID: "userID",
 			},
@@ -376,6 +566,7 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 				contentType: handlers.ContentTypeText,
 				body:        "",
 				location:    url3,
+				redirect:    metrics.RedirectResultServed,
 			},
 		},
 		{
@@ -383,6 +574,7 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 			fields: fields{
 				cfg:              cfg4,
 				uidGenerator:     uidGenerator4,
+				blocklist:        blocklist4,
 				rep:              rep4,
 				contextKeyUserID: "jwt",
 			},
@@ -398,6 +590,132 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 					handlers.MessageURLWasDeleted,
 				),
 				location: "",
+				redirect: metrics.RedirectResultGone,
+			},
+		},
+		{
+			name: "test case 5: blocked",
+			fields: fields{
+				cfg:              cfg5,
+				uidGenerator:     uidGenerator5,
+				blocklist:        blocklist5,
+				rep:              rep5,
+				contextKeyUserID: "userID",
+			},
+			request: request{
+				uid: uid5.String(),
+			},
+			response: response{
+				statusCode:  http.StatusUnavailableForLegalReasons,
+				contentType: handlers.ContentTypeText,
+				body: fmt.Sprintf(
+					"%s: %s",
+					http.StatusText(http.StatusUnavailableForLegalReasons),
+					handlers.MessageURLIsBlocked,
+				),
+				location: "",
+				redirect: metrics.RedirectResultBlocked,
+			},
+		},
+		{
+			name: "test case 6: blocked with warning page",
+			fields: fields{
+				cfg:              cfg6,
+				uidGenerator:     uidGenerator6,
+				blocklist:        blocklist6,
+				rep:              rep6,
+				contextKeyUserID: "userID",
+			},
+			request: request{
+				uid: uid6.String(),
+			},
+			response: response{
+				statusCode:  http.StatusOK,
+				contentType: handlers.ContentTypeHTML,
+				body: `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Warning</title></head>
+<body>
+<h1>This link may be unsafe</h1>
+<p>The destination of this short link matches our abuse blocklist.</p>
+<p>If you trust it, you can continue to <a href="https://phishing.example.com/login" ` +
+					`rel="noopener noreferrer nofollow">https://phishing.example.com/login</a>.</p>
+</body>
+</html>`,
+				location: "",
+				redirect: metrics.RedirectResultWarned,
+			},
+		},
+		{
+			name: "test case 7: expired",
+			fields: fields{
+				cfg:              cfg7,
+				uidGenerator:     uidGenerator7,
+				blocklist:        blocklist7,
+				rep:              rep7,
+				contextKeyUserID: "jwt",
+			},
+			request: request{
+				uid: uid7.String(),
+			},
+			response: response{
+				statusCode:  http.StatusGone,
+				contentType: handlers.ContentTypeText,
+				body: fmt.Sprintf(
+					"%s: %s",
+					http.StatusText(http.StatusGone),
+					handlers.MessageURLHasExpired,
+				),
+				location: "",
+				redirect: metrics.RedirectResultGone,
+			},
+		},
+		{
+			name: "test case 8: disabled",
+			fields: fields{
+				cfg:              cfg8,
+				uidGenerator:     uidGenerator8,
+				blocklist:        blocklist8,
+				rep:              rep8,
+				contextKeyUserID: "userID",
+			},
+			request: request{
+				uid: uid8.String(),
+			},
+			response: response{
+				statusCode:  http.StatusForbidden,
+				contentType: handlers.ContentTypeText,
+				body: fmt.Sprintf(
+					"%s: %s",
+					http.StatusText(http.StatusForbidden),
+					handlers.MessageURLIsDisabled,
+				),
+				location: "",
+				redirect: metrics.RedirectResultDisabled,
+			},
+		},
+		{
+			name: "test case 9: owner is banned",
+			fields: fields{
+				cfg:              cfg9,
+				uidGenerator:     uidGenerator9,
+				blocklist:        blocklist9,
+				rep:              rep9,
+				contextKeyUserID: "userID",
+			},
+			request: request{
+				uid: uid9.String(),
+			},
+			response: response{
+				statusCode:  http.StatusForbidden,
+				contentType: handlers.ContentTypeText,
+				body: fmt.Sprintf(
+					"%s: %s",
+					http.StatusText(http.StatusForbidden),
+					handlers.MessageURLIsDisabled,
+				),
+				location: "",
+				redirect: metrics.RedirectResultBanned,
 			},
 		},
 	}
@@ -406,11 +724,24 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 		t.Run(testCase.name, func(t *testing.T) {
 			t.Parallel()
 
+			appMetrics := metrics.NewMetrics()
+
 			handler := handlers.NewShortenerHandler(
 				testCase.fields.cfg,
 				testCase.fields.uidGenerator,
+				utils.NewConfigurableURLNormalizer(testCase.fields.cfg.Policy),
+				utils.NewConfigurableURLPolicy(
+					testCase.fields.cfg.Policy,
+					testCase.fields.cfg.Server.BaseURL,
+					testCase.fields.blocklist,
+				),
+				testCase.fields.blocklist,
+				utils.NewConfigQuotaManager(testCase.fields.cfg.Quota, testCase.fields.rep, logger.NewNop()),
 				testCase.fields.rep,
 				testCase.fields.contextKeyUserID,
+				webhooks,
+				appMetrics,
+				logger.NewNop(),
 			)
 
 			request := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -432,6 +763,24 @@ func TestShortenerHandler_Redirect(t *testing.T) {
 
 			assert.Equal(t, testCase.response.body, strings.TrimSuffix(string(body), "\n"))
 			assert.Equal(t, testCase.response.location, result.Header.Get("Location"))
+
+			for _, redirectResult := range []string{
+				metrics.RedirectResultServed,
+				metrics.RedirectResultNotFound,
+				metrics.RedirectResultGone,
+				metrics.RedirectResultDisabled,
+				metrics.RedirectResultBanned,
+				metrics.RedirectResultBlocked,
+				metrics.RedirectResultWarned,
+				metrics.RedirectResultError,
+			} {
+				expected := 0.0
+				if redirectResult == testCase.response.redirect {
+					expected = 1
+				}
+
+				assert.Equal(t, expected, appMetrics.Redirects.Value(redirectResult), redirectResult)
+			}
 		})
 	}
 }
@@ -454,6 +803,9 @@ func TestShortenerHandler_Ping(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	t.Cleanup(ctrl.Finish)
 
+	webhooks := mocks.NewMockWebhookDispatcher(ctrl)
+	webhooks.EXPECT().Emit(gomock.Any(), gomock.Any()).AnyTimes()
+
 	// test case 1
 	cfg1 := configs.NewDefaultConfig()
 	uidGenerator1 := mocks.NewMockUIDGenerator(ctrl)
@@ -506,8 +858,19 @@ func TestShortenerHandler_Ping(t *testing.T) {
 			handler := handlers.NewShortenerHandler(
 				testCase.fields.cfg,
 				testCase.fields.uidGenerator,
+				utils.NewConfigurableURLNormalizer(testCase.fields.cfg.Policy),
+				utils.NewConfigurableURLPolicy(
+					testCase.fields.cfg.Policy,
+					testCase.fields.cfg.Server.BaseURL,
+					utils.NewFileBlocklist("", 0, logger.NewNop()),
+				),
+				utils.NewFileBlocklist("", 0, logger.NewNop()),
+				utils.NewConfigQuotaManager(testCase.fields.cfg.Quota, testCase.fields.rep, logger.NewNop()),
 				testCase.fields.rep,
 				"",
+				webhooks,
+				metrics.NewMetrics(),
+				logger.NewNop(),
 			)
 
 			request := httptest.NewRequest(http.MethodGet, "/ping", nil)
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+func NewStatsResponseJSON(counts *models.GlobalCounts) interface{} {
+	response := struct {
+		Links        int `json:"links"`
+		ActiveLinks  int `json:"active_links"`
+		DeletedLinks int `json:"deleted_links"`
+		Users        int `json:"users"`
+		Clicks       int `json:"clicks"`
+	}{
+		Links:        counts.Links,
+		ActiveLinks:  counts.ActiveLinks,
+		DeletedLinks: counts.DeletedLinks,
+		Users:        counts.Users,
+		Clicks:       counts.Clicks,
+	}
+
+	return &response
+}
+
+// StatsHandler serves internal statistics for dashboards. Access is restricted by the TrustedSubnet middleware.
+type StatsHandler struct {
+	rep repositories.Repository
+	log *logger.Logger
+}
+
+func NewStatsHandler(rep repositories.Repository, log *logger.Logger) *StatsHandler {
+	return &StatsHandler{
+		rep: rep,
+		log: log,
+	}
+}
+
+func (h StatsHandler) Stats(writer http.ResponseWriter, request *http.Request) {
+	counts, err := h.rep.CountAll(request.Context())
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writeJSON(writer, request, http.StatusOK, NewStatsResponseJSON(counts), h.log)
+}
@@ -0,0 +1,48 @@
+package handlers_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/handlers"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/mocks"
+	"github.com/tmitry/shorturl/internal/app/models"
+)
+
+func TestStatsHandler_Stats(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	rep := mocks.NewMockRepository(ctrl)
+	rep.EXPECT().CountAll(gomock.Any()).Return(&models.GlobalCounts{
+		Links:         10,
+		ActiveLinks:   7,
+		DeletedLinks:  2,
+		DisabledLinks: 1,
+		Users:         3,
+		BannedUsers:   1,
+		Clicks:        42,
+	}, nil)
+
+	handler := handlers.NewStatsHandler(rep, logger.NewNop())
+
+	recorder := httptest.NewRecorder()
+	handler.Stats(recorder, httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil))
+	result := recorder.Result()
+
+	body, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	require.NoError(t, result.Body.Close())
+
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, handlers.ContentTypeJSON, handlers.GetContentType(result))
+	assert.JSONEq(t, `{"links":10,"active_links":7,"deleted_links":2,"users":3,"clicks":42}`, string(body))
+}
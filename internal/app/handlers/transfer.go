@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+const (
+	MessageIncorrectTransferToken = "transfer token is incorrect or expired"
+	MessageOwnTransferToken       = "transfer token is issued by the same user"
+	MessageClaimedTransferToken   = "transfer token is already claimed"
+
+	transferTokenLifetime = 15 * time.Minute
+)
+
+func NewTransferTokenResponseJSON(token string, expiresAt time.Time) interface{} {
+	response := struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{Token: token, ExpiresAt: expiresAt}
+
+	return &response
+}
+
+/*
+TransferHandler moves links between anonymous identities, e.g. created on different machines.
+The user A issues a short-lived signed transfer token, the user B claims it and receives all links of A.
+Links which B has already shortened stay with A. A token can be claimed only once, so a leaked token
+can't be replayed to take links A creates afterwards.
+*/
+type TransferHandler struct {
+	rep              repositories.Repository
+	quotaManager     utils.QuotaManager
+	keyRing          *middlewares.JWTKeyRing
+	contextKeyUserID middlewares.ContextKey
+	log              *logger.Logger
+}
+
+func NewTransferHandler(
+	rep repositories.Repository,
+	quotaManager utils.QuotaManager,
+	keyRing *middlewares.JWTKeyRing,
+	contextKeyUserID middlewares.ContextKey,
+	log *logger.Logger,
+) *TransferHandler {
+	return &TransferHandler{
+		rep:              rep,
+		quotaManager:     quotaManager,
+		keyRing:          keyRing,
+		contextKeyUserID: contextKeyUserID,
+		log:              log,
+	}
+}
+
+func (h TransferHandler) Create(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
+	if !ok {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
+
+		return
+	}
+
+	jwt := middlewares.NewTransferJWT(userID, time.Now(), transferTokenLifetime)
+
+	token, err := h.keyRing.Sign(jwt)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	responseJSON := NewTransferTokenResponseJSON(token, time.Unix(jwt.Payload.ExpiresAt, 0).UTC())
+
+	writeJSON(writer, request, http.StatusCreated, responseJSON, h.log)
+}
+
+func (h TransferHandler) Claim(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
+	if !ok {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
+
+		return
+	}
+
+	reader, err := getRequestReader(request)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	defer func(reader io.ReadCloser) {
+		err := reader.Close()
+		if err != nil {
+			h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+		}
+	}(reader)
+
+	requestJSON := &struct {
+		Token string `json:"token"`
+	}{Token: ""}
+
+	if err := json.NewDecoder(reader).Decode(requestJSON); err != nil {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeIncorrectJSON,
+			MessageIncorrectJSON,
+		))
+
+		return
+	}
+
+	jwt, err := h.keyRing.Verify(requestJSON.Token, time.Now())
+	if err != nil || jwt.Payload.Audience != middlewares.JWTAudienceTransfer || jwt.Payload.ID == "" {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeBadRequest,
+			MessageIncorrectTransferToken,
+		))
+
+		return
+	}
+
+	if jwt.Payload.UserID == userID {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeBadRequest,
+			MessageOwnTransferToken,
+		))
+
+		return
+	}
+
+	if !refuseBannedSource(writer, request, h.rep, jwt.Payload.UserID, h.log) {
+		return
+	}
+
+	moved, conflicts, err := h.rep.ClaimTransfer(
+		request.Context(),
+		jwt.Payload.ID,
+		time.Unix(jwt.Payload.ExpiresAt, 0),
+		jwt.Payload.UserID,
+		userID,
+		h.quotaManager.GetPlan(userID).MaxLinks,
+	)
+	if err != nil {
+		if errors.Is(err, repositories.ErrQuotaExceeded) {
+			writeUserQuotaExceeded(writer, request, h.quotaManager, userID, h.log)
+
+			return
+		}
+
+		if errors.Is(err, repositories.ErrAlreadyClaimed) {
+			utils.WriteError(writer, request, utils.NewProblem(
+				http.StatusConflict,
+				utils.ProblemCodeConflict,
+				MessageClaimedTransferToken,
+			))
+
+			return
+		}
+
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writeJSON(writer, request, http.StatusOK, NewMergeResponseJSON(moved, conflicts), h.log)
+}
@@ -0,0 +1,342 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/handlers"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/mocks"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+// newTransferQuotaConfig assigns the plan "free" with maxLinks to every user.
+func newTransferQuotaConfig(maxLinks int) *configs.QuotaConfig {
+	quotaCfg := configs.NewDefaultQuotaConfig()
+	quotaCfg.Plans["free"] = configs.PlanConfig{MaxLinks: maxLinks, MaxBatchSize: 0, MaxTTL: 0}
+	quotaCfg.DefaultPlan = "free"
+
+	return quotaCfg
+}
+
+func TestTransferHandler_Claim(t *testing.T) {
+	t.Parallel()
+
+	const contextKeyUserID middlewares.ContextKey = "userID"
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	keyRing := middlewares.NewJWTKeyRing("1", "signature_key", nil, time.Time{})
+	now := time.Now()
+
+	sign := func(jwt *middlewares.JWT) string {
+		token, err := keyRing.Sign(jwt)
+		require.NoError(t, err)
+
+		return token
+	}
+
+	userID := uuid.New()
+	sourceUserID := uuid.New()
+
+	// test case 5
+	transferJWT5 := middlewares.NewTransferJWT(sourceUserID, now, time.Minute)
+	rep5 := mocks.NewMockRepository(ctrl)
+	rep5.EXPECT().FindBanByUserID(gomock.Any(), sourceUserID).Return(nil, repositories.ErrNotFound)
+	rep5.EXPECT().ClaimTransfer(
+		gomock.Any(),
+		transferJWT5.Payload.ID,
+		time.Unix(transferJWT5.Payload.ExpiresAt, 0),
+		sourceUserID,
+		userID,
+		5,
+	).Return(3, 1, nil)
+
+	// test case 6
+	rep6 := mocks.NewMockRepository(ctrl)
+	rep6.EXPECT().FindBanByUserID(gomock.Any(), sourceUserID).Return(nil, repositories.ErrNotFound)
+	rep6.EXPECT().ClaimTransfer(gomock.Any(), gomock.Any(), gomock.Any(), sourceUserID, userID, 5).
+		Return(0, 0, repositories.ErrAlreadyClaimed)
+
+	// test case 7
+	transferJWT7 := middlewares.NewJWT(sourceUserID, now, time.Minute)
+	transferJWT7.Payload.Audience = middlewares.JWTAudienceTransfer
+
+	// test case 8
+	rep8 := mocks.NewMockRepository(ctrl)
+	rep8.EXPECT().FindBanByUserID(gomock.Any(), sourceUserID).Return(models.NewBan(sourceUserID, "spam"), nil)
+
+	// test case 9
+	rep9 := mocks.NewMockRepository(ctrl)
+	rep9.EXPECT().FindBanByUserID(gomock.Any(), sourceUserID).Return(nil, repositories.ErrNotFound)
+	rep9.EXPECT().ClaimTransfer(gomock.Any(), gomock.Any(), gomock.Any(), sourceUserID, userID, 5).
+		Return(0, 0, repositories.ErrQuotaExceeded)
+	rep9.EXPECT().CountActiveByUserID(gomock.Any(), userID).Return(4, nil)
+
+	tests := []struct {
+		name       string
+		rep        repositories.Repository
+		token      string
+		statusCode int
+		body       string
+	}{
+		{
+			name:       "test case 1: incorrect signature",
+			rep:        mocks.NewMockRepository(ctrl),
+			token:      sign(middlewares.NewTransferJWT(sourceUserID, now, time.Minute)) + "x",
+			statusCode: http.StatusBadRequest,
+			body:       fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), handlers.MessageIncorrectTransferToken),
+		},
+		{
+			name:       "test case 2: expired token",
+			rep:        mocks.NewMockRepository(ctrl),
+			token:      sign(middlewares.NewTransferJWT(sourceUserID, now.Add(-time.Hour), time.Minute)),
+			statusCode: http.StatusBadRequest,
+			body:       fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), handlers.MessageIncorrectTransferToken),
+		},
+		{
+			name:       "test case 3: session token is not a transfer token",
+			rep:        mocks.NewMockRepository(ctrl),
+			token:      sign(middlewares.NewJWT(sourceUserID, now, time.Minute)),
+			statusCode: http.StatusBadRequest,
+			body:       fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), handlers.MessageIncorrectTransferToken),
+		},
+		{
+			name:       "test case 4: own token",
+			rep:        mocks.NewMockRepository(ctrl),
+			token:      sign(middlewares.NewTransferJWT(userID, now, time.Minute)),
+			statusCode: http.StatusBadRequest,
+			body:       fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), handlers.MessageOwnTransferToken),
+		},
+		{
+			name:       "test case 5: claimed",
+			rep:        rep5,
+			token:      sign(transferJWT5),
+			statusCode: http.StatusOK,
+			body:       `{"merged_links":3,"conflicting_links":1}`,
+		},
+		{
+			name:       "test case 6: already claimed",
+			rep:        rep6,
+			token:      sign(middlewares.NewTransferJWT(sourceUserID, now, time.Minute)),
+			statusCode: http.StatusConflict,
+			body:       fmt.Sprintf("%s: %s", http.StatusText(http.StatusConflict), handlers.MessageClaimedTransferToken),
+		},
+		{
+			name:       "test case 7: token without jti",
+			rep:        mocks.NewMockRepository(ctrl),
+			token:      sign(transferJWT7),
+			statusCode: http.StatusBadRequest,
+			body:       fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), handlers.MessageIncorrectTransferToken),
+		},
+		{
+			name:       "test case 8: links of a banned user",
+			rep:        rep8,
+			token:      sign(middlewares.NewTransferJWT(sourceUserID, now, time.Minute)),
+			statusCode: http.StatusForbidden,
+			body:       fmt.Sprintf("%s: %s", http.StatusText(http.StatusForbidden), handlers.MessageSourceUserIsBanned),
+		},
+		{
+			name:       "test case 9: links exceed the quota",
+			rep:        rep9,
+			token:      sign(middlewares.NewTransferJWT(sourceUserID, now, time.Minute)),
+			statusCode: http.StatusForbidden,
+			body: fmt.Sprintf(
+				"%s: %s: plan \"free\" allows 5 active links, 4 used",
+				http.StatusText(http.StatusForbidden),
+				handlers.MessageQuotaExceeded,
+			),
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := handlers.NewTransferHandler(
+				testCase.rep,
+				utils.NewConfigQuotaManager(newTransferQuotaConfig(5), testCase.rep, logger.NewNop()),
+				keyRing,
+				contextKeyUserID,
+				logger.NewNop(),
+			)
+
+			requestBody, err := json.Marshal(map[string]string{"token": testCase.token})
+			require.NoError(t, err)
+
+			request := httptest.NewRequest(http.MethodPost, "/api/user/transfers/claim", strings.NewReader(string(requestBody)))
+			request = request.WithContext(context.WithValue(request.Context(), contextKeyUserID, userID))
+
+			recorder := httptest.NewRecorder()
+			handler.Claim(recorder, request)
+			result := recorder.Result()
+
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.statusCode, result.StatusCode)
+			assert.Equal(t, testCase.body, strings.TrimSuffix(string(body), "\n"))
+		})
+	}
+}
+
+func TestTransferHandler_Claim_Replay(t *testing.T) {
+	t.Parallel()
+
+	const contextKeyUserID middlewares.ContextKey = "userID"
+
+	keyRing := middlewares.NewJWTKeyRing("1", "signature_key", nil, time.Time{})
+	rep := repositories.NewMemoryRepository()
+	handler := handlers.NewTransferHandler(
+		rep,
+		utils.NewConfigQuotaManager(configs.NewDefaultQuotaConfig(), rep, logger.NewNop()),
+		keyRing,
+		contextKeyUserID,
+		logger.NewNop(),
+	)
+
+	userID := uuid.New()
+	sourceUserID := uuid.New()
+	ctx := context.Background()
+
+	token, err := keyRing.Sign(middlewares.NewTransferJWT(sourceUserID, time.Now(), time.Minute))
+	require.NoError(t, err)
+
+	claim := func() int {
+		requestBody, err := json.Marshal(map[string]string{"token": token})
+		require.NoError(t, err)
+
+		request := httptest.NewRequest(http.MethodPost, "/api/user/transfers/claim", strings.NewReader(string(requestBody)))
+		request = request.WithContext(context.WithValue(request.Context(), contextKeyUserID, userID))
+
+		recorder := httptest.NewRecorder()
+		handler.Claim(recorder, request)
+
+		return recorder.Code
+	}
+
+	require.NoError(t, rep.Save(ctx, models.NewShortURL(0, "https://example.com/1", "abc", sourceUserID), 0))
+	assert.Equal(t, http.StatusOK, claim())
+
+	// The link created after the claim stays with its owner.
+	require.NoError(t, rep.Save(ctx, models.NewShortURL(0, "https://example.com/2", "def", sourceUserID), 0))
+	assert.Equal(t, http.StatusConflict, claim())
+
+	shortURL, err := rep.FindOneByUID(ctx, "def")
+	require.NoError(t, err)
+	assert.Equal(t, sourceUserID, shortURL.UserID)
+}
+
+func TestTransferHandler_Claim_Quota(t *testing.T) {
+	t.Parallel()
+
+	const contextKeyUserID middlewares.ContextKey = "userID"
+
+	keyRing := middlewares.NewJWTKeyRing("1", "signature_key", nil, time.Time{})
+	rep := repositories.NewMemoryRepository()
+
+	userID := uuid.New()
+	sourceUserID := uuid.New()
+	ctx := context.Background()
+
+	token, err := keyRing.Sign(middlewares.NewTransferJWT(sourceUserID, time.Now(), time.Minute))
+	require.NoError(t, err)
+
+	claim := func(maxLinks int) int {
+		handler := handlers.NewTransferHandler(
+			rep,
+			utils.NewConfigQuotaManager(newTransferQuotaConfig(maxLinks), rep, logger.NewNop()),
+			keyRing,
+			contextKeyUserID,
+			logger.NewNop(),
+		)
+
+		requestBody, err := json.Marshal(map[string]string{"token": token})
+		require.NoError(t, err)
+
+		request := httptest.NewRequest(http.MethodPost, "/api/user/transfers/claim", strings.NewReader(string(requestBody)))
+		request = request.WithContext(context.WithValue(request.Context(), contextKeyUserID, userID))
+
+		recorder := httptest.NewRecorder()
+		handler.Claim(recorder, request)
+
+		return recorder.Code
+	}
+
+	require.NoError(t, rep.Save(ctx, models.NewShortURL(0, "https://example.com/1", "abc", userID), 0))
+	require.NoError(t, rep.Save(ctx, models.NewShortURL(0, "https://example.com/2", "def", sourceUserID), 0))
+	require.NoError(t, rep.Save(ctx, models.NewShortURL(0, "https://example.com/3", "ghi", sourceUserID), 0))
+
+	// The rejected transfer moves nothing and stays claimable.
+	assert.Equal(t, http.StatusForbidden, claim(2))
+
+	shortURL, err := rep.FindOneByUID(ctx, "def")
+	require.NoError(t, err)
+	assert.Equal(t, sourceUserID, shortURL.UserID)
+
+	assert.Equal(t, http.StatusOK, claim(3))
+
+	shortURL, err = rep.FindOneByUID(ctx, "ghi")
+	require.NoError(t, err)
+	assert.Equal(t, userID, shortURL.UserID)
+}
+
+func TestTransferHandler_Create(t *testing.T) {
+	t.Parallel()
+
+	const contextKeyUserID middlewares.ContextKey = "userID"
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	keyRing := middlewares.NewJWTKeyRing("1", "signature_key", nil, time.Time{})
+	userID := uuid.New()
+
+	rep := mocks.NewMockRepository(ctrl)
+	handler := handlers.NewTransferHandler(
+		rep,
+		utils.NewConfigQuotaManager(configs.NewDefaultQuotaConfig(), rep, logger.NewNop()),
+		keyRing,
+		contextKeyUserID,
+		logger.NewNop(),
+	)
+
+	request := httptest.NewRequest(http.MethodPost, "/api/user/transfers", nil)
+	request = request.WithContext(context.WithValue(request.Context(), contextKeyUserID, userID))
+
+	recorder := httptest.NewRecorder()
+	handler.Create(recorder, request)
+	result := recorder.Result()
+
+	response := struct {
+		Token string `json:"token"`
+	}{Token: ""}
+
+	require.NoError(t, json.NewDecoder(result.Body).Decode(&response))
+	require.NoError(t, result.Body.Close())
+
+	assert.Equal(t, http.StatusCreated, result.StatusCode)
+
+	jwt, err := keyRing.Verify(response.Token, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, userID, jwt.Payload.UserID)
+	assert.Equal(t, middlewares.JWTAudienceTransfer, jwt.Payload.Audience)
+	assert.NotEmpty(t, jwt.Payload.ID)
+}
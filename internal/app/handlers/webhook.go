@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+const (
+	ParameterNameWebhookID = "id"
+
+	MessageIncorrectEventType = "incorrect event type"
+	MessageIncorrectWebhookID = "incorrect webhook ID"
+	MessageWebhookNotFound    = "webhook not found"
+
+	webhookDeliveriesLimit = 100
+)
+
+type createWebhookRequestJSON struct {
+	URL    models.URL `json:"url"`
+	Events []string   `json:"events"`
+}
+
+type webhookResponseJSON struct {
+	ID        uuid.UUID  `json:"id"`
+	URL       models.URL `json:"url"`
+	Secret    string     `json:"secret,omitempty"`
+	Events    []string   `json:"events"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NewWebhookResponseJSON builds a response for webhook. The secret is shown only right after creation.
+func NewWebhookResponseJSON(webhook *models.Webhook, isSecretShown bool) interface{} {
+	response := webhookResponseJSON{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Secret:    "",
+		Events:    webhook.EventTypes,
+		CreatedAt: webhook.CreatedAt,
+	}
+
+	if isSecretShown {
+		response.Secret = webhook.Secret
+	}
+
+	if response.Events == nil {
+		response.Events = []string{}
+	}
+
+	return &response
+}
+
+func NewWebhooksResponseJSON(webhooks []*models.Webhook) interface{} {
+	response := make([]interface{}, 0, len(webhooks))
+
+	for _, webhook := range webhooks {
+		response = append(response, NewWebhookResponseJSON(webhook, false))
+	}
+
+	return &response
+}
+
+func NewWebhookDeliveriesResponseJSON(deliveries []*models.WebhookDelivery) interface{} {
+	type deliveryResponseJSON struct {
+		ID             uuid.UUID       `json:"id"`
+		EventID        uuid.UUID       `json:"event_id"`
+		EventType      string          `json:"event_type"`
+		Payload        json.RawMessage `json:"payload"`
+		Status         string          `json:"status"`
+		Attempts       int             `json:"attempts"`
+		LastStatusCode int             `json:"last_status_code"`
+		LastError      string          `json:"last_error"`
+		CreatedAt      time.Time       `json:"created_at"`
+		UpdatedAt      time.Time       `json:"updated_at"`
+	}
+
+	response := make([]deliveryResponseJSON, 0, len(deliveries))
+
+	for _, delivery := range deliveries {
+		response = append(response, deliveryResponseJSON{
+			ID:             delivery.ID,
+			EventID:        delivery.EventID,
+			EventType:      delivery.EventType,
+			Payload:        json.RawMessage(delivery.Payload),
+			Status:         delivery.Status,
+			Attempts:       delivery.Attempts,
+			LastStatusCode: delivery.LastStatusCode,
+			LastError:      delivery.LastError,
+			CreatedAt:      delivery.CreatedAt,
+			UpdatedAt:      delivery.UpdatedAt,
+		})
+	}
+
+	return &response
+}
+
+type WebhookHandler struct {
+	rep                  repositories.WebhookRepository
+	allowPrivateNetworks bool
+	contextKeyUserID     middlewares.ContextKey
+	log                  *logger.Logger
+}
+
+func NewWebhookHandler(
+	rep repositories.WebhookRepository,
+	allowPrivateNetworks bool,
+	contextKeyUserID middlewares.ContextKey,
+	log *logger.Logger,
+) *WebhookHandler {
+	return &WebhookHandler{
+		rep:                  rep,
+		allowPrivateNetworks: allowPrivateNetworks,
+		contextKeyUserID:     contextKeyUserID,
+		log:                  log,
+	}
+}
+
+func (h WebhookHandler) Create(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
+	if !ok {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
+
+		return
+	}
+
+	reader, err := getRequestReader(request)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	defer func(reader io.ReadCloser) {
+		err := reader.Close()
+		if err != nil {
+			utils.WriteError(writer, request, utils.NewInternalProblem())
+			h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+		}
+	}(reader)
+
+	requestJSON := createWebhookRequestJSON{URL: "", Events: nil}
+	if err := json.NewDecoder(reader).Decode(&requestJSON); err != nil {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeIncorrectJSON,
+			MessageIncorrectJSON,
+		))
+
+		return
+	}
+
+	if !requestJSON.URL.IsValid() {
+		writeFieldError(writer, request, MessageIncorrectURL, "/url")
+
+		return
+	}
+
+	if err := utils.CheckWebhookURL(requestJSON.URL, h.allowPrivateNetworks); err != nil {
+		writeURLPolicyError(writer, request, err, "/url", h.log)
+
+		return
+	}
+
+	for index, eventType := range requestJSON.Events {
+		if !isWebhookEventType(eventType) {
+			writeFieldError(
+				writer,
+				request,
+				fmt.Sprintf("%s %q", MessageIncorrectEventType, eventType),
+				fmt.Sprintf("/events/%d", index),
+			)
+
+			return
+		}
+	}
+
+	secret, err := utils.GenerateWebhookSecret()
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	webhook := models.NewWebhook(userID, requestJSON.URL, secret, requestJSON.Events)
+
+	if err := h.rep.SaveWebhook(request.Context(), webhook); err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writeJSON(writer, request, http.StatusCreated, NewWebhookResponseJSON(webhook, true), h.log)
+}
+
+func (h WebhookHandler) List(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
+	if !ok {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
+
+		return
+	}
+
+	webhooks, err := h.rep.FindAllWebhooksByUserID(request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			writer.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writeJSON(writer, request, http.StatusOK, NewWebhooksResponseJSON(webhooks), h.log)
+}
+
+func (h WebhookHandler) Delete(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
+	if !ok {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
+
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(request, ParameterNameWebhookID))
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeBadRequest,
+			MessageIncorrectWebhookID,
+		))
+
+		return
+	}
+
+	if err := h.rep.DeleteWebhook(request.Context(), userID, id); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			utils.WriteError(writer, request, utils.NewProblem(
+				http.StatusNotFound,
+				utils.ProblemCodeNotFound,
+				MessageWebhookNotFound,
+			))
+
+			return
+		}
+
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// Deliveries shows the latest deliveries of the webhook, the newest first.
+func (h WebhookHandler) Deliveries(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := request.Context().Value(h.contextKeyUserID).(uuid.UUID)
+	if !ok {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(MessageIncorrectUserID)
+
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(request, ParameterNameWebhookID))
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusBadRequest,
+			utils.ProblemCodeBadRequest,
+			MessageIncorrectWebhookID,
+		))
+
+		return
+	}
+
+	isOwned, err := h.isOwnWebhook(request, userID, id)
+	if err != nil {
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	if !isOwned {
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusNotFound,
+			utils.ProblemCodeNotFound,
+			MessageWebhookNotFound,
+		))
+
+		return
+	}
+
+	deliveries, err := h.rep.FindAllWebhookDeliveriesByWebhookID(request.Context(), id, webhookDeliveriesLimit)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			writer.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+
+		utils.WriteError(writer, request, utils.NewInternalProblem())
+		h.log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+		return
+	}
+
+	writeJSON(writer, request, http.StatusOK, NewWebhookDeliveriesResponseJSON(deliveries), h.log)
+}
+
+func (h WebhookHandler) isOwnWebhook(request *http.Request, userID, id uuid.UUID) (bool, error) {
+	webhooks, err := h.rep.FindAllWebhooksByUserID(request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to find webhooks: %w", err)
+	}
+
+	for _, webhook := range webhooks {
+		if webhook.ID == id {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func isWebhookEventType(eventType string) bool {
+	for _, knownType := range models.WebhookEventTypes {
+		if knownType == eventType {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,228 @@
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/handlers"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/mocks"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+func TestWebhookHandler_Create(t *testing.T) {
+	t.Parallel()
+
+	const contextKeyUserID middlewares.ContextKey = "userID"
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	// test case 4
+	userID4 := uuid.New()
+	rep4 := mocks.NewMockWebhookRepository(ctrl)
+	rep4.EXPECT().SaveWebhook(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, webhook *models.Webhook) error {
+			assert.Equal(t, userID4, webhook.UserID)
+			assert.Equal(t, models.URL("https://crm.example.com/hook"), webhook.URL)
+			assert.Equal(t, []string{models.WebhookEventLinkCreated}, webhook.EventTypes)
+			assert.NotEmpty(t, webhook.Secret)
+
+			return nil
+		},
+	)
+
+	tests := []struct {
+		name       string
+		rep        repositories.WebhookRepository
+		userID     uuid.UUID
+		body       string
+		statusCode int
+		message    string
+	}{
+		{
+			name:       "test case 1: incorrect json",
+			rep:        mocks.NewMockWebhookRepository(ctrl),
+			userID:     uuid.New(),
+			body:       `bad json`,
+			statusCode: http.StatusBadRequest,
+			message:    fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), handlers.MessageIncorrectJSON),
+		},
+		{
+			name:       "test case 2: incorrect url",
+			rep:        mocks.NewMockWebhookRepository(ctrl),
+			userID:     uuid.New(),
+			body:       `{"url":"not a url"}`,
+			statusCode: http.StatusBadRequest,
+			message: fmt.Sprintf(
+				"%s: %s: /url: %s",
+				http.StatusText(http.StatusBadRequest),
+				handlers.MessageInvalidRequestBody,
+				handlers.MessageIncorrectURL,
+			),
+		},
+		{
+			name:       "test case 3: incorrect event type",
+			rep:        mocks.NewMockWebhookRepository(ctrl),
+			userID:     uuid.New(),
+			body:       `{"url":"https://crm.example.com/hook","events":["link.renamed"]}`,
+			statusCode: http.StatusBadRequest,
+			message: fmt.Sprintf(
+				"%s: %s: /events/0: %s %q",
+				http.StatusText(http.StatusBadRequest),
+				handlers.MessageInvalidRequestBody,
+				handlers.MessageIncorrectEventType,
+				"link.renamed",
+			),
+		},
+		{
+			name:       "test case 4: created",
+			rep:        rep4,
+			userID:     userID4,
+			body:       `{"url":"https://crm.example.com/hook","events":["link.created"]}`,
+			statusCode: http.StatusCreated,
+			message:    "",
+		},
+		{
+			name:       "test case 5: private network",
+			rep:        mocks.NewMockWebhookRepository(ctrl),
+			userID:     uuid.New(),
+			body:       `{"url":"http://127.0.0.1:8080/hook"}`,
+			statusCode: http.StatusBadRequest,
+			message: fmt.Sprintf(
+				"%s: %s (%s): %s: /url: %s",
+				http.StatusText(http.StatusBadRequest),
+				handlers.MessageURLPolicyViolation,
+				utils.URLPolicyRulePrivateNetwork,
+				`host "127.0.0.1" belongs to a private network`,
+				`host "127.0.0.1" belongs to a private network`,
+			),
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := handlers.NewWebhookHandler(testCase.rep, false, contextKeyUserID, logger.NewNop())
+
+			request := httptest.NewRequest(http.MethodPost, "/api/user/webhooks", strings.NewReader(testCase.body))
+			request = request.WithContext(context.WithValue(request.Context(), contextKeyUserID, testCase.userID))
+
+			recorder := httptest.NewRecorder()
+			handler.Create(recorder, request)
+			result := recorder.Result()
+
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.statusCode, result.StatusCode)
+
+			if testCase.message != "" {
+				assert.Equal(t, testCase.message, strings.TrimSuffix(string(body), "\n"))
+			} else {
+				assert.Contains(t, string(body), `"secret":"whsec_`)
+			}
+		})
+	}
+}
+
+func TestWebhookHandler_Deliveries(t *testing.T) {
+	t.Parallel()
+
+	const contextKeyUserID middlewares.ContextKey = "userID"
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	// test case 2
+	rep2 := mocks.NewMockWebhookRepository(ctrl)
+	rep2.EXPECT().FindAllWebhooksByUserID(gomock.Any(), gomock.Any()).Return(nil, repositories.ErrNotFound)
+
+	// test case 3
+	userID3 := uuid.New()
+	webhook3 := models.NewWebhook(userID3, "https://crm.example.com/hook", "secret", nil)
+	delivery3 := models.NewWebhookDelivery(webhook3.ID, uuid.New(), models.WebhookEventLinkClicked, `{"type":"link.clicked"}`)
+	delivery3.Status = models.WebhookDeliveryStatusDeadLetter
+	delivery3.Attempts = 5
+	delivery3.LastStatusCode = http.StatusInternalServerError
+	rep3 := mocks.NewMockWebhookRepository(ctrl)
+	rep3.EXPECT().FindAllWebhooksByUserID(gomock.Any(), userID3).Return([]*models.Webhook{webhook3}, nil)
+	rep3.EXPECT().FindAllWebhookDeliveriesByWebhookID(gomock.Any(), webhook3.ID, gomock.Any()).
+		Return([]*models.WebhookDelivery{delivery3}, nil)
+
+	tests := []struct {
+		name       string
+		rep        repositories.WebhookRepository
+		userID     uuid.UUID
+		webhookID  string
+		statusCode int
+		contains   string
+	}{
+		{
+			name:       "test case 1: incorrect webhook id",
+			rep:        mocks.NewMockWebhookRepository(ctrl),
+			userID:     uuid.New(),
+			webhookID:  "bad-id",
+			statusCode: http.StatusBadRequest,
+			contains:   handlers.MessageIncorrectWebhookID,
+		},
+		{
+			name:       "test case 2: webhook of another user",
+			rep:        rep2,
+			userID:     uuid.New(),
+			webhookID:  webhook3.ID.String(),
+			statusCode: http.StatusNotFound,
+			contains:   handlers.MessageWebhookNotFound,
+		},
+		{
+			name:       "test case 3: dead-lettered delivery",
+			rep:        rep3,
+			userID:     userID3,
+			webhookID:  webhook3.ID.String(),
+			statusCode: http.StatusOK,
+			contains: `"event_type":"link.clicked","payload":{"type":"link.clicked"},"status":"dead_letter",` +
+				`"attempts":5,"last_status_code":500`,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := handlers.NewWebhookHandler(testCase.rep, false, contextKeyUserID, logger.NewNop())
+
+			routeContext := chi.NewRouteContext()
+			routeContext.URLParams.Add(handlers.ParameterNameWebhookID, testCase.webhookID)
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, routeContext))
+			request = request.WithContext(context.WithValue(request.Context(), contextKeyUserID, testCase.userID))
+
+			recorder := httptest.NewRecorder()
+			handler.Deliveries(recorder, request)
+			result := recorder.Result()
+
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.statusCode, result.StatusCode)
+			assert.Contains(t, string(body), testCase.contains)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"context"
+)
+
+type contextKey int
+
+const (
+	contextKeyRequestID contextKey = iota
+	contextKeyUserID
+	contextKeyLogger
+)
+
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKeyRequestID, requestID)
+}
+
+// RequestID returns the ID of the request which started the work of the context, empty if it is unknown.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(contextKeyRequestID).(string)
+
+	return requestID
+}
+
+func ContextWithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, contextKeyUserID, userID)
+}
+
+func UserID(ctx context.Context) string {
+	userID, _ := ctx.Value(contextKeyUserID).(string)
+
+	return userID
+}
+
+func ContextWithLogger(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, contextKeyLogger, logger)
+}
+
+/*
+FromContext returns the logger of the request which started the work of the context with its request and user IDs,
+the default logger is used for work outside requests.
+*/
+func FromContext(ctx context.Context) *Logger {
+	logger, ok := ctx.Value(contextKeyLogger).(*Logger)
+	if !ok {
+		logger = Default()
+	}
+
+	return logger.Ctx(ctx)
+}
+
+/*
+Detach returns a background context which keeps the request and user IDs of ctx, but not its deadline
+and cancellation. Work which outlives the request uses it, so its lines still carry the originating request ID.
+*/
+func Detach(ctx context.Context) context.Context {
+	detached := context.Background()
+
+	if requestID := RequestID(ctx); requestID != "" {
+		detached = ContextWithRequestID(detached, requestID)
+	}
+
+	if userID := UserID(ctx); userID != "" {
+		detached = ContextWithUserID(detached, userID)
+	}
+
+	return detached
+}
+
+// Ctx returns a logger which adds the request and user IDs of ctx to every line.
+func (l *Logger) Ctx(ctx context.Context) *Logger {
+	fields := make([]interface{}, 0, 4)
+
+	if requestID := RequestID(ctx); requestID != "" {
+		fields = append(fields, KeyRequestID, requestID)
+	}
+
+	if userID := UserID(ctx); userID != "" {
+		fields = append(fields, KeyUserID, userID)
+	}
+
+	return l.With(fields...)
+}
@@ -0,0 +1,321 @@
+package logger
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+
+	KeyTime      = "time"
+	KeyLevel     = "level"
+	KeyMessage   = "msg"
+	KeyError     = "error"
+	KeyRequestID = "request_id"
+	KeyUserID    = "user_id"
+
+	keyBadKey = "!BADKEY"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+
+	return strconv.Itoa(int(l))
+}
+
+func ParseLevel(name string) (Level, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(strings.TrimSpace(name), levelName) {
+			return level, nil
+		}
+	}
+
+	return LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+func ParseFormat(name string) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(name))
+	if format != FormatJSON && format != FormatLogfmt {
+		return "", fmt.Errorf("unknown log format %q", name)
+	}
+
+	return format, nil
+}
+
+// sink is shared by a logger and every logger derived from it, so lines of concurrent goroutines never interleave.
+type sink struct {
+	mu     sync.Mutex
+	writer io.Writer
+	level  Level
+	format string
+	now    func() time.Time
+}
+
+/*
+Logger writes leveled structured lines as JSON objects or logfmt.
+Fields are given as alternating keys and values, e.g. Error("failed to delete", "uids", uids, KeyError, err).
+Loggers made by With and Ctx share the output of their parent and add fields to every line.
+*/
+type Logger struct {
+	sink   *sink
+	fields []interface{}
+}
+
+func New(writer io.Writer, level Level, format string) *Logger {
+	return &Logger{
+		sink: &sink{
+			mu:     sync.Mutex{},
+			writer: writer,
+			level:  level,
+			format: format,
+			now:    time.Now,
+		},
+		fields: nil,
+	}
+}
+
+// NewNop returns a logger which discards every line.
+func NewNop() *Logger {
+	return New(io.Discard, LevelError+1, FormatJSON)
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultLogger = New(os.Stderr, LevelInfo, FormatJSON)
+)
+
+// Default returns the logger of code which has no logger injected, e.g. package level helpers.
+func Default() *Logger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+
+	return defaultLogger
+}
+
+func SetDefault(logger *Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	defaultLogger = logger
+}
+
+// With returns a logger which adds the fields to every line.
+func (l *Logger) With(keysAndValues ...interface{}) *Logger {
+	if len(keysAndValues) == 0 {
+		return l
+	}
+
+	fields := make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keysAndValues...)
+
+	return &Logger{sink: l.sink, fields: fields}
+}
+
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.sink.level
+}
+
+func (l *Logger) Debug(msg string, keysAndValues ...interface{}) {
+	l.log(LevelDebug, msg, keysAndValues)
+}
+
+func (l *Logger) Info(msg string, keysAndValues ...interface{}) {
+	l.log(LevelInfo, msg, keysAndValues)
+}
+
+func (l *Logger) Warn(msg string, keysAndValues ...interface{}) {
+	l.log(LevelWarn, msg, keysAndValues)
+}
+
+func (l *Logger) Error(msg string, keysAndValues ...interface{}) {
+	l.log(LevelError, msg, keysAndValues)
+}
+
+// Panic writes the line with the error level and panics with the message and the fields.
+func (l *Logger) Panic(msg string, keysAndValues ...interface{}) {
+	l.log(LevelError, msg, keysAndValues)
+
+	panic(formatLine(FormatLogfmt, nil, msg, keysAndValues))
+}
+
+// Fatal writes the line with the error level and exits with status 1.
+func (l *Logger) Fatal(msg string, keysAndValues ...interface{}) {
+	l.log(LevelError, msg, keysAndValues)
+
+	os.Exit(1)
+}
+
+func (l *Logger) log(level Level, msg string, keysAndValues []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	head := []interface{}{KeyTime, l.sink.now().UTC().Format(time.RFC3339Nano), KeyLevel, level.String()}
+
+	fields := make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keysAndValues...)
+
+	line := formatLine(l.sink.format, head, msg, fields)
+
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+
+	_, _ = io.WriteString(l.sink.writer, line+"\n")
+}
+
+func formatLine(format string, head []interface{}, msg string, fields []interface{}) string {
+	pairs := make([]interface{}, 0, len(head)+2+len(fields)+1)
+	pairs = append(pairs, head...)
+	pairs = append(pairs, KeyMessage, msg)
+	pairs = append(pairs, fields...)
+
+	if len(pairs)%2 != 0 {
+		pairs = append(pairs[:len(pairs)-1], keyBadKey, pairs[len(pairs)-1])
+	}
+
+	if format == FormatLogfmt {
+		return formatLogfmt(pairs)
+	}
+
+	return formatJSON(pairs)
+}
+
+func formatJSON(pairs []interface{}) string {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for index := 0; index < len(pairs); index += 2 {
+		if index > 0 {
+			buf.WriteByte(',')
+		}
+
+		buf.Write(marshalJSON(keyString(pairs[index])))
+		buf.WriteByte(':')
+		buf.Write(marshalJSON(jsonValue(pairs[index+1])))
+	}
+
+	buf.WriteByte('}')
+
+	return buf.String()
+}
+
+func marshalJSON(value interface{}) []byte {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(value); err != nil {
+		return marshalJSON(fmt.Sprintf("%+v", value))
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+func jsonValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case error:
+		return typedValue.Error()
+	case json.Marshaler, encoding.TextMarshaler:
+		return typedValue
+	case fmt.Stringer:
+		return typedValue.String()
+	case time.Duration:
+		return typedValue.String()
+	default:
+		return typedValue
+	}
+}
+
+func formatLogfmt(pairs []interface{}) string {
+	var builder strings.Builder
+
+	for index := 0; index < len(pairs); index += 2 {
+		if index > 0 {
+			builder.WriteByte(' ')
+		}
+
+		builder.WriteString(strings.Map(func(r rune) rune {
+			if r <= ' ' || r == '=' || r == '"' {
+				return '_'
+			}
+
+			return r
+		}, keyString(pairs[index])))
+		builder.WriteByte('=')
+		builder.WriteString(logfmtValue(pairs[index+1]))
+	}
+
+	return builder.String()
+}
+
+func logfmtValue(value interface{}) string {
+	var text string
+
+	switch typedValue := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		text = typedValue
+	case error:
+		text = typedValue.Error()
+	case fmt.Stringer:
+		text = typedValue.String()
+	case encoding.TextMarshaler:
+		raw, err := typedValue.MarshalText()
+		if err != nil {
+			text = fmt.Sprintf("%+v", value)
+		} else {
+			text = string(raw)
+		}
+	case []string, []interface{}, map[string]interface{}:
+		text = string(marshalJSON(typedValue))
+	default:
+		text = fmt.Sprintf("%+v", value)
+	}
+
+	if text == "" || strings.ContainsAny(text, " =\"\\\t\r\n") {
+		return strconv.Quote(text)
+	}
+
+	return text
+}
+
+func keyString(key interface{}) string {
+	if text, ok := key.(string); ok {
+		return text
+	}
+
+	return fmt.Sprint(key)
+}
@@ -0,0 +1,144 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/logger"
+)
+
+func TestLogger_JSON(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	log := logger.New(&buf, logger.LevelInfo, logger.FormatJSON)
+	log.With("component", "test").Error("failed to save", "links", 2, logger.KeyError, errors.New("disk is full"))
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+
+	assert.NotEmpty(t, line[logger.KeyTime])
+	delete(line, logger.KeyTime)
+	assert.Equal(t, map[string]interface{}{
+		logger.KeyLevel:   "error",
+		logger.KeyMessage: "failed to save",
+		"component":       "test",
+		"links":           float64(2),
+		logger.KeyError:   "disk is full",
+	}, line)
+}
+
+func TestLogger_Logfmt(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	log := logger.New(&buf, logger.LevelDebug, logger.FormatLogfmt)
+	log.Debug("request served", "path", "/api/shorten", "status", 201, "note", "two words")
+
+	line := strings.TrimSuffix(buf.String(), "\n")
+	assert.Regexp(t, `^time=\S+ level=debug msg="request served" path=/api/shorten status=201 note="two words"$`, line)
+}
+
+func TestLogger_Level(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		level logger.Level
+		lines int
+	}{
+		{
+			name:  "debug - every line",
+			level: logger.LevelDebug,
+			lines: 4,
+		},
+		{
+			name:  "warn - warnings and errors",
+			level: logger.LevelWarn,
+			lines: 2,
+		},
+		{
+			name:  "error - errors only",
+			level: logger.LevelError,
+			lines: 1,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			log := logger.New(&buf, testCase.level, logger.FormatJSON)
+			log.Debug("debug")
+			log.Info("info")
+			log.Warn("warn")
+			log.Error("error")
+
+			assert.Equal(t, testCase.lines, strings.Count(buf.String(), "\n"))
+		})
+	}
+}
+
+func TestLogger_Ctx(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	ctx := logger.ContextWithRequestID(context.Background(), "req-1")
+	ctx = logger.ContextWithUserID(ctx, "user-1")
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	detached := logger.Detach(cancelCtx)
+	assert.NoError(t, detached.Err())
+
+	logger.New(&buf, logger.LevelInfo, logger.FormatJSON).Ctx(detached).Info("links are deleted")
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+
+	assert.Equal(t, "req-1", line[logger.KeyRequestID])
+	assert.Equal(t, "user-1", line[logger.KeyUserID])
+}
+
+func TestFromContext(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	ctx := logger.ContextWithRequestID(context.Background(), "req-1")
+	ctx = logger.ContextWithLogger(ctx, logger.New(&buf, logger.LevelInfo, logger.FormatJSON))
+
+	logger.FromContext(ctx).Error("failed to write problem")
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+
+	assert.Equal(t, "req-1", line[logger.KeyRequestID])
+	assert.Equal(t, "failed to write problem", line[logger.KeyMessage])
+}
+
+func TestParseLevel(t *testing.T) {
+	t.Parallel()
+
+	level, err := logger.ParseLevel(" WARN ")
+	require.NoError(t, err)
+	assert.Equal(t, logger.LevelWarn, level)
+
+	_, err = logger.ParseLevel("verbose")
+	assert.Error(t, err)
+
+	_, err = logger.ParseFormat("xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+const (
+	RedirectResultServed   = "served"
+	RedirectResultNotFound = "not_found"
+	RedirectResultGone     = "gone"
+	RedirectResultDisabled = "disabled"
+	RedirectResultBanned   = "banned"
+	RedirectResultBlocked  = "blocked"
+	RedirectResultWarned   = "warned"
+	RedirectResultError    = "error"
+
+	// RouteUnmatched labels requests which match no route, so unknown paths do not make new series.
+	RouteUnmatched = "unmatched"
+)
+
+// FlushSizeBuckets are upper bounds of the histogram of deletion buffer flush sizes.
+var FlushSizeBuckets = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000}
+
+// Metrics are the metrics of the application, they are exposed by the registry.
+type Metrics struct {
+	Registry *Registry
+
+	HTTPRequests        *CounterVec
+	HTTPRequestDuration *HistogramVec
+
+	Redirects *CounterVec
+
+	DeletionQueueDepth    *Gauge
+	DeletionFlushSize     *HistogramVec
+	DeletionFlushDuration *HistogramVec
+
+	RepositoryCallDuration *HistogramVec
+}
+
+func NewMetrics() *Metrics {
+	registry := NewRegistry()
+
+	return &Metrics{
+		Registry: registry,
+		HTTPRequests: registry.NewCounterVec(
+			"shorturl_http_requests_total",
+			"Number of HTTP requests by route pattern, method and status.",
+			"route", "method", "status",
+		),
+		HTTPRequestDuration: registry.NewHistogramVec(
+			"shorturl_http_request_duration_seconds",
+			"Latency of HTTP requests by route pattern, method and status.",
+			DefaultDurationBuckets,
+			"route", "method", "status",
+		),
+		Redirects: registry.NewCounterVec(
+			"shorturl_redirects_total",
+			"Number of redirects by result: served, not_found, gone, disabled, banned, blocked, warned or error.",
+			"result",
+		),
+		DeletionQueueDepth: registry.NewGauge(
+			"shorturl_deletion_buffer_queue_depth",
+			"Number of links waiting in the deletion buffer.",
+		),
+		DeletionFlushSize: registry.NewHistogramVec(
+			"shorturl_deletion_buffer_flush_size",
+			"Number of links deleted by a flush of the deletion buffer.",
+			FlushSizeBuckets,
+			"status",
+		),
+		DeletionFlushDuration: registry.NewHistogramVec(
+			"shorturl_deletion_buffer_flush_duration_seconds",
+			"Duration of flushes of the deletion buffer.",
+			DefaultDurationBuckets,
+			"status",
+		),
+		RepositoryCallDuration: registry.NewHistogramVec(
+			"shorturl_repository_call_duration_seconds",
+			"Latency of repository calls by backend, method and status.",
+			DefaultDurationBuckets,
+			"backend", "method", "status",
+		),
+	}
+}
+
+func (m *Metrics) ObserveHTTPRequest(route, method string, status int, duration time.Duration) {
+	if route == "" {
+		route = RouteUnmatched
+	}
+
+	statusLabel := strconv.Itoa(status)
+
+	m.HTTPRequests.Inc(route, method, statusLabel)
+	m.HTTPRequestDuration.Observe(duration.Seconds(), route, method, statusLabel)
+}
+
+func (m *Metrics) ObserveRedirect(result string) {
+	m.Redirects.Inc(result)
+}
+
+func (m *Metrics) ObserveDeletionFlush(size int, duration time.Duration, err error) {
+	status := StatusLabel(err)
+
+	m.DeletionFlushSize.Observe(float64(size), status)
+	m.DeletionFlushDuration.Observe(duration.Seconds(), status)
+}
+
+func (m *Metrics) ObserveRepositoryCall(backend, method string, duration time.Duration, err error) {
+	m.RepositoryCallDuration.Observe(duration.Seconds(), backend, method, StatusLabel(err))
+}
+
+// RegisterDBStats exposes statistics of the database connection pool, stats is called on every scrape.
+func (m *Metrics) RegisterDBStats(stats func() sql.DBStats) {
+	gauges := []struct {
+		name  string
+		help  string
+		value func(stats sql.DBStats) float64
+	}{
+		{
+			name:  "shorturl_db_max_open_connections",
+			help:  "Maximum number of open connections to the database.",
+			value: func(stats sql.DBStats) float64 { return float64(stats.MaxOpenConnections) },
+		},
+		{
+			name:  "shorturl_db_open_connections",
+			help:  "Number of established connections both in use and idle.",
+			value: func(stats sql.DBStats) float64 { return float64(stats.OpenConnections) },
+		},
+		{
+			name:  "shorturl_db_in_use_connections",
+			help:  "Number of connections currently in use.",
+			value: func(stats sql.DBStats) float64 { return float64(stats.InUse) },
+		},
+		{
+			name:  "shorturl_db_idle_connections",
+			help:  "Number of idle connections.",
+			value: func(stats sql.DBStats) float64 { return float64(stats.Idle) },
+		},
+	}
+
+	for _, gauge := range gauges {
+		value := gauge.value
+		m.Registry.NewGaugeFunc(gauge.name, gauge.help, func() float64 { return value(stats()) })
+	}
+
+	counters := []struct {
+		name  string
+		help  string
+		value func(stats sql.DBStats) float64
+	}{
+		{
+			name:  "shorturl_db_wait_count_total",
+			help:  "Total number of connections waited for.",
+			value: func(stats sql.DBStats) float64 { return float64(stats.WaitCount) },
+		},
+		{
+			name:  "shorturl_db_wait_duration_seconds_total",
+			help:  "Total time blocked waiting for a new connection.",
+			value: func(stats sql.DBStats) float64 { return stats.WaitDuration.Seconds() },
+		},
+		{
+			name:  "shorturl_db_max_idle_closed_total",
+			help:  "Total number of connections closed due to SetMaxIdleConns.",
+			value: func(stats sql.DBStats) float64 { return float64(stats.MaxIdleClosed) },
+		},
+		{
+			name:  "shorturl_db_max_idle_time_closed_total",
+			help:  "Total number of connections closed due to SetConnMaxIdleTime.",
+			value: func(stats sql.DBStats) float64 { return float64(stats.MaxIdleTimeClosed) },
+		},
+		{
+			name:  "shorturl_db_max_lifetime_closed_total",
+			help:  "Total number of connections closed due to SetConnMaxLifetime.",
+			value: func(stats sql.DBStats) float64 { return float64(stats.MaxLifetimeClosed) },
+		},
+	}
+
+	for _, counter := range counters {
+		value := counter.value
+		m.Registry.NewCounterFunc(counter.name, counter.help, func() float64 { return value(stats()) })
+	}
+}
+
+// StatusLabel labels the outcome of a call: "ok" or "error".
+func StatusLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+
+	return "ok"
+}
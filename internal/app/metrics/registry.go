@@ -0,0 +1,408 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	ContentTypeText = "text/plain; version=0.0.4; charset=utf-8"
+
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+
+	labelValueSeparator = "\xff"
+)
+
+// DefaultDurationBuckets are upper bounds of latency histograms in seconds.
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type family interface {
+	write(writer io.Writer) error
+}
+
+// Registry keeps metric families and writes them in the Prometheus text exposition format.
+type Registry struct {
+	mu       sync.Mutex
+	names    map[string]bool
+	families []family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		mu:       sync.Mutex{},
+		names:    map[string]bool{},
+		families: nil,
+	}
+}
+
+func (r *Registry) register(name string, f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[name] {
+		panic(fmt.Sprintf("metric %q is registered already", name))
+	}
+
+	r.names[name] = true
+	r.families = append(r.families, f)
+}
+
+// WriteText writes every family in the order of registration, series of a family are sorted by label values.
+func (r *Registry) WriteText(writer io.Writer) error {
+	r.mu.Lock()
+	families := append([]family(nil), r.families...)
+	r.mu.Unlock()
+
+	bufWriter := bufio.NewWriter(writer)
+
+	for _, f := range families {
+		if err := f.write(bufWriter); err != nil {
+			return fmt.Errorf("failed to write metrics: %w", err)
+		}
+	}
+
+	if err := bufWriter.Flush(); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+
+	return nil
+}
+
+// CounterVec is a family of counters partitioned by labels.
+type CounterVec struct {
+	name       string
+	help       string
+	labelNames []string
+	mu         sync.Mutex
+	values     map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labelValues []string
+	value       float64
+}
+
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	vec := &CounterVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		mu:         sync.Mutex{},
+		values:     map[string]*counterSeries{},
+	}
+
+	r.register(name, vec)
+
+	return vec
+}
+
+// Add increases the counter of the label values, which are given in the order of label names.
+func (v *CounterVec) Add(delta float64, labelValues ...string) {
+	checkLabelValues(v.name, v.labelNames, labelValues)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key := strings.Join(labelValues, labelValueSeparator)
+
+	series, ok := v.values[key]
+	if !ok {
+		series = &counterSeries{labelValues: labelValues, value: 0}
+		v.values[key] = series
+	}
+
+	series.value += delta
+}
+
+func (v *CounterVec) Inc(labelValues ...string) {
+	v.Add(1, labelValues...)
+}
+
+// Value returns the counter of the label values.
+func (v *CounterVec) Value(labelValues ...string) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	series, ok := v.values[strings.Join(labelValues, labelValueSeparator)]
+	if !ok {
+		return 0
+	}
+
+	return series.value
+}
+
+func (v *CounterVec) write(writer io.Writer) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err := writeHeader(writer, v.name, v.help, typeCounter); err != nil {
+		return err
+	}
+
+	for _, key := range sortedKeys(v.values) {
+		series := v.values[key]
+
+		if err := writeSample(writer, v.name, v.labelNames, series.labelValues, series.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HistogramVec is a family of histograms partitioned by labels.
+type HistogramVec struct {
+	name       string
+	help       string
+	buckets    []float64
+	labelNames []string
+	mu         sync.Mutex
+	values     map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64 // Non-cumulative counts of buckets.
+	count       uint64
+	sum         float64
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	sortedBuckets := append([]float64(nil), buckets...)
+	sort.Float64s(sortedBuckets)
+
+	vec := &HistogramVec{
+		name:       name,
+		help:       help,
+		buckets:    sortedBuckets,
+		labelNames: labelNames,
+		mu:         sync.Mutex{},
+		values:     map[string]*histogramSeries{},
+	}
+
+	r.register(name, vec)
+
+	return vec
+}
+
+// Observe adds the value to the histogram of the label values, which are given in the order of label names.
+func (v *HistogramVec) Observe(value float64, labelValues ...string) {
+	checkLabelValues(v.name, v.labelNames, labelValues)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key := strings.Join(labelValues, labelValueSeparator)
+
+	series, ok := v.values[key]
+	if !ok {
+		series = &histogramSeries{labelValues: labelValues, counts: make([]uint64, len(v.buckets)), count: 0, sum: 0}
+		v.values[key] = series
+	}
+
+	if index := sort.SearchFloat64s(v.buckets, value); index < len(v.buckets) {
+		series.counts[index]++
+	}
+
+	series.count++
+	series.sum += value
+}
+
+// Count returns the number of observations of the label values.
+func (v *HistogramVec) Count(labelValues ...string) uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	series, ok := v.values[strings.Join(labelValues, labelValueSeparator)]
+	if !ok {
+		return 0
+	}
+
+	return series.count
+}
+
+func (v *HistogramVec) write(writer io.Writer) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err := writeHeader(writer, v.name, v.help, typeHistogram); err != nil {
+		return err
+	}
+
+	bucketLabelNames := append(append([]string(nil), v.labelNames...), "le")
+
+	for _, key := range sortedKeys(v.values) {
+		series := v.values[key]
+
+		var cumulative uint64
+
+		for index, upperBound := range v.buckets {
+			cumulative += series.counts[index]
+
+			labelValues := append(append([]string(nil), series.labelValues...), formatFloat(upperBound))
+			if err := writeSample(writer, v.name+"_bucket", bucketLabelNames, labelValues, float64(cumulative)); err != nil {
+				return err
+			}
+		}
+
+		labelValues := append(append([]string(nil), series.labelValues...), formatFloat(math.Inf(1)))
+		if err := writeSample(writer, v.name+"_bucket", bucketLabelNames, labelValues, float64(series.count)); err != nil {
+			return err
+		}
+
+		if err := writeSample(writer, v.name+"_sum", v.labelNames, series.labelValues, series.sum); err != nil {
+			return err
+		}
+
+		if err := writeSample(writer, v.name+"_count", v.labelNames, series.labelValues, float64(series.count)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Gauge is a single value which goes up and down.
+type Gauge struct {
+	name  string
+	help  string
+	mu    sync.Mutex
+	value float64
+}
+
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	gauge := &Gauge{name: name, help: help, mu: sync.Mutex{}, value: 0}
+
+	r.register(name, gauge)
+
+	return gauge
+}
+
+func (g *Gauge) Set(value float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.value = value
+}
+
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.value
+}
+
+func (g *Gauge) write(writer io.Writer) error {
+	if err := writeHeader(writer, g.name, g.help, typeGauge); err != nil {
+		return err
+	}
+
+	return writeSample(writer, g.name, nil, nil, g.Value())
+}
+
+// valueFunc is a counter or a gauge which value is read from a function on every scrape.
+type valueFunc struct {
+	name       string
+	help       string
+	metricType string
+	value      func() float64
+}
+
+// NewGaugeFunc registers a gauge which value is read from value on every scrape.
+func (r *Registry) NewGaugeFunc(name, help string, value func() float64) {
+	r.register(name, &valueFunc{name: name, help: help, metricType: typeGauge, value: value})
+}
+
+// NewCounterFunc registers a counter which value is read from value on every scrape, value must never decrease.
+func (r *Registry) NewCounterFunc(name, help string, value func() float64) {
+	r.register(name, &valueFunc{name: name, help: help, metricType: typeCounter, value: value})
+}
+
+func (f *valueFunc) write(writer io.Writer) error {
+	if err := writeHeader(writer, f.name, f.help, f.metricType); err != nil {
+		return err
+	}
+
+	return writeSample(writer, f.name, nil, nil, f.value())
+}
+
+func checkLabelValues(name string, labelNames, labelValues []string) {
+	if len(labelNames) != len(labelValues) {
+		panic(fmt.Sprintf("metric %q expects %d label values, got %d", name, len(labelNames), len(labelValues)))
+	}
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func writeHeader(writer io.Writer, name, help, metricType string) error {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+
+	if _, err := fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType); err != nil {
+		return fmt.Errorf("failed to write header of %q: %w", name, err)
+	}
+
+	return nil
+}
+
+func writeSample(writer io.Writer, name string, labelNames, labelValues []string, value float64) error {
+	var builder strings.Builder
+
+	builder.WriteString(name)
+
+	if len(labelNames) > 0 {
+		builder.WriteByte('{')
+
+		for index, labelName := range labelNames {
+			if index > 0 {
+				builder.WriteByte(',')
+			}
+
+			builder.WriteString(labelName)
+			builder.WriteString(`="`)
+			builder.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labelValues[index]))
+			builder.WriteByte('"')
+		}
+
+		builder.WriteByte('}')
+	}
+
+	builder.WriteByte(' ')
+	builder.WriteString(formatFloat(value))
+	builder.WriteByte('\n')
+
+	if _, err := io.WriteString(writer, builder.String()); err != nil {
+		return fmt.Errorf("failed to write sample of %q: %w", name, err)
+	}
+
+	return nil
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
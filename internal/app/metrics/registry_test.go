@@ -0,0 +1,68 @@
+package metrics_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/metrics"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+
+	requests := registry.NewCounterVec("requests_total", "Number of requests.", "route", "status")
+	requests.Inc("/b", "200")
+	requests.Add(2, "/a", "404")
+	requests.Inc("/a\"\n", "500")
+
+	duration := registry.NewHistogramVec("duration_seconds", "Duration.", []float64{1, 0.1}, "route")
+	duration.Observe(0.05, "/a")
+	duration.Observe(0.1, "/a")
+	duration.Observe(3, "/a")
+
+	depth := registry.NewGauge("queue_depth", "Queue depth.")
+	depth.Set(7)
+
+	registry.NewCounterFunc("waits_total", "Waits.", func() float64 { return 1.5 })
+
+	var buf bytes.Buffer
+	require.NoError(t, registry.WriteText(&buf))
+
+	expected := `# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{route="/a\"\n",status="500"} 1
+requests_total{route="/a",status="404"} 2
+requests_total{route="/b",status="200"} 1
+# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/a",le="0.1"} 2
+duration_seconds_bucket{route="/a",le="1"} 2
+duration_seconds_bucket{route="/a",le="+Inf"} 3
+duration_seconds_sum{route="/a"} 3.15
+duration_seconds_count{route="/a"} 3
+# HELP queue_depth Queue depth.
+# TYPE queue_depth gauge
+queue_depth 7
+# HELP waits_total Waits.
+# TYPE waits_total counter
+waits_total 1.5
+`
+
+	assert.Equal(t, expected, buf.String())
+	assert.Equal(t, float64(2), requests.Value("/a", "404"))
+	assert.Equal(t, uint64(3), duration.Count("/a"))
+}
+
+func TestRegistry_Register(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	registry.NewGauge("queue_depth", "Queue depth.")
+
+	assert.Panics(t, func() { registry.NewGauge("queue_depth", "Queue depth.") })
+	assert.Panics(t, func() { registry.NewCounterVec("requests_total", "", "route").Inc() })
+}
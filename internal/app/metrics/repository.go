@@ -0,0 +1,324 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+)
+
+const (
+	BackendMemory   = "memory"
+	BackendFile     = "file"
+	BackendDatabase = "database"
+)
+
+// Backend is implemented by every storage backend.
+type Backend interface {
+	repositories.Repository
+	repositories.APIKeyRepository
+	repositories.UserRepository
+	repositories.WebhookRepository
+	repositories.OutboxRepository
+	repositories.ChangeFeedRepository
+	repositories.DeletionJobRepository
+}
+
+/*
+InstrumentedRepository measures latencies of calls to the backend by method.
+Expected outcomes such as ErrNotFound or ErrURLDuplicate are labeled apart from failures.
+*/
+type InstrumentedRepository struct {
+	backend Backend
+	name    string
+	metrics *Metrics
+}
+
+func NewInstrumentedRepository(backend Backend, name string, metrics *Metrics) *InstrumentedRepository {
+	return &InstrumentedRepository{
+		backend: backend,
+		name:    name,
+		metrics: metrics,
+	}
+}
+
+// observe is deferred by every method with the start time evaluated at the call.
+func (r InstrumentedRepository) observe(method string, start time.Time, err error) {
+	r.metrics.RepositoryCallDuration.Observe(time.Since(start).Seconds(), r.name, method, repositoryStatusLabel(err))
+}
+
+func repositoryStatusLabel(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, repositories.ErrNotFound), errors.Is(err, repositories.ErrNothingToDelete):
+		return "not_found"
+	case errors.Is(err, repositories.ErrURLDuplicate), errors.Is(err, repositories.ErrUsernameTaken):
+		return "conflict"
+	default:
+		return "error"
+	}
+}
+
+func (r InstrumentedRepository) FindOneByUID(ctx context.Context, uid models.UID) (_ *models.ShortURL, err error) {
+	defer func(start time.Time) { r.observe("FindOneByUID", start, err) }(time.Now())
+
+	return r.backend.FindOneByUID(ctx, uid)
+}
+
+func (r InstrumentedRepository) FindAllByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+) (_ []*models.ShortURL, err error) {
+	defer func(start time.Time) { r.observe("FindAllByUserID", start, err) }(time.Now())
+
+	return r.backend.FindAllByUserID(ctx, userID)
+}
+
+func (r InstrumentedRepository) Save(ctx context.Context, shortURL *models.ShortURL, maxLinks int) (err error) {
+	defer func(start time.Time) { r.observe("Save", start, err) }(time.Now())
+
+	return r.backend.Save(ctx, shortURL, maxLinks)
+}
+
+func (r InstrumentedRepository) BatchSave(
+	ctx context.Context,
+	shortURLs []*models.ShortURL,
+	maxLinks int,
+) (err error) {
+	defer func(start time.Time) { r.observe("BatchSave", start, err) }(time.Now())
+
+	return r.backend.BatchSave(ctx, shortURLs, maxLinks)
+}
+
+func (r InstrumentedRepository) BatchDelete(ctx context.Context, shortURLs []*models.ShortURL) (err error) {
+	defer func(start time.Time) { r.observe("BatchDelete", start, err) }(time.Now())
+
+	return r.backend.BatchDelete(ctx, shortURLs)
+}
+
+func (r InstrumentedRepository) FindAllByUserIDAndUIDs(
+	ctx context.Context,
+	userID uuid.UUID,
+	uids []models.UID,
+) (_ []*models.ShortURL, err error) {
+	defer func(start time.Time) { r.observe("FindAllByUserIDAndUIDs", start, err) }(time.Now())
+
+	return r.backend.FindAllByUserIDAndUIDs(ctx, userID, uids)
+}
+
+func (r InstrumentedRepository) CountActiveByUserID(ctx context.Context, userID uuid.UUID) (_ int, err error) {
+	defer func(start time.Time) { r.observe("CountActiveByUserID", start, err) }(time.Now())
+
+	return r.backend.CountActiveByUserID(ctx, userID)
+}
+
+func (r InstrumentedRepository) ReassignUserID(
+	ctx context.Context,
+	fromUserID, toUserID uuid.UUID,
+	maxLinks int,
+) (moved, conflicts int, err error) {
+	defer func(start time.Time) { r.observe("ReassignUserID", start, err) }(time.Now())
+
+	return r.backend.ReassignUserID(ctx, fromUserID, toUserID, maxLinks)
+}
+
+func (r InstrumentedRepository) ClaimTransfer(
+	ctx context.Context,
+	tokenID string,
+	expiresAt time.Time,
+	fromUserID, toUserID uuid.UUID,
+	maxLinks int,
+) (moved, conflicts int, err error) {
+	defer func(start time.Time) { r.observe("ClaimTransfer", start, err) }(time.Now())
+
+	return r.backend.ClaimTransfer(ctx, tokenID, expiresAt, fromUserID, toUserID, maxLinks)
+}
+
+func (r InstrumentedRepository) SearchShortURLs(
+	ctx context.Context,
+	filter *models.ShortURLFilter,
+) (_ []*models.ShortURL, err error) {
+	defer func(start time.Time) { r.observe("SearchShortURLs", start, err) }(time.Now())
+
+	return r.backend.SearchShortURLs(ctx, filter)
+}
+
+func (r InstrumentedRepository) SetDisabled(ctx context.Context, uid models.UID, isDisabled bool) (err error) {
+	defer func(start time.Time) { r.observe("SetDisabled", start, err) }(time.Now())
+
+	return r.backend.SetDisabled(ctx, uid, isDisabled)
+}
+
+func (r InstrumentedRepository) SaveBan(ctx context.Context, ban *models.Ban) (err error) {
+	defer func(start time.Time) { r.observe("SaveBan", start, err) }(time.Now())
+
+	return r.backend.SaveBan(ctx, ban)
+}
+
+func (r InstrumentedRepository) DeleteBan(ctx context.Context, userID uuid.UUID) (err error) {
+	defer func(start time.Time) { r.observe("DeleteBan", start, err) }(time.Now())
+
+	return r.backend.DeleteBan(ctx, userID)
+}
+
+func (r InstrumentedRepository) FindBanByUserID(ctx context.Context, userID uuid.UUID) (_ *models.Ban, err error) {
+	defer func(start time.Time) { r.observe("FindBanByUserID", start, err) }(time.Now())
+
+	return r.backend.FindBanByUserID(ctx, userID)
+}
+
+func (r InstrumentedRepository) CountAll(ctx context.Context) (_ *models.GlobalCounts, err error) {
+	defer func(start time.Time) { r.observe("CountAll", start, err) }(time.Now())
+
+	return r.backend.CountAll(ctx)
+}
+
+func (r InstrumentedRepository) RegisterClick(ctx context.Context, uid models.UID) (err error) {
+	defer func(start time.Time) { r.observe("RegisterClick", start, err) }(time.Now())
+
+	return r.backend.RegisterClick(ctx, uid)
+}
+
+func (r InstrumentedRepository) Ping(ctx context.Context) (err error) {
+	defer func(start time.Time) { r.observe("Ping", start, err) }(time.Now())
+
+	return r.backend.Ping(ctx)
+}
+
+func (r InstrumentedRepository) SaveAPIKey(ctx context.Context, apiKey *models.APIKey) (err error) {
+	defer func(start time.Time) { r.observe("SaveAPIKey", start, err) }(time.Now())
+
+	return r.backend.SaveAPIKey(ctx, apiKey)
+}
+
+func (r InstrumentedRepository) FindAPIKeyByHash(ctx context.Context, hash string) (_ *models.APIKey, err error) {
+	defer func(start time.Time) { r.observe("FindAPIKeyByHash", start, err) }(time.Now())
+
+	return r.backend.FindAPIKeyByHash(ctx, hash)
+}
+
+func (r InstrumentedRepository) FindAllAPIKeysByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+) (_ []*models.APIKey, err error) {
+	defer func(start time.Time) { r.observe("FindAllAPIKeysByUserID", start, err) }(time.Now())
+
+	return r.backend.FindAllAPIKeysByUserID(ctx, userID)
+}
+
+func (r InstrumentedRepository) RevokeAPIKey(ctx context.Context, userID, id uuid.UUID) (err error) {
+	defer func(start time.Time) { r.observe("RevokeAPIKey", start, err) }(time.Now())
+
+	return r.backend.RevokeAPIKey(ctx, userID, id)
+}
+
+func (r InstrumentedRepository) SaveUser(ctx context.Context, user *models.User) (err error) {
+	defer func(start time.Time) { r.observe("SaveUser", start, err) }(time.Now())
+
+	return r.backend.SaveUser(ctx, user)
+}
+
+func (r InstrumentedRepository) FindUserByID(ctx context.Context, id uuid.UUID) (_ *models.User, err error) {
+	defer func(start time.Time) { r.observe("FindUserByID", start, err) }(time.Now())
+
+	return r.backend.FindUserByID(ctx, id)
+}
+
+func (r InstrumentedRepository) FindUserByUsername(ctx context.Context, username string) (_ *models.User, err error) {
+	defer func(start time.Time) { r.observe("FindUserByUsername", start, err) }(time.Now())
+
+	return r.backend.FindUserByUsername(ctx, username)
+}
+
+func (r InstrumentedRepository) SaveWebhook(ctx context.Context, webhook *models.Webhook) (err error) {
+	defer func(start time.Time) { r.observe("SaveWebhook", start, err) }(time.Now())
+
+	return r.backend.SaveWebhook(ctx, webhook)
+}
+
+func (r InstrumentedRepository) FindAllWebhooksByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+) (_ []*models.Webhook, err error) {
+	defer func(start time.Time) { r.observe("FindAllWebhooksByUserID", start, err) }(time.Now())
+
+	return r.backend.FindAllWebhooksByUserID(ctx, userID)
+}
+
+func (r InstrumentedRepository) FindWebhookByID(ctx context.Context, id uuid.UUID) (_ *models.Webhook, err error) {
+	defer func(start time.Time) { r.observe("FindWebhookByID", start, err) }(time.Now())
+
+	return r.backend.FindWebhookByID(ctx, id)
+}
+
+func (r InstrumentedRepository) DeleteWebhook(ctx context.Context, userID, id uuid.UUID) (err error) {
+	defer func(start time.Time) { r.observe("DeleteWebhook", start, err) }(time.Now())
+
+	return r.backend.DeleteWebhook(ctx, userID, id)
+}
+
+func (r InstrumentedRepository) SaveWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) (err error) {
+	defer func(start time.Time) { r.observe("SaveWebhookDelivery", start, err) }(time.Now())
+
+	return r.backend.SaveWebhookDelivery(ctx, delivery)
+}
+
+func (r InstrumentedRepository) FindAllWebhookDeliveriesByWebhookID(
+	ctx context.Context,
+	webhookID uuid.UUID,
+	limit int,
+) (_ []*models.WebhookDelivery, err error) {
+	defer func(start time.Time) { r.observe("FindAllWebhookDeliveriesByWebhookID", start, err) }(time.Now())
+
+	return r.backend.FindAllWebhookDeliveriesByWebhookID(ctx, webhookID, limit)
+}
+
+func (r InstrumentedRepository) FindAllPendingWebhookDeliveries(
+	ctx context.Context,
+) (_ []*models.WebhookDelivery, err error) {
+	defer func(start time.Time) { r.observe("FindAllPendingWebhookDeliveries", start, err) }(time.Now())
+
+	return r.backend.FindAllPendingWebhookDeliveries(ctx)
+}
+
+func (r InstrumentedRepository) SaveDeletionJob(ctx context.Context, job *models.DeletionJob) (err error) {
+	defer func(start time.Time) { r.observe("SaveDeletionJob", start, err) }(time.Now())
+
+	return r.backend.SaveDeletionJob(ctx, job)
+}
+
+func (r InstrumentedRepository) FindDeletionJobByID(ctx context.Context, id uuid.UUID) (_ *models.DeletionJob, err error) {
+	defer func(start time.Time) { r.observe("FindDeletionJobByID", start, err) }(time.Now())
+
+	return r.backend.FindDeletionJobByID(ctx, id)
+}
+
+func (r InstrumentedRepository) FindAllPendingDeletionJobs(ctx context.Context) (_ []*models.DeletionJob, err error) {
+	defer func(start time.Time) { r.observe("FindAllPendingDeletionJobs", start, err) }(time.Now())
+
+	return r.backend.FindAllPendingDeletionJobs(ctx)
+}
+
+func (r InstrumentedRepository) PublishOutbox(
+	ctx context.Context,
+	limit int,
+	publish func(messages []*models.OutboxMessage) error,
+) (_ int, err error) {
+	defer func(start time.Time) { r.observe("PublishOutbox", start, err) }(time.Now())
+
+	return r.backend.PublishOutbox(ctx, limit, publish)
+}
+
+func (r InstrumentedRepository) FindChanges(
+	ctx context.Context,
+	since int64,
+	limit int,
+) (_ []*models.Change, err error) {
+	defer func(start time.Time) { r.observe("FindChanges", start, err) }(time.Now())
+
+	return r.backend.FindChanges(ctx, since, limit)
+}
@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/tmitry/shorturl/internal/app/logger"
+)
+
+// AccessLog middleware writes a line per request with its status, size and duration.
+func AccessLog(log *logger.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		AccessLogFunction := func(writer http.ResponseWriter, request *http.Request) {
+			start := time.Now()
+			wrapWriter := middleware.NewWrapResponseWriter(writer, request.ProtoMajor)
+
+			next.ServeHTTP(wrapWriter, request)
+
+			status := wrapWriter.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			log.Ctx(request.Context()).Info(
+				"request served",
+				"method", request.Method,
+				"path", request.URL.Path,
+				"status", status,
+				"bytes", wrapWriter.BytesWritten(),
+				"duration", time.Since(start),
+			)
+		}
+
+		return http.HandlerFunc(AccessLogFunction)
+	}
+}
+
+// Recoverer middleware logs a panic of the handler with its stack and answers 500 Internal Server Error.
+func Recoverer(log *logger.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		RecovererFunction := func(writer http.ResponseWriter, request *http.Request) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+
+				log.Ctx(request.Context()).Error("request panicked", "panic", r, "stack", string(debug.Stack()))
+
+				writer.WriteHeader(http.StatusInternalServerError)
+			}()
+
+			next.ServeHTTP(writer, request)
+		}
+
+		return http.HandlerFunc(RecovererFunction)
+	}
+}
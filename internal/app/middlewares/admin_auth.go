@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+const MessageAdminRequired = "admin role required"
+
+// AdminRole keeps user IDs and API key IDs granted the admin role.
+type AdminRole struct {
+	userIDs   map[uuid.UUID]struct{}
+	apiKeyIDs map[uuid.UUID]struct{}
+}
+
+func NewAdminRole(userIDs, apiKeyIDs []string) (*AdminRole, error) {
+	role := &AdminRole{
+		userIDs:   make(map[uuid.UUID]struct{}, len(userIDs)),
+		apiKeyIDs: make(map[uuid.UUID]struct{}, len(apiKeyIDs)),
+	}
+
+	for _, userID := range userIDs {
+		id, err := uuid.Parse(userID)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect admin user id %q: %w", userID, err)
+		}
+
+		role.userIDs[id] = struct{}{}
+	}
+
+	for _, apiKeyID := range apiKeyIDs {
+		id, err := uuid.Parse(apiKeyID)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect admin API key id %q: %w", apiKeyID, err)
+		}
+
+		role.apiKeyIDs[id] = struct{}{}
+	}
+
+	return role, nil
+}
+
+// AdminOnly middleware rejects requests which are made neither by an admin user nor with an admin API key.
+func AdminOnly(role *AdminRole, contextKeyUserID, contextKeyAPIKey ContextKey) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		AdminOnlyFunction := func(writer http.ResponseWriter, request *http.Request) {
+			if !role.isGranted(request, contextKeyUserID, contextKeyAPIKey) {
+				utils.WriteError(writer, request, utils.NewProblem(
+					http.StatusForbidden,
+					utils.ProblemCodeForbidden,
+					MessageAdminRequired,
+				))
+
+				return
+			}
+
+			next.ServeHTTP(writer, request)
+		}
+
+		return http.HandlerFunc(AdminOnlyFunction)
+	}
+}
+
+func (r AdminRole) isGranted(request *http.Request, contextKeyUserID, contextKeyAPIKey ContextKey) bool {
+	if apiKey, ok := request.Context().Value(contextKeyAPIKey).(*models.APIKey); ok {
+		if _, ok := r.apiKeyIDs[apiKey.ID]; ok {
+			return true
+		}
+	}
+
+	if userID, ok := request.Context().Value(contextKeyUserID).(uuid.UUID); ok {
+		if _, ok := r.userIDs[userID]; ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,95 @@
+package middlewares_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/models"
+)
+
+func TestAdminOnly(t *testing.T) {
+	t.Parallel()
+
+	const (
+		contextKeyUserID middlewares.ContextKey = "userID"
+		contextKeyAPIKey middlewares.ContextKey = "apiKey"
+	)
+
+	adminUserID := uuid.New()
+	adminAPIKey := models.NewAPIKey(uuid.New(), "ops", "sk_abcdef", "hash", nil)
+	userAPIKey := models.NewAPIKey(uuid.New(), "ci", "sk_ghijkl", "hash", nil)
+
+	role, err := middlewares.NewAdminRole([]string{adminUserID.String()}, []string{adminAPIKey.ID.String()})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		userID     uuid.UUID
+		apiKey     *models.APIKey
+		statusCode int
+	}{
+		{
+			name:       "admin user - allowed",
+			userID:     adminUserID,
+			apiKey:     nil,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "admin API key - allowed",
+			userID:     adminAPIKey.UserID,
+			apiKey:     adminAPIKey,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "regular user - forbidden",
+			userID:     uuid.New(),
+			apiKey:     nil,
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "regular API key - forbidden",
+			userID:     userAPIKey.UserID,
+			apiKey:     userAPIKey,
+			statusCode: http.StatusForbidden,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			router := chi.NewRouter()
+			router.Use(middlewares.AdminOnly(role, contextKeyUserID, contextKeyAPIKey))
+			router.Get("/", func(writer http.ResponseWriter, r *http.Request) {})
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			ctx := context.WithValue(request.Context(), contextKeyUserID, testCase.userID)
+
+			if testCase.apiKey != nil {
+				ctx = context.WithValue(ctx, contextKeyAPIKey, testCase.apiKey)
+			}
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request.WithContext(ctx))
+
+			result := recorder.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.statusCode, result.StatusCode)
+		})
+	}
+}
+
+func TestNewAdminRole(t *testing.T) {
+	t.Parallel()
+
+	_, err := middlewares.NewAdminRole([]string{"not-a-uuid"}, nil)
+	assert.Error(t, err)
+}
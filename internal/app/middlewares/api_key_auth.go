@@ -0,0 +1,104 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+const (
+	MessageInvalidAPIKey = "invalid API key"
+	MessageMissingScope  = "API key has no scope"
+
+	bearerPrefix = "Bearer "
+)
+
+/*
+APIKeyAuth middleware provide authentication using API keys sent in the "Authorization: Bearer" header.
+Requests without the header are passed on unchanged, so they can be authenticated by JWTAuth.
+Requests with an unknown or revoked key are rejected. Safe methods require the read scope, other methods
+require the write scope.
+The identifier of the key owner and the key itself are sent down the request context.
+*/
+func APIKeyAuth(
+	rep repositories.APIKeyRepository,
+	contextKeyUserID ContextKey,
+	contextKeyAPIKey ContextKey,
+	log *logger.Logger,
+) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		APIKeyAuthFunction := func(writer http.ResponseWriter, request *http.Request) {
+			authorization := request.Header.Get("Authorization")
+			if authorization == "" {
+				next.ServeHTTP(writer, request)
+
+				return
+			}
+
+			if !strings.HasPrefix(authorization, bearerPrefix) {
+				writeInvalidAPIKey(writer, request)
+
+				return
+			}
+
+			hash := utils.HashAPIKey(strings.TrimSpace(strings.TrimPrefix(authorization, bearerPrefix)))
+
+			apiKey, err := rep.FindAPIKeyByHash(request.Context(), hash)
+			if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+				utils.WriteError(writer, request, utils.NewInternalProblem())
+				log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+				return
+			}
+
+			if err != nil || apiKey.IsRevoked() {
+				writeInvalidAPIKey(writer, request)
+
+				return
+			}
+
+			scope := models.APIKeyScopeWrite
+			if isSafeMethod(request.Method) {
+				scope = models.APIKeyScopeRead
+			}
+
+			if !apiKey.HasScope(scope) {
+				utils.WriteError(writer, request, utils.NewProblem(
+					http.StatusForbidden,
+					utils.ProblemCodeForbidden,
+					fmt.Sprintf("%s %q", MessageMissingScope, scope),
+				))
+
+				return
+			}
+
+			ctx := context.WithValue(request.Context(), contextKeyUserID, apiKey.UserID)
+			ctx = context.WithValue(ctx, contextKeyAPIKey, apiKey)
+			ctx = logger.ContextWithUserID(ctx, apiKey.UserID.String())
+
+			next.ServeHTTP(writer, request.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(APIKeyAuthFunction)
+	}
+}
+
+func writeInvalidAPIKey(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	utils.WriteError(writer, request, utils.NewProblem(
+		http.StatusUnauthorized,
+		utils.ProblemCodeUnauthorized,
+		MessageInvalidAPIKey,
+	))
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,180 @@
+package middlewares_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		method        string
+		authorization string
+	}
+
+	type want struct {
+		statusCode int
+		userID     string
+	}
+
+	const (
+		contextKeyUserID middlewares.ContextKey = "userID"
+		contextKeyAPIKey middlewares.ContextKey = "apiKey"
+	)
+
+	rep := repositories.NewMemoryRepository()
+	userID := uuid.New()
+
+	newKey := func(scopes []string, isRevoked bool) string {
+		key, prefix, err := utils.GenerateAPIKey()
+		require.NoError(t, err)
+
+		apiKey := models.NewAPIKey(userID, "test", prefix, utils.HashAPIKey(key), scopes)
+		if isRevoked {
+			apiKey.RevokedAt = time.Now()
+		}
+
+		require.NoError(t, rep.SaveAPIKey(context.Background(), apiKey))
+
+		return key
+	}
+
+	fullKey := newKey(nil, false)
+	readOnlyKey := newKey([]string{models.APIKeyScopeRead}, false)
+	revokedKey := newKey(nil, true)
+
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			name: "no header - passes to next authentication",
+			args: args{method: http.MethodPost, authorization: ""},
+			want: want{statusCode: http.StatusOK, userID: ""},
+		},
+		{
+			name: "valid key - identifies owner",
+			args: args{method: http.MethodPost, authorization: "Bearer " + fullKey},
+			want: want{statusCode: http.StatusOK, userID: userID.String()},
+		},
+		{
+			name: "unknown key - rejected",
+			args: args{method: http.MethodGet, authorization: "Bearer sk_unknown"},
+			want: want{statusCode: http.StatusUnauthorized, userID: ""},
+		},
+		{
+			name: "not bearer scheme - rejected",
+			args: args{method: http.MethodGet, authorization: "Basic " + fullKey},
+			want: want{statusCode: http.StatusUnauthorized, userID: ""},
+		},
+		{
+			name: "revoked key - rejected",
+			args: args{method: http.MethodGet, authorization: "Bearer " + revokedKey},
+			want: want{statusCode: http.StatusUnauthorized, userID: ""},
+		},
+		{
+			name: "read only key - allows reading",
+			args: args{method: http.MethodGet, authorization: "Bearer " + readOnlyKey},
+			want: want{statusCode: http.StatusOK, userID: userID.String()},
+		},
+		{
+			name: "read only key - forbids writing",
+			args: args{method: http.MethodDelete, authorization: "Bearer " + readOnlyKey},
+			want: want{statusCode: http.StatusForbidden, userID: ""},
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			router := chi.NewRouter()
+			router.Use(middlewares.APIKeyAuth(rep, contextKeyUserID, contextKeyAPIKey, logger.NewNop()))
+			router.HandleFunc("/", func(writer http.ResponseWriter, r *http.Request) {
+				if userID, ok := r.Context().Value(contextKeyUserID).(uuid.UUID); ok {
+					_, _ = writer.Write([]byte(userID.String()))
+				}
+			})
+
+			request := httptest.NewRequest(testCase.args.method, "/", nil)
+			if testCase.args.authorization != "" {
+				request.Header.Set("Authorization", testCase.args.authorization)
+			}
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			result := recorder.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.want.statusCode, result.StatusCode)
+
+			if testCase.want.statusCode == http.StatusOK {
+				assert.Equal(t, testCase.want.userID, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuth_ConcurrentRevoke(t *testing.T) {
+	t.Parallel()
+
+	const contextKeyUserID middlewares.ContextKey = "userID"
+
+	rep := repositories.NewMemoryRepository()
+	userID := uuid.New()
+
+	key, prefix, err := utils.GenerateAPIKey()
+	require.NoError(t, err)
+
+	apiKey := models.NewAPIKey(userID, "test", prefix, utils.HashAPIKey(key), nil)
+	require.NoError(t, rep.SaveAPIKey(context.Background(), apiKey))
+
+	router := chi.NewRouter()
+	router.Use(middlewares.APIKeyAuth(rep, contextKeyUserID, "apiKey", logger.NewNop()))
+	router.Get("/", func(writer http.ResponseWriter, r *http.Request) {})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set("Authorization", "Bearer "+key)
+			router.ServeHTTP(httptest.NewRecorder(), request)
+		}()
+	}
+
+	require.NoError(t, rep.RevokeAPIKey(context.Background(), userID, apiKey.ID))
+	wg.Wait()
+
+	// The saved key is a copy, the caller's key is not revoked.
+	assert.False(t, apiKey.IsRevoked())
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", "Bearer "+key)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+const MessageUserIsBanned = "user is banned"
+
+// RefuseBanned middleware rejects requests of banned users. It guards routes which create or move links.
+func RefuseBanned(
+	rep repositories.Repository,
+	contextKeyUserID ContextKey,
+	log *logger.Logger,
+) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		RefuseBannedFunction := func(writer http.ResponseWriter, request *http.Request) {
+			userID, ok := request.Context().Value(contextKeyUserID).(uuid.UUID)
+			if !ok {
+				next.ServeHTTP(writer, request)
+
+				return
+			}
+
+			_, err := rep.FindBanByUserID(request.Context(), userID)
+			if err == nil {
+				utils.WriteError(writer, request, utils.NewProblem(
+					http.StatusForbidden,
+					utils.ProblemCodeUserIsBanned,
+					MessageUserIsBanned,
+				))
+
+				return
+			}
+
+			if !errors.Is(err, repositories.ErrNotFound) {
+				utils.WriteError(writer, request, utils.NewInternalProblem())
+				log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+				return
+			}
+
+			next.ServeHTTP(writer, request)
+		}
+
+		return http.HandlerFunc(RefuseBannedFunction)
+	}
+}
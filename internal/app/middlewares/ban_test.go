@@ -0,0 +1,66 @@
+package middlewares_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+)
+
+func TestRefuseBanned(t *testing.T) {
+	t.Parallel()
+
+	const contextKeyUserID middlewares.ContextKey = "userID"
+
+	rep := repositories.NewMemoryRepository()
+	bannedUserID := uuid.New()
+
+	require.NoError(t, rep.SaveBan(context.Background(), models.NewBan(bannedUserID, "spam")))
+
+	tests := []struct {
+		name       string
+		userID     uuid.UUID
+		statusCode int
+	}{
+		{
+			name:       "banned user - forbidden",
+			userID:     bannedUserID,
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "regular user - allowed",
+			userID:     uuid.New(),
+			statusCode: http.StatusOK,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			router := chi.NewRouter()
+			router.Use(middlewares.RefuseBanned(rep, contextKeyUserID, logger.NewNop()))
+			router.Post("/", func(writer http.ResponseWriter, r *http.Request) {})
+
+			request := httptest.NewRequest(http.MethodPost, "/", nil)
+			request = request.WithContext(context.WithValue(request.Context(), contextKeyUserID, testCase.userID))
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			result := recorder.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.statusCode, result.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package middlewares
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseTrustedProxies parses CIDRs and single IP addresses of trusted proxies.
+func ParseTrustedProxies(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("failed to parse trusted proxy %q", cidr)
+			}
+
+			bits := net.IPv6len * 8
+			if ip.To4() != nil {
+				bits = net.IPv4len * 8
+			}
+
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse trusted proxy %q: %w", cidr, err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+/*
+ClientIP returns the address of the client which sent the request.
+X-Forwarded-For and X-Real-IP are taken into account only when the request came from a trusted proxy.
+X-Forwarded-For is read from right to left, the first address which is not a trusted proxy is the client.
+*/
+func ClientIP(request *http.Request, trustedProxies []*net.IPNet) net.IP {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+
+	remoteIP := net.ParseIP(host)
+	if remoteIP == nil || !isTrustedProxy(remoteIP, trustedProxies) {
+		return remoteIP
+	}
+
+	if forwardedFor := request.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+		addresses := strings.Split(forwardedFor, ",")
+
+		for index := len(addresses) - 1; index >= 0; index-- {
+			ip := net.ParseIP(strings.TrimSpace(addresses[index]))
+			if ip == nil {
+				break
+			}
+
+			if index == 0 || !isTrustedProxy(ip, trustedProxies) {
+				return ip
+			}
+		}
+	}
+
+	if realIP := net.ParseIP(strings.TrimSpace(request.Header.Get("X-Real-IP"))); realIP != nil {
+		return realIP
+	}
+
+	return remoteIP
+}
+
+func isTrustedProxy(ip net.IP, trustedProxies []*net.IPNet) bool {
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
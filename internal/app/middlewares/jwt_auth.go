@@ -8,57 +8,84 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/utils"
 )
 
 type ContextKey string
 
 const (
 	jwtAlg = "HS256"
-	jwtTyp = "jwt"
+	jwtTyp = "JWT"
+
+	// jwtLegacyTyp marks tokens issued before expiration was introduced. They carry no kid and exp claims.
+	jwtLegacyTyp = "jwt"
+
+	// JWTAudienceTransfer marks tokens which allow to claim links of the issuer. They never authenticate requests.
+	JWTAudienceTransfer = "transfer"
 )
 
-var ErrNoCorrectJWT = errors.New("jwt cookie not present or incorrect")
+var (
+	ErrNoCorrectJWT      = errors.New("jwt cookie not present or incorrect")
+	ErrIncorrectJWTKey   = errors.New("incorrect jwt key")
+	ErrIncorrectSameSite = errors.New("incorrect SameSite value")
+)
 
 /*
-JWTAuth middleware provide authentication using jwt (HS256).
-It checks user and if user not exist or incorrect (including incorrect jwt signature) then creates a new.
+JWTAuth middleware provide authentication using jwt (RFC 7519, HS256).
+It checks user and if user not exist or incorrect (including incorrect jwt signature or expired jwt)
+then creates a new. Tokens expiring within the renewal window are re-issued for the same user.
+Requests already authenticated by a previous middleware (e.g. APIKeyAuth) are passed on unchanged.
 The identifier is sent down the request context.
 */
-func JWTAuth(signatureKey, jwtCookieName string, contextKeyUserID ContextKey) func(next http.Handler) http.Handler {
+func JWTAuth(
+	keyRing *JWTKeyRing,
+	options *JWTOptions,
+	contextKeyUserID ContextKey,
+	log *logger.Logger,
+) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		JWTAuthFunction := func(writer http.ResponseWriter, request *http.Request) {
-			jwt, err := jwtRead(request, signatureKey, jwtCookieName)
-			if err != nil && !errors.Is(err, ErrNoCorrectJWT) {
-				http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-				log.Println(err.Error())
+			if _, ok := request.Context().Value(contextKeyUserID).(uuid.UUID); ok {
+				next.ServeHTTP(writer, request)
 
 				return
 			}
 
-			if err != nil {
-				jwt, err = NewJWT(signatureKey)
-				if err != nil {
-					http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-					log.Println(err.Error())
+			now := time.Now()
 
-					return
+			jwt, err := ReadJWTCookie(request, keyRing, options.CookieName, now)
+			if err != nil && !errors.Is(err, ErrNoCorrectJWT) {
+				utils.WriteError(writer, request, utils.NewInternalProblem())
+				log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+				return
+			}
+
+			if err != nil || jwt.needsRenewal(now, options.RenewBefore, keyRing.signingKeyID) {
+				userID := uuid.New()
+				if err == nil {
+					userID = jwt.Payload.UserID
 				}
 
-				err = jwtWrite(writer, jwt, jwtCookieName)
+				jwt = NewJWT(userID, now, options.Lifetime)
+
+				err = WriteJWTCookie(writer, keyRing, options, jwt)
 				if err != nil {
-					http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-					log.Println(err.Error())
+					utils.WriteError(writer, request, utils.NewInternalProblem())
+					log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
 
 					return
 				}
 			}
 
-			request = request.WithContext(context.WithValue(request.Context(), contextKeyUserID, jwt.Payload.UserID))
+			ctx := context.WithValue(request.Context(), contextKeyUserID, jwt.Payload.UserID)
+			request = request.WithContext(logger.ContextWithUserID(ctx, jwt.Payload.UserID.String()))
 
 			next.ServeHTTP(writer, request)
 		}
@@ -67,156 +94,281 @@ func JWTAuth(signatureKey, jwtCookieName string, contextKeyUserID ContextKey) fu
 	}
 }
 
-type jwtHeader struct {
-	Alg string `json:"alg"`
-	Typ string `json:"typ"`
+// JWTOptions describes lifetime of issued tokens and attributes of the cookie they are stored in.
+type JWTOptions struct {
+	CookieName  string
+	Lifetime    time.Duration
+	RenewBefore time.Duration
+	HTTPOnly    bool
+	Secure      bool
+	SameSite    http.SameSite
 }
 
-type jwtPayload struct {
-	UserID uuid.UUID `json:"user_id"`
+func NewJWTOptions(
+	cookieName string,
+	lifetime, renewBefore time.Duration,
+	httpOnly, secure bool,
+	sameSite http.SameSite,
+) *JWTOptions {
+	return &JWTOptions{
+		CookieName:  cookieName,
+		Lifetime:    lifetime,
+		RenewBefore: renewBefore,
+		HTTPOnly:    httpOnly,
+		Secure:      secure,
+		SameSite:    sameSite,
+	}
 }
 
-type JWT struct {
-	Header    jwtHeader
-	Payload   jwtPayload
-	Signature []byte
+func ParseSameSite(sameSite string) (http.SameSite, error) {
+	switch strings.ToLower(sameSite) {
+	case "":
+		return http.SameSiteDefaultMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrIncorrectSameSite, sameSite)
+	}
 }
 
-func NewJWT(signatureKey string) (*JWT, error) {
-	userID := uuid.New()
+/*
+JWTKeyRing signs tokens with the current key and verifies them with any known key selected by kid.
+Legacy tokens never expire by themselves, so they are accepted only before legacyUntil.
+*/
+type JWTKeyRing struct {
+	signingKeyID string
+	keys         map[string][]byte
+	legacyUntil  time.Time
+}
 
-	jwtHeader := jwtHeader{
-		Alg: jwtAlg,
-		Typ: jwtTyp,
+func NewJWTKeyRing(
+	signingKeyID, signingKey string,
+	verificationKeys map[string]string,
+	legacyUntil time.Time,
+) *JWTKeyRing {
+	keys := make(map[string][]byte, len(verificationKeys)+1)
+	for keyID, key := range verificationKeys {
+		keys[keyID] = []byte(key)
 	}
 
-	jwtPayload := jwtPayload{
-		UserID: userID,
-	}
+	keys[signingKeyID] = []byte(signingKey)
 
-	jwt := &JWT{
-		Header:  jwtHeader,
-		Payload: jwtPayload,
+	return &JWTKeyRing{
+		signingKeyID: signingKeyID,
+		keys:         keys,
+		legacyUntil:  legacyUntil,
 	}
+}
 
-	var err error
+// ParseJWTVerificationKeys parses "kid:key" pairs.
+func ParseJWTVerificationKeys(pairs []string) (map[string]string, error) {
+	keys := make(map[string]string, len(pairs))
 
-	jwt.Signature, err = jwt.GenerateSignature(signatureKey)
-	if err != nil {
-		return nil, err
+	for _, pair := range pairs {
+		keyID, key, ok := strings.Cut(pair, ":")
+		if !ok || keyID == "" || key == "" {
+			return nil, fmt.Errorf("%w: expected \"kid:key\"", ErrIncorrectJWTKey)
+		}
+
+		keys[keyID] = key
 	}
 
-	return jwt, nil
+	return keys, nil
 }
 
-func (j JWT) GenerateSignature(signatureKey string) ([]byte, error) {
-	jsonHeader, err := json.Marshal(j.Header)
+func (r *JWTKeyRing) Sign(jwt *JWT) (string, error) {
+	jwt.Header.Kid = r.signingKeyID
+
+	jsonHeader, err := json.Marshal(jwt.Header)
 	if err != nil {
-		return []byte{}, fmt.Errorf("failed to marshal jwt header: %w", err)
+		return "", fmt.Errorf("failed to marshal jwt header: %w", err)
 	}
 
-	base64Header := base64.StdEncoding.EncodeToString(jsonHeader)
-
-	jsonPayload, err := json.Marshal(j.Payload)
+	jsonPayload, err := json.Marshal(jwt.Payload)
 	if err != nil {
-		return []byte{}, fmt.Errorf("failed to marshal jwt payload: %w", err)
+		return "", fmt.Errorf("failed to marshal jwt payload: %w", err)
 	}
 
-	base64Payload := base64.StdEncoding.EncodeToString(jsonPayload)
+	signingInput := fmt.Sprintf(
+		"%s.%s",
+		base64.RawURLEncoding.EncodeToString(jsonHeader),
+		base64.RawURLEncoding.EncodeToString(jsonPayload),
+	)
 
-	hash := hmac.New(sha256.New, []byte(signatureKey))
-	hash.Write([]byte(fmt.Sprintf("%s.%s", base64Header, base64Payload)))
+	signature := generateSignature(r.keys[r.signingKeyID], signingInput)
 
-	return hash.Sum(nil), nil
+	return fmt.Sprintf("%s.%s", signingInput, base64.RawURLEncoding.EncodeToString(signature)), nil
 }
 
-func (j JWT) GetString() (string, error) {
-	jsonHeader, err := json.Marshal(j.Header)
+// Verify parses token and checks its signature and expiration time (the migration window for legacy tokens).
+func (r *JWTKeyRing) Verify(token string, now time.Time) (*JWT, error) {
+	jwt, signingInput, signature, err := jwtParse(token)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal jwt header: %w", err)
+		return nil, err
+	}
+
+	if jwt.Header.Alg != jwtAlg {
+		return nil, ErrNoCorrectJWT
 	}
 
-	header := base64.StdEncoding.EncodeToString(jsonHeader)
+	keyID := jwt.Header.Kid
 
-	jsonPayload, err := json.Marshal(j.Payload)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal jwt payload: %w", err)
+	switch jwt.Header.Typ {
+	case jwtTyp:
+		if jwt.Payload.ExpiresAt == 0 || !now.Before(time.Unix(jwt.Payload.ExpiresAt, 0)) {
+			return nil, ErrNoCorrectJWT
+		}
+	case jwtLegacyTyp:
+		if !now.Before(r.legacyUntil) {
+			return nil, ErrNoCorrectJWT
+		}
+
+		keyID = r.signingKeyID
+	default:
+		return nil, ErrNoCorrectJWT
 	}
 
-	payload := base64.StdEncoding.EncodeToString(jsonPayload)
+	key, ok := r.keys[keyID]
+	if !ok || !hmac.Equal(generateSignature(key, signingInput), signature) {
+		return nil, ErrNoCorrectJWT
+	}
 
-	signature := base64.StdEncoding.EncodeToString(j.Signature)
+	return jwt, nil
+}
 
-	return fmt.Sprintf("%s.%s.%s", header, payload, signature), nil
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid,omitempty"`
 }
 
-func (j JWT) isValidHeader() bool {
-	return j.Header.Typ == jwtTyp && j.Header.Alg == jwtAlg
+type jwtPayload struct {
+	ID        string    `json:"jti,omitempty"`
+	UserID    uuid.UUID `json:"user_id"`
+	IssuedAt  int64     `json:"iat,omitempty"`
+	ExpiresAt int64     `json:"exp,omitempty"`
+	Audience  string    `json:"aud,omitempty"`
 }
 
-func jwtParse(s string) (*JWT, error) {
-	const jwtSize = 3
+type JWT struct {
+	Header  jwtHeader
+	Payload jwtPayload
+}
 
-	jwtParts := strings.Split(s, ".")
-	if len(jwtParts) != jwtSize {
-		return nil, ErrNoCorrectJWT
+func NewJWT(userID uuid.UUID, issuedAt time.Time, lifetime time.Duration) *JWT {
+	return &JWT{
+		Header: jwtHeader{
+			Alg: jwtAlg,
+			Typ: jwtTyp,
+			Kid: "",
+		},
+		Payload: jwtPayload{
+			ID:        "",
+			UserID:    userID,
+			IssuedAt:  issuedAt.Unix(),
+			ExpiresAt: issuedAt.Add(lifetime).Unix(),
+			Audience:  "",
+		},
 	}
+}
 
-	base64Header := jwtParts[0]
-	base64Payload := jwtParts[1]
-	base64Signature := jwtParts[2]
+// NewTransferJWT creates a token which allows another user to claim links of userID once, jti identifies the claim.
+func NewTransferJWT(userID uuid.UUID, issuedAt time.Time, lifetime time.Duration) *JWT {
+	jwt := NewJWT(userID, issuedAt, lifetime)
+	jwt.Payload.ID = uuid.NewString()
+	jwt.Payload.Audience = JWTAudienceTransfer
 
-	jsonHeader, err := base64.StdEncoding.DecodeString(base64Header)
+	return jwt
+}
+
+// needsRenewal reports whether the token is legacy, signed by a retired key or expires within renewBefore.
+func (j JWT) needsRenewal(now time.Time, renewBefore time.Duration, signingKeyID string) bool {
+	return j.Header.Typ == jwtLegacyTyp ||
+		j.Header.Kid != signingKeyID ||
+		time.Unix(j.Payload.ExpiresAt, 0).Sub(now) < renewBefore
+}
+
+func generateSignature(key []byte, signingInput string) []byte {
+	hash := hmac.New(sha256.New, key)
+	hash.Write([]byte(signingInput))
+
+	return hash.Sum(nil)
+}
+
+// decodeSegment decodes base64url segments. Legacy tokens use standard base64 with padding.
+func decodeSegment(segment string) ([]byte, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(segment)
+	if err == nil {
+		return decoded, nil
+	}
+
+	decoded, err = base64.StdEncoding.DecodeString(segment)
 	if err != nil {
 		return nil, ErrNoCorrectJWT
 	}
 
-	jwtHeader := jwtHeader{
-		Alg: "",
-		Typ: "",
+	return decoded, nil
+}
+
+func jwtParse(token string) (*JWT, string, []byte, error) {
+	const jwtSize = 3
+
+	jwtParts := strings.Split(token, ".")
+	if len(jwtParts) != jwtSize {
+		return nil, "", nil, ErrNoCorrectJWT
 	}
 
-	err = json.Unmarshal(jsonHeader, &jwtHeader)
+	jsonHeader, err := decodeSegment(jwtParts[0])
 	if err != nil {
-		return nil, ErrNoCorrectJWT
+		return nil, "", nil, err
 	}
 
-	jsonPayload, err := base64.StdEncoding.DecodeString(base64Payload)
+	jsonPayload, err := decodeSegment(jwtParts[1])
 	if err != nil {
-		return nil, ErrNoCorrectJWT
+		return nil, "", nil, err
 	}
 
-	jwtPayload := jwtPayload{
-		UserID: uuid.UUID{},
+	signature, err := decodeSegment(jwtParts[2])
+	if err != nil {
+		return nil, "", nil, err
 	}
 
-	err = json.Unmarshal(jsonPayload, &jwtPayload)
-	if err != nil {
-		return nil, ErrNoCorrectJWT
+	jwt := &JWT{
+		Header:  jwtHeader{Alg: "", Typ: "", Kid: ""},
+		Payload: jwtPayload{UserID: uuid.UUID{}, IssuedAt: 0, ExpiresAt: 0, Audience: ""},
 	}
 
-	signature, err := base64.StdEncoding.DecodeString(base64Signature)
-	if err != nil {
-		return nil, ErrNoCorrectJWT
+	if err := json.Unmarshal(jsonHeader, &jwt.Header); err != nil {
+		return nil, "", nil, ErrNoCorrectJWT
 	}
 
-	return &JWT{
-		Header:    jwtHeader,
-		Payload:   jwtPayload,
-		Signature: signature,
-	}, nil
+	if err := json.Unmarshal(jsonPayload, &jwt.Payload); err != nil {
+		return nil, "", nil, ErrNoCorrectJWT
+	}
+
+	return jwt, jwtParts[0] + "." + jwtParts[1], signature, nil
 }
 
-func jwtWrite(writer http.ResponseWriter, jwt *JWT, jwtCookieName string) error {
-	val, err := jwt.GetString()
+// WriteJWTCookie signs jwt and stores it in the cookie described by options.
+func WriteJWTCookie(writer http.ResponseWriter, keyRing *JWTKeyRing, options *JWTOptions, jwt *JWT) error {
+	val, err := keyRing.Sign(jwt)
 	if err != nil {
 		return err
 	}
 
 	jwtCookie := &http.Cookie{
-		Name:  jwtCookieName,
-		Value: val,
-		Path:  "/",
+		Name:     options.CookieName,
+		Value:    val,
+		Path:     "/",
+		Expires:  time.Unix(jwt.Payload.ExpiresAt, 0),
+		MaxAge:   int(time.Until(time.Unix(jwt.Payload.ExpiresAt, 0)).Seconds()),
+		HttpOnly: options.HTTPOnly,
+		Secure:   options.Secure,
+		SameSite: options.SameSite,
 	}
 
 	http.SetCookie(writer, jwtCookie)
@@ -224,7 +376,24 @@ func jwtWrite(writer http.ResponseWriter, jwt *JWT, jwtCookieName string) error
 	return nil
 }
 
-func jwtRead(request *http.Request, signatureKey, jwtCookieName string) (*JWT, error) {
+// ClearJWTCookie tells the browser to drop the cookie described by options.
+func ClearJWTCookie(writer http.ResponseWriter, options *JWTOptions) {
+	http.SetCookie(writer, &http.Cookie{
+		Name:     options.CookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: options.HTTPOnly,
+		Secure:   options.Secure,
+		SameSite: options.SameSite,
+	})
+}
+
+/*
+ReadJWTCookie reads and verifies jwt stored in the cookie. It returns ErrNoCorrectJWT if there is no valid jwt.
+Tokens issued for an audience (e.g. transfer tokens) are not accepted in cookies.
+*/
+func ReadJWTCookie(request *http.Request, keyRing *JWTKeyRing, jwtCookieName string, now time.Time) (*JWT, error) {
 	jwtCookie, err := request.Cookie(jwtCookieName)
 	if err != nil {
 		if errors.Is(err, http.ErrNoCookie) {
@@ -234,21 +403,12 @@ func jwtRead(request *http.Request, signatureKey, jwtCookieName string) (*JWT, e
 		return nil, fmt.Errorf("failed to read cookie: %w", err)
 	}
 
-	jwt, err := jwtParse(jwtCookie.Value)
-	if err != nil {
-		return nil, err
-	}
-
-	if !jwt.isValidHeader() {
-		return nil, ErrNoCorrectJWT
-	}
-
-	correctSignature, err := jwt.GenerateSignature(signatureKey)
+	jwt, err := keyRing.Verify(jwtCookie.Value, now)
 	if err != nil {
 		return nil, err
 	}
 
-	if !hmac.Equal(correctSignature, jwt.Signature) {
+	if jwt.Payload.Audience != "" {
 		return nil, ErrNoCorrectJWT
 	}
 
@@ -2,14 +2,21 @@ package middlewares_test
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/logger"
 	"github.com/tmitry/shorturl/internal/app/middlewares"
 )
 
@@ -17,53 +24,109 @@ func TestJWTAuth(t *testing.T) {
 	t.Parallel()
 
 	type args struct {
-		signatureKey     string
 		jwtCookieName    string
 		contextKeyUserID middlewares.ContextKey
-		jwt              *middlewares.JWT
-		isCorrect        bool
+		token            string
 	}
 
-	jwtIncorrectSignature, err := middlewares.NewJWT("incorrect_signature_key")
-	require.NoError(t, err)
+	type want struct {
+		userID    *uuid.UUID
+		isRenewed bool
+	}
+
+	const lifetime = 24 * time.Hour
+
+	now := time.Now()
+
+	keyRing := middlewares.NewJWTKeyRing(
+		"2",
+		"signature_key",
+		map[string]string{"1": "retired_signature_key"},
+		now.Add(lifetime),
+	)
+	retiredKeyRing := middlewares.NewJWTKeyRing("1", "retired_signature_key", nil, time.Time{})
+	incorrectKeyRing := middlewares.NewJWTKeyRing("2", "incorrect_signature_key", nil, time.Time{})
+	userID := uuid.New()
+
+	sign := func(keyRing *middlewares.JWTKeyRing, jwt *middlewares.JWT) string {
+		token, err := keyRing.Sign(jwt)
+		require.NoError(t, err)
 
-	jwt, err := middlewares.NewJWT("signature_key")
-	require.NoError(t, err)
+		return token
+	}
 
 	tests := []struct {
 		name string
 		args args
-		want func(next http.Handler) http.Handler
+		want want
 	}{
 		{
-			name: "incorrect empty jwt - generates new user uuid",
+			name: "empty jwt - generates new user uuid",
+			args: args{jwtCookieName: "jwt_cookie", contextKeyUserID: "UserID", token: ""},
+			want: want{userID: nil, isRenewed: true},
+		},
+		{
+			name: "incorrect jwt signature - generates new user uuid",
 			args: args{
-				signatureKey:     "signature_key",
-				jwtCookieName:    "jwt_cookie",
-				contextKeyUserID: "UserID",
-				jwt:              nil,
-				isCorrect:        false,
+				jwtCookieName:    "jwt",
+				contextKeyUserID: "ID",
+				token:            sign(incorrectKeyRing, middlewares.NewJWT(userID, now, lifetime)),
 			},
+			want: want{userID: nil, isRenewed: true},
 		},
 		{
-			name: "incorrect jwt signature - generates new user uuid",
+			name: "expired jwt - generates new user uuid",
 			args: args{
-				signatureKey:     "signature_key",
 				jwtCookieName:    "jwt",
 				contextKeyUserID: "ID",
-				jwt:              jwtIncorrectSignature,
-				isCorrect:        false,
+				token:            sign(keyRing, middlewares.NewJWT(userID, now.Add(-2*lifetime), lifetime)),
 			},
+			want: want{userID: nil, isRenewed: true},
+		},
+		{
+			name: "transfer jwt - generates new user uuid",
+			args: args{
+				jwtCookieName:    "jwt",
+				contextKeyUserID: "ID",
+				token:            sign(keyRing, middlewares.NewTransferJWT(userID, now, lifetime)),
+			},
+			want: want{userID: nil, isRenewed: true},
 		},
 		{
 			name: "correct jwt - uses uuid from request",
 			args: args{
-				signatureKey:     "signature_key",
 				jwtCookieName:    "JWT",
 				contextKeyUserID: "userID",
-				jwt:              jwt,
-				isCorrect:        true,
+				token:            sign(keyRing, middlewares.NewJWT(userID, now, lifetime)),
+			},
+			want: want{userID: &userID, isRenewed: false},
+		},
+		{
+			name: "jwt close to expiration - renews for the same user",
+			args: args{
+				jwtCookieName:    "JWT",
+				contextKeyUserID: "userID",
+				token:            sign(keyRing, middlewares.NewJWT(userID, now.Add(-lifetime+time.Minute), lifetime)),
 			},
+			want: want{userID: &userID, isRenewed: true},
+		},
+		{
+			name: "jwt signed by retired key - renews for the same user",
+			args: args{
+				jwtCookieName:    "JWT",
+				contextKeyUserID: "userID",
+				token:            sign(retiredKeyRing, middlewares.NewJWT(userID, now, lifetime)),
+			},
+			want: want{userID: &userID, isRenewed: true},
+		},
+		{
+			name: "legacy jwt - renews for the same user",
+			args: args{
+				jwtCookieName:    "JWT",
+				contextKeyUserID: "userID",
+				token:            newLegacyJWT(userID, "signature_key"),
+			},
+			want: want{userID: &userID, isRenewed: true},
 		},
 	}
 	for _, testCase := range tests {
@@ -76,9 +139,10 @@ func TestJWTAuth(t *testing.T) {
 			router := chi.NewRouter()
 
 			router.Use(middlewares.JWTAuth(
-				testCase.args.signatureKey,
-				testCase.args.jwtCookieName,
+				keyRing,
+				middlewares.NewJWTOptions(testCase.args.jwtCookieName, lifetime, time.Hour, true, true, http.SameSiteLaxMode),
 				testCase.args.contextKeyUserID,
+				logger.NewNop(),
 			))
 			router.Post("/", func(writer http.ResponseWriter, r *http.Request) {
 				userID, ok := r.Context().Value(testCase.args.contextKeyUserID).(uuid.UUID)
@@ -97,31 +161,22 @@ func TestJWTAuth(t *testing.T) {
 			body := []byte("The best content in the world. Than you for your attention.")
 			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
 
-			if testCase.args.jwt != nil {
-				val, err := testCase.args.jwt.GetString()
-				assert.NoError(t, err)
-
-				cookie := &http.Cookie{
+			if testCase.args.token != "" {
+				req.AddCookie(&http.Cookie{
 					Name:  testCase.args.jwtCookieName,
-					Value: val,
-				}
-				req.AddCookie(cookie)
+					Value: testCase.args.token,
+				})
 			}
 
 			router.ServeHTTP(recorder, req)
 
-			_, err := uuid.FromBytes(recorder.Body.Bytes())
+			requestUserID, err := uuid.FromBytes(recorder.Body.Bytes())
 			assert.NoError(t, err)
 
-			if testCase.args.jwt != nil {
-				uuidBinary, err := testCase.args.jwt.Payload.UserID.MarshalBinary()
-				assert.NoError(t, err)
-
-				if testCase.args.isCorrect {
-					assert.Equal(t, recorder.Body.Bytes(), uuidBinary)
-				} else {
-					assert.NotEqual(t, recorder.Body.Bytes(), uuidBinary)
-				}
+			if testCase.want.userID != nil {
+				assert.Equal(t, *testCase.want.userID, requestUserID)
+			} else {
+				assert.NotEqual(t, userID, requestUserID)
 			}
 
 			res := recorder.Result()
@@ -129,6 +184,86 @@ func TestJWTAuth(t *testing.T) {
 			require.NoError(t, err)
 
 			assert.Equal(t, res.StatusCode, http.StatusOK)
+
+			cookies := res.Cookies()
+			if !testCase.want.isRenewed {
+				assert.Empty(t, cookies)
+
+				return
+			}
+
+			require.Len(t, cookies, 1)
+			assert.True(t, cookies[0].HttpOnly)
+			assert.True(t, cookies[0].Secure)
+			assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+			assert.NotContains(t, cookies[0].Value, "=")
+
+			jwt, err := keyRing.Verify(cookies[0].Value, time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, requestUserID, jwt.Payload.UserID)
+			assert.Equal(t, "2", jwt.Header.Kid)
+		})
+	}
+}
+
+func TestJWTKeyRing_Verify_LegacyCutoff(t *testing.T) {
+	t.Parallel()
+
+	cutoff := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	userID := uuid.New()
+	token := newLegacyJWT(userID, "signature_key")
+
+	tests := []struct {
+		name    string
+		keyRing *middlewares.JWTKeyRing
+		now     time.Time
+		isValid bool
+	}{
+		{
+			name:    "test case 1: before cutoff",
+			keyRing: middlewares.NewJWTKeyRing("1", "signature_key", nil, cutoff),
+			now:     cutoff.Add(-time.Second),
+			isValid: true,
+		},
+		{
+			name:    "test case 2: at cutoff",
+			keyRing: middlewares.NewJWTKeyRing("1", "signature_key", nil, cutoff),
+			now:     cutoff,
+			isValid: false,
+		},
+		{
+			name:    "test case 3: no migration window",
+			keyRing: middlewares.NewJWTKeyRing("1", "signature_key", nil, time.Time{}),
+			now:     cutoff.Add(-time.Second),
+			isValid: false,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			jwt, err := testCase.keyRing.Verify(token, testCase.now)
+
+			if !testCase.isValid {
+				assert.ErrorIs(t, err, middlewares.ErrNoCorrectJWT)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, userID, jwt.Payload.UserID)
 		})
 	}
 }
+
+// newLegacyJWT builds a token in the format used before expiration was introduced.
+func newLegacyJWT(userID uuid.UUID, signatureKey string) string {
+	header := base64.StdEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"jwt"}`))
+	payload := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(`{"user_id":"%s"}`, userID)))
+
+	hash := hmac.New(sha256.New, []byte(signatureKey))
+	hash.Write([]byte(strings.Join([]string{header, payload}, ".")))
+
+	return fmt.Sprintf("%s.%s.%s", header, payload, base64.StdEncoding.EncodeToString(hash.Sum(nil)))
+}
@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/tmitry/shorturl/internal/app/metrics"
+)
+
+/*
+Metrics middleware counts requests and measures their latency by the chi route pattern, method and status.
+The pattern is known only after routing, so the middleware must be used by the root router.
+*/
+func Metrics(appMetrics *metrics.Metrics) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		MetricsFunction := func(writer http.ResponseWriter, request *http.Request) {
+			start := time.Now()
+			wrapWriter := middleware.NewWrapResponseWriter(writer, request.ProtoMajor)
+
+			next.ServeHTTP(wrapWriter, request)
+
+			status := wrapWriter.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			route := ""
+			if routeContext := chi.RouteContext(request.Context()); routeContext != nil {
+				route = routeContext.RoutePattern()
+			}
+
+			appMetrics.ObserveHTTPRequest(route, request.Method, status, time.Since(start))
+		}
+
+		return http.HandlerFunc(MetricsFunction)
+	}
+}
@@ -0,0 +1,75 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/tmitry/shorturl/internal/app/metrics"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		route  string
+		status string
+	}{
+		{
+			name:   "route with parameter - pattern",
+			method: http.MethodGet,
+			path:   "/api/user/webhooks/123/deliveries",
+			route:  "/api/user/webhooks/{id}/deliveries",
+			status: "200",
+		},
+		{
+			name:   "explicit status",
+			method: http.MethodDelete,
+			path:   "/api/user/urls",
+			route:  "/api/user/urls",
+			status: "202",
+		},
+		{
+			name:   "unknown path - unmatched",
+			method: http.MethodGet,
+			path:   "/unknown/path",
+			route:  metrics.RouteUnmatched,
+			status: "404",
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			appMetrics := metrics.NewMetrics()
+
+			router := chi.NewRouter()
+			router.Use(middlewares.Metrics(appMetrics))
+			router.Route("/api", func(router chi.Router) {
+				router.Get("/user/webhooks/{id}/deliveries", func(writer http.ResponseWriter, _ *http.Request) {
+					_, _ = writer.Write([]byte("[]"))
+				})
+				router.Delete("/user/urls", func(writer http.ResponseWriter, _ *http.Request) {
+					writer.WriteHeader(http.StatusAccepted)
+				})
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(testCase.method, testCase.path, nil))
+
+			assert.Equal(t, float64(1), appMetrics.HTTPRequests.Value(testCase.route, testCase.method, testCase.status))
+			assert.Equal(
+				t,
+				uint64(1),
+				appMetrics.HTTPRequestDuration.Count(testCase.route, testCase.method, testCase.status),
+			)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+/*
+ProblemDetails middleware makes errors of requests to paths under pathPrefix application/problem+json (RFC 7807).
+It goes before other middlewares, so their errors follow the format too. Other paths keep plain text errors.
+*/
+func ProblemDetails(pathPrefix string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		ProblemDetailsFunction := func(writer http.ResponseWriter, request *http.Request) {
+			if strings.HasPrefix(request.URL.Path, pathPrefix) {
+				request = request.WithContext(utils.WithProblemDetails(request.Context()))
+			}
+
+			next.ServeHTTP(writer, request)
+		}
+
+		return http.HandlerFunc(ProblemDetailsFunction)
+	}
+}
@@ -0,0 +1,61 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+func TestProblemDetails(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+	}{
+		{
+			name:        "api path - problem details",
+			path:        "/api/shorten",
+			contentType: utils.ContentTypeProblemJSON,
+		},
+		{
+			name:        "other path - plain text",
+			path:        "/",
+			contentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:        "path only starting like api - plain text",
+			path:        "/apix",
+			contentType: "text/plain; charset=utf-8",
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := middlewares.ProblemDetails("/api/")(http.HandlerFunc(
+				func(writer http.ResponseWriter, request *http.Request) {
+					utils.WriteError(writer, request, utils.NewProblem(
+						http.StatusBadRequest,
+						utils.ProblemCodeBadRequest,
+						"bad request",
+					))
+				},
+			))
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, testCase.path, nil))
+			result := recorder.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+			assert.Equal(t, testCase.contentType, result.Header.Get("Content-Type"))
+		})
+	}
+}
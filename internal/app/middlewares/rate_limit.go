@@ -0,0 +1,171 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+const (
+	RateLimitClassCreate   = "create"
+	RateLimitClassBatch    = "batch"
+	RateLimitClassDelete   = "delete"
+	RateLimitClassRedirect = "redirect"
+	RateLimitClassLogin    = "login"
+)
+
+/*
+RateLimit middleware limits requests of one route class using token buckets.
+Every request takes a token from the bucket of the user (if the user is known) and from the bucket of the client IP.
+When any of the buckets is empty, no token is taken and 429 Too Many Requests with Retry-After is returned.
+RateLimit-* headers describe the most restrictive bucket.
+Limiter errors are logged and do not block requests.
+*/
+func RateLimit(
+	limiter utils.RateLimiter,
+	class string,
+	rule utils.RateLimitRule,
+	trustedProxies []*net.IPNet,
+	contextKeyUserID ContextKey,
+	log *logger.Logger,
+) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if rule.Limit <= 0 {
+			return next
+		}
+
+		rateLimitFunction := func(writer http.ResponseWriter, request *http.Request) {
+			keys := make([]string, 0, 2)
+
+			if userID, ok := request.Context().Value(contextKeyUserID).(uuid.UUID); ok {
+				keys = append(keys, fmt.Sprintf("%s:user:%s", class, userID))
+			}
+
+			if ip := ClientIP(request, trustedProxies); ip != nil {
+				keys = append(keys, fmt.Sprintf("%s:ip:%s", class, ip))
+			}
+
+			takeToken(writer, request, limiter, keys, rule, log, next)
+		}
+
+		return http.HandlerFunc(rateLimitFunction)
+	}
+}
+
+/*
+LoginRateLimit middleware limits login and register attempts like RateLimit, but the bucket of the user
+is the one of the username in the JSON request body, so guessing passwords of one account is limited
+even if the attacker changes IPs and drops cookies. The body is left for the next handler.
+*/
+func LoginRateLimit(
+	limiter utils.RateLimiter,
+	rule utils.RateLimitRule,
+	trustedProxies []*net.IPNet,
+	log *logger.Logger,
+) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if rule.Limit <= 0 {
+			return next
+		}
+
+		loginRateLimitFunction := func(writer http.ResponseWriter, request *http.Request) {
+			keys := make([]string, 0, 2)
+
+			if username := readUsername(request); username != "" {
+				keys = append(keys, fmt.Sprintf("%s:username:%s", RateLimitClassLogin, username))
+			}
+
+			if ip := ClientIP(request, trustedProxies); ip != nil {
+				keys = append(keys, fmt.Sprintf("%s:ip:%s", RateLimitClassLogin, ip))
+			}
+
+			takeToken(writer, request, limiter, keys, rule, log, next)
+		}
+
+		return http.HandlerFunc(loginRateLimitFunction)
+	}
+}
+
+// readUsername returns the lower-cased username of the JSON request body, empty if there is none.
+func readUsername(request *http.Request) string {
+	if request.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(request.Body)
+	request.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err != nil {
+		return ""
+	}
+
+	var credentials struct {
+		Username string `json:"username"`
+	}
+
+	if err := json.Unmarshal(body, &credentials); err != nil {
+		return ""
+	}
+
+	return strings.ToLower(credentials.Username)
+}
+
+/*
+takeToken takes a token from the buckets of keys and passes the request to next if all of them allow it,
+otherwise it returns 429 Too Many Requests.
+*/
+func takeToken(
+	writer http.ResponseWriter,
+	request *http.Request,
+	limiter utils.RateLimiter,
+	keys []string,
+	rule utils.RateLimitRule,
+	log *logger.Logger,
+	next http.Handler,
+) {
+	if len(keys) == 0 {
+		next.ServeHTTP(writer, request)
+
+		return
+	}
+
+	restrictive, err := limiter.Take(request.Context(), keys, rule)
+	if err != nil {
+		log.Ctx(request.Context()).Error("rate limiter failed", "keys", keys, logger.KeyError, err)
+		next.ServeHTTP(writer, request)
+
+		return
+	}
+
+	writer.Header().Set("RateLimit-Limit", strconv.Itoa(restrictive.Limit))
+	writer.Header().Set("RateLimit-Remaining", strconv.Itoa(restrictive.Remaining))
+	writer.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(restrictive.ResetAfter)))
+
+	if !restrictive.Allowed {
+		writer.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(restrictive.RetryAfter)))
+		utils.WriteError(writer, request, utils.NewProblem(
+			http.StatusTooManyRequests,
+			utils.ProblemCodeTooManyRequests,
+			"",
+		))
+
+		return
+	}
+
+	next.ServeHTTP(writer, request)
+}
+
+func ceilSeconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
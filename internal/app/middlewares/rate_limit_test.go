@@ -0,0 +1,259 @@
+package middlewares_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		userID        any
+		remoteAddr    string
+		xForwardedFor string
+	}
+
+	type args struct {
+		limit          int
+		trustedProxies []string
+		requests       []request
+	}
+
+	type want struct {
+		statusCode int
+		limit      string
+		remaining  string
+		retryAfter string
+	}
+
+	const contextKeyUserID middlewares.ContextKey = "userID"
+
+	userID := uuid.New()
+
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			name: "disabled limit - passes without headers",
+			args: args{
+				limit:          0,
+				trustedProxies: nil,
+				requests: []request{
+					{userID: userID, remoteAddr: "203.0.113.1:1234", xForwardedFor: ""},
+					{userID: userID, remoteAddr: "203.0.113.1:1234", xForwardedFor: ""},
+				},
+			},
+			want: want{statusCode: http.StatusOK, limit: "", remaining: "", retryAfter: ""},
+		},
+		{
+			name: "user limit exhausted from different IPs - too many requests",
+			args: args{
+				limit:          2,
+				trustedProxies: nil,
+				requests: []request{
+					{userID: userID, remoteAddr: "203.0.113.1:1234", xForwardedFor: ""},
+					{userID: userID, remoteAddr: "203.0.113.2:1234", xForwardedFor: ""},
+					{userID: userID, remoteAddr: "203.0.113.3:1234", xForwardedFor: ""},
+				},
+			},
+			want: want{statusCode: http.StatusTooManyRequests, limit: "2", remaining: "0", retryAfter: "30"},
+		},
+		{
+			name: "ip limit exhausted by anonymous requests - too many requests",
+			args: args{
+				limit:          1,
+				trustedProxies: nil,
+				requests: []request{
+					{userID: nil, remoteAddr: "203.0.113.1:1234", xForwardedFor: ""},
+					{userID: nil, remoteAddr: "203.0.113.1:4321", xForwardedFor: ""},
+				},
+			},
+			want: want{statusCode: http.StatusTooManyRequests, limit: "1", remaining: "0", retryAfter: "60"},
+		},
+		{
+			name: "request rejected by ip limit - user bucket is not charged",
+			args: args{
+				limit:          2,
+				trustedProxies: nil,
+				requests: []request{
+					{userID: nil, remoteAddr: "203.0.113.1:1234", xForwardedFor: ""},
+					{userID: nil, remoteAddr: "203.0.113.1:1234", xForwardedFor: ""},
+					{userID: userID, remoteAddr: "203.0.113.1:1234", xForwardedFor: ""},
+					{userID: userID, remoteAddr: "203.0.113.2:1234", xForwardedFor: ""},
+				},
+			},
+			want: want{statusCode: http.StatusOK, limit: "2", remaining: "1", retryAfter: ""},
+		},
+		{
+			name: "clients behind trusted proxy - separate buckets",
+			args: args{
+				limit:          1,
+				trustedProxies: []string{"10.0.0.0/8"},
+				requests: []request{
+					{userID: nil, remoteAddr: "10.0.0.1:1234", xForwardedFor: "198.51.100.1"},
+					{userID: nil, remoteAddr: "10.0.0.1:1234", xForwardedFor: "198.51.100.2, 10.0.0.2"},
+				},
+			},
+			want: want{statusCode: http.StatusOK, limit: "1", remaining: "0", retryAfter: ""},
+		},
+		{
+			name: "forwarded header from untrusted client - ignored",
+			args: args{
+				limit:          1,
+				trustedProxies: []string{"10.0.0.0/8"},
+				requests: []request{
+					{userID: nil, remoteAddr: "203.0.113.1:1234", xForwardedFor: "198.51.100.1"},
+					{userID: nil, remoteAddr: "203.0.113.1:1234", xForwardedFor: "198.51.100.2"},
+				},
+			},
+			want: want{statusCode: http.StatusTooManyRequests, limit: "1", remaining: "0", retryAfter: "60"},
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			trustedProxies, err := middlewares.ParseTrustedProxies(testCase.args.trustedProxies)
+			require.NoError(t, err)
+
+			router := chi.NewRouter()
+			router.Use(middlewares.RateLimit(
+				utils.NewMemoryRateLimiter(time.Minute),
+				middlewares.RateLimitClassCreate,
+				utils.NewRateLimitRule(testCase.args.limit, time.Minute),
+				trustedProxies,
+				contextKeyUserID,
+				logger.NewNop(),
+			))
+			router.Post("/", func(writer http.ResponseWriter, r *http.Request) {
+				writer.WriteHeader(http.StatusOK)
+			})
+
+			var result *http.Response
+
+			for _, r := range testCase.args.requests {
+				req := httptest.NewRequest(http.MethodPost, "/", nil)
+				req.RemoteAddr = r.remoteAddr
+
+				if r.xForwardedFor != "" {
+					req.Header.Set("X-Forwarded-For", r.xForwardedFor)
+				}
+
+				if r.userID != nil {
+					req = req.WithContext(context.WithValue(req.Context(), contextKeyUserID, r.userID))
+				}
+
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, req)
+
+				result = recorder.Result()
+				err := result.Body.Close()
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, testCase.want.statusCode, result.StatusCode)
+			assert.Equal(t, testCase.want.limit, result.Header.Get("RateLimit-Limit"))
+			assert.Equal(t, testCase.want.remaining, result.Header.Get("RateLimit-Remaining"))
+			assert.Equal(t, testCase.want.retryAfter, result.Header.Get("Retry-After"))
+		})
+	}
+}
+
+func TestLoginRateLimit(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		body       string
+		remoteAddr string
+	}
+
+	tests := []struct {
+		name       string
+		requests   []request
+		statusCode int
+	}{
+		{
+			name: "test case 1: username limit exhausted from different IPs",
+			requests: []request{
+				{body: `{"username":"alice","password":"guess one"}`, remoteAddr: "203.0.113.1:1234"},
+				{body: `{"username":"ALICE","password":"guess two"}`, remoteAddr: "203.0.113.2:1234"},
+				{body: `{"username":"alice","password":"guess three"}`, remoteAddr: "203.0.113.3:1234"},
+			},
+			statusCode: http.StatusTooManyRequests,
+		},
+		{
+			name: "test case 2: ip limit exhausted with different usernames",
+			requests: []request{
+				{body: `{"username":"alice","password":"guess one"}`, remoteAddr: "203.0.113.1:1234"},
+				{body: `{"username":"bob","password":"guess two"}`, remoteAddr: "203.0.113.1:1234"},
+				{body: `{"username":"carol","password":"guess three"}`, remoteAddr: "203.0.113.1:1234"},
+			},
+			statusCode: http.StatusTooManyRequests,
+		},
+		{
+			name: "test case 3: different usernames from different IPs",
+			requests: []request{
+				{body: `{"username":"alice","password":"guess one"}`, remoteAddr: "203.0.113.1:1234"},
+				{body: `{"username":"bob","password":"guess two"}`, remoteAddr: "203.0.113.2:1234"},
+				{body: `{"username":"carol","password":"guess three"}`, remoteAddr: "203.0.113.3:1234"},
+			},
+			statusCode: http.StatusOK,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			router := chi.NewRouter()
+			router.Use(middlewares.LoginRateLimit(
+				utils.NewMemoryRateLimiter(time.Minute),
+				utils.NewRateLimitRule(2, time.Minute),
+				nil,
+				logger.NewNop(),
+			))
+			router.Post("/", func(writer http.ResponseWriter, r *http.Request) {
+				// The body is left for the handler.
+				body, err := io.ReadAll(r.Body)
+				if err != nil || len(body) == 0 {
+					writer.WriteHeader(http.StatusInternalServerError)
+
+					return
+				}
+
+				writer.WriteHeader(http.StatusOK)
+			})
+
+			var statusCode int
+
+			for _, r := range testCase.requests {
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(r.body))
+				req.RemoteAddr = r.remoteAddr
+
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, req)
+
+				statusCode = recorder.Code
+			}
+
+			assert.Equal(t, testCase.statusCode, statusCode)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/logger"
+)
+
+const (
+	HeaderRequestID = "X-Request-ID"
+
+	maxRequestIDLength = 128
+
+	messageRequestFailed = "request failed"
+)
+
+/*
+RequestID middleware takes the request ID from the X-Request-ID header or generates one, when the header is
+missing or is not a short printable string. The ID is echoed in the response header and sent down the request
+context together with log, so every log line of the request carries it.
+*/
+func RequestID(log *logger.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		RequestIDFunction := func(writer http.ResponseWriter, request *http.Request) {
+			requestID := request.Header.Get(HeaderRequestID)
+			if !isValidRequestID(requestID) {
+				requestID = uuid.NewString()
+			}
+
+			writer.Header().Set(HeaderRequestID, requestID)
+
+			ctx := logger.ContextWithRequestID(request.Context(), requestID)
+
+			next.ServeHTTP(writer, request.WithContext(logger.ContextWithLogger(ctx, log)))
+		}
+
+		return http.HandlerFunc(RequestIDFunction)
+	}
+}
+
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, r := range requestID {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,104 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		header      string
+		isGenerated bool
+	}{
+		{
+			name:        "header - kept",
+			header:      "abc-123",
+			isGenerated: false,
+		},
+		{
+			name:        "no header - generated",
+			header:      "",
+			isGenerated: true,
+		},
+		{
+			name:        "header with spaces - generated",
+			header:      "abc 123",
+			isGenerated: true,
+		},
+		{
+			name:        "too long header - generated",
+			header:      strings.Repeat("a", 129),
+			isGenerated: true,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var contextRequestID string
+
+			handler := middlewares.RequestID(logger.NewNop())(http.HandlerFunc(
+				func(writer http.ResponseWriter, request *http.Request) {
+					contextRequestID = logger.RequestID(request.Context())
+				},
+			))
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if testCase.header != "" {
+				request.Header.Set(middlewares.HeaderRequestID, testCase.header)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			responseRequestID := recorder.Header().Get(middlewares.HeaderRequestID)
+			assert.Equal(t, contextRequestID, responseRequestID)
+
+			if testCase.isGenerated {
+				_, err := uuid.Parse(responseRequestID)
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, testCase.header, responseRequestID)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	log := logger.New(&buf, logger.LevelInfo, logger.FormatJSON)
+	handler := middlewares.RequestID(log)(middlewares.AccessLog(log)(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusCreated)
+		},
+	)))
+
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten", nil)
+	request.Header.Set(middlewares.HeaderRequestID, "abc-123")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+
+	assert.Equal(t, "abc-123", line[logger.KeyRequestID])
+	assert.Equal(t, http.MethodPost, line["method"])
+	assert.Equal(t, "/api/shorten", line["path"])
+	assert.Equal(t, float64(http.StatusCreated), line["status"])
+}
@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+const MessageUntrustedSubnet = "client is not in the trusted subnet"
+
+// ParseTrustedSubnet parses the CIDR of the trusted subnet. Empty CIDR gives nil subnet which trusts nobody.
+func ParseTrustedSubnet(cidr string) (*net.IPNet, error) {
+	cidr = strings.TrimSpace(cidr)
+	if cidr == "" {
+		return nil, nil
+	}
+
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trusted subnet %q: %w", cidr, err)
+	}
+
+	return subnet, nil
+}
+
+/*
+TrustedSubnet middleware rejects requests of clients outside the subnet.
+The client address is resolved by ClientIP, so X-Real-IP and X-Forwarded-For are used only behind trusted proxies.
+*/
+func TrustedSubnet(subnet *net.IPNet, trustedProxies []*net.IPNet) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		TrustedSubnetFunction := func(writer http.ResponseWriter, request *http.Request) {
+			clientIP := ClientIP(request, trustedProxies)
+
+			if subnet == nil || clientIP == nil || !subnet.Contains(clientIP) {
+				utils.WriteError(writer, request, utils.NewProblem(
+					http.StatusForbidden,
+					utils.ProblemCodeForbidden,
+					MessageUntrustedSubnet,
+				))
+
+				return
+			}
+
+			next.ServeHTTP(writer, request)
+		}
+
+		return http.HandlerFunc(TrustedSubnetFunction)
+	}
+}
@@ -0,0 +1,110 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+)
+
+func TestTrustedSubnet(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		trustedSubnet string
+		remoteAddr    string
+		forwardedFor  string
+		realIP        string
+	}
+
+	trustedProxies, err := middlewares.ParseTrustedProxies([]string{"10.0.0.1"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		args       args
+		statusCode int
+	}{
+		{
+			name:       "client in subnet - allowed",
+			args:       args{trustedSubnet: "192.168.1.0/24", remoteAddr: "192.168.1.10:1234", forwardedFor: "", realIP: ""},
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "client outside subnet - forbidden",
+			args:       args{trustedSubnet: "192.168.1.0/24", remoteAddr: "192.168.2.10:1234", forwardedFor: "", realIP: ""},
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "empty subnet - forbidden",
+			args:       args{trustedSubnet: "", remoteAddr: "192.168.1.10:1234", forwardedFor: "", realIP: ""},
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name: "forwarded by trusted proxy - allowed",
+			args: args{
+				trustedSubnet: "192.168.1.0/24",
+				remoteAddr:    "10.0.0.1:1234",
+				forwardedFor:  "192.168.1.10",
+				realIP:        "",
+			},
+			statusCode: http.StatusOK,
+		},
+		{
+			name: "real ip set by trusted proxy - allowed",
+			args: args{
+				trustedSubnet: "192.168.1.0/24",
+				remoteAddr:    "10.0.0.1:1234",
+				forwardedFor:  "",
+				realIP:        "192.168.1.10",
+			},
+			statusCode: http.StatusOK,
+		},
+		{
+			name: "forwarded by untrusted client - forbidden",
+			args: args{
+				trustedSubnet: "192.168.1.0/24",
+				remoteAddr:    "203.0.113.5:1234",
+				forwardedFor:  "192.168.1.10",
+				realIP:        "192.168.1.10",
+			},
+			statusCode: http.StatusForbidden,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			trustedSubnet, err := middlewares.ParseTrustedSubnet(testCase.args.trustedSubnet)
+			require.NoError(t, err)
+
+			router := chi.NewRouter()
+			router.Use(middlewares.TrustedSubnet(trustedSubnet, trustedProxies))
+			router.Get("/", func(writer http.ResponseWriter, r *http.Request) {})
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = testCase.args.remoteAddr
+
+			if testCase.args.forwardedFor != "" {
+				request.Header.Set("X-Forwarded-For", testCase.args.forwardedFor)
+			}
+
+			if testCase.args.realIP != "" {
+				request.Header.Set("X-Real-IP", testCase.args.realIP)
+			}
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			result := recorder.Result()
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.statusCode, result.StatusCode)
+		})
+	}
+}
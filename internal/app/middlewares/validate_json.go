@@ -0,0 +1,129 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+const (
+	MessageRequestBodyDoesNotMatchSchema = "request body does not match the schema"
+	MessageRequestBodyIsTooLarge         = "request body is too large"
+
+	contentEncodingGZIP = "gzip"
+)
+
+/*
+ValidateJSONBody middleware validates JSON request bodies against the OpenAPI document. A violation is rejected
+with every field error, e.g. "/0/original_url: must be a string", as the errors of the problem.
+A body which is not JSON at all is passed on, so the handler reports it as before. The body is restored for the handler.
+A body longer than maxBodySize bytes is rejected, a gzip body is limited both as it is sent and once decompressed.
+The decompressed body is passed on, so the handler does not decompress it again.
+*/
+func ValidateJSONBody(
+	spec *utils.OpenAPISpec,
+	maxBodySize int64,
+	log *logger.Logger,
+) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		ValidateJSONBodyFunction := func(writer http.ResponseWriter, request *http.Request) {
+			if request.Body == nil || request.Body == http.NoBody {
+				next.ServeHTTP(writer, request)
+
+				return
+			}
+
+			raw, err := io.ReadAll(http.MaxBytesReader(writer, request.Body, maxBodySize))
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					writeRequestBodyIsTooLarge(writer, request, maxBodySize)
+
+					return
+				}
+
+				utils.WriteError(writer, request, utils.NewInternalProblem())
+				log.Ctx(request.Context()).Error(messageRequestFailed, logger.KeyError, err)
+
+				return
+			}
+
+			request.Body = io.NopCloser(bytes.NewReader(raw))
+
+			body, err := decodeContent(raw, request.Header.Get("Content-Encoding"), maxBodySize)
+			if errors.Is(err, errDecodedBodyIsTooLarge) {
+				writeRequestBodyIsTooLarge(writer, request, maxBodySize)
+
+				return
+			}
+
+			if err != nil {
+				next.ServeHTTP(writer, request)
+
+				return
+			}
+
+			if request.Header.Get("Content-Encoding") == contentEncodingGZIP {
+				request.Body = io.NopCloser(bytes.NewReader(body))
+				request.ContentLength = int64(len(body))
+				request.Header.Del("Content-Encoding")
+			}
+
+			err = spec.ValidateRequestBody(request.Method, request.URL.Path, body)
+
+			var validationErr *utils.SchemaValidationError
+			if errors.As(err, &validationErr) {
+				utils.WriteError(
+					writer,
+					request,
+					utils.NewValidationProblem(MessageRequestBodyDoesNotMatchSchema, validationErr.Fields),
+				)
+
+				return
+			}
+
+			next.ServeHTTP(writer, request)
+		}
+
+		return http.HandlerFunc(ValidateJSONBodyFunction)
+	}
+}
+
+var errDecodedBodyIsTooLarge = errors.New("decoded body is too large")
+
+func writeRequestBodyIsTooLarge(writer http.ResponseWriter, request *http.Request, maxBodySize int64) {
+	utils.WriteError(writer, request, utils.NewProblem(
+		http.StatusRequestEntityTooLarge,
+		utils.ProblemCodeRequestTooLarge,
+		fmt.Sprintf("%s: limit is %d bytes", MessageRequestBodyIsTooLarge, maxBodySize),
+	))
+}
+
+func decodeContent(raw []byte, contentEncoding string, maxBodySize int64) ([]byte, error) {
+	if contentEncoding != contentEncodingGZIP {
+		return raw, nil
+	}
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+
+	// One more byte than the limit is read to tell a body of exactly the limit from a longer one.
+	body, err := io.ReadAll(io.LimitReader(gzipReader, maxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gzip body: %w", err)
+	}
+
+	if int64(len(body)) > maxBodySize {
+		return nil, errDecodedBodyIsTooLarge
+	}
+
+	return body, nil
+}
@@ -0,0 +1,184 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/api"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/middlewares"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+func TestValidateJSONBody(t *testing.T) {
+	t.Parallel()
+
+	spec, err := utils.NewOpenAPISpec(api.OpenAPI)
+	require.NoError(t, err)
+
+	const maxBodySize = 1024
+
+	badRequest := http.StatusText(http.StatusBadRequest) + ": " + middlewares.MessageRequestBodyDoesNotMatchSchema + ": "
+	tooLarge := fmt.Sprintf(
+		"%s: %s: limit is %d bytes",
+		http.StatusText(http.StatusRequestEntityTooLarge),
+		middlewares.MessageRequestBodyIsTooLarge,
+		maxBodySize,
+	)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		isGZIP     bool
+		statusCode int
+		response   string
+	}{
+		{
+			name:       "valid body - passed with the same body",
+			method:     http.MethodPost,
+			path:       "/api/shorten",
+			body:       `{"url":"https://example.com/","ttl":60}`,
+			statusCode: http.StatusOK,
+			response:   `{"url":"https://example.com/","ttl":60}`,
+		},
+		{
+			name:       "missing and mistyped fields - every field reported",
+			method:     http.MethodPost,
+			path:       "/api/shorten",
+			body:       `{"ttl":-1}`,
+			statusCode: http.StatusBadRequest,
+			response:   badRequest + "/url: is required; /ttl: must be greater than or equal to 0",
+		},
+		{
+			name:       "batch items - pointers to items",
+			method:     http.MethodPost,
+			path:       "/api/shorten/batch",
+			body:       `[{"correlation_id":"1","original_url":"https://example.com/"},{"correlation_id":"2","original_url":5}]`,
+			statusCode: http.StatusBadRequest,
+			response:   badRequest + "/1/original_url: must be a string",
+		},
+		{
+			name:       "wrong document type",
+			method:     http.MethodDelete,
+			path:       "/api/user/urls",
+			body:       `{"uid":"abc"}`,
+			statusCode: http.StatusBadRequest,
+			response:   badRequest + "must be an array",
+		},
+		{
+			name:       "unknown event type - allowed values listed",
+			method:     http.MethodPost,
+			path:       "/api/user/webhooks",
+			body:       `{"url":"https://example.com/hook","events":["link.created","link.moved"]}`,
+			statusCode: http.StatusBadRequest,
+			response: badRequest + `/events/1: must be one of ` +
+				`["link.created", "link.updated", "link.deleted", "link.clicked"]`,
+		},
+		{
+			name:       "gzip body - validated decompressed",
+			method:     http.MethodPost,
+			path:       "/api/user/transfers/claim",
+			body:       `{"token":""}`,
+			isGZIP:     true,
+			statusCode: http.StatusBadRequest,
+			response:   badRequest + "/token: length must be at least 1",
+		},
+		{
+			name:       "valid gzip body - passed decompressed",
+			method:     http.MethodPost,
+			path:       "/api/user/transfers/claim",
+			body:       `{"token":"abc"}`,
+			isGZIP:     true,
+			statusCode: http.StatusOK,
+			response:   `{"token":"abc"}`,
+		},
+		{
+			name:       "optional body - empty body passed",
+			method:     http.MethodPut,
+			path:       "/api/admin/users/3d1f3d9c-7b8e-4c1a-9a4e-0c1e2f3a4b5c/ban",
+			body:       "",
+			statusCode: http.StatusOK,
+			response:   "",
+		},
+		{
+			name:       "malformed JSON - passed to the handler",
+			method:     http.MethodPost,
+			path:       "/api/shorten",
+			body:       `{"url":`,
+			statusCode: http.StatusOK,
+			response:   `{"url":`,
+		},
+		{
+			name:       "body longer than the limit - rejected",
+			method:     http.MethodPost,
+			path:       "/api/shorten",
+			body:       `{"url":"https://example.com/` + strings.Repeat("a", maxBodySize) + `"}`,
+			statusCode: http.StatusRequestEntityTooLarge,
+			response:   tooLarge,
+		},
+		{
+			name:       "gzip body longer than the limit once decompressed - rejected",
+			method:     http.MethodPost,
+			path:       "/api/user/transfers/claim",
+			body:       `{"token":"` + strings.Repeat("a", maxBodySize) + `"}`,
+			isGZIP:     true,
+			statusCode: http.StatusRequestEntityTooLarge,
+			response:   tooLarge,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := middlewares.ValidateJSONBody(spec, maxBodySize, logger.NewNop())(http.HandlerFunc(
+				func(writer http.ResponseWriter, request *http.Request) {
+					body, err := io.ReadAll(request.Body)
+					require.NoError(t, err)
+
+					_, err = writer.Write(body)
+					require.NoError(t, err)
+				},
+			))
+
+			body := []byte(testCase.body)
+
+			if testCase.isGZIP {
+				var buf bytes.Buffer
+
+				gzipWriter := gzip.NewWriter(&buf)
+				_, err := gzipWriter.Write(body)
+				require.NoError(t, err)
+				require.NoError(t, gzipWriter.Close())
+
+				body = buf.Bytes()
+			}
+
+			request := httptest.NewRequest(testCase.method, testCase.path, bytes.NewReader(body))
+			if testCase.isGZIP {
+				request.Header.Set("Content-Encoding", "gzip")
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			result := recorder.Result()
+
+			response, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.statusCode, result.StatusCode)
+			assert.Equal(t, testCase.response, strings.TrimSuffix(string(response), "\n"))
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/tmitry/shorturl/internal/app/repositories (interfaces: APIKeyRepository)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
+	models "github.com/tmitry/shorturl/internal/app/models"
+)
+
+// MockAPIKeyRepository is a mock of APIKeyRepository interface.
+type MockAPIKeyRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeyRepositoryMockRecorder
+}
+
+// MockAPIKeyRepositoryMockRecorder is the mock recorder for MockAPIKeyRepository.
+type MockAPIKeyRepositoryMockRecorder struct {
+	mock *MockAPIKeyRepository
+}
+
+// NewMockAPIKeyRepository creates a new mock instance.
+func NewMockAPIKeyRepository(ctrl *gomock.Controller) *MockAPIKeyRepository {
+	mock := &MockAPIKeyRepository{ctrl: ctrl}
+	mock.recorder = &MockAPIKeyRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPIKeyRepository) EXPECT() *MockAPIKeyRepositoryMockRecorder {
+	return m.recorder
+}
+
+// FindAPIKeyByHash mocks base method.
+func (m *MockAPIKeyRepository) FindAPIKeyByHash(arg0 context.Context, arg1 string) (*models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAPIKeyByHash", arg0, arg1)
+	ret0, _ := ret[0].(*models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAPIKeyByHash indicates an expected call of FindAPIKeyByHash.
+func (mr *MockAPIKeyRepositoryMockRecorder) FindAPIKeyByHash(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAPIKeyByHash", reflect.TypeOf((*MockAPIKeyRepository)(nil).FindAPIKeyByHash), arg0, arg1)
+}
+
+// FindAllAPIKeysByUserID mocks base method.
+func (m *MockAPIKeyRepository) FindAllAPIKeysByUserID(arg0 context.Context, arg1 uuid.UUID) ([]*models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAllAPIKeysByUserID", arg0, arg1)
+	ret0, _ := ret[0].([]*models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAllAPIKeysByUserID indicates an expected call of FindAllAPIKeysByUserID.
+func (mr *MockAPIKeyRepositoryMockRecorder) FindAllAPIKeysByUserID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAllAPIKeysByUserID", reflect.TypeOf((*MockAPIKeyRepository)(nil).FindAllAPIKeysByUserID), arg0, arg1)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockAPIKeyRepository) RevokeAPIKey(arg0 context.Context, arg1, arg2 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockAPIKeyRepositoryMockRecorder) RevokeAPIKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockAPIKeyRepository)(nil).RevokeAPIKey), arg0, arg1, arg2)
+}
+
+// SaveAPIKey mocks base method.
+func (m *MockAPIKeyRepository) SaveAPIKey(arg0 context.Context, arg1 *models.APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAPIKey indicates an expected call of SaveAPIKey.
+func (mr *MockAPIKeyRepositoryMockRecorder) SaveAPIKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAPIKey", reflect.TypeOf((*MockAPIKeyRepository)(nil).SaveAPIKey), arg0, arg1)
+}
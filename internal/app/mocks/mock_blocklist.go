@@ -0,0 +1,50 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/tmitry/shorturl/internal/app/utils (interfaces: Blocklist)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/tmitry/shorturl/internal/app/models"
+)
+
+// MockBlocklist is a mock of Blocklist interface.
+type MockBlocklist struct {
+	ctrl     *gomock.Controller
+	recorder *MockBlocklistMockRecorder
+}
+
+// MockBlocklistMockRecorder is the mock recorder for MockBlocklist.
+type MockBlocklistMockRecorder struct {
+	mock *MockBlocklist
+}
+
+// NewMockBlocklist creates a new mock instance.
+func NewMockBlocklist(ctrl *gomock.Controller) *MockBlocklist {
+	mock := &MockBlocklist{ctrl: ctrl}
+	mock.recorder = &MockBlocklistMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBlocklist) EXPECT() *MockBlocklistMockRecorder {
+	return m.recorder
+}
+
+// Match mocks base method.
+func (m *MockBlocklist) Match(arg0 models.URL) (string, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Match", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Match indicates an expected call of Match.
+func (mr *MockBlocklistMockRecorder) Match(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Match", reflect.TypeOf((*MockBlocklist)(nil).Match), arg0)
+}
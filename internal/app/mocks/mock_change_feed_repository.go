@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/tmitry/shorturl/internal/app/repositories (interfaces: ChangeFeedRepository)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/tmitry/shorturl/internal/app/models"
+)
+
+// MockChangeFeedRepository is a mock of ChangeFeedRepository interface.
+type MockChangeFeedRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockChangeFeedRepositoryMockRecorder
+}
+
+// MockChangeFeedRepositoryMockRecorder is the mock recorder for MockChangeFeedRepository.
+type MockChangeFeedRepositoryMockRecorder struct {
+	mock *MockChangeFeedRepository
+}
+
+// NewMockChangeFeedRepository creates a new mock instance.
+func NewMockChangeFeedRepository(ctrl *gomock.Controller) *MockChangeFeedRepository {
+	mock := &MockChangeFeedRepository{ctrl: ctrl}
+	mock.recorder = &MockChangeFeedRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockChangeFeedRepository) EXPECT() *MockChangeFeedRepositoryMockRecorder {
+	return m.recorder
+}
+
+// FindChanges mocks base method.
+func (m *MockChangeFeedRepository) FindChanges(arg0 context.Context, arg1 int64, arg2 int) ([]*models.Change, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindChanges", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]*models.Change)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindChanges indicates an expected call of FindChanges.
+func (mr *MockChangeFeedRepositoryMockRecorder) FindChanges(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindChanges", reflect.TypeOf((*MockChangeFeedRepository)(nil).FindChanges), arg0, arg1, arg2)
+}
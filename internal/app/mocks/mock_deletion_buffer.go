@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -36,13 +37,16 @@ func (m *MockDeletionBuffer) EXPECT() *MockDeletionBufferMockRecorder {
 }
 
 // Push mocks base method.
-func (m *MockDeletionBuffer) Push(arg0 []models.UID, arg1 uuid.UUID) {
+func (m *MockDeletionBuffer) Push(arg0 context.Context, arg1 []models.UID, arg2 uuid.UUID) (*models.DeletionJob, error) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Push", arg0, arg1)
+	ret := m.ctrl.Call(m, "Push", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.DeletionJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Push indicates an expected call of Push.
-func (mr *MockDeletionBufferMockRecorder) Push(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockDeletionBufferMockRecorder) Push(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Push", reflect.TypeOf((*MockDeletionBuffer)(nil).Push), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Push", reflect.TypeOf((*MockDeletionBuffer)(nil).Push), arg0, arg1, arg2)
 }
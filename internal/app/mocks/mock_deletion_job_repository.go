@@ -0,0 +1,81 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/tmitry/shorturl/internal/app/repositories (interfaces: DeletionJobRepository)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
+	models "github.com/tmitry/shorturl/internal/app/models"
+)
+
+// MockDeletionJobRepository is a mock of DeletionJobRepository interface.
+type MockDeletionJobRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockDeletionJobRepositoryMockRecorder
+}
+
+// MockDeletionJobRepositoryMockRecorder is the mock recorder for MockDeletionJobRepository.
+type MockDeletionJobRepositoryMockRecorder struct {
+	mock *MockDeletionJobRepository
+}
+
+// NewMockDeletionJobRepository creates a new mock instance.
+func NewMockDeletionJobRepository(ctrl *gomock.Controller) *MockDeletionJobRepository {
+	mock := &MockDeletionJobRepository{ctrl: ctrl}
+	mock.recorder = &MockDeletionJobRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDeletionJobRepository) EXPECT() *MockDeletionJobRepositoryMockRecorder {
+	return m.recorder
+}
+
+// FindAllPendingDeletionJobs mocks base method.
+func (m *MockDeletionJobRepository) FindAllPendingDeletionJobs(arg0 context.Context) ([]*models.DeletionJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAllPendingDeletionJobs", arg0)
+	ret0, _ := ret[0].([]*models.DeletionJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAllPendingDeletionJobs indicates an expected call of FindAllPendingDeletionJobs.
+func (mr *MockDeletionJobRepositoryMockRecorder) FindAllPendingDeletionJobs(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAllPendingDeletionJobs", reflect.TypeOf((*MockDeletionJobRepository)(nil).FindAllPendingDeletionJobs), arg0)
+}
+
+// FindDeletionJobByID mocks base method.
+func (m *MockDeletionJobRepository) FindDeletionJobByID(arg0 context.Context, arg1 uuid.UUID) (*models.DeletionJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindDeletionJobByID", arg0, arg1)
+	ret0, _ := ret[0].(*models.DeletionJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindDeletionJobByID indicates an expected call of FindDeletionJobByID.
+func (mr *MockDeletionJobRepositoryMockRecorder) FindDeletionJobByID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindDeletionJobByID", reflect.TypeOf((*MockDeletionJobRepository)(nil).FindDeletionJobByID), arg0, arg1)
+}
+
+// SaveDeletionJob mocks base method.
+func (m *MockDeletionJobRepository) SaveDeletionJob(arg0 context.Context, arg1 *models.DeletionJob) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveDeletionJob", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveDeletionJob indicates an expected call of SaveDeletionJob.
+func (mr *MockDeletionJobRepositoryMockRecorder) SaveDeletionJob(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveDeletionJob", reflect.TypeOf((*MockDeletionJobRepository)(nil).SaveDeletionJob), arg0, arg1)
+}
@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	uuid "github.com/google/uuid"
@@ -51,17 +52,77 @@ func (mr *MockRepositoryMockRecorder) BatchDelete(arg0, arg1 interface{}) *gomoc
 }
 
 // BatchSave mocks base method.
-func (m *MockRepository) BatchSave(arg0 context.Context, arg1 []*models.ShortURL) error {
+func (m *MockRepository) BatchSave(arg0 context.Context, arg1 []*models.ShortURL, arg2 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BatchSave", arg0, arg1)
+	ret := m.ctrl.Call(m, "BatchSave", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // BatchSave indicates an expected call of BatchSave.
-func (mr *MockRepositoryMockRecorder) BatchSave(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) BatchSave(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchSave", reflect.TypeOf((*MockRepository)(nil).BatchSave), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchSave", reflect.TypeOf((*MockRepository)(nil).BatchSave), arg0, arg1, arg2)
+}
+
+// ClaimTransfer mocks base method.
+func (m *MockRepository) ClaimTransfer(arg0 context.Context, arg1 string, arg2 time.Time, arg3, arg4 uuid.UUID, arg5 int) (int, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimTransfer", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ClaimTransfer indicates an expected call of ClaimTransfer.
+func (mr *MockRepositoryMockRecorder) ClaimTransfer(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimTransfer", reflect.TypeOf((*MockRepository)(nil).ClaimTransfer), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
+// CountActiveByUserID mocks base method.
+func (m *MockRepository) CountActiveByUserID(arg0 context.Context, arg1 uuid.UUID) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountActiveByUserID", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountActiveByUserID indicates an expected call of CountActiveByUserID.
+func (mr *MockRepositoryMockRecorder) CountActiveByUserID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountActiveByUserID", reflect.TypeOf((*MockRepository)(nil).CountActiveByUserID), arg0, arg1)
+}
+
+// CountAll mocks base method.
+func (m *MockRepository) CountAll(arg0 context.Context) (*models.GlobalCounts, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountAll", arg0)
+	ret0, _ := ret[0].(*models.GlobalCounts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountAll indicates an expected call of CountAll.
+func (mr *MockRepositoryMockRecorder) CountAll(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountAll", reflect.TypeOf((*MockRepository)(nil).CountAll), arg0)
+}
+
+// DeleteBan mocks base method.
+func (m *MockRepository) DeleteBan(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBan", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBan indicates an expected call of DeleteBan.
+func (mr *MockRepositoryMockRecorder) DeleteBan(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBan", reflect.TypeOf((*MockRepository)(nil).DeleteBan), arg0, arg1)
 }
 
 // FindAllByUserID mocks base method.
@@ -94,6 +155,21 @@ func (mr *MockRepositoryMockRecorder) FindAllByUserIDAndUIDs(arg0, arg1, arg2 in
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAllByUserIDAndUIDs", reflect.TypeOf((*MockRepository)(nil).FindAllByUserIDAndUIDs), arg0, arg1, arg2)
 }
 
+// FindBanByUserID mocks base method.
+func (m *MockRepository) FindBanByUserID(arg0 context.Context, arg1 uuid.UUID) (*models.Ban, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindBanByUserID", arg0, arg1)
+	ret0, _ := ret[0].(*models.Ban)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindBanByUserID indicates an expected call of FindBanByUserID.
+func (mr *MockRepositoryMockRecorder) FindBanByUserID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBanByUserID", reflect.TypeOf((*MockRepository)(nil).FindBanByUserID), arg0, arg1)
+}
+
 // FindOneByUID mocks base method.
 func (m *MockRepository) FindOneByUID(arg0 context.Context, arg1 models.UID) (*models.ShortURL, error) {
 	m.ctrl.T.Helper()
@@ -123,16 +199,89 @@ func (mr *MockRepositoryMockRecorder) Ping(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockRepository)(nil).Ping), arg0)
 }
 
+// ReassignUserID mocks base method.
+func (m *MockRepository) ReassignUserID(arg0 context.Context, arg1, arg2 uuid.UUID, arg3 int) (int, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReassignUserID", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ReassignUserID indicates an expected call of ReassignUserID.
+func (mr *MockRepositoryMockRecorder) ReassignUserID(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReassignUserID", reflect.TypeOf((*MockRepository)(nil).ReassignUserID), arg0, arg1, arg2, arg3)
+}
+
+// RegisterClick mocks base method.
+func (m *MockRepository) RegisterClick(arg0 context.Context, arg1 models.UID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterClick", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RegisterClick indicates an expected call of RegisterClick.
+func (mr *MockRepositoryMockRecorder) RegisterClick(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterClick", reflect.TypeOf((*MockRepository)(nil).RegisterClick), arg0, arg1)
+}
+
 // Save mocks base method.
-func (m *MockRepository) Save(arg0 context.Context, arg1 *models.ShortURL) error {
+func (m *MockRepository) Save(arg0 context.Context, arg1 *models.ShortURL, arg2 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Save", arg0, arg1)
+	ret := m.ctrl.Call(m, "Save", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Save indicates an expected call of Save.
-func (mr *MockRepositoryMockRecorder) Save(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) Save(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockRepository)(nil).Save), arg0, arg1, arg2)
+}
+
+// SaveBan mocks base method.
+func (m *MockRepository) SaveBan(arg0 context.Context, arg1 *models.Ban) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveBan", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveBan indicates an expected call of SaveBan.
+func (mr *MockRepositoryMockRecorder) SaveBan(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBan", reflect.TypeOf((*MockRepository)(nil).SaveBan), arg0, arg1)
+}
+
+// SearchShortURLs mocks base method.
+func (m *MockRepository) SearchShortURLs(arg0 context.Context, arg1 *models.ShortURLFilter) ([]*models.ShortURL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchShortURLs", arg0, arg1)
+	ret0, _ := ret[0].([]*models.ShortURL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchShortURLs indicates an expected call of SearchShortURLs.
+func (mr *MockRepositoryMockRecorder) SearchShortURLs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchShortURLs", reflect.TypeOf((*MockRepository)(nil).SearchShortURLs), arg0, arg1)
+}
+
+// SetDisabled mocks base method.
+func (m *MockRepository) SetDisabled(arg0 context.Context, arg1 models.UID, arg2 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDisabled", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDisabled indicates an expected call of SetDisabled.
+func (mr *MockRepositoryMockRecorder) SetDisabled(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockRepository)(nil).Save), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDisabled", reflect.TypeOf((*MockRepository)(nil).SetDisabled), arg0, arg1, arg2)
 }
@@ -0,0 +1,62 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/tmitry/shorturl/internal/app/utils (interfaces: WebhookDispatcher)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/tmitry/shorturl/internal/app/models"
+)
+
+// MockWebhookDispatcher is a mock of WebhookDispatcher interface.
+type MockWebhookDispatcher struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhookDispatcherMockRecorder
+}
+
+// MockWebhookDispatcherMockRecorder is the mock recorder for MockWebhookDispatcher.
+type MockWebhookDispatcherMockRecorder struct {
+	mock *MockWebhookDispatcher
+}
+
+// NewMockWebhookDispatcher creates a new mock instance.
+func NewMockWebhookDispatcher(ctrl *gomock.Controller) *MockWebhookDispatcher {
+	mock := &MockWebhookDispatcher{ctrl: ctrl}
+	mock.recorder = &MockWebhookDispatcherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhookDispatcher) EXPECT() *MockWebhookDispatcherMockRecorder {
+	return m.recorder
+}
+
+// Dispatch mocks base method.
+func (m *MockWebhookDispatcher) Dispatch(arg0 context.Context, arg1 *models.WebhookEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Dispatch", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Dispatch indicates an expected call of Dispatch.
+func (mr *MockWebhookDispatcherMockRecorder) Dispatch(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Dispatch", reflect.TypeOf((*MockWebhookDispatcher)(nil).Dispatch), arg0, arg1)
+}
+
+// Emit mocks base method.
+func (m *MockWebhookDispatcher) Emit(arg0 context.Context, arg1 *models.WebhookEvent) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Emit", arg0, arg1)
+}
+
+// Emit indicates an expected call of Emit.
+func (mr *MockWebhookDispatcherMockRecorder) Emit(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Emit", reflect.TypeOf((*MockWebhookDispatcher)(nil).Emit), arg0, arg1)
+}
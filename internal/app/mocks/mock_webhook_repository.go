@@ -0,0 +1,139 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/tmitry/shorturl/internal/app/repositories (interfaces: WebhookRepository)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
+	models "github.com/tmitry/shorturl/internal/app/models"
+)
+
+// MockWebhookRepository is a mock of WebhookRepository interface.
+type MockWebhookRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhookRepositoryMockRecorder
+}
+
+// MockWebhookRepositoryMockRecorder is the mock recorder for MockWebhookRepository.
+type MockWebhookRepositoryMockRecorder struct {
+	mock *MockWebhookRepository
+}
+
+// NewMockWebhookRepository creates a new mock instance.
+func NewMockWebhookRepository(ctrl *gomock.Controller) *MockWebhookRepository {
+	mock := &MockWebhookRepository{ctrl: ctrl}
+	mock.recorder = &MockWebhookRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhookRepository) EXPECT() *MockWebhookRepositoryMockRecorder {
+	return m.recorder
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockWebhookRepository) DeleteWebhook(arg0 context.Context, arg1, arg2 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockWebhookRepositoryMockRecorder) DeleteWebhook(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockWebhookRepository)(nil).DeleteWebhook), arg0, arg1, arg2)
+}
+
+// FindAllPendingWebhookDeliveries mocks base method.
+func (m *MockWebhookRepository) FindAllPendingWebhookDeliveries(arg0 context.Context) ([]*models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAllPendingWebhookDeliveries", arg0)
+	ret0, _ := ret[0].([]*models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAllPendingWebhookDeliveries indicates an expected call of FindAllPendingWebhookDeliveries.
+func (mr *MockWebhookRepositoryMockRecorder) FindAllPendingWebhookDeliveries(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAllPendingWebhookDeliveries", reflect.TypeOf((*MockWebhookRepository)(nil).FindAllPendingWebhookDeliveries), arg0)
+}
+
+// FindAllWebhookDeliveriesByWebhookID mocks base method.
+func (m *MockWebhookRepository) FindAllWebhookDeliveriesByWebhookID(arg0 context.Context, arg1 uuid.UUID, arg2 int) ([]*models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAllWebhookDeliveriesByWebhookID", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]*models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAllWebhookDeliveriesByWebhookID indicates an expected call of FindAllWebhookDeliveriesByWebhookID.
+func (mr *MockWebhookRepositoryMockRecorder) FindAllWebhookDeliveriesByWebhookID(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAllWebhookDeliveriesByWebhookID", reflect.TypeOf((*MockWebhookRepository)(nil).FindAllWebhookDeliveriesByWebhookID), arg0, arg1, arg2)
+}
+
+// FindAllWebhooksByUserID mocks base method.
+func (m *MockWebhookRepository) FindAllWebhooksByUserID(arg0 context.Context, arg1 uuid.UUID) ([]*models.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAllWebhooksByUserID", arg0, arg1)
+	ret0, _ := ret[0].([]*models.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAllWebhooksByUserID indicates an expected call of FindAllWebhooksByUserID.
+func (mr *MockWebhookRepositoryMockRecorder) FindAllWebhooksByUserID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAllWebhooksByUserID", reflect.TypeOf((*MockWebhookRepository)(nil).FindAllWebhooksByUserID), arg0, arg1)
+}
+
+// FindWebhookByID mocks base method.
+func (m *MockWebhookRepository) FindWebhookByID(arg0 context.Context, arg1 uuid.UUID) (*models.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindWebhookByID", arg0, arg1)
+	ret0, _ := ret[0].(*models.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindWebhookByID indicates an expected call of FindWebhookByID.
+func (mr *MockWebhookRepositoryMockRecorder) FindWebhookByID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindWebhookByID", reflect.TypeOf((*MockWebhookRepository)(nil).FindWebhookByID), arg0, arg1)
+}
+
+// SaveWebhook mocks base method.
+func (m *MockWebhookRepository) SaveWebhook(arg0 context.Context, arg1 *models.Webhook) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveWebhook", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveWebhook indicates an expected call of SaveWebhook.
+func (mr *MockWebhookRepositoryMockRecorder) SaveWebhook(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveWebhook", reflect.TypeOf((*MockWebhookRepository)(nil).SaveWebhook), arg0, arg1)
+}
+
+// SaveWebhookDelivery mocks base method.
+func (m *MockWebhookRepository) SaveWebhookDelivery(arg0 context.Context, arg1 *models.WebhookDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveWebhookDelivery", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveWebhookDelivery indicates an expected call of SaveWebhookDelivery.
+func (mr *MockWebhookRepositoryMockRecorder) SaveWebhookDelivery(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveWebhookDelivery", reflect.TypeOf((*MockWebhookRepository)(nil).SaveWebhookDelivery), arg0, arg1)
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ban refuses link creation for the user and stops redirects of all user links.
+type Ban struct {
+	UserID    uuid.UUID
+	Reason    string
+	CreatedAt time.Time
+}
+
+func NewBan(userID uuid.UUID, reason string) *Ban {
+	return &Ban{
+		UserID:    userID,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+}
+
+// ShortURLFilter selects links of all users. Empty Query and zero UserID match any link, zero Limit means no limit.
+type ShortURLFilter struct {
+	Query  string // Substring of the original URL.
+	UserID uuid.UUID
+	Limit  int
+	Offset int
+}
+
+func NewShortURLFilter(query string, userID uuid.UUID, limit, offset int) *ShortURLFilter {
+	return &ShortURLFilter{
+		Query:  query,
+		UserID: userID,
+		Limit:  limit,
+		Offset: offset,
+	}
+}
+
+// Match reports whether the link satisfies Query and UserID of the filter.
+func (f ShortURLFilter) Match(shortURL *ShortURL) bool {
+	if f.UserID != (uuid.UUID{}) && shortURL.UserID != f.UserID {
+		return false
+	}
+
+	return f.Query == "" || strings.Contains(shortURL.URL.String(), f.Query)
+}
+
+// GlobalCounts describes the whole service. Users are the distinct owners of links.
+type GlobalCounts struct {
+	Links         int
+	ActiveLinks   int
+	DeletedLinks  int
+	DisabledLinks int
+	Users         int
+	BannedUsers   int
+	Clicks        int
+}
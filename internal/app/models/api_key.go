@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	APIKeyScopeRead  = "read"
+	APIKeyScopeWrite = "write"
+)
+
+// APIKey identifies its owner in requests sent with the "Authorization: Bearer" header.
+// Only the hash of the key is stored. Empty Scopes grant every scope, zero RevokedAt means an active key.
+type APIKey struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Name      string
+	Prefix    string
+	Hash      string
+	Scopes    []string
+	CreatedAt time.Time
+	RevokedAt time.Time
+}
+
+func NewAPIKey(userID uuid.UUID, name, prefix, hash string, scopes []string) *APIKey {
+	return &APIKey{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hash,
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+		RevokedAt: time.Time{},
+	}
+}
+
+func (k APIKey) IsRevoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	if len(k.Scopes) == 0 {
+		return true
+	}
+
+	for _, keyScope := range k.Scopes {
+		if keyScope == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+const (
+	ChangeTypeCreate = "create"
+	ChangeTypeUpdate = "update"
+	ChangeTypeDelete = "delete"
+)
+
+// Change is a record of the change feed. Seq grows monotonically over changes of all links.
+type Change struct {
+	Seq       int64
+	Type      string
+	ShortURL  ShortURL // Snapshot of the link after the change.
+	ChangedAt time.Time
+}
+
+func NewChange(changeType string, shortURL *ShortURL) *Change {
+	return &Change{
+		Seq:       0,
+		Type:      changeType,
+		ShortURL:  *shortURL,
+		ChangedAt: time.Now(),
+	}
+}
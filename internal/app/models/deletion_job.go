@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DeletionStatusPending   = "pending"
+	DeletionStatusCompleted = "completed"
+	DeletionStatusFailed    = "failed"
+)
+
+// DeletionItem is a link of the deletion job. Reason explains why the item failed.
+type DeletionItem struct {
+	UID    UID
+	Status string
+	Reason string
+}
+
+/*
+DeletionJob is a request of the user to delete links. It is saved before the request is answered,
+so pending items are deleted even if the service restarts in between.
+*/
+type DeletionJob struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Items     []DeletionItem
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func NewDeletionJob(userID uuid.UUID, uids []UID) *DeletionJob {
+	now := time.Now()
+
+	items := make([]DeletionItem, 0, len(uids))
+	for _, uid := range uids {
+		items = append(items, DeletionItem{UID: uid, Status: DeletionStatusPending, Reason: ""})
+	}
+
+	return &DeletionJob{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Items:     items,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Status is pending while any item is pending, failed if any item failed and completed otherwise.
+func (j DeletionJob) Status() string {
+	status := DeletionStatusCompleted
+
+	for _, item := range j.Items {
+		switch item.Status {
+		case DeletionStatusPending:
+			return DeletionStatusPending
+		case DeletionStatusFailed:
+			status = DeletionStatusFailed
+		}
+	}
+
+	return status
+}
+
+// PendingUIDs returns UIDs of the items which are not deleted yet.
+func (j DeletionJob) PendingUIDs() []UID {
+	var uids []UID
+
+	for _, item := range j.Items {
+		if item.Status == DeletionStatusPending {
+			uids = append(uids, item.UID)
+		}
+	}
+
+	return uids
+}
+
+// Resolve sets the status and the reason of pending items with the UIDs.
+func (j *DeletionJob) Resolve(uids []UID, status, reason string) {
+	isResolved := make(map[UID]bool, len(uids))
+	for _, uid := range uids {
+		isResolved[uid] = true
+	}
+
+	for index, item := range j.Items {
+		if item.Status == DeletionStatusPending && isResolved[item.UID] {
+			j.Items[index].Status = status
+			j.Items[index].Reason = reason
+		}
+	}
+
+	j.UpdatedAt = time.Now()
+}
+
+// Copy returns a copy of the job which items can be changed apart from the job.
+func (j DeletionJob) Copy() *DeletionJob {
+	j.Items = append([]DeletionItem(nil), j.Items...)
+
+	return &j
+}
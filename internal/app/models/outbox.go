@@ -0,0 +1,15 @@
+package models
+
+// OutboxMessage is an event recorded together with the change of the link which caused it.
+// ID is assigned by the repository, messages are published in its order.
+type OutboxMessage struct {
+	ID    int64
+	Event WebhookEvent
+}
+
+func NewOutboxMessage(event *WebhookEvent) *OutboxMessage {
+	return &OutboxMessage{
+		ID:    0,
+		Event: *event,
+	}
+}
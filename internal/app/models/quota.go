@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Plan describes limits of a user. Zero value of a limit means no limit.
+type Plan struct {
+	Name         string
+	MaxLinks     int
+	MaxBatchSize int
+	MaxTTL       time.Duration
+}
+
+func NewPlan(name string, maxLinks, maxBatchSize int, maxTTL time.Duration) *Plan {
+	return &Plan{
+		Name:         name,
+		MaxLinks:     maxLinks,
+		MaxBatchSize: maxBatchSize,
+		MaxTTL:       maxTTL,
+	}
+}
+
+// ExpiresAt returns expiration time of a link created at now with requested TTL, capped by MaxTTL.
+// Zero time means that the link never expires.
+func (p Plan) ExpiresAt(requestedTTL time.Duration, now time.Time) time.Time {
+	ttl := requestedTTL
+	if p.MaxTTL > 0 && (ttl <= 0 || ttl > p.MaxTTL) {
+		ttl = p.MaxTTL
+	}
+
+	if ttl <= 0 {
+		return time.Time{}
+	}
+
+	return now.Add(ttl)
+}
+
+type Quota struct {
+	Plan      *Plan
+	UsedLinks int
+}
+
+func NewQuota(plan *Plan, usedLinks int) *Quota {
+	return &Quota{
+		Plan:      plan,
+		UsedLinks: usedLinks,
+	}
+}
+
+// RemainingLinks returns how many links can still be created, -1 means no limit.
+func (q Quota) RemainingLinks() int {
+	if q.Plan.MaxLinks <= 0 {
+		return -1
+	}
+
+	if q.UsedLinks >= q.Plan.MaxLinks {
+		return 0
+	}
+
+	return q.Plan.MaxLinks - q.UsedLinks
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// RateLimitBucket is the token bucket state of one rate limiting key.
+// Zero UpdatedAt means that the bucket has never been used and is full.
+type RateLimitBucket struct {
+	Key       string
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+func NewRateLimitBucket(key string) *RateLimitBucket {
+	return &RateLimitBucket{
+		Key:       key,
+		Tokens:    0,
+		UpdatedAt: time.Time{},
+	}
+}
@@ -2,28 +2,48 @@ package models
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// ShortURL keeps the original URL for redirects and its canonical form for deduplication.
+// Zero ExpiresAt means that the link never expires. A link disabled by an admin is kept but not redirected.
 type ShortURL struct {
-	ID        int
-	UID       UID
-	URL       URL
-	UserID    uuid.UUID
-	IsDeleted bool
+	ID           int
+	UID          UID
+	URL          URL
+	CanonicalURL URL
+	UserID       uuid.UUID
+	IsDeleted    bool
+	IsDisabled   bool
+	ExpiresAt    time.Time
+	Clicks       int
 }
 
 func NewShortURL(id int, url URL, uid UID, userID uuid.UUID) *ShortURL {
 	return &ShortURL{
-		ID:        id,
-		UID:       uid,
-		URL:       url,
-		UserID:    userID,
-		IsDeleted: false,
+		ID:           id,
+		UID:          uid,
+		URL:          url,
+		CanonicalURL: url,
+		UserID:       userID,
+		IsDeleted:    false,
+		IsDisabled:   false,
+		ExpiresAt:    time.Time{},
+		Clicks:       0,
 	}
 }
 
+func (s ShortURL) IsExpired(now time.Time) bool {
+	return !s.ExpiresAt.IsZero() && !now.Before(s.ExpiresAt)
+}
+
+// IsActive reports whether the link counts towards the quota of its owner.
+func (s ShortURL) IsActive(now time.Time) bool {
+	return !s.IsDeleted && !s.IsExpired(now)
+}
+
 func (s ShortURL) GetShortURL(baseURL string) URL {
 	return URL(fmt.Sprintf("%s/%s", baseURL, s.UID))
 }
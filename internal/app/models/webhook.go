@@ -0,0 +1,121 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WebhookEventLinkCreated = "link.created"
+	WebhookEventLinkUpdated = "link.updated"
+	WebhookEventLinkDeleted = "link.deleted"
+	WebhookEventLinkClicked = "link.clicked"
+
+	WebhookDeliveryStatusPending    = "pending"
+	WebhookDeliveryStatusSucceeded  = "succeeded"
+	WebhookDeliveryStatusDeadLetter = "dead_letter"
+)
+
+var WebhookEventTypes = []string{
+	WebhookEventLinkCreated,
+	WebhookEventLinkUpdated,
+	WebhookEventLinkDeleted,
+	WebhookEventLinkClicked,
+}
+
+// Webhook receives events of links of its owner. Empty EventTypes subscribe to every event.
+// Secret signs the deliveries, zero DeletedAt means an active webhook.
+type Webhook struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	URL        URL
+	Secret     string
+	EventTypes []string
+	CreatedAt  time.Time
+	DeletedAt  time.Time
+}
+
+func NewWebhook(userID uuid.UUID, url URL, secret string, eventTypes []string) *Webhook {
+	return &Webhook{
+		ID:         uuid.New(),
+		UserID:     userID,
+		URL:        url,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		CreatedAt:  time.Now(),
+		DeletedAt:  time.Time{},
+	}
+}
+
+func (w Webhook) IsDeleted() bool {
+	return !w.DeletedAt.IsZero()
+}
+
+func (w Webhook) IsSubscribed(eventType string) bool {
+	if w.IsDeleted() {
+		return false
+	}
+
+	if len(w.EventTypes) == 0 {
+		return true
+	}
+
+	for _, subscribedType := range w.EventTypes {
+		if subscribedType == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WebhookEvent is a change of the link, it is delivered to webhooks of the link owner.
+type WebhookEvent struct {
+	ID         uuid.UUID
+	Type       string
+	ShortURL   ShortURL // Snapshot of the link at the moment of the event.
+	OccurredAt time.Time
+}
+
+func NewWebhookEvent(eventType string, shortURL *ShortURL) *WebhookEvent {
+	return &WebhookEvent{
+		ID:         uuid.New(),
+		Type:       eventType,
+		ShortURL:   *shortURL,
+		OccurredAt: time.Now(),
+	}
+}
+
+// WebhookDelivery is an entry of the delivery log. Payload is the exact body sent to the webhook.
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	WebhookID      uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        string
+	Status         string
+	Attempts       int
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func NewWebhookDelivery(webhookID, eventID uuid.UUID, eventType, payload string) *WebhookDelivery {
+	now := time.Now()
+
+	return &WebhookDelivery{
+		ID:             uuid.New(),
+		WebhookID:      webhookID,
+		EventID:        eventID,
+		EventType:      eventType,
+		Payload:        payload,
+		Status:         WebhookDeliveryStatusPending,
+		Attempts:       0,
+		LastStatusCode: 0,
+		LastError:      "",
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
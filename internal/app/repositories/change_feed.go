@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"sort"
+
+	"github.com/tmitry/shorturl/internal/app/models"
+)
+
+// memoryChangeFeed keeps the change feed of the memory and file repositories. It is used under their lock.
+type memoryChangeFeed struct {
+	changes []*models.Change
+	lastSeq int64
+}
+
+func newMemoryChangeFeed() *memoryChangeFeed {
+	return &memoryChangeFeed{
+		changes: nil,
+		lastSeq: 0,
+	}
+}
+
+func (c *memoryChangeFeed) newChange(changeType string, shortURL *models.ShortURL) *models.Change {
+	change := models.NewChange(changeType, shortURL)
+	change.Seq = c.lastSeq + 1
+
+	return change
+}
+
+func (c *memoryChangeFeed) add(change *models.Change) {
+	c.changes = append(c.changes, change)
+	c.lastSeq = change.Seq
+}
+
+func (c *memoryChangeFeed) find(since int64, limit int) ([]*models.Change, error) {
+	index := sort.Search(len(c.changes), func(i int) bool {
+		return c.changes[i].Seq > since
+	})
+
+	changes := c.changes[index:]
+	if len(changes) == 0 {
+		return nil, ErrNotFound
+	}
+
+	if limit > 0 && len(changes) > limit {
+		changes = changes[:limit]
+	}
+
+	return append([]*models.Change(nil), changes...), nil
+}
@@ -3,24 +3,66 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/jackc/pgx/v5/stdlib" // solely for its side effects (initialization)
 	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/logger"
 	"github.com/tmitry/shorturl/internal/app/models"
 )
 
+const (
+	shortURLColumns = "id, uid, url, canonical_url, user_id, is_deleted, is_disabled, expires_at, clicks"
+	apiKeyColumns   = "id, user_id, name, prefix, hash, scopes, created_at, revoked_at"
+	webhookColumns  = "id, user_id, url, secret, event_types, created_at, deleted_at"
+
+	countActiveQuery = `SELECT COUNT(*) FROM short_url 
+WHERE user_id = $1 AND NOT is_deleted AND (expires_at IS NULL OR expires_at > now())`
+
+	deletionJobColumns = "id, user_id, items, created_at, updated_at"
+
+	webhookDeliveryColumns = "id, webhook_id, event_id, event_type, payload, status, attempts, " +
+		"last_status_code, last_error, created_at, updated_at"
+
+	messageMigrationsNotApplied = "migrations are not applied"
+
+	// changeFeedLockKey is the key of the advisory lock which orders writers of the change feed.
+	changeFeedLockKey = 7031
+)
+
+// databaseTables are the tables which CreateDatabase creates.
+var databaseTables = []string{
+	"short_url",
+	"rate_limit_bucket",
+	"api_key",
+	"app_user",
+	"ban",
+	"webhook",
+	"webhook_delivery",
+	"outbox",
+	"deletion_job",
+	"change_feed",
+	"transfer_claim",
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
 type DatabaseRepository struct {
 	db *sql.DB
 }
 
-func NewDatabaseRepository(databaseCfg *configs.DatabaseConfig) *DatabaseRepository {
+func NewDatabaseRepository(databaseCfg *configs.DatabaseConfig, log *logger.Logger) *DatabaseRepository {
 	database, err := sql.Open("pgx", databaseCfg.DSN)
 	if err != nil {
-		log.Panic(err)
+		log.Panic(messageFailedToOpenDatabase, logger.KeyError, err)
 	}
 
 	rep := &DatabaseRepository{
@@ -29,26 +71,18 @@ func NewDatabaseRepository(databaseCfg *configs.DatabaseConfig) *DatabaseReposit
 
 	err = rep.CreateDatabase()
 	if err != nil {
-		log.Panic(err)
+		log.Panic(messageFailedToOpenDatabase, logger.KeyError, err)
 	}
 
 	return rep
 }
 
 func (d DatabaseRepository) FindOneByUID(ctx context.Context, uid models.UID) (*models.ShortURL, error) {
-	shortURL := models.NewShortURL(0, "", "", uuid.UUID{})
-
-	err := d.db.QueryRowContext(
+	shortURL, err := scanShortURL(d.db.QueryRowContext(
 		ctx,
-		"SELECT id, uid, url, user_id, is_deleted FROM short_url WHERE uid = $1",
+		"SELECT "+shortURLColumns+" FROM short_url WHERE uid = $1",
 		uid,
-	).Scan(
-		&shortURL.ID,
-		&shortURL.UID,
-		&shortURL.URL,
-		&shortURL.UserID,
-		&shortURL.IsDeleted,
-	)
+	))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
@@ -65,7 +99,7 @@ func (d DatabaseRepository) FindAllByUserID(ctx context.Context, userID uuid.UUI
 
 	rows, err := d.db.QueryContext(
 		ctx,
-		"SELECT id, uid, url, user_id, is_deleted FROM short_url WHERE user_id = $1",
+		"SELECT "+shortURLColumns+" FROM short_url WHERE user_id = $1",
 		userID,
 	)
 	if err != nil {
@@ -80,15 +114,7 @@ func (d DatabaseRepository) FindAllByUserID(ctx context.Context, userID uuid.UUI
 	}(rows)
 
 	for rows.Next() {
-		shortURL := models.NewShortURL(0, "", "", uuid.UUID{})
-
-		err = rows.Scan(
-			&shortURL.ID,
-			&shortURL.UID,
-			&shortURL.URL,
-			&shortURL.UserID,
-			&shortURL.IsDeleted,
-		)
+		shortURL, err := scanShortURL(rows)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
 		}
@@ -108,7 +134,7 @@ func (d DatabaseRepository) FindAllByUserID(ctx context.Context, userID uuid.UUI
 	return userShortURLs, nil
 }
 
-func (d DatabaseRepository) Save(ctx context.Context, shortURL *models.ShortURL) (fnErr error) {
+func (d DatabaseRepository) Save(ctx context.Context, shortURL *models.ShortURL, maxLinks int) (fnErr error) {
 	transaction, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("%s: %w", messageFailedToSave, err)
@@ -122,8 +148,8 @@ func (d DatabaseRepository) Save(ctx context.Context, shortURL *models.ShortURL)
 	}(transaction)
 
 	insertTxStmt, err := transaction.Prepare(`
-INSERT INTO short_url(url, uid, user_id) VALUES($1, $2, $3) 
-ON CONFLICT(user_id, url) DO NOTHING RETURNING id
+INSERT INTO short_url(url, canonical_url, uid, user_id, expires_at) VALUES($1, $2, $3, $4, $5) 
+ON CONFLICT(user_id, canonical_url) DO NOTHING RETURNING id
 `)
 	if err != nil {
 		return fmt.Errorf("%s: %w", messageFailedToSave, err)
@@ -137,7 +163,7 @@ ON CONFLICT(user_id, url) DO NOTHING RETURNING id
 	}(insertTxStmt)
 
 	selectTxStmt, err := transaction.Prepare(`
-SELECT id, uid FROM short_url WHERE user_id = $1 AND url = $2
+SELECT id, uid, url, expires_at FROM short_url WHERE user_id = $1 AND canonical_url = $2
 `)
 	if err != nil {
 		return fmt.Errorf("%s: %w", messageFailedToSave, err)
@@ -150,19 +176,42 @@ SELECT id, uid FROM short_url WHERE user_id = $1 AND url = $2
 		}
 	}(selectTxStmt)
 
-	row := insertTxStmt.QueryRowContext(ctx, shortURL.URL, shortURL.UID, shortURL.UserID)
+	if maxLinks > 0 {
+		if err := lockQuota(ctx, transaction, []uuid.UUID{shortURL.UserID}); err != nil {
+			return err
+		}
+
+		// The duplicate is reported even if the user has no links left.
+		isFound, err := selectDuplicate(ctx, selectTxStmt, shortURL)
+		if err != nil {
+			return err
+		}
+
+		if isFound {
+			return ErrURLDuplicate
+		}
+
+		if err := checkActiveLinks(ctx, transaction, map[uuid.UUID]int{shortURL.UserID: 1}, maxLinks); err != nil {
+			return err
+		}
+	}
+
+	row := insertTxStmt.QueryRowContext(
+		ctx,
+		shortURL.URL,
+		shortURL.CanonicalURL,
+		shortURL.UID,
+		shortURL.UserID,
+		nullTime(shortURL.ExpiresAt),
+	)
 	if row.Err() != nil {
 		return fmt.Errorf("%s: %w", messageFailedToSave, row.Err())
 	}
 
 	if err := row.Scan(&shortURL.ID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			err := selectTxStmt.QueryRowContext(ctx, shortURL.UserID, shortURL.URL).Scan(
-				&shortURL.ID,
-				&shortURL.UID,
-			)
-			if err != nil {
-				return fmt.Errorf("%s: %w", messageFailedToFind, err)
+			if _, err := selectDuplicate(ctx, selectTxStmt, shortURL); err != nil {
+				return err
 			}
 
 			return ErrURLDuplicate
@@ -171,6 +220,14 @@ SELECT id, uid FROM short_url WHERE user_id = $1 AND url = $2
 		return fmt.Errorf("%s: %w", messageFailedToSave, err)
 	}
 
+	if err := insertOutboxMessage(ctx, transaction, models.WebhookEventLinkCreated, shortURL); err != nil {
+		return err
+	}
+
+	if err := insertChanges(ctx, transaction, models.ChangeTypeCreate, []*models.ShortURL{shortURL}); err != nil {
+		return err
+	}
+
 	if err = transaction.Commit(); err != nil {
 		return fmt.Errorf("%s: %w", messageFailedToSave, err)
 	}
@@ -178,7 +235,11 @@ SELECT id, uid FROM short_url WHERE user_id = $1 AND url = $2
 	return nil
 }
 
-func (d DatabaseRepository) BatchSave(ctx context.Context, shortURLs []*models.ShortURL) (fnErr error) {
+func (d DatabaseRepository) BatchSave(
+	ctx context.Context,
+	shortURLs []*models.ShortURL,
+	maxLinks int,
+) (fnErr error) {
 	transaction, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("%s: %w", messageFailedToSave, err)
@@ -192,8 +253,8 @@ func (d DatabaseRepository) BatchSave(ctx context.Context, shortURLs []*models.S
 	}(transaction)
 
 	insertTxStmt, err := transaction.Prepare(`
-INSERT INTO short_url(url, uid, user_id) VALUES($1, $2, $3) 
-ON CONFLICT(user_id, url) DO NOTHING RETURNING id
+INSERT INTO short_url(url, canonical_url, uid, user_id, expires_at) VALUES($1, $2, $3, $4, $5) 
+ON CONFLICT(user_id, canonical_url) DO NOTHING RETURNING id
 `)
 	if err != nil {
 		return fmt.Errorf("%s: %w", messageFailedToSave, err)
@@ -207,7 +268,7 @@ ON CONFLICT(user_id, url) DO NOTHING RETURNING id
 	}(insertTxStmt)
 
 	selectTxStmt, err := transaction.Prepare(`
-SELECT id, uid FROM short_url WHERE user_id = $1 AND url = $2
+SELECT id, uid, url, expires_at FROM short_url WHERE user_id = $1 AND canonical_url = $2
 `)
 	if err != nil {
 		return fmt.Errorf("%s: %w", messageFailedToSave, err)
@@ -220,20 +281,36 @@ SELECT id, uid FROM short_url WHERE user_id = $1 AND url = $2
 		}
 	}(selectTxStmt)
 
+	if maxLinks > 0 {
+		newLinks, err := lockNewLinks(ctx, transaction, selectTxStmt, shortURLs)
+		if err != nil {
+			return err
+		}
+
+		if err := checkActiveLinks(ctx, transaction, newLinks, maxLinks); err != nil {
+			return err
+		}
+	}
+
+	var savedShortURLs []*models.ShortURL
+
 	for _, shortURL := range shortURLs {
-		row := insertTxStmt.QueryRowContext(ctx, shortURL.URL, shortURL.UID, shortURL.UserID)
+		row := insertTxStmt.QueryRowContext(
+			ctx,
+			shortURL.URL,
+			shortURL.CanonicalURL,
+			shortURL.UID,
+			shortURL.UserID,
+			nullTime(shortURL.ExpiresAt),
+		)
 		if row.Err() != nil {
 			return fmt.Errorf("%s: %w", messageFailedToSave, row.Err())
 		}
 
 		if err := row.Scan(&shortURL.ID); err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				err := selectTxStmt.QueryRowContext(ctx, shortURL.UserID, shortURL.URL).Scan(
-					&shortURL.ID,
-					&shortURL.UID,
-				)
-				if err != nil {
-					return fmt.Errorf("%s: %w", messageFailedToFind, err)
+				if _, err := selectDuplicate(ctx, selectTxStmt, shortURL); err != nil {
+					return err
 				}
 
 				continue
@@ -241,6 +318,16 @@ SELECT id, uid FROM short_url WHERE user_id = $1 AND url = $2
 
 			return fmt.Errorf("%s: %w", messageFailedToSave, err)
 		}
+
+		if err := insertOutboxMessage(ctx, transaction, models.WebhookEventLinkCreated, shortURL); err != nil {
+			return err
+		}
+
+		savedShortURLs = append(savedShortURLs, shortURL)
+	}
+
+	if err := insertChanges(ctx, transaction, models.ChangeTypeCreate, savedShortURLs); err != nil {
+		return err
 	}
 
 	if err = transaction.Commit(); err != nil {
@@ -250,7 +337,7 @@ SELECT id, uid FROM short_url WHERE user_id = $1 AND url = $2
 	return nil
 }
 
-func (d DatabaseRepository) BatchDelete(ctx context.Context, shortURLs []*models.ShortURL) error {
+func (d DatabaseRepository) BatchDelete(ctx context.Context, shortURLs []*models.ShortURL) (fnErr error) {
 	if len(shortURLs) == 0 {
 		return ErrNothingToDelete
 	}
@@ -261,14 +348,199 @@ func (d DatabaseRepository) BatchDelete(ctx context.Context, shortURLs []*models
 		ids = append(ids, shortURL.ID)
 	}
 
-	_, err := d.db.ExecContext(ctx, "UPDATE short_url SET is_deleted = true WHERE id = ANY($1)", ids)
+	transaction, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToDelete, err)
+	}
+
+	defer func(transaction *sql.Tx) {
+		err := transaction.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			fnErr = fmt.Errorf("%s: %w", messageFailedToDelete, err)
+		}
+	}(transaction)
+
+	deletedShortURLs, err := markDeleted(ctx, transaction, ids)
 	if err != nil {
 		return fmt.Errorf("%s: %w", messageFailedToDelete, err)
 	}
 
+	for _, deletedShortURL := range deletedShortURLs {
+		if err := insertOutboxMessage(ctx, transaction, models.WebhookEventLinkDeleted, deletedShortURL); err != nil {
+			return err
+		}
+	}
+
+	if err := insertChanges(ctx, transaction, models.ChangeTypeDelete, deletedShortURLs); err != nil {
+		return err
+	}
+
+	if err = transaction.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToDelete, err)
+	}
+
+	return nil
+}
+
+// selectDuplicate replaces shortURL with the link of its user which has the same canonical URL if there is one.
+func selectDuplicate(ctx context.Context, selectTxStmt *sql.Stmt, shortURL *models.ShortURL) (bool, error) {
+	var (
+		id        int
+		uid       models.UID
+		url       models.URL
+		expiresAt sql.NullTime
+	)
+
+	err := selectTxStmt.QueryRowContext(ctx, shortURL.UserID, shortURL.CanonicalURL).Scan(&id, &uid, &url, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	shortURL.ID = id
+	shortURL.UID = uid
+	shortURL.URL = url
+	shortURL.ExpiresAt = expiresAt.Time
+
+	return true, nil
+}
+
+/*
+lockQuota serializes saving links of the users until the end of the transaction,
+so concurrent requests can't exceed the quota between counting and inserting links.
+Users are locked in the same order by every transaction.
+*/
+func lockQuota(ctx context.Context, transaction *sql.Tx, userIDs []uuid.UUID) error {
+	sort.Slice(userIDs, func(i, j int) bool {
+		return userIDs[i].String() < userIDs[j].String()
+	})
+
+	for _, userID := range userIDs {
+		_, err := transaction.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", userID.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", messageFailedToSave, err)
+		}
+	}
+
+	return nil
+}
+
+// lockNewLinks locks the quota of the users of shortURLs and counts links which the users have not shortened yet.
+func lockNewLinks(
+	ctx context.Context,
+	transaction *sql.Tx,
+	selectTxStmt *sql.Stmt,
+	shortURLs []*models.ShortURL,
+) (map[uuid.UUID]int, error) {
+	seen := make(map[uuid.UUID]map[models.URL]struct{})
+
+	for _, shortURL := range shortURLs {
+		if seen[shortURL.UserID] == nil {
+			seen[shortURL.UserID] = make(map[models.URL]struct{})
+		}
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(seen))
+	for userID := range seen {
+		userIDs = append(userIDs, userID)
+	}
+
+	if err := lockQuota(ctx, transaction, userIDs); err != nil {
+		return nil, err
+	}
+
+	newLinks := make(map[uuid.UUID]int, len(seen))
+
+	for _, shortURL := range shortURLs {
+		if _, ok := seen[shortURL.UserID][shortURL.CanonicalURL]; ok {
+			continue
+		}
+
+		seen[shortURL.UserID][shortURL.CanonicalURL] = struct{}{}
+
+		existing := *shortURL
+
+		isFound, err := selectDuplicate(ctx, selectTxStmt, &existing)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isFound {
+			newLinks[shortURL.UserID]++
+		}
+	}
+
+	return newLinks, nil
+}
+
+// checkActiveLinks returns ErrQuotaExceeded if any user can't have newLinks more active links.
+func checkActiveLinks(ctx context.Context, transaction *sql.Tx, newLinks map[uuid.UUID]int, maxLinks int) error {
+	for userID, count := range newLinks {
+		var activeLinks int
+
+		err := transaction.QueryRowContext(ctx, countActiveQuery, userID).Scan(&activeLinks)
+		if err != nil {
+			return fmt.Errorf("%s: %w", messageFailedToFind, err)
+		}
+
+		if activeLinks+count > maxLinks {
+			return ErrQuotaExceeded
+		}
+	}
+
 	return nil
 }
 
+// markDeleted deletes links which are not deleted yet and returns them.
+func markDeleted(ctx context.Context, transaction *sql.Tx, ids []int) ([]*models.ShortURL, error) {
+	return queryShortURLs(
+		ctx,
+		transaction,
+		"UPDATE short_url SET is_deleted = true WHERE id = ANY($1) AND NOT is_deleted RETURNING "+shortURLColumns,
+		ids,
+	)
+}
+
+// queryShortURLs runs the query in the transaction and scans the returned rows of shortURLColumns.
+func queryShortURLs(
+	ctx context.Context,
+	transaction *sql.Tx,
+	query string,
+	args ...any,
+) (_ []*models.ShortURL, fnErr error) {
+	rows, err := transaction.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fnErr = err
+		}
+	}(rows)
+
+	var shortURLs []*models.ShortURL
+
+	for rows.Next() {
+		shortURL, err := scanShortURL(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		shortURLs = append(shortURLs, shortURL)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shortURLs, nil
+}
+
 func (d DatabaseRepository) FindAllByUserIDAndUIDs(
 	ctx context.Context,
 	userID uuid.UUID,
@@ -278,7 +550,7 @@ func (d DatabaseRepository) FindAllByUserIDAndUIDs(
 
 	rows, err := d.db.QueryContext(
 		ctx,
-		"SELECT id, uid, url, user_id, is_deleted FROM short_url WHERE user_id = $1 AND uid = ANY($2)",
+		"SELECT "+shortURLColumns+" FROM short_url WHERE user_id = $1 AND uid = ANY($2)",
 		userID,
 		uids,
 	)
@@ -294,15 +566,7 @@ func (d DatabaseRepository) FindAllByUserIDAndUIDs(
 	}(rows)
 
 	for rows.Next() {
-		shortURL := models.NewShortURL(0, "", "", uuid.UUID{})
-
-		err = rows.Scan(
-			&shortURL.ID,
-			&shortURL.UID,
-			&shortURL.URL,
-			&shortURL.UserID,
-			&shortURL.IsDeleted,
-		)
+		shortURL, err := scanShortURL(rows)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
 		}
@@ -322,32 +586,1471 @@ func (d DatabaseRepository) FindAllByUserIDAndUIDs(
 	return shortURLs, nil
 }
 
-func (d DatabaseRepository) Ping(ctx context.Context) error {
-	if err := d.db.PingContext(ctx); err != nil {
-		return fmt.Errorf("%s: %w", messageFailedToPing, err)
+func (d DatabaseRepository) CountActiveByUserID(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+
+	err := d.db.QueryRowContext(ctx, countActiveQuery, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", messageFailedToFind, err)
 	}
 
-	return nil
+	return count, nil
 }
 
-func (d DatabaseRepository) CreateDatabase() error {
-	query := `
-CREATE TABLE IF NOT EXISTS short_url (
-	id SERIAL,
-	uid VARCHAR(32) NOT NULL,
-	url TEXT NOT NULL,
-	user_id VARCHAR(36) NOT NULL,
-	is_deleted BOOL NOT NULL DEFAULT false,
-	CONSTRAINT short_url_pkey PRIMARY KEY (id)
-);
+func (d DatabaseRepository) ReassignUserID(
+	ctx context.Context,
+	fromUserID, toUserID uuid.UUID,
+	maxLinks int,
+) (_ int, _ int, fnErr error) {
+	if fromUserID == toUserID {
+		return 0, 0, nil
+	}
 
-CREATE UNIQUE INDEX IF NOT EXISTS short_url_uid_idx ON short_url (uid);
-CREATE UNIQUE INDEX IF NOT EXISTS short_url_user_id_url_idx ON short_url (user_id, url);
-`
+	transaction, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
 
-	if _, err := d.db.Exec(query); err != nil {
-		return fmt.Errorf("%s: %w", messageFailedToCreateDatabase, err)
+	defer func(transaction *sql.Tx) {
+		err := transaction.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			fnErr = fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+		}
+	}(transaction)
+
+	moved, conflicts, err := reassignUserID(ctx, transaction, fromUserID, toUserID, maxLinks)
+	if err != nil {
+		return 0, 0, err
 	}
 
-	return nil
+	if err = transaction.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
+
+	return moved, conflicts, nil
+}
+
+// ClaimTransfer rolls the claim back together with the links if the move fails, e.g. with ErrQuotaExceeded.
+func (d DatabaseRepository) ClaimTransfer(
+	ctx context.Context,
+	tokenID string,
+	expiresAt time.Time,
+	fromUserID, toUserID uuid.UUID,
+	maxLinks int,
+) (_ int, _ int, fnErr error) {
+	transaction, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
+
+	defer func(transaction *sql.Tx) {
+		err := transaction.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			fnErr = fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+		}
+	}(transaction)
+
+	_, err = transaction.ExecContext(ctx, "DELETE FROM transfer_claim WHERE expires_at <= now()")
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", messageFailedToDelete, err)
+	}
+
+	// Concurrent claims of the same token wait for each other on the primary key, only one of them inserts.
+	result, err := transaction.ExecContext(
+		ctx,
+		"INSERT INTO transfer_claim(token_id, expires_at) VALUES($1, $2) ON CONFLICT(token_id) DO NOTHING",
+		tokenID,
+		expiresAt,
+	)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", messageFailedToSave, err)
+	}
+
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", messageFailedToSave, err)
+	}
+
+	if claimed == 0 {
+		return 0, 0, ErrAlreadyClaimed
+	}
+
+	moved, conflicts, err := reassignUserID(ctx, transaction, fromUserID, toUserID, maxLinks)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = transaction.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
+
+	return moved, conflicts, nil
+}
+
+/*
+reassignUserID moves the links within the transaction. With positive maxLinks the quota of toUserID is locked
+and ErrQuotaExceeded is returned if the moved links exceed it, the caller rolls the transaction back then.
+*/
+func reassignUserID(
+	ctx context.Context,
+	transaction *sql.Tx,
+	fromUserID, toUserID uuid.UUID,
+	maxLinks int,
+) (int, int, error) {
+	if fromUserID == toUserID {
+		return 0, 0, nil
+	}
+
+	if maxLinks > 0 {
+		if err := lockQuota(ctx, transaction, []uuid.UUID{toUserID}); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	moved, err := queryShortURLs(
+		ctx,
+		transaction,
+		`UPDATE short_url SET user_id = $2 
+WHERE user_id = $1 AND NOT EXISTS (
+	SELECT 1 FROM short_url AS target WHERE target.user_id = $2 AND target.canonical_url = short_url.canonical_url
+) RETURNING `+shortURLColumns,
+		fromUserID,
+		toUserID,
+	)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
+
+	if maxLinks > 0 && countActive(moved, time.Now()) > 0 {
+		if err := checkActiveLinks(ctx, transaction, map[uuid.UUID]int{toUserID: 0}, maxLinks); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if err := insertChanges(ctx, transaction, models.ChangeTypeUpdate, moved); err != nil {
+		return 0, 0, err
+	}
+
+	var conflicts int
+
+	err = transaction.QueryRowContext(ctx, "SELECT COUNT(*) FROM short_url WHERE user_id = $1", fromUserID).
+		Scan(&conflicts)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	return len(moved), conflicts, nil
+}
+
+func (d DatabaseRepository) SearchShortURLs(
+	ctx context.Context,
+	filter *models.ShortURLFilter,
+) (_ []*models.ShortURL, fnErr error) {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	if filter.Query != "" {
+		args = append(args, "%"+escapeLike(filter.Query)+"%")
+		conditions = append(conditions, fmt.Sprintf("url LIKE $%d", len(args)))
+	}
+
+	if filter.UserID != (uuid.UUID{}) {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+
+	query := "SELECT " + shortURLColumns + " FROM short_url"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query += " ORDER BY uid"
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	args = append(args, filter.Offset)
+	query += fmt.Sprintf(" OFFSET $%d", len(args))
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fnErr = err
+		}
+	}(rows)
+
+	var shortURLs []*models.ShortURL
+
+	for rows.Next() {
+		shortURL, err := scanShortURL(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+		}
+
+		shortURLs = append(shortURLs, shortURL)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	if len(shortURLs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return shortURLs, nil
+}
+
+func (d DatabaseRepository) SetDisabled(ctx context.Context, uid models.UID, isDisabled bool) (fnErr error) {
+	transaction, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
+
+	defer func(transaction *sql.Tx) {
+		err := transaction.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			fnErr = fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+		}
+	}(transaction)
+
+	shortURL, err := scanShortURL(transaction.QueryRowContext(
+		ctx,
+		"UPDATE short_url SET is_disabled = $2 WHERE uid = $1 RETURNING "+shortURLColumns,
+		uid,
+		isDisabled,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+
+		return fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
+
+	if err := insertChanges(ctx, transaction, models.ChangeTypeUpdate, []*models.ShortURL{shortURL}); err != nil {
+		return err
+	}
+
+	if err = transaction.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
+
+	return nil
+}
+
+func (d DatabaseRepository) SaveBan(ctx context.Context, ban *models.Ban) error {
+	_, err := d.db.ExecContext(
+		ctx,
+		`INSERT INTO ban(user_id, reason, created_at) VALUES($1, $2, $3) 
+ON CONFLICT(user_id) DO UPDATE SET reason = EXCLUDED.reason, created_at = EXCLUDED.created_at`,
+		ban.UserID,
+		ban.Reason,
+		ban.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToSave, err)
+	}
+
+	return nil
+}
+
+func (d DatabaseRepository) DeleteBan(ctx context.Context, userID uuid.UUID) error {
+	result, err := d.db.ExecContext(ctx, "DELETE FROM ban WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToDelete, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToDelete, err)
+	}
+
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (d DatabaseRepository) FindBanByUserID(ctx context.Context, userID uuid.UUID) (*models.Ban, error) {
+	ban := models.NewBan(uuid.UUID{}, "")
+
+	err := d.db.QueryRowContext(ctx, "SELECT user_id, reason, created_at FROM ban WHERE user_id = $1", userID).
+		Scan(&ban.UserID, &ban.Reason, &ban.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	return ban, nil
+}
+
+func (d DatabaseRepository) CountAll(ctx context.Context) (*models.GlobalCounts, error) {
+	counts := &models.GlobalCounts{
+		Links:         0,
+		ActiveLinks:   0,
+		DeletedLinks:  0,
+		DisabledLinks: 0,
+		Users:         0,
+		BannedUsers:   0,
+		Clicks:        0,
+	}
+
+	err := d.db.QueryRowContext(
+		ctx,
+		`SELECT 
+	COUNT(*),
+	COUNT(*) FILTER (WHERE NOT is_deleted AND (expires_at IS NULL OR expires_at > now())),
+	COUNT(*) FILTER (WHERE is_deleted),
+	COUNT(*) FILTER (WHERE is_disabled),
+	COUNT(DISTINCT user_id),
+	(SELECT COUNT(*) FROM ban),
+	COALESCE(SUM(clicks), 0)
+FROM short_url`,
+	).Scan(
+		&counts.Links,
+		&counts.ActiveLinks,
+		&counts.DeletedLinks,
+		&counts.DisabledLinks,
+		&counts.Users,
+		&counts.BannedUsers,
+		&counts.Clicks,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	return counts, nil
+}
+
+func (d DatabaseRepository) RegisterClick(ctx context.Context, uid models.UID) error {
+	result, err := d.db.ExecContext(ctx, "UPDATE short_url SET clicks = clicks + 1 WHERE uid = $1", uid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
+
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (d DatabaseRepository) Ping(ctx context.Context) error {
+	if err := d.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToPing, err)
+	}
+
+	return nil
+}
+
+// CheckMigrations reports an error if any table created by CreateDatabase is missing from the schema.
+func (d DatabaseRepository) CheckMigrations(ctx context.Context) (fnErr error) {
+	rows, err := d.db.QueryContext(
+		ctx,
+		"SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ANY($1)",
+		databaseTables,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageMigrationsNotApplied, err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fnErr = err
+		}
+	}(rows)
+
+	isCreated := make(map[string]bool, len(databaseTables))
+
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return fmt.Errorf("%s: %w", messageMigrationsNotApplied, err)
+		}
+
+		isCreated[table] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", messageMigrationsNotApplied, err)
+	}
+
+	for _, table := range databaseTables {
+		if !isCreated[table] {
+			return fmt.Errorf("%s: table %s is missing", messageMigrationsNotApplied, table)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the connection pool, it waits for queries which have started.
+func (d DatabaseRepository) Close() error {
+	if err := d.db.Close(); err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToClose, err)
+	}
+
+	return nil
+}
+
+// Stats returns statistics of the connection pool.
+func (d DatabaseRepository) Stats() sql.DBStats {
+	return d.db.Stats()
+}
+
+func (d DatabaseRepository) UpdateRateLimitBuckets(
+	ctx context.Context,
+	keys []string,
+	update func(buckets []*models.RateLimitBucket),
+) (fnErr error) {
+	transaction, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
+
+	defer func(transaction *sql.Tx) {
+		err := transaction.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			fnErr = fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+		}
+	}(transaction)
+
+	// Rows are locked in the same order by every request, so requests sharing buckets can't deadlock.
+	lockOrder := make([]int, len(keys))
+	for index := range lockOrder {
+		lockOrder[index] = index
+	}
+
+	sort.SliceStable(lockOrder, func(i, j int) bool {
+		return keys[lockOrder[i]] < keys[lockOrder[j]]
+	})
+
+	buckets := make([]*models.RateLimitBucket, len(keys))
+
+	for _, index := range lockOrder {
+		key := keys[index]
+
+		// The empty row makes concurrent first requests wait for each other on the row lock.
+		_, err = transaction.ExecContext(
+			ctx,
+			"INSERT INTO rate_limit_bucket(key) VALUES($1) ON CONFLICT(key) DO NOTHING",
+			key,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+		}
+
+		var (
+			tokens    sql.NullFloat64
+			updatedAt sql.NullTime
+		)
+
+		err = transaction.QueryRowContext(
+			ctx,
+			"SELECT tokens, updated_at FROM rate_limit_bucket WHERE key = $1 FOR UPDATE",
+			key,
+		).Scan(&tokens, &updatedAt)
+		if err != nil {
+			return fmt.Errorf("%s: %w", messageFailedToFind, err)
+		}
+
+		bucket := models.NewRateLimitBucket(key)
+		if tokens.Valid && updatedAt.Valid {
+			bucket.Tokens = tokens.Float64
+			bucket.UpdatedAt = updatedAt.Time
+		}
+
+		buckets[index] = bucket
+	}
+
+	update(buckets)
+
+	for _, bucket := range buckets {
+		_, err = transaction.ExecContext(
+			ctx,
+			"UPDATE rate_limit_bucket SET tokens = $2, updated_at = $3 WHERE key = $1",
+			bucket.Key,
+			bucket.Tokens,
+			bucket.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+		}
+	}
+
+	if err = transaction.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
+
+	return nil
+}
+
+func (d DatabaseRepository) DeleteStaleRateLimitBuckets(ctx context.Context, updatedBefore time.Time) error {
+	_, err := d.db.ExecContext(ctx, "DELETE FROM rate_limit_bucket WHERE updated_at < $1", updatedBefore)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToDelete, err)
+	}
+
+	return nil
+}
+
+func (d DatabaseRepository) SaveAPIKey(ctx context.Context, apiKey *models.APIKey) error {
+	_, err := d.db.ExecContext(
+		ctx,
+		"INSERT INTO api_key("+apiKeyColumns+") VALUES($1, $2, $3, $4, $5, $6, $7, $8)",
+		apiKey.ID,
+		apiKey.UserID,
+		apiKey.Name,
+		apiKey.Prefix,
+		apiKey.Hash,
+		strings.Join(apiKey.Scopes, ","),
+		apiKey.CreatedAt,
+		nullTime(apiKey.RevokedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToSave, err)
+	}
+
+	return nil
+}
+
+func (d DatabaseRepository) FindAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	apiKey, err := scanAPIKey(d.db.QueryRowContext(
+		ctx,
+		"SELECT "+apiKeyColumns+" FROM api_key WHERE hash = $1",
+		hash,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	return apiKey, nil
+}
+
+func (d DatabaseRepository) FindAllAPIKeysByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+) (_ []*models.APIKey, fnErr error) {
+	rows, err := d.db.QueryContext(
+		ctx,
+		"SELECT "+apiKeyColumns+" FROM api_key WHERE user_id = $1 ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fnErr = err
+		}
+	}(rows)
+
+	var apiKeys []*models.APIKey
+
+	for rows.Next() {
+		apiKey, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+		}
+
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	if len(apiKeys) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return apiKeys, nil
+}
+
+func (d DatabaseRepository) RevokeAPIKey(ctx context.Context, userID, id uuid.UUID) error {
+	result, err := d.db.ExecContext(
+		ctx,
+		"UPDATE api_key SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1 AND user_id = $2",
+		id,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
+
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (d DatabaseRepository) SaveUser(ctx context.Context, user *models.User) error {
+	result, err := d.db.ExecContext(
+		ctx,
+		`INSERT INTO app_user(id, username, password_hash, created_at) VALUES($1, $2, $3, $4) 
+ON CONFLICT(username) DO NOTHING`,
+		user.ID,
+		user.Username,
+		user.PasswordHash,
+		user.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToSave, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToSave, err)
+	}
+
+	if affected == 0 {
+		return ErrUsernameTaken
+	}
+
+	return nil
+}
+
+func (d DatabaseRepository) FindUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return d.findUser(ctx, "id", id)
+}
+
+func (d DatabaseRepository) FindUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return d.findUser(ctx, "username", username)
+}
+
+func (d DatabaseRepository) findUser(ctx context.Context, column string, value any) (*models.User, error) {
+	user := models.NewUser(uuid.UUID{}, "", "")
+
+	err := d.db.QueryRowContext(
+		ctx,
+		"SELECT id, username, password_hash, created_at FROM app_user WHERE "+column+" = $1",
+		value,
+	).Scan(
+		&user.ID,
+		&user.Username,
+		&user.PasswordHash,
+		&user.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	return user, nil
+}
+
+func (d DatabaseRepository) SaveWebhook(ctx context.Context, webhook *models.Webhook) error {
+	_, err := d.db.ExecContext(
+		ctx,
+		"INSERT INTO webhook("+webhookColumns+") VALUES($1, $2, $3, $4, $5, $6, $7)",
+		webhook.ID,
+		webhook.UserID,
+		webhook.URL,
+		webhook.Secret,
+		strings.Join(webhook.EventTypes, ","),
+		webhook.CreatedAt,
+		nullTime(webhook.DeletedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToSave, err)
+	}
+
+	return nil
+}
+
+func (d DatabaseRepository) FindAllWebhooksByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+) (_ []*models.Webhook, fnErr error) {
+	rows, err := d.db.QueryContext(
+		ctx,
+		"SELECT "+webhookColumns+" FROM webhook WHERE user_id = $1 AND deleted_at IS NULL ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fnErr = err
+		}
+	}(rows)
+
+	var webhooks []*models.Webhook
+
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	if len(webhooks) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return webhooks, nil
+}
+
+func (d DatabaseRepository) FindWebhookByID(ctx context.Context, id uuid.UUID) (*models.Webhook, error) {
+	webhook, err := scanWebhook(d.db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhook WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	return webhook, nil
+}
+
+func (d DatabaseRepository) DeleteWebhook(ctx context.Context, userID, id uuid.UUID) error {
+	result, err := d.db.ExecContext(
+		ctx,
+		"UPDATE webhook SET deleted_at = now() WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL",
+		id,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToDelete, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToDelete, err)
+	}
+
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (d DatabaseRepository) SaveWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	_, err := d.db.ExecContext(
+		ctx,
+		`INSERT INTO webhook_delivery(`+webhookDeliveryColumns+`) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT(id) DO UPDATE SET status = EXCLUDED.status, attempts = EXCLUDED.attempts, 
+last_status_code = EXCLUDED.last_status_code, last_error = EXCLUDED.last_error, updated_at = EXCLUDED.updated_at`,
+		delivery.ID,
+		delivery.WebhookID,
+		delivery.EventID,
+		delivery.EventType,
+		delivery.Payload,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.LastStatusCode,
+		delivery.LastError,
+		delivery.CreatedAt,
+		delivery.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToSave, err)
+	}
+
+	return nil
+}
+
+func (d DatabaseRepository) FindAllWebhookDeliveriesByWebhookID(
+	ctx context.Context,
+	webhookID uuid.UUID,
+	limit int,
+) (_ []*models.WebhookDelivery, fnErr error) {
+	rows, err := d.db.QueryContext(
+		ctx,
+		"SELECT "+webhookDeliveryColumns+" FROM webhook_delivery WHERE webhook_id = $1 "+
+			"ORDER BY created_at DESC LIMIT NULLIF($2, 0)",
+		webhookID,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fnErr = err
+		}
+	}(rows)
+
+	var deliveries []*models.WebhookDelivery
+
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	if len(deliveries) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return deliveries, nil
+}
+
+func (d DatabaseRepository) FindAllPendingWebhookDeliveries(
+	ctx context.Context,
+) (_ []*models.WebhookDelivery, fnErr error) {
+	rows, err := d.db.QueryContext(
+		ctx,
+		"SELECT "+webhookDeliveryColumns+" FROM webhook_delivery WHERE status = $1 ORDER BY created_at",
+		models.WebhookDeliveryStatusPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fnErr = err
+		}
+	}(rows)
+
+	var deliveries []*models.WebhookDelivery
+
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	if len(deliveries) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return deliveries, nil
+}
+
+// SaveDeletionJob stores items as JSON, the status column lets pending jobs be found without decoding them.
+func (d DatabaseRepository) SaveDeletionJob(ctx context.Context, job *models.DeletionJob) error {
+	items, err := json.Marshal(job.Items)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToSave, err)
+	}
+
+	_, err = d.db.ExecContext(
+		ctx,
+		`INSERT INTO deletion_job(`+deletionJobColumns+`, status) VALUES($1, $2, $3, $4, $5, $6)
+ON CONFLICT(id) DO UPDATE SET items = EXCLUDED.items, updated_at = EXCLUDED.updated_at, status = EXCLUDED.status`,
+		job.ID,
+		job.UserID,
+		string(items),
+		job.CreatedAt,
+		job.UpdatedAt,
+		job.Status(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToSave, err)
+	}
+
+	return nil
+}
+
+func (d DatabaseRepository) FindDeletionJobByID(ctx context.Context, id uuid.UUID) (*models.DeletionJob, error) {
+	job, err := scanDeletionJob(d.db.QueryRowContext(
+		ctx,
+		"SELECT "+deletionJobColumns+" FROM deletion_job WHERE id = $1",
+		id,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	return job, nil
+}
+
+func (d DatabaseRepository) FindAllPendingDeletionJobs(ctx context.Context) (_ []*models.DeletionJob, fnErr error) {
+	rows, err := d.db.QueryContext(
+		ctx,
+		"SELECT "+deletionJobColumns+" FROM deletion_job WHERE status = $1 ORDER BY created_at",
+		models.DeletionStatusPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fnErr = err
+		}
+	}(rows)
+
+	var jobs []*models.DeletionJob
+
+	for rows.Next() {
+		job, err := scanDeletionJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	if len(jobs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return jobs, nil
+}
+
+// insertOutboxMessage records the event in the transaction which changes the link.
+func insertOutboxMessage(ctx context.Context, transaction *sql.Tx, eventType string, shortURL *models.ShortURL) error {
+	event := models.NewWebhookEvent(eventType, shortURL)
+
+	snapshot, err := json.Marshal(event.ShortURL)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToSave, err)
+	}
+
+	_, err = transaction.ExecContext(
+		ctx,
+		"INSERT INTO outbox(event_id, event_type, short_url, occurred_at) VALUES($1, $2, $3, $4)",
+		event.ID,
+		event.Type,
+		string(snapshot),
+		event.OccurredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToSave, err)
+	}
+
+	return nil
+}
+
+/*
+insertChanges records changes of the links in the transaction which changes them. It is called after all other
+statements of the transaction. The advisory lock is held until the transaction ends, so changes are committed
+in the order of their Seq and a reader never skips a change which is committed later with a smaller Seq.
+*/
+func insertChanges(ctx context.Context, transaction *sql.Tx, changeType string, shortURLs []*models.ShortURL) error {
+	if len(shortURLs) == 0 {
+		return nil
+	}
+
+	if _, err := transaction.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", changeFeedLockKey); err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToSave, err)
+	}
+
+	for _, shortURL := range shortURLs {
+		change := models.NewChange(changeType, shortURL)
+
+		snapshot, err := json.Marshal(change.ShortURL)
+		if err != nil {
+			return fmt.Errorf("%s: %w", messageFailedToSave, err)
+		}
+
+		_, err = transaction.ExecContext(
+			ctx,
+			"INSERT INTO change_feed(change_type, short_url, changed_at) VALUES($1, $2, $3)",
+			change.Type,
+			string(snapshot),
+			change.ChangedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", messageFailedToSave, err)
+		}
+	}
+
+	return nil
+}
+
+func (d DatabaseRepository) FindChanges(
+	ctx context.Context,
+	since int64,
+	limit int,
+) (_ []*models.Change, fnErr error) {
+	rows, err := d.db.QueryContext(
+		ctx,
+		"SELECT seq, change_type, short_url, changed_at FROM change_feed WHERE seq > $1 ORDER BY seq LIMIT NULLIF($2, 0)",
+		since,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fnErr = err
+		}
+	}(rows)
+
+	var changes []*models.Change
+
+	for rows.Next() {
+		change := models.NewChange("", models.NewShortURL(0, "", "", uuid.UUID{}))
+
+		var snapshot string
+
+		if err := rows.Scan(&change.Seq, &change.Type, &snapshot, &change.ChangedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+		}
+
+		if err := json.Unmarshal([]byte(snapshot), &change.ShortURL); err != nil {
+			return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+		}
+
+		changes = append(changes, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	if len(changes) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return changes, nil
+}
+
+/*
+PublishOutbox locks the oldest pending messages with FOR UPDATE SKIP LOCKED, so messages taken
+by another replica are skipped. The messages are deleted in the same transaction once publish succeeds,
+a failure rolls the transaction back and the messages are taken again later.
+*/
+func (d DatabaseRepository) PublishOutbox(
+	ctx context.Context,
+	limit int,
+	publish func(messages []*models.OutboxMessage) error,
+) (_ int, fnErr error) {
+	transaction, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	defer func(transaction *sql.Tx) {
+		err := transaction.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			fnErr = fmt.Errorf("%s: %w", messageFailedToDelete, err)
+		}
+	}(transaction)
+
+	messages, err := lockOutboxMessages(ctx, transaction, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", messageFailedToFind, err)
+	}
+
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	if err := publish(messages); err != nil {
+		return 0, err
+	}
+
+	ids := make([]int64, 0, len(messages))
+
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+
+	if _, err := transaction.ExecContext(ctx, "DELETE FROM outbox WHERE id = ANY($1)", ids); err != nil {
+		return 0, fmt.Errorf("%s: %w", messageFailedToDelete, err)
+	}
+
+	if err = transaction.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", messageFailedToDelete, err)
+	}
+
+	return len(messages), nil
+}
+
+func lockOutboxMessages(
+	ctx context.Context,
+	transaction *sql.Tx,
+	limit int,
+) (_ []*models.OutboxMessage, fnErr error) {
+	rows, err := transaction.QueryContext(
+		ctx,
+		`SELECT id, event_id, event_type, short_url, occurred_at FROM outbox ORDER BY id LIMIT $1 
+FOR UPDATE SKIP LOCKED`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fnErr = err
+		}
+	}(rows)
+
+	var messages []*models.OutboxMessage
+
+	for rows.Next() {
+		message := models.NewOutboxMessage(models.NewWebhookEvent("", models.NewShortURL(0, "", "", uuid.UUID{})))
+
+		var snapshot string
+
+		err := rows.Scan(&message.ID, &message.Event.ID, &message.Event.Type, &snapshot, &message.Event.OccurredAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(snapshot), &message.Event.ShortURL); err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (d DatabaseRepository) CreateDatabase() error {
+	query := `
+CREATE TABLE IF NOT EXISTS short_url (
+	id SERIAL,
+	uid VARCHAR(32) NOT NULL,
+	url TEXT NOT NULL,
+	user_id VARCHAR(36) NOT NULL,
+	is_deleted BOOL NOT NULL DEFAULT false,
+	CONSTRAINT short_url_pkey PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS short_url_uid_idx ON short_url (uid);
+
+ALTER TABLE short_url ADD COLUMN IF NOT EXISTS canonical_url TEXT;
+UPDATE short_url SET canonical_url = url WHERE canonical_url IS NULL;
+ALTER TABLE short_url ALTER COLUMN canonical_url SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS short_url_user_id_canonical_url_idx ON short_url (user_id, canonical_url);
+DROP INDEX IF EXISTS short_url_user_id_url_idx;
+
+ALTER TABLE short_url ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS rate_limit_bucket (
+	key TEXT NOT NULL,
+	tokens DOUBLE PRECISION,
+	updated_at TIMESTAMPTZ,
+	CONSTRAINT rate_limit_bucket_pkey PRIMARY KEY (key)
+);
+
+CREATE TABLE IF NOT EXISTS api_key (
+	id VARCHAR(36) NOT NULL,
+	user_id VARCHAR(36) NOT NULL,
+	name TEXT NOT NULL,
+	prefix VARCHAR(16) NOT NULL,
+	hash VARCHAR(64) NOT NULL,
+	scopes TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	revoked_at TIMESTAMPTZ,
+	CONSTRAINT api_key_pkey PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS api_key_hash_idx ON api_key (hash);
+CREATE INDEX IF NOT EXISTS api_key_user_id_idx ON api_key (user_id);
+
+CREATE TABLE IF NOT EXISTS app_user (
+	id VARCHAR(36) NOT NULL,
+	username VARCHAR(64) NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	CONSTRAINT app_user_pkey PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS app_user_username_idx ON app_user (username);
+
+ALTER TABLE short_url ADD COLUMN IF NOT EXISTS is_disabled BOOL NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS ban (
+	user_id VARCHAR(36) NOT NULL,
+	reason TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	CONSTRAINT ban_pkey PRIMARY KEY (user_id)
+);
+
+ALTER TABLE short_url ADD COLUMN IF NOT EXISTS clicks BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS webhook (
+	id VARCHAR(36) NOT NULL,
+	user_id VARCHAR(36) NOT NULL,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	event_types TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	deleted_at TIMESTAMPTZ,
+	CONSTRAINT webhook_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_user_id_idx ON webhook (user_id);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+	id VARCHAR(36) NOT NULL,
+	webhook_id VARCHAR(36) NOT NULL,
+	event_id VARCHAR(36) NOT NULL,
+	event_type VARCHAR(32) NOT NULL,
+	payload TEXT NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	last_status_code INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	CONSTRAINT webhook_delivery_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_webhook_id_created_at_idx ON webhook_delivery (webhook_id, created_at);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_pending_idx ON webhook_delivery (created_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS outbox (
+	id BIGSERIAL,
+	event_id VARCHAR(36) NOT NULL,
+	event_type VARCHAR(32) NOT NULL,
+	short_url TEXT NOT NULL,
+	occurred_at TIMESTAMPTZ NOT NULL,
+	CONSTRAINT outbox_pkey PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS deletion_job (
+	id VARCHAR(36) NOT NULL,
+	user_id VARCHAR(36) NOT NULL,
+	items TEXT NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	CONSTRAINT deletion_job_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS deletion_job_status_created_at_idx ON deletion_job (status, created_at);
+
+CREATE TABLE IF NOT EXISTS change_feed (
+	seq BIGSERIAL,
+	change_type VARCHAR(16) NOT NULL,
+	short_url TEXT NOT NULL,
+	changed_at TIMESTAMPTZ NOT NULL,
+	CONSTRAINT change_feed_pkey PRIMARY KEY (seq)
+);
+
+CREATE TABLE IF NOT EXISTS transfer_claim (
+	token_id VARCHAR(36) NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	CONSTRAINT transfer_claim_pkey PRIMARY KEY (token_id)
+);
+`
+
+	if _, err := d.db.Exec(query); err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToCreateDatabase, err)
+	}
+
+	return nil
+}
+
+// scanShortURL scans a row selected with shortURLColumns.
+func scanShortURL(row rowScanner) (*models.ShortURL, error) {
+	shortURL := models.NewShortURL(0, "", "", uuid.UUID{})
+
+	var expiresAt sql.NullTime
+
+	err := row.Scan(
+		&shortURL.ID,
+		&shortURL.UID,
+		&shortURL.URL,
+		&shortURL.CanonicalURL,
+		&shortURL.UserID,
+		&shortURL.IsDeleted,
+		&shortURL.IsDisabled,
+		&expiresAt,
+		&shortURL.Clicks,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	shortURL.ExpiresAt = expiresAt.Time
+
+	return shortURL, nil
+}
+
+// escapeLike escapes wildcards of LIKE patterns.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// scanAPIKey scans a row selected with apiKeyColumns.
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	apiKey := models.NewAPIKey(uuid.UUID{}, "", "", "", nil)
+
+	var (
+		scopes    string
+		revokedAt sql.NullTime
+	)
+
+	err := row.Scan(
+		&apiKey.ID,
+		&apiKey.UserID,
+		&apiKey.Name,
+		&apiKey.Prefix,
+		&apiKey.Hash,
+		&scopes,
+		&apiKey.CreatedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if scopes != "" {
+		apiKey.Scopes = strings.Split(scopes, ",")
+	}
+
+	apiKey.RevokedAt = revokedAt.Time
+
+	return apiKey, nil
+}
+
+// scanWebhook scans a row selected with webhookColumns.
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	webhook := models.NewWebhook(uuid.UUID{}, "", "", nil)
+
+	var (
+		eventTypes string
+		deletedAt  sql.NullTime
+	)
+
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.UserID,
+		&webhook.URL,
+		&webhook.Secret,
+		&eventTypes,
+		&webhook.CreatedAt,
+		&deletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if eventTypes != "" {
+		webhook.EventTypes = strings.Split(eventTypes, ",")
+	}
+
+	webhook.DeletedAt = deletedAt.Time
+
+	return webhook, nil
+}
+
+// scanWebhookDelivery scans a row selected with webhookDeliveryColumns.
+func scanWebhookDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	delivery := models.NewWebhookDelivery(uuid.UUID{}, uuid.UUID{}, "", "")
+
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.LastStatusCode,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&delivery.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// scanDeletionJob scans a row selected with deletionJobColumns.
+func scanDeletionJob(row rowScanner) (*models.DeletionJob, error) {
+	job := models.NewDeletionJob(uuid.UUID{}, nil)
+
+	var items string
+
+	if err := row.Scan(&job.ID, &job.UserID, &items, &job.CreatedAt, &job.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(items), &job.Items); err != nil {
+		return nil, err
+	}
+
+	return job, nil
 }
@@ -1,40 +1,120 @@
 package repositories
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/logger"
 	"github.com/tmitry/shorturl/internal/app/models"
 )
 
 const (
-	messageFileNotSpecified = "file not specified"
+	messageFileNotSpecified    = "file not specified"
+	messageFailedToLoadStorage = "failed to load file storage"
+
+	messageStorageIsNotWritable = "file storage is not writable"
+	messageFailedToFlushClicks  = "failed to flush clicks"
 
 	fileMode = 0o777
+
+	apiKeysFileSuffix = ".api_keys"
+	usersFileSuffix   = ".users"
+	bansFileSuffix    = ".bans"
+	clicksFileSuffix  = ".clicks"
+
+	webhooksFileSuffix          = ".webhooks"
+	webhookDeliveriesFileSuffix = ".webhook_deliveries"
+	outboxFileSuffix            = ".outbox"
+	changesFileSuffix           = ".changes"
+	deletionJobsFileSuffix      = ".deletion_jobs"
+	transferClaimsFileSuffix    = ".transfer_claims"
+
+	clickFlushInterval = time.Second
 )
 
 type FileRepository struct {
 	mu            sync.RWMutex
 	shortURLs     map[models.UID]*models.ShortURL
 	userShortURLs map[uuid.UUID][]*models.ShortURL
+	file          *os.File
 	encoder       *json.Encoder
+	apiKeys       map[string]*models.APIKey // by hash
+	apiKeyJournal *fileJournal
+	users         map[uuid.UUID]*models.User
+	usernames     map[string]uuid.UUID
+	userJournal   *fileJournal
+	bans          map[uuid.UUID]*models.Ban
+	banJournal    *fileJournal
+	clicks        int
+	clickJournal  *fileJournal
+
+	// Clicks are buffered and flushed every clickFlushInterval, so a redirect takes neither the write lock
+	// nor a journal write. Clicks of the last interval are flushed by Close.
+	clickMu          sync.Mutex
+	pendingClicks    map[models.UID]int
+	stopClickFlusher chan struct{}
+	clickFlusherDone chan struct{}
+
+	webhooks        map[uuid.UUID]*models.Webhook
+	webhookJournal  *fileJournal
+	deliveries      map[uuid.UUID]*models.WebhookDelivery
+	deliveryJournal *fileJournal
+	outbox          *memoryOutbox
+	outboxJournal   *fileJournal
+	changes         *memoryChangeFeed
+	changeJournal   *fileJournal
+	deletionJobs    map[uuid.UUID]*models.DeletionJob
+	jobJournal      *fileJournal
+	transferClaims  map[string]time.Time // expiration by token ID
+	claimJournal    *fileJournal
+	log             *logger.Logger
+}
+
+// banRecord is a record of the bans journal. A lifted ban is appended as a record with IsLifted set.
+type banRecord struct {
+	Ban      *models.Ban
+	IsLifted bool
+}
+
+/*
+outboxRecord is a record of the outbox journal. It either adds Message or marks messages up to PublishedUpTo
+as published. The file has no transactions, so a message is appended right after the change of the link
+under the same lock.
+*/
+type outboxRecord struct {
+	Message       *models.OutboxMessage
+	PublishedUpTo int64
+}
+
+// transferClaimRecord is a record of the transfer claims journal.
+type transferClaimRecord struct {
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+// clickRecord is a record of the clicks journal. It keeps the running number of clicks of the link.
+type clickRecord struct {
+	UID    models.UID
+	Clicks int
 }
 
-func NewFileRepository(fileStoragePath string) *FileRepository {
+func NewFileRepository(fileStoragePath string, log *logger.Logger) *FileRepository {
 	if fileStoragePath == "" {
 		log.Panic(messageFileNotSpecified)
 	}
 
 	fileWriter, err := os.OpenFile(fileStoragePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, fileMode)
 	if err != nil {
-		log.Panic(err)
+		log.Panic(messageFailedToLoadStorage, "path", fileStoragePath, logger.KeyError, err)
 	}
 
 	encoder := json.NewEncoder(fileWriter)
@@ -44,23 +124,55 @@ func NewFileRepository(fileStoragePath string) *FileRepository {
 		mu:            sync.RWMutex{},
 		shortURLs:     map[models.UID]*models.ShortURL{},
 		userShortURLs: map[uuid.UUID][]*models.ShortURL{},
+		file:          fileWriter,
 		encoder:       encoder,
+		apiKeys:       map[string]*models.APIKey{},
+		apiKeyJournal: nil,
+		users:         map[uuid.UUID]*models.User{},
+		usernames:     map[string]uuid.UUID{},
+		userJournal:   nil,
+		bans:          map[uuid.UUID]*models.Ban{},
+		banJournal:    nil,
+		clicks:        0,
+		clickJournal:  nil,
+
+		clickMu:          sync.Mutex{},
+		pendingClicks:    map[models.UID]int{},
+		stopClickFlusher: make(chan struct{}),
+		clickFlusherDone: make(chan struct{}),
+
+		webhooks:        map[uuid.UUID]*models.Webhook{},
+		webhookJournal:  nil,
+		deliveries:      map[uuid.UUID]*models.WebhookDelivery{},
+		deliveryJournal: nil,
+		outbox:          newMemoryOutbox(),
+		outboxJournal:   nil,
+		changes:         newMemoryChangeFeed(),
+		changeJournal:   nil,
+		deletionJobs:    map[uuid.UUID]*models.DeletionJob{},
+		jobJournal:      nil,
+		transferClaims:  map[string]time.Time{},
+		claimJournal:    nil,
+		log:             log,
 	}
 
 	fileReader, err := os.OpenFile(fileStoragePath, os.O_RDONLY|os.O_CREATE, fileMode)
 	if err != nil {
-		log.Panic(err)
+		log.Panic(messageFailedToLoadStorage, "path", fileStoragePath, logger.KeyError, err)
 	}
 
 	defer func(fileReader *os.File) {
 		err := fileReader.Close()
 		if err != nil {
-			log.Panic(err)
+			log.Panic(messageFailedToLoadStorage, "path", fileStoragePath, logger.KeyError, err)
 		}
 	}(fileReader)
 
 	decoder := json.NewDecoder(fileReader)
 
+	// A short URL is written again when it changes, so the last record of an UID wins.
+	var uids []models.UID
+
 	for {
 		shortURL := models.NewShortURL(0, "", "", uuid.UUID{})
 		if err := decoder.Decode(shortURL); err != nil {
@@ -68,13 +180,133 @@ func NewFileRepository(fileStoragePath string) *FileRepository {
 				break
 			}
 
-			log.Panic(err)
+			log.Panic(messageFailedToLoadStorage, "path", fileStoragePath, logger.KeyError, err)
+		}
+
+		// Records written before canonicalization was introduced have no canonical form.
+		if shortURL.CanonicalURL == "" {
+			shortURL.CanonicalURL = shortURL.URL
+		}
+
+		if _, ok := fileRepository.shortURLs[shortURL.UID]; !ok {
+			uids = append(uids, shortURL.UID)
 		}
 
 		fileRepository.shortURLs[shortURL.UID] = shortURL
+	}
+
+	for _, uid := range uids {
+		shortURL := fileRepository.shortURLs[uid]
 		fileRepository.userShortURLs[shortURL.UserID] = append(fileRepository.userShortURLs[shortURL.UserID], shortURL)
 	}
 
+	fileRepository.apiKeyJournal = newFileJournal(
+		fileStoragePath+apiKeysFileSuffix,
+		func(apiKey *models.APIKey) {
+			fileRepository.apiKeys[apiKey.Hash] = apiKey
+		},
+		log,
+	)
+
+	fileRepository.userJournal = newFileJournal(
+		fileStoragePath+usersFileSuffix,
+		func(user *models.User) {
+			fileRepository.users[user.ID] = user
+			fileRepository.usernames[user.Username] = user.ID
+		},
+		log,
+	)
+
+	fileRepository.banJournal = newFileJournal(
+		fileStoragePath+bansFileSuffix,
+		func(record *banRecord) {
+			if record.IsLifted {
+				delete(fileRepository.bans, record.Ban.UserID)
+
+				return
+			}
+
+			fileRepository.bans[record.Ban.UserID] = record.Ban
+		},
+		log,
+	)
+
+	fileRepository.clickJournal = newFileJournal(
+		fileStoragePath+clicksFileSuffix,
+		func(record *clickRecord) {
+			if shortURL, ok := fileRepository.shortURLs[record.UID]; ok {
+				shortURL.Clicks = record.Clicks
+			}
+		},
+		log,
+	)
+
+	for _, shortURL := range fileRepository.shortURLs {
+		fileRepository.clicks += shortURL.Clicks
+	}
+
+	fileRepository.webhookJournal = newFileJournal(
+		fileStoragePath+webhooksFileSuffix,
+		func(webhook *models.Webhook) {
+			fileRepository.webhooks[webhook.ID] = webhook
+		},
+		log,
+	)
+
+	// A delivery is appended after every attempt, so the last record of a delivery holds its state.
+	fileRepository.deliveryJournal = newFileJournal(
+		fileStoragePath+webhookDeliveriesFileSuffix,
+		func(delivery *models.WebhookDelivery) {
+			fileRepository.deliveries[delivery.ID] = delivery
+		},
+		log,
+	)
+
+	fileRepository.outboxJournal = newFileJournal(
+		fileStoragePath+outboxFileSuffix,
+		func(record *outboxRecord) {
+			if record.Message != nil {
+				fileRepository.outbox.add(record.Message)
+
+				return
+			}
+
+			fileRepository.outbox.removeUpTo(record.PublishedUpTo)
+
+			if record.PublishedUpTo > fileRepository.outbox.lastID {
+				fileRepository.outbox.lastID = record.PublishedUpTo
+			}
+		},
+		log,
+	)
+
+	fileRepository.changeJournal = newFileJournal(
+		fileStoragePath+changesFileSuffix,
+		func(change *models.Change) {
+			fileRepository.changes.add(change)
+		},
+		log,
+	)
+
+	// A job is appended every time its items are resolved, so the last record of a job holds its state.
+	fileRepository.jobJournal = newFileJournal(
+		fileStoragePath+deletionJobsFileSuffix,
+		func(job *models.DeletionJob) {
+			fileRepository.deletionJobs[job.ID] = job
+		},
+		log,
+	)
+
+	fileRepository.claimJournal = newFileJournal(
+		fileStoragePath+transferClaimsFileSuffix,
+		func(record *transferClaimRecord) {
+			fileRepository.transferClaims[record.TokenID] = record.ExpiresAt
+		},
+		log,
+	)
+
+	go fileRepository.runClickFlusher()
+
 	return fileRepository
 }
 
@@ -102,19 +334,18 @@ func (f *FileRepository) FindAllByUserID(_ context.Context, userID uuid.UUID) ([
 	return userShortURLs, nil
 }
 
-func (f *FileRepository) Save(_ context.Context, shortURL *models.ShortURL) error {
+func (f *FileRepository) Save(_ context.Context, shortURL *models.ShortURL, maxLinks int) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	userShortURLs, ok := f.userShortURLs[shortURL.UserID]
-	if ok {
-		for _, userShortURL := range userShortURLs {
-			if userShortURL.URL == shortURL.URL {
-				*shortURL = *userShortURL
+	if duplicate, ok := findDuplicate(f.userShortURLs, shortURL); ok {
+		*shortURL = *duplicate
 
-				return ErrURLDuplicate
-			}
-		}
+		return ErrURLDuplicate
+	}
+
+	if err := checkQuota(f.userShortURLs, map[uuid.UUID]int{shortURL.UserID: 1}, maxLinks); err != nil {
+		return err
 	}
 
 	err := f.encoder.Encode(shortURL)
@@ -125,39 +356,43 @@ func (f *FileRepository) Save(_ context.Context, shortURL *models.ShortURL) erro
 	f.shortURLs[shortURL.UID] = shortURL
 	f.userShortURLs[shortURL.UserID] = append(f.userShortURLs[shortURL.UserID], shortURL)
 
-	return nil
+	if err := f.addOutboxMessage(models.WebhookEventLinkCreated, shortURL); err != nil {
+		return err
+	}
+
+	return f.addChange(models.ChangeTypeCreate, shortURL)
 }
 
-func (f *FileRepository) BatchSave(_ context.Context, shortURLs []*models.ShortURL) error {
+func (f *FileRepository) BatchSave(_ context.Context, shortURLs []*models.ShortURL, maxLinks int) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	for _, shortURL := range shortURLs {
-		isFound := false
-
-		userShortURLs, ok := f.userShortURLs[shortURL.UserID]
-		if ok {
-			for _, userShortURL := range userShortURLs {
-				if userShortURL.URL == shortURL.URL {
-					*shortURL = *userShortURL
-					isFound = true
+	if err := checkQuota(f.userShortURLs, countNewLinks(f.userShortURLs, shortURLs), maxLinks); err != nil {
+		return err
+	}
 
-					break
-				}
-			}
-		}
+	for _, shortURL := range shortURLs {
+		if duplicate, ok := findDuplicate(f.userShortURLs, shortURL); ok {
+			*shortURL = *duplicate
 
-		if isFound {
 			continue
 		}
 
 		err := f.encoder.Encode(shortURL)
 		if err != nil {
-			log.Panic(err)
+			f.log.Panic(messageFailedToSave, logger.KeyError, err)
 		}
 
 		f.shortURLs[shortURL.UID] = shortURL
 		f.userShortURLs[shortURL.UserID] = append(f.userShortURLs[shortURL.UserID], shortURL)
+
+		if err := f.addOutboxMessage(models.WebhookEventLinkCreated, shortURL); err != nil {
+			return err
+		}
+
+		if err := f.addChange(models.ChangeTypeCreate, shortURL); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -168,14 +403,63 @@ func (f *FileRepository) BatchDelete(_ context.Context, shortURLs []*models.Shor
 		return ErrNothingToDelete
 	}
 
-	// not implemented
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	for _, shortURL := range shortURLs {
+		storedShortURL, ok := f.shortURLs[shortURL.UID]
+		if !ok || storedShortURL.IsDeleted {
+			continue
+		}
+
+		deletedShortURL := *storedShortURL
+		deletedShortURL.IsDeleted = true
+
+		if err := f.encoder.Encode(&deletedShortURL); err != nil {
+			return fmt.Errorf("%s: %w", messageFailedToDelete, err)
+		}
+
+		storedShortURL.IsDeleted = true
 		shortURL.IsDeleted = true
+
+		if err := f.addOutboxMessage(models.WebhookEventLinkDeleted, storedShortURL); err != nil {
+			return err
+		}
+
+		if err := f.addChange(models.ChangeTypeDelete, storedShortURL); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// addOutboxMessage records the event of the link change. It is called under the lock.
+func (f *FileRepository) addOutboxMessage(eventType string, shortURL *models.ShortURL) error {
+	message := f.outbox.newMessage(eventType, shortURL)
+
+	if err := f.outboxJournal.Append(&outboxRecord{Message: message, PublishedUpTo: 0}); err != nil {
+		return err
+	}
+
+	f.outbox.add(message)
+
+	return nil
+}
+
+// addChange records the change of the link in the change feed. It is called under the lock.
+func (f *FileRepository) addChange(changeType string, shortURL *models.ShortURL) error {
+	change := f.changes.newChange(changeType, shortURL)
+
+	if err := f.changeJournal.Append(change); err != nil {
+		return err
+	}
+
+	f.changes.add(change)
+
+	return nil
+}
+
 func (f *FileRepository) FindAllByUserIDAndUIDs(
 	_ context.Context,
 	userID uuid.UUID,
@@ -200,6 +484,553 @@ func (f *FileRepository) FindAllByUserIDAndUIDs(
 	return shortURLs, nil
 }
 
+func (f *FileRepository) CountActiveByUserID(_ context.Context, userID uuid.UUID) (int, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return countActive(f.userShortURLs[userID], time.Now()), nil
+}
+
+// ReassignUserID appends moved links with a single write, so either all of them are persisted or none.
+func (f *FileRepository) ReassignUserID(
+	_ context.Context,
+	fromUserID, toUserID uuid.UUID,
+	maxLinks int,
+) (int, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := checkReassignQuota(f.userShortURLs, fromUserID, toUserID, maxLinks); err != nil {
+		return 0, 0, err
+	}
+
+	return f.reassignUserID(fromUserID, toUserID)
+}
+
+// ClaimTransfer appends the claim before moving links, so a failed move can't make the token reusable.
+func (f *FileRepository) ClaimTransfer(
+	_ context.Context,
+	tokenID string,
+	expiresAt time.Time,
+	fromUserID, toUserID uuid.UUID,
+	maxLinks int,
+) (int, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := checkReassignQuota(f.userShortURLs, fromUserID, toUserID, maxLinks); err != nil {
+		return 0, 0, err
+	}
+
+	if err := claimTransfer(f.transferClaims, tokenID, expiresAt, time.Now()); err != nil {
+		return 0, 0, err
+	}
+
+	record := &transferClaimRecord{TokenID: tokenID, ExpiresAt: expiresAt}
+	if err := f.claimJournal.Append(record); err != nil {
+		delete(f.transferClaims, tokenID)
+
+		return 0, 0, err
+	}
+
+	return f.reassignUserID(fromUserID, toUserID)
+}
+
+func (f *FileRepository) reassignUserID(fromUserID, toUserID uuid.UUID) (int, int, error) {
+	moved, kept := splitReassignable(f.userShortURLs, fromUserID, toUserID)
+	if len(moved) == 0 {
+		return 0, len(kept), nil
+	}
+
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	for _, shortURL := range moved {
+		movedShortURL := *shortURL
+		movedShortURL.UserID = toUserID
+
+		if err := encoder.Encode(&movedShortURL); err != nil {
+			return 0, 0, fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+		}
+	}
+
+	if _, err := f.file.Write(buf.Bytes()); err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
+
+	applyReassign(f.userShortURLs, fromUserID, toUserID, moved, kept)
+
+	for _, shortURL := range moved {
+		if err := f.addChange(models.ChangeTypeUpdate, shortURL); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return len(moved), len(kept), nil
+}
+
+func (f *FileRepository) SearchShortURLs(
+	_ context.Context,
+	filter *models.ShortURLFilter,
+) ([]*models.ShortURL, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return searchShortURLs(f.shortURLs, filter)
+}
+
+// SetDisabled appends the changed link, so it replaces the previous record on start.
+func (f *FileRepository) SetDisabled(_ context.Context, uid models.UID, isDisabled bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	shortURL, ok := f.shortURLs[uid]
+	if !ok {
+		return ErrNotFound
+	}
+
+	changedShortURL := *shortURL
+	changedShortURL.IsDisabled = isDisabled
+
+	if err := f.encoder.Encode(&changedShortURL); err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToUpdate, err)
+	}
+
+	shortURL.IsDisabled = isDisabled
+
+	return f.addChange(models.ChangeTypeUpdate, shortURL)
+}
+
+func (f *FileRepository) SaveBan(_ context.Context, ban *models.Ban) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.banJournal.Append(&banRecord{Ban: ban, IsLifted: false}); err != nil {
+		return err
+	}
+
+	f.bans[ban.UserID] = ban
+
+	return nil
+}
+
+func (f *FileRepository) DeleteBan(_ context.Context, userID uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ban, ok := f.bans[userID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if err := f.banJournal.Append(&banRecord{Ban: ban, IsLifted: true}); err != nil {
+		return err
+	}
+
+	delete(f.bans, userID)
+
+	return nil
+}
+
+func (f *FileRepository) FindBanByUserID(_ context.Context, userID uuid.UUID) (*models.Ban, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	ban, ok := f.bans[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return ban, nil
+}
+
+func (f *FileRepository) CountAll(_ context.Context) (*models.GlobalCounts, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return countAll(f.shortURLs, f.userShortURLs, f.bans, f.clicks), nil
+}
+
+// RegisterClick buffers the click, it is counted and written to the journal by the next flush.
+func (f *FileRepository) RegisterClick(_ context.Context, uid models.UID) error {
+	f.mu.RLock()
+	_, ok := f.shortURLs[uid]
+	f.mu.RUnlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	f.clickMu.Lock()
+	f.pendingClicks[uid]++
+	f.clickMu.Unlock()
+
+	return nil
+}
+
+func (f *FileRepository) runClickFlusher() {
+	defer close(f.clickFlusherDone)
+
+	ticker := time.NewTicker(clickFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.stopClickFlusher:
+			return
+		case <-ticker.C:
+			f.mu.Lock()
+			err := f.flushClicks()
+			f.mu.Unlock()
+
+			if err != nil {
+				f.log.Error(messageFailedToFlushClicks, logger.KeyError, err)
+			}
+		}
+	}
+}
+
+// flushClicks appends a record per clicked link to the journal. It must be called with the write lock held.
+func (f *FileRepository) flushClicks() error {
+	f.clickMu.Lock()
+	pending := f.pendingClicks
+	f.pendingClicks = map[models.UID]int{}
+	f.clickMu.Unlock()
+
+	for uid, clicks := range pending {
+		shortURL, ok := f.shortURLs[uid]
+		if !ok {
+			continue
+		}
+
+		if err := f.clickJournal.Append(&clickRecord{UID: uid, Clicks: shortURL.Clicks + clicks}); err != nil {
+			return err
+		}
+
+		shortURL.Clicks += clicks
+		f.clicks += clicks
+	}
+
+	return nil
+}
+
 func (f *FileRepository) Ping(_ context.Context) error {
 	return nil
 }
+
+/*
+CheckWritable reports an error if the storage file is closed or a new file can not be created next to it,
+e.g. the disk is full or the directory has become read-only.
+*/
+func (f *FileRepository) CheckWritable(_ context.Context) error {
+	if _, err := f.file.Stat(); err != nil {
+		return fmt.Errorf("%s: %w", messageStorageIsNotWritable, err)
+	}
+
+	probe, err := os.CreateTemp(filepath.Dir(f.file.Name()), ".writable-*")
+	if err != nil {
+		return fmt.Errorf("%s: %w", messageStorageIsNotWritable, err)
+	}
+
+	closeErr := probe.Close()
+
+	if err := os.Remove(probe.Name()); err != nil {
+		return fmt.Errorf("%s: %w", messageStorageIsNotWritable, err)
+	}
+
+	if closeErr != nil {
+		return fmt.Errorf("%s: %w", messageStorageIsNotWritable, closeErr)
+	}
+
+	return nil
+}
+
+/*
+Close flushes the buffered clicks and closes the storage file and the journals.
+Every file is closed even if closing another one fails.
+*/
+func (f *FileRepository) Close() error {
+	close(f.stopClickFlusher)
+	<-f.clickFlusherDone
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	closeErr := f.flushClicks()
+
+	if err := f.file.Close(); err != nil && closeErr == nil {
+		closeErr = fmt.Errorf("%s: %w", messageFailedToClose, err)
+	}
+
+	journals := []*fileJournal{
+		f.apiKeyJournal,
+		f.userJournal,
+		f.banJournal,
+		f.clickJournal,
+		f.webhookJournal,
+		f.deliveryJournal,
+		f.outboxJournal,
+		f.changeJournal,
+		f.jobJournal,
+		f.claimJournal,
+	}
+
+	for _, journal := range journals {
+		if err := journal.Close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+
+	return closeErr
+}
+
+func (f *FileRepository) SaveAPIKey(_ context.Context, apiKey *models.APIKey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.apiKeyJournal.Append(apiKey); err != nil {
+		return err
+	}
+
+	f.apiKeys[apiKey.Hash] = copyAPIKey(apiKey)
+
+	return nil
+}
+
+func (f *FileRepository) FindAPIKeyByHash(_ context.Context, hash string) (*models.APIKey, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	apiKey, ok := f.apiKeys[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return copyAPIKey(apiKey), nil
+}
+
+func (f *FileRepository) FindAllAPIKeysByUserID(_ context.Context, userID uuid.UUID) ([]*models.APIKey, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return findAllAPIKeysByUserID(f.apiKeys, userID)
+}
+
+func (f *FileRepository) RevokeAPIKey(_ context.Context, userID, id uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	apiKey, err := findAPIKeyByID(f.apiKeys, userID, id)
+	if err != nil {
+		return err
+	}
+
+	if apiKey.IsRevoked() {
+		return nil
+	}
+
+	revokedAPIKey := copyAPIKey(apiKey)
+	revokedAPIKey.RevokedAt = time.Now()
+
+	if err := f.apiKeyJournal.Append(revokedAPIKey); err != nil {
+		return err
+	}
+
+	f.apiKeys[apiKey.Hash] = revokedAPIKey
+
+	return nil
+}
+
+func (f *FileRepository) SaveUser(_ context.Context, user *models.User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.usernames[user.Username]; ok {
+		return ErrUsernameTaken
+	}
+
+	if err := f.userJournal.Append(user); err != nil {
+		return err
+	}
+
+	f.users[user.ID] = user
+	f.usernames[user.Username] = user.ID
+
+	return nil
+}
+
+func (f *FileRepository) FindUserByID(_ context.Context, id uuid.UUID) (*models.User, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	user, ok := f.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return user, nil
+}
+
+func (f *FileRepository) FindUserByUsername(_ context.Context, username string) (*models.User, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	id, ok := f.usernames[username]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return f.users[id], nil
+}
+
+func (f *FileRepository) SaveWebhook(_ context.Context, webhook *models.Webhook) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.webhookJournal.Append(webhook); err != nil {
+		return err
+	}
+
+	f.webhooks[webhook.ID] = webhook
+
+	return nil
+}
+
+func (f *FileRepository) FindAllWebhooksByUserID(_ context.Context, userID uuid.UUID) ([]*models.Webhook, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return findAllWebhooksByUserID(f.webhooks, userID)
+}
+
+func (f *FileRepository) FindWebhookByID(_ context.Context, id uuid.UUID) (*models.Webhook, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return findWebhookByID(f.webhooks, id)
+}
+
+func (f *FileRepository) DeleteWebhook(_ context.Context, userID, id uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	webhook, ok := f.webhooks[id]
+	if !ok || webhook.UserID != userID || webhook.IsDeleted() {
+		return ErrNotFound
+	}
+
+	deletedWebhook := *webhook
+	deletedWebhook.DeletedAt = time.Now()
+
+	if err := f.webhookJournal.Append(&deletedWebhook); err != nil {
+		return err
+	}
+
+	// Webhooks are read by the dispatcher without the lock, so the deleted one replaces the stored one.
+	f.webhooks[id] = &deletedWebhook
+
+	return nil
+}
+
+func (f *FileRepository) SaveWebhookDelivery(_ context.Context, delivery *models.WebhookDelivery) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.deliveryJournal.Append(delivery); err != nil {
+		return err
+	}
+
+	// The caller keeps updating the delivery between attempts, so a copy is stored.
+	savedDelivery := *delivery
+	f.deliveries[delivery.ID] = &savedDelivery
+
+	return nil
+}
+
+func (f *FileRepository) SaveDeletionJob(_ context.Context, job *models.DeletionJob) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.jobJournal.Append(job); err != nil {
+		return err
+	}
+
+	f.deletionJobs[job.ID] = job.Copy()
+
+	return nil
+}
+
+func (f *FileRepository) FindDeletionJobByID(_ context.Context, id uuid.UUID) (*models.DeletionJob, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return findDeletionJobByID(f.deletionJobs, id)
+}
+
+func (f *FileRepository) FindAllPendingDeletionJobs(_ context.Context) ([]*models.DeletionJob, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return findAllPendingDeletionJobs(f.deletionJobs)
+}
+
+func (f *FileRepository) FindAllWebhookDeliveriesByWebhookID(
+	_ context.Context,
+	webhookID uuid.UUID,
+	limit int,
+) ([]*models.WebhookDelivery, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return findAllWebhookDeliveriesByWebhookID(f.deliveries, webhookID, limit)
+}
+
+func (f *FileRepository) FindAllPendingWebhookDeliveries(_ context.Context) ([]*models.WebhookDelivery, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return findAllPendingWebhookDeliveries(f.deliveries)
+}
+
+func (f *FileRepository) FindChanges(_ context.Context, since int64, limit int) ([]*models.Change, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.changes.find(since, limit)
+}
+
+func (f *FileRepository) PublishOutbox(
+	_ context.Context,
+	limit int,
+	publish func(messages []*models.OutboxMessage) error,
+) (int, error) {
+	f.outbox.publishMu.Lock()
+	defer f.outbox.publishMu.Unlock()
+
+	f.mu.RLock()
+	messages := f.outbox.head(limit)
+	f.mu.RUnlock()
+
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	if err := publish(messages); err != nil {
+		return 0, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	lastID := messages[len(messages)-1].ID
+
+	if err := f.outboxJournal.Append(&outboxRecord{Message: nil, PublishedUpTo: lastID}); err != nil {
+		return 0, err
+	}
+
+	f.outbox.removeUpTo(lastID)
+
+	return len(messages), nil
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tmitry/shorturl/internal/app/logger"
+)
+
+/*
+fileJournal is an append-only file of JSON records stored next to the main storage file.
+Every change of an entity is appended as the full record, so on start records are replayed
+in order and the last record of an entity wins.
+*/
+type fileJournal struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func newFileJournal[T any](path string, replay func(record *T), log *logger.Logger) *fileJournal {
+	fileReader, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, fileMode)
+	if err != nil {
+		log.Panic(messageFailedToLoadStorage, "path", path, logger.KeyError, err)
+	}
+
+	defer func(fileReader *os.File) {
+		err := fileReader.Close()
+		if err != nil {
+			log.Panic(messageFailedToLoadStorage, "path", path, logger.KeyError, err)
+		}
+	}(fileReader)
+
+	decoder := json.NewDecoder(fileReader)
+
+	for {
+		record := new(T)
+		if err := decoder.Decode(record); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			log.Panic(messageFailedToLoadStorage, "path", path, logger.KeyError, err)
+		}
+
+		replay(record)
+	}
+
+	fileWriter, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, fileMode)
+	if err != nil {
+		log.Panic(messageFailedToLoadStorage, "path", path, logger.KeyError, err)
+	}
+
+	encoder := json.NewEncoder(fileWriter)
+	encoder.SetEscapeHTML(false)
+
+	return &fileJournal{
+		file:    fileWriter,
+		encoder: encoder,
+	}
+}
+
+func (j *fileJournal) Append(record any) error {
+	if err := j.encoder.Encode(record); err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToSave, err)
+	}
+
+	return nil
+}
+
+func (j *fileJournal) Close() error {
+	if err := j.file.Close(); err != nil {
+		return fmt.Errorf("%s: %w", messageFailedToClose, err)
+	}
+
+	return nil
+}
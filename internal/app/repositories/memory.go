@@ -2,7 +2,9 @@ package repositories
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/tmitry/shorturl/internal/app/models"
@@ -12,6 +14,18 @@ type MemoryRepository struct {
 	mu            sync.RWMutex
 	shortURLs     map[models.UID]*models.ShortURL
 	userShortURLs map[uuid.UUID][]*models.ShortURL
+	apiKeys       map[string]*models.APIKey // by hash
+	users         map[uuid.UUID]*models.User
+	usernames     map[string]uuid.UUID
+	bans          map[uuid.UUID]*models.Ban
+	clicks        int
+	webhooks      map[uuid.UUID]*models.Webhook
+	deliveries    map[uuid.UUID]*models.WebhookDelivery
+	deletionJobs  map[uuid.UUID]*models.DeletionJob
+	outbox        *memoryOutbox
+	changes       *memoryChangeFeed
+
+	transferClaims map[string]time.Time // expiration by token ID
 }
 
 func NewMemoryRepository() *MemoryRepository {
@@ -19,6 +33,18 @@ func NewMemoryRepository() *MemoryRepository {
 		mu:            sync.RWMutex{},
 		shortURLs:     map[models.UID]*models.ShortURL{},
 		userShortURLs: map[uuid.UUID][]*models.ShortURL{},
+		apiKeys:       map[string]*models.APIKey{},
+		users:         map[uuid.UUID]*models.User{},
+		usernames:     map[string]uuid.UUID{},
+		bans:          map[uuid.UUID]*models.Ban{},
+		clicks:        0,
+		webhooks:      map[uuid.UUID]*models.Webhook{},
+		deliveries:    map[uuid.UUID]*models.WebhookDelivery{},
+		deletionJobs:  map[uuid.UUID]*models.DeletionJob{},
+		outbox:        newMemoryOutbox(),
+		changes:       newMemoryChangeFeed(),
+
+		transferClaims: map[string]time.Time{},
 	}
 }
 
@@ -46,52 +72,47 @@ func (m *MemoryRepository) FindAllByUserID(_ context.Context, userID uuid.UUID)
 	return userShortURLs, nil
 }
 
-func (m *MemoryRepository) Save(_ context.Context, shortURL *models.ShortURL) error {
+func (m *MemoryRepository) Save(_ context.Context, shortURL *models.ShortURL, maxLinks int) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	userShortURLs, ok := m.userShortURLs[shortURL.UserID]
-	if ok {
-		for _, userShortURL := range userShortURLs {
-			if userShortURL.URL == shortURL.URL {
-				*shortURL = *userShortURL
+	if duplicate, ok := findDuplicate(m.userShortURLs, shortURL); ok {
+		*shortURL = *duplicate
 
-				return ErrURLDuplicate
-			}
-		}
+		return ErrURLDuplicate
+	}
+
+	if err := checkQuota(m.userShortURLs, map[uuid.UUID]int{shortURL.UserID: 1}, maxLinks); err != nil {
+		return err
 	}
 
 	m.shortURLs[shortURL.UID] = shortURL
 	m.userShortURLs[shortURL.UserID] = append(m.userShortURLs[shortURL.UserID], shortURL)
+	m.outbox.add(m.outbox.newMessage(models.WebhookEventLinkCreated, shortURL))
+	m.changes.add(m.changes.newChange(models.ChangeTypeCreate, shortURL))
 
 	return nil
 }
 
-func (m *MemoryRepository) BatchSave(_ context.Context, shortURLs []*models.ShortURL) error {
+func (m *MemoryRepository) BatchSave(_ context.Context, shortURLs []*models.ShortURL, maxLinks int) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for _, shortURL := range shortURLs {
-		isFound := false
-
-		userShortURLs, ok := m.userShortURLs[shortURL.UserID]
-		if ok {
-			for _, userShortURL := range userShortURLs {
-				if userShortURL.URL == shortURL.URL {
-					*shortURL = *userShortURL
-					isFound = true
+	if err := checkQuota(m.userShortURLs, countNewLinks(m.userShortURLs, shortURLs), maxLinks); err != nil {
+		return err
+	}
 
-					break
-				}
-			}
-		}
+	for _, shortURL := range shortURLs {
+		if duplicate, ok := findDuplicate(m.userShortURLs, shortURL); ok {
+			*shortURL = *duplicate
 
-		if isFound {
 			continue
 		}
 
 		m.shortURLs[shortURL.UID] = shortURL
 		m.userShortURLs[shortURL.UserID] = append(m.userShortURLs[shortURL.UserID], shortURL)
+		m.outbox.add(m.outbox.newMessage(models.WebhookEventLinkCreated, shortURL))
+		m.changes.add(m.changes.newChange(models.ChangeTypeCreate, shortURL))
 	}
 
 	return nil
@@ -102,8 +123,19 @@ func (m *MemoryRepository) BatchDelete(_ context.Context, shortURLs []*models.Sh
 		return ErrNothingToDelete
 	}
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	for _, shortURL := range shortURLs {
+		storedShortURL, ok := m.shortURLs[shortURL.UID]
+		if !ok || storedShortURL.IsDeleted {
+			continue
+		}
+
+		storedShortURL.IsDeleted = true
 		shortURL.IsDeleted = true
+		m.outbox.add(m.outbox.newMessage(models.WebhookEventLinkDeleted, storedShortURL))
+		m.changes.add(m.changes.newChange(models.ChangeTypeDelete, storedShortURL))
 	}
 
 	return nil
@@ -133,6 +165,738 @@ func (m *MemoryRepository) FindAllByUserIDAndUIDs(
 	return shortURLs, nil
 }
 
+func (m *MemoryRepository) CountActiveByUserID(_ context.Context, userID uuid.UUID) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return countActive(m.userShortURLs[userID], time.Now()), nil
+}
+
+func (m *MemoryRepository) ReassignUserID(
+	_ context.Context,
+	fromUserID, toUserID uuid.UUID,
+	maxLinks int,
+) (int, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := checkReassignQuota(m.userShortURLs, fromUserID, toUserID, maxLinks); err != nil {
+		return 0, 0, err
+	}
+
+	moved, conflicts := m.reassignUserID(fromUserID, toUserID)
+
+	return moved, conflicts, nil
+}
+
+func (m *MemoryRepository) ClaimTransfer(
+	_ context.Context,
+	tokenID string,
+	expiresAt time.Time,
+	fromUserID, toUserID uuid.UUID,
+	maxLinks int,
+) (int, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := checkReassignQuota(m.userShortURLs, fromUserID, toUserID, maxLinks); err != nil {
+		return 0, 0, err
+	}
+
+	if err := claimTransfer(m.transferClaims, tokenID, expiresAt, time.Now()); err != nil {
+		return 0, 0, err
+	}
+
+	moved, conflicts := m.reassignUserID(fromUserID, toUserID)
+
+	return moved, conflicts, nil
+}
+
+func (m *MemoryRepository) reassignUserID(fromUserID, toUserID uuid.UUID) (int, int) {
+	moved, kept := splitReassignable(m.userShortURLs, fromUserID, toUserID)
+
+	applyReassign(m.userShortURLs, fromUserID, toUserID, moved, kept)
+
+	for _, shortURL := range moved {
+		m.changes.add(m.changes.newChange(models.ChangeTypeUpdate, shortURL))
+	}
+
+	return len(moved), len(kept)
+}
+
+func (m *MemoryRepository) SearchShortURLs(
+	_ context.Context,
+	filter *models.ShortURLFilter,
+) ([]*models.ShortURL, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return searchShortURLs(m.shortURLs, filter)
+}
+
+func (m *MemoryRepository) SetDisabled(_ context.Context, uid models.UID, isDisabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	shortURL, ok := m.shortURLs[uid]
+	if !ok {
+		return ErrNotFound
+	}
+
+	shortURL.IsDisabled = isDisabled
+	m.changes.add(m.changes.newChange(models.ChangeTypeUpdate, shortURL))
+
+	return nil
+}
+
+func (m *MemoryRepository) SaveBan(_ context.Context, ban *models.Ban) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.bans[ban.UserID] = ban
+
+	return nil
+}
+
+func (m *MemoryRepository) DeleteBan(_ context.Context, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.bans[userID]; !ok {
+		return ErrNotFound
+	}
+
+	delete(m.bans, userID)
+
+	return nil
+}
+
+func (m *MemoryRepository) FindBanByUserID(_ context.Context, userID uuid.UUID) (*models.Ban, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ban, ok := m.bans[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return ban, nil
+}
+
+func (m *MemoryRepository) CountAll(_ context.Context) (*models.GlobalCounts, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return countAll(m.shortURLs, m.userShortURLs, m.bans, m.clicks), nil
+}
+
+func (m *MemoryRepository) RegisterClick(_ context.Context, uid models.UID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	shortURL, ok := m.shortURLs[uid]
+	if !ok {
+		return ErrNotFound
+	}
+
+	shortURL.Clicks++
+	m.clicks++
+
+	return nil
+}
+
 func (m *MemoryRepository) Ping(_ context.Context) error {
 	return nil
 }
+
+func (m *MemoryRepository) SaveAPIKey(_ context.Context, apiKey *models.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.apiKeys[apiKey.Hash] = copyAPIKey(apiKey)
+
+	return nil
+}
+
+func (m *MemoryRepository) FindAPIKeyByHash(_ context.Context, hash string) (*models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	apiKey, ok := m.apiKeys[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return copyAPIKey(apiKey), nil
+}
+
+func (m *MemoryRepository) FindAllAPIKeysByUserID(_ context.Context, userID uuid.UUID) ([]*models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findAllAPIKeysByUserID(m.apiKeys, userID)
+}
+
+func (m *MemoryRepository) RevokeAPIKey(_ context.Context, userID, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	apiKey, err := findAPIKeyByID(m.apiKeys, userID, id)
+	if err != nil {
+		return err
+	}
+
+	if !apiKey.IsRevoked() {
+		revokedAPIKey := copyAPIKey(apiKey)
+		revokedAPIKey.RevokedAt = time.Now()
+		m.apiKeys[apiKey.Hash] = revokedAPIKey
+	}
+
+	return nil
+}
+
+/*
+copyAPIKey copies the key, so keys are never shared with callers: stored keys are replaced instead of modified,
+and keys returned to a request can't be changed by a concurrent revocation.
+*/
+func copyAPIKey(apiKey *models.APIKey) *models.APIKey {
+	apiKeyCopy := *apiKey
+	apiKeyCopy.Scopes = append([]string(nil), apiKey.Scopes...)
+
+	return &apiKeyCopy
+}
+
+func findAPIKeyByID(apiKeys map[string]*models.APIKey, userID, id uuid.UUID) (*models.APIKey, error) {
+	for _, apiKey := range apiKeys {
+		if apiKey.ID == id && apiKey.UserID == userID {
+			return apiKey, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func findAllAPIKeysByUserID(apiKeys map[string]*models.APIKey, userID uuid.UUID) ([]*models.APIKey, error) {
+	var userAPIKeys []*models.APIKey
+
+	for _, apiKey := range apiKeys {
+		if apiKey.UserID == userID {
+			userAPIKeys = append(userAPIKeys, copyAPIKey(apiKey))
+		}
+	}
+
+	if len(userAPIKeys) == 0 {
+		return nil, ErrNotFound
+	}
+
+	sort.Slice(userAPIKeys, func(i, j int) bool {
+		return userAPIKeys[i].CreatedAt.Before(userAPIKeys[j].CreatedAt)
+	})
+
+	return userAPIKeys, nil
+}
+
+func (m *MemoryRepository) SaveUser(_ context.Context, user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.usernames[user.Username]; ok {
+		return ErrUsernameTaken
+	}
+
+	m.users[user.ID] = user
+	m.usernames[user.Username] = user.ID
+
+	return nil
+}
+
+func (m *MemoryRepository) FindUserByID(_ context.Context, id uuid.UUID) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return user, nil
+}
+
+func (m *MemoryRepository) FindUserByUsername(_ context.Context, username string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.usernames[username]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return m.users[id], nil
+}
+
+func (m *MemoryRepository) SaveWebhook(_ context.Context, webhook *models.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.webhooks[webhook.ID] = webhook
+
+	return nil
+}
+
+func (m *MemoryRepository) FindAllWebhooksByUserID(_ context.Context, userID uuid.UUID) ([]*models.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findAllWebhooksByUserID(m.webhooks, userID)
+}
+
+func (m *MemoryRepository) FindWebhookByID(_ context.Context, id uuid.UUID) (*models.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findWebhookByID(m.webhooks, id)
+}
+
+func (m *MemoryRepository) DeleteWebhook(_ context.Context, userID, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok || webhook.UserID != userID || webhook.IsDeleted() {
+		return ErrNotFound
+	}
+
+	// Webhooks are read by the dispatcher without the lock, so the deleted one replaces the stored one.
+	deletedWebhook := *webhook
+	deletedWebhook.DeletedAt = time.Now()
+	m.webhooks[id] = &deletedWebhook
+
+	return nil
+}
+
+func (m *MemoryRepository) SaveWebhookDelivery(_ context.Context, delivery *models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The caller keeps updating the delivery between attempts, so a copy is stored.
+	savedDelivery := *delivery
+	m.deliveries[delivery.ID] = &savedDelivery
+
+	return nil
+}
+
+func (m *MemoryRepository) FindAllWebhookDeliveriesByWebhookID(
+	_ context.Context,
+	webhookID uuid.UUID,
+	limit int,
+) ([]*models.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findAllWebhookDeliveriesByWebhookID(m.deliveries, webhookID, limit)
+}
+
+func (m *MemoryRepository) FindAllPendingWebhookDeliveries(_ context.Context) ([]*models.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findAllPendingWebhookDeliveries(m.deliveries)
+}
+
+func (m *MemoryRepository) SaveDeletionJob(_ context.Context, job *models.DeletionJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The deletion buffer keeps resolving items of the job, so a copy is stored.
+	m.deletionJobs[job.ID] = job.Copy()
+
+	return nil
+}
+
+func (m *MemoryRepository) FindDeletionJobByID(_ context.Context, id uuid.UUID) (*models.DeletionJob, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findDeletionJobByID(m.deletionJobs, id)
+}
+
+func (m *MemoryRepository) FindAllPendingDeletionJobs(_ context.Context) ([]*models.DeletionJob, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findAllPendingDeletionJobs(m.deletionJobs)
+}
+
+func (m *MemoryRepository) PublishOutbox(
+	_ context.Context,
+	limit int,
+	publish func(messages []*models.OutboxMessage) error,
+) (int, error) {
+	m.outbox.publishMu.Lock()
+	defer m.outbox.publishMu.Unlock()
+
+	m.mu.RLock()
+	messages := m.outbox.head(limit)
+	m.mu.RUnlock()
+
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	if err := publish(messages); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	m.outbox.removeUpTo(messages[len(messages)-1].ID)
+	m.mu.Unlock()
+
+	return len(messages), nil
+}
+
+func (m *MemoryRepository) FindChanges(_ context.Context, since int64, limit int) ([]*models.Change, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.changes.find(since, limit)
+}
+
+func findAllWebhooksByUserID(webhooks map[uuid.UUID]*models.Webhook, userID uuid.UUID) ([]*models.Webhook, error) {
+	var userWebhooks []*models.Webhook
+
+	for _, webhook := range webhooks {
+		if webhook.UserID == userID && !webhook.IsDeleted() {
+			userWebhooks = append(userWebhooks, webhook)
+		}
+	}
+
+	if len(userWebhooks) == 0 {
+		return nil, ErrNotFound
+	}
+
+	sort.Slice(userWebhooks, func(i, j int) bool {
+		return userWebhooks[i].CreatedAt.Before(userWebhooks[j].CreatedAt)
+	})
+
+	return userWebhooks, nil
+}
+
+func findWebhookByID(webhooks map[uuid.UUID]*models.Webhook, id uuid.UUID) (*models.Webhook, error) {
+	webhook, ok := webhooks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return webhook, nil
+}
+
+func findAllPendingWebhookDeliveries(
+	deliveries map[uuid.UUID]*models.WebhookDelivery,
+) ([]*models.WebhookDelivery, error) {
+	var pendingDeliveries []*models.WebhookDelivery
+
+	for _, delivery := range deliveries {
+		if delivery.Status == models.WebhookDeliveryStatusPending {
+			// The dispatcher keeps updating the delivery between attempts, so a copy is returned.
+			pendingDelivery := *delivery
+			pendingDeliveries = append(pendingDeliveries, &pendingDelivery)
+		}
+	}
+
+	if len(pendingDeliveries) == 0 {
+		return nil, ErrNotFound
+	}
+
+	sort.Slice(pendingDeliveries, func(i, j int) bool {
+		return pendingDeliveries[i].CreatedAt.Before(pendingDeliveries[j].CreatedAt)
+	})
+
+	return pendingDeliveries, nil
+}
+
+func findAllWebhookDeliveriesByWebhookID(
+	deliveries map[uuid.UUID]*models.WebhookDelivery,
+	webhookID uuid.UUID,
+	limit int,
+) ([]*models.WebhookDelivery, error) {
+	var webhookDeliveries []*models.WebhookDelivery
+
+	for _, delivery := range deliveries {
+		if delivery.WebhookID == webhookID {
+			webhookDeliveries = append(webhookDeliveries, delivery)
+		}
+	}
+
+	if len(webhookDeliveries) == 0 {
+		return nil, ErrNotFound
+	}
+
+	sort.Slice(webhookDeliveries, func(i, j int) bool {
+		return webhookDeliveries[i].CreatedAt.After(webhookDeliveries[j].CreatedAt)
+	})
+
+	if limit > 0 && len(webhookDeliveries) > limit {
+		webhookDeliveries = webhookDeliveries[:limit]
+	}
+
+	return webhookDeliveries, nil
+}
+
+func findDeletionJobByID(jobs map[uuid.UUID]*models.DeletionJob, id uuid.UUID) (*models.DeletionJob, error) {
+	job, ok := jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return job.Copy(), nil
+}
+
+func findAllPendingDeletionJobs(jobs map[uuid.UUID]*models.DeletionJob) ([]*models.DeletionJob, error) {
+	var pendingJobs []*models.DeletionJob
+
+	for _, job := range jobs {
+		if job.Status() == models.DeletionStatusPending {
+			pendingJobs = append(pendingJobs, job.Copy())
+		}
+	}
+
+	if len(pendingJobs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	sort.Slice(pendingJobs, func(i, j int) bool {
+		return pendingJobs[i].CreatedAt.Before(pendingJobs[j].CreatedAt)
+	})
+
+	return pendingJobs, nil
+}
+
+// findDuplicate finds the link of the user of shortURL which has the same canonical URL.
+func findDuplicate(
+	userShortURLs map[uuid.UUID][]*models.ShortURL,
+	shortURL *models.ShortURL,
+) (*models.ShortURL, bool) {
+	for _, userShortURL := range userShortURLs[shortURL.UserID] {
+		if userShortURL.CanonicalURL == shortURL.CanonicalURL {
+			return userShortURL, true
+		}
+	}
+
+	return nil, false
+}
+
+// countNewLinks counts links of every user which the user has not shortened yet, repeated ones are counted once.
+func countNewLinks(
+	userShortURLs map[uuid.UUID][]*models.ShortURL,
+	shortURLs []*models.ShortURL,
+) map[uuid.UUID]int {
+	newLinks := make(map[uuid.UUID]int)
+	seen := make(map[uuid.UUID]map[models.URL]struct{})
+
+	for _, shortURL := range shortURLs {
+		if _, ok := findDuplicate(userShortURLs, shortURL); ok {
+			continue
+		}
+
+		if _, ok := seen[shortURL.UserID][shortURL.CanonicalURL]; ok {
+			continue
+		}
+
+		if seen[shortURL.UserID] == nil {
+			seen[shortURL.UserID] = make(map[models.URL]struct{})
+		}
+
+		seen[shortURL.UserID][shortURL.CanonicalURL] = struct{}{}
+		newLinks[shortURL.UserID]++
+	}
+
+	return newLinks
+}
+
+// checkQuota returns ErrQuotaExceeded if any user can't have newLinks more active links with positive maxLinks.
+func checkQuota(userShortURLs map[uuid.UUID][]*models.ShortURL, newLinks map[uuid.UUID]int, maxLinks int) error {
+	if maxLinks <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+
+	for userID, count := range newLinks {
+		if countActive(userShortURLs[userID], now)+count > maxLinks {
+			return ErrQuotaExceeded
+		}
+	}
+
+	return nil
+}
+
+func countActive(shortURLs []*models.ShortURL, now time.Time) int {
+	count := 0
+
+	for _, shortURL := range shortURLs {
+		if shortURL.IsActive(now) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// claimTransfer records the claim of tokenID, claims of expired tokens are forgotten.
+func claimTransfer(transferClaims map[string]time.Time, tokenID string, expiresAt, now time.Time) error {
+	for claimedTokenID, claimExpiresAt := range transferClaims {
+		if !now.Before(claimExpiresAt) {
+			delete(transferClaims, claimedTokenID)
+		}
+	}
+
+	if _, ok := transferClaims[tokenID]; ok {
+		return ErrAlreadyClaimed
+	}
+
+	transferClaims[tokenID] = expiresAt
+
+	return nil
+}
+
+/*
+checkReassignQuota returns ErrQuotaExceeded if active links which can be moved from fromUserID
+would make toUserID exceed positive maxLinks.
+*/
+func checkReassignQuota(
+	userShortURLs map[uuid.UUID][]*models.ShortURL,
+	fromUserID, toUserID uuid.UUID,
+	maxLinks int,
+) error {
+	moved, _ := splitReassignable(userShortURLs, fromUserID, toUserID)
+
+	movedLinks := countActive(moved, time.Now())
+	if movedLinks == 0 {
+		return nil
+	}
+
+	return checkQuota(userShortURLs, map[uuid.UUID]int{toUserID: movedLinks}, maxLinks)
+}
+
+// splitReassignable splits links of fromUserID into those which can be moved to toUserID and conflicting ones.
+func splitReassignable(
+	userShortURLs map[uuid.UUID][]*models.ShortURL,
+	fromUserID, toUserID uuid.UUID,
+) ([]*models.ShortURL, []*models.ShortURL) {
+	if fromUserID == toUserID {
+		return nil, nil
+	}
+
+	canonicalURLs := make(map[models.URL]struct{}, len(userShortURLs[toUserID]))
+	for _, shortURL := range userShortURLs[toUserID] {
+		canonicalURLs[shortURL.CanonicalURL] = struct{}{}
+	}
+
+	var moved, kept []*models.ShortURL
+
+	for _, shortURL := range userShortURLs[fromUserID] {
+		if _, ok := canonicalURLs[shortURL.CanonicalURL]; ok {
+			kept = append(kept, shortURL)
+
+			continue
+		}
+
+		moved = append(moved, shortURL)
+	}
+
+	return moved, kept
+}
+
+func applyReassign(
+	userShortURLs map[uuid.UUID][]*models.ShortURL,
+	fromUserID, toUserID uuid.UUID,
+	moved, kept []*models.ShortURL,
+) {
+	if len(moved) == 0 {
+		return
+	}
+
+	for _, shortURL := range moved {
+		shortURL.UserID = toUserID
+	}
+
+	userShortURLs[toUserID] = append(userShortURLs[toUserID], moved...)
+
+	if len(kept) == 0 {
+		delete(userShortURLs, fromUserID)
+
+		return
+	}
+
+	userShortURLs[fromUserID] = kept
+}
+
+func searchShortURLs(
+	shortURLs map[models.UID]*models.ShortURL,
+	filter *models.ShortURLFilter,
+) ([]*models.ShortURL, error) {
+	var found []*models.ShortURL
+
+	for _, shortURL := range shortURLs {
+		if filter.Match(shortURL) {
+			found = append(found, shortURL)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].UID < found[j].UID
+	})
+
+	if filter.Offset >= len(found) {
+		return nil, ErrNotFound
+	}
+
+	found = found[filter.Offset:]
+
+	if filter.Limit > 0 && filter.Limit < len(found) {
+		found = found[:filter.Limit]
+	}
+
+	return found, nil
+}
+
+func countAll(
+	shortURLs map[models.UID]*models.ShortURL,
+	userShortURLs map[uuid.UUID][]*models.ShortURL,
+	bans map[uuid.UUID]*models.Ban,
+	clicks int,
+) *models.GlobalCounts {
+	counts := &models.GlobalCounts{
+		Links:         len(shortURLs),
+		ActiveLinks:   0,
+		DeletedLinks:  0,
+		DisabledLinks: 0,
+		Users:         0,
+		BannedUsers:   len(bans),
+		Clicks:        clicks,
+	}
+
+	for _, links := range userShortURLs {
+		if len(links) > 0 {
+			counts.Users++
+		}
+	}
+
+	now := time.Now()
+
+	for _, shortURL := range shortURLs {
+		if shortURL.IsActive(now) {
+			counts.ActiveLinks++
+		}
+
+		if shortURL.IsDeleted {
+			counts.DeletedLinks++
+		}
+
+		if shortURL.IsDisabled {
+			counts.DisabledLinks++
+		}
+	}
+
+	return counts
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"sync"
+
+	"github.com/tmitry/shorturl/internal/app/models"
+)
+
+/*
+memoryOutbox keeps pending outbox messages of the memory and file repositories.
+Messages are added under the lock of the repository together with the change of links.
+publishMu lets one publisher at a time take messages from the head, so the repository lock
+is not held while messages are published.
+*/
+type memoryOutbox struct {
+	publishMu sync.Mutex
+	messages  []*models.OutboxMessage
+	lastID    int64
+}
+
+func newMemoryOutbox() *memoryOutbox {
+	return &memoryOutbox{
+		publishMu: sync.Mutex{},
+		messages:  nil,
+		lastID:    0,
+	}
+}
+
+func (o *memoryOutbox) newMessage(eventType string, shortURL *models.ShortURL) *models.OutboxMessage {
+	o.lastID++
+
+	message := models.NewOutboxMessage(models.NewWebhookEvent(eventType, shortURL))
+	message.ID = o.lastID
+
+	return message
+}
+
+func (o *memoryOutbox) add(message *models.OutboxMessage) {
+	o.messages = append(o.messages, message)
+
+	if message.ID > o.lastID {
+		o.lastID = message.ID
+	}
+}
+
+func (o *memoryOutbox) head(limit int) []*models.OutboxMessage {
+	if limit > len(o.messages) {
+		limit = len(o.messages)
+	}
+
+	return append([]*models.OutboxMessage(nil), o.messages[:limit]...)
+}
+
+// removeUpTo removes published messages which ID is less than or equal to id.
+func (o *memoryOutbox) removeUpTo(id int64) {
+	index := 0
+	for index < len(o.messages) && o.messages[index].ID <= id {
+		index++
+	}
+
+	o.messages = o.messages[index:]
+}
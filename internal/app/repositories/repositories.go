@@ -3,6 +3,7 @@ package repositories
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/tmitry/shorturl/internal/app/models"
@@ -13,13 +14,19 @@ const (
 	messageFailedToFind           = "failed to find"
 	messageFailedToPing           = "failed to ping"
 	messageFailedToCreateDatabase = "failed to create database"
+	messageFailedToOpenDatabase   = "failed to open database"
 	messageFailedToDelete         = "failed to delete"
+	messageFailedToUpdate         = "failed to update"
+	messageFailedToClose          = "failed to close"
 )
 
 var (
 	ErrNotFound        = errors.New("not found")
 	ErrURLDuplicate    = errors.New("duplicate url")
 	ErrNothingToDelete = errors.New("nothing to delete")
+	ErrUsernameTaken   = errors.New("username is taken")
+	ErrQuotaExceeded   = errors.New("link quota exceeded")
+	ErrAlreadyClaimed  = errors.New("transfer is already claimed")
 )
 
 type Repository interface {
@@ -27,13 +34,148 @@ type Repository interface {
 
 	FindAllByUserID(ctx context.Context, userID uuid.UUID) ([]*models.ShortURL, error)
 
-	Save(ctx context.Context, shortURL *models.ShortURL) error
+	/*
+		Save returns ErrURLDuplicate and replaces shortURL with the existing link if the user has already shortened
+		the canonical URL. Otherwise, positive maxLinks limits active links of the user: ErrQuotaExceeded is
+		returned if the user already has maxLinks of them. The check and the insert are atomic.
+	*/
+	Save(ctx context.Context, shortURL *models.ShortURL, maxLinks int) error
 
-	BatchSave(ctx context.Context, shortURLs []*models.ShortURL) error
+	/*
+		BatchSave replaces already shortened links with the existing ones and saves the rest.
+		Positive maxLinks limits active links of every user: if the new links would exceed it,
+		nothing is saved and ErrQuotaExceeded is returned. The check and the inserts are atomic.
+	*/
+	BatchSave(ctx context.Context, shortURLs []*models.ShortURL, maxLinks int) error
 
 	BatchDelete(ctx context.Context, shortURLs []*models.ShortURL) error
 
 	FindAllByUserIDAndUIDs(ctx context.Context, userID uuid.UUID, uids []models.UID) ([]*models.ShortURL, error)
 
+	// CountActiveByUserID counts links of the user which are neither deleted nor expired.
+	CountActiveByUserID(ctx context.Context, userID uuid.UUID) (int, error)
+
+	/*
+		ReassignUserID atomically moves links of fromUserID to toUserID.
+		Links whose canonical URL is already shortened by toUserID stay with fromUserID.
+		Positive maxLinks limits active links of toUserID: if the moved links would exceed it,
+		nothing is moved and ErrQuotaExceeded is returned.
+		It returns the numbers of moved and conflicting links.
+	*/
+	ReassignUserID(ctx context.Context, fromUserID, toUserID uuid.UUID, maxLinks int) (moved, conflicts int, err error)
+
+	/*
+		ClaimTransfer marks the transfer tokenID as claimed and reassigns links like ReassignUserID, atomically.
+		It returns ErrAlreadyClaimed if the transfer is already claimed. Claims are kept until expiresAt,
+		when the token itself is not accepted anymore. A transfer rejected with ErrQuotaExceeded stays unclaimed.
+	*/
+	ClaimTransfer(
+		ctx context.Context,
+		tokenID string,
+		expiresAt time.Time,
+		fromUserID, toUserID uuid.UUID,
+		maxLinks int,
+	) (moved, conflicts int, err error)
+
+	// SearchShortURLs finds links of all users ordered by UID.
+	SearchShortURLs(ctx context.Context, filter *models.ShortURLFilter) ([]*models.ShortURL, error)
+
+	// SetDisabled disables or enables the link regardless of its owner.
+	SetDisabled(ctx context.Context, uid models.UID, isDisabled bool) error
+
+	SaveBan(ctx context.Context, ban *models.Ban) error
+
+	// DeleteBan returns ErrNotFound if the user is not banned.
+	DeleteBan(ctx context.Context, userID uuid.UUID) error
+
+	// FindBanByUserID returns ErrNotFound if the user is not banned.
+	FindBanByUserID(ctx context.Context, userID uuid.UUID) (*models.Ban, error)
+
+	CountAll(ctx context.Context) (*models.GlobalCounts, error)
+
+	// RegisterClick counts a redirect by the link.
+	RegisterClick(ctx context.Context, uid models.UID) error
+
 	Ping(ctx context.Context) error
 }
+
+// RateLimitRepository shares rate limiting state between replicas.
+type RateLimitRepository interface {
+	// UpdateRateLimitBuckets locks the buckets of keys, passes them to update in the same order and stores the result.
+	UpdateRateLimitBuckets(ctx context.Context, keys []string, update func(buckets []*models.RateLimitBucket)) error
+
+	DeleteStaleRateLimitBuckets(ctx context.Context, updatedBefore time.Time) error
+}
+
+type APIKeyRepository interface {
+	SaveAPIKey(ctx context.Context, apiKey *models.APIKey) error
+
+	FindAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+
+	FindAllAPIKeysByUserID(ctx context.Context, userID uuid.UUID) ([]*models.APIKey, error)
+
+	// RevokeAPIKey revokes the key only if it belongs to the user.
+	RevokeAPIKey(ctx context.Context, userID, id uuid.UUID) error
+}
+
+type UserRepository interface {
+	// SaveUser returns ErrUsernameTaken if the username is already registered.
+	SaveUser(ctx context.Context, user *models.User) error
+
+	FindUserByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+
+	FindUserByUsername(ctx context.Context, username string) (*models.User, error)
+}
+
+// ChangeFeedRepository records every change of links made by Repository in the change feed.
+type ChangeFeedRepository interface {
+	// FindChanges finds up to limit changes which Seq is greater than since, ordered by Seq.
+	FindChanges(ctx context.Context, since int64, limit int) ([]*models.Change, error)
+}
+
+// OutboxRepository records events of Save, BatchSave and BatchDelete together with the change of links.
+type OutboxRepository interface {
+	/*
+		PublishOutbox passes up to limit oldest pending messages to publish and removes them once publish succeeds.
+		Messages taken by one publisher are skipped by the others, so several replicas can publish at once.
+		It returns the number of published messages.
+	*/
+	PublishOutbox(ctx context.Context, limit int, publish func(messages []*models.OutboxMessage) error) (int, error)
+}
+
+// DeletionJobRepository keeps deletion jobs, so their pending items are deleted after a restart.
+type DeletionJobRepository interface {
+	// SaveDeletionJob inserts the job or updates its items if it is already saved.
+	SaveDeletionJob(ctx context.Context, job *models.DeletionJob) error
+
+	FindDeletionJobByID(ctx context.Context, id uuid.UUID) (*models.DeletionJob, error)
+
+	// FindAllPendingDeletionJobs finds jobs which have pending items, the oldest first.
+	FindAllPendingDeletionJobs(ctx context.Context) ([]*models.DeletionJob, error)
+}
+
+type WebhookRepository interface {
+	SaveWebhook(ctx context.Context, webhook *models.Webhook) error
+
+	// FindAllWebhooksByUserID finds active webhooks of the user.
+	FindAllWebhooksByUserID(ctx context.Context, userID uuid.UUID) ([]*models.Webhook, error)
+
+	// FindWebhookByID finds the webhook even if it is deleted.
+	FindWebhookByID(ctx context.Context, id uuid.UUID) (*models.Webhook, error)
+
+	// DeleteWebhook deletes the webhook only if it belongs to the user.
+	DeleteWebhook(ctx context.Context, userID, id uuid.UUID) error
+
+	// SaveWebhookDelivery inserts the delivery or updates its state if it is already saved.
+	SaveWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+
+	// FindAllWebhookDeliveriesByWebhookID finds up to limit latest deliveries, the newest first.
+	FindAllWebhookDeliveriesByWebhookID(
+		ctx context.Context,
+		webhookID uuid.UUID,
+		limit int,
+	) ([]*models.WebhookDelivery, error)
+
+	// FindAllPendingWebhookDeliveries finds deliveries which are not finished, the oldest first.
+	FindAllPendingWebhookDeliveries(ctx context.Context) ([]*models.WebhookDelivery, error)
+}
@@ -0,0 +1,259 @@
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+)
+
+var errPublish = errors.New("broker is unavailable")
+
+// localRepository is implemented by the repositories which keep links in the process.
+type localRepository interface {
+	repositories.Repository
+	repositories.ChangeFeedRepository
+	repositories.OutboxRepository
+}
+
+type backend struct {
+	name string
+	open func(t *testing.T) localRepository
+}
+
+// backends share the quota, change feed and outbox helpers, so every test runs against both of them.
+func backends() []backend {
+	return []backend{
+		{
+			name: "memory",
+			open: func(*testing.T) localRepository {
+				return repositories.NewMemoryRepository()
+			},
+		},
+		{
+			name: "file",
+			open: func(t *testing.T) localRepository {
+				t.Helper()
+
+				return openFileRepository(t, filepath.Join(t.TempDir(), "storage.json"))
+			},
+		},
+	}
+}
+
+func openFileRepository(t *testing.T, path string) *repositories.FileRepository {
+	t.Helper()
+
+	rep := repositories.NewFileRepository(path, logger.NewNop())
+	t.Cleanup(func() { _ = rep.Close() })
+
+	return rep
+}
+
+func TestRepository_SaveQuota(t *testing.T) {
+	t.Parallel()
+
+	for _, backend := range backends() {
+		backend := backend
+		t.Run(backend.name, func(t *testing.T) {
+			t.Parallel()
+
+			rep := backend.open(t)
+			ctx := context.Background()
+			userID := uuid.New()
+
+			require.NoError(t, rep.Save(ctx, models.NewShortURL(0, "https://example.com/1", "abc", userID), 2))
+
+			// The duplicate does not count against the quota and is answered with the existing link.
+			duplicate := models.NewShortURL(0, "https://example.com/1", "def", userID)
+			assert.ErrorIs(t, rep.Save(ctx, duplicate, 2), repositories.ErrURLDuplicate)
+			assert.Equal(t, models.UID("abc"), duplicate.UID)
+
+			require.NoError(t, rep.Save(ctx, models.NewShortURL(0, "https://example.com/2", "ghi", userID), 2))
+
+			err := rep.Save(ctx, models.NewShortURL(0, "https://example.com/3", "jkl", userID), 2)
+			assert.ErrorIs(t, err, repositories.ErrQuotaExceeded)
+
+			// Other users have their own quota.
+			require.NoError(t, rep.Save(ctx, models.NewShortURL(0, "https://example.com/3", "mno", uuid.New()), 2))
+
+			count, err := rep.CountActiveByUserID(ctx, userID)
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+		})
+	}
+}
+
+func TestRepository_BatchSaveQuota(t *testing.T) {
+	t.Parallel()
+
+	for _, backend := range backends() {
+		backend := backend
+		t.Run(backend.name, func(t *testing.T) {
+			t.Parallel()
+
+			rep := backend.open(t)
+			ctx := context.Background()
+			userID := uuid.New()
+
+			require.NoError(t, rep.Save(ctx, models.NewShortURL(0, "https://example.com/1", "abc", userID), 3))
+
+			// The existing link and the repeated one are not new, so two new links fit into the quota of three.
+			batch := []*models.ShortURL{
+				models.NewShortURL(0, "https://example.com/1", "def", userID),
+				models.NewShortURL(0, "https://example.com/2", "ghi", userID),
+				models.NewShortURL(0, "https://example.com/2", "jkl", userID),
+				models.NewShortURL(0, "https://example.com/3", "mno", userID),
+			}
+			require.NoError(t, rep.BatchSave(ctx, batch, 3))
+
+			assert.Equal(t, models.UID("abc"), batch[0].UID)
+			assert.Equal(t, models.UID("ghi"), batch[1].UID)
+			assert.Equal(t, models.UID("ghi"), batch[2].UID)
+			assert.Equal(t, models.UID("mno"), batch[3].UID)
+
+			// A batch over the quota is rejected as a whole.
+			batch = []*models.ShortURL{
+				models.NewShortURL(0, "https://example.com/3", "pqr", userID),
+				models.NewShortURL(0, "https://example.com/4", "stu", userID),
+			}
+			assert.ErrorIs(t, rep.BatchSave(ctx, batch, 3), repositories.ErrQuotaExceeded)
+
+			_, err := rep.FindOneByUID(ctx, "stu")
+			assert.ErrorIs(t, err, repositories.ErrNotFound)
+
+			count, err := rep.CountActiveByUserID(ctx, userID)
+			require.NoError(t, err)
+			assert.Equal(t, 3, count)
+		})
+	}
+}
+
+func TestRepository_FindChanges(t *testing.T) {
+	t.Parallel()
+
+	for _, backend := range backends() {
+		backend := backend
+		t.Run(backend.name, func(t *testing.T) {
+			t.Parallel()
+
+			rep := backend.open(t)
+			ctx := context.Background()
+			userID := uuid.New()
+
+			_, err := rep.FindChanges(ctx, 0, 10)
+			assert.ErrorIs(t, err, repositories.ErrNotFound)
+
+			require.NoError(t, rep.Save(ctx, models.NewShortURL(0, "https://example.com/1", "abc", userID), 0))
+			require.NoError(t, rep.Save(ctx, models.NewShortURL(0, "https://example.com/2", "def", userID), 0))
+			require.NoError(t, rep.SetDisabled(ctx, "abc", true))
+
+			changes, err := rep.FindChanges(ctx, 0, 10)
+			require.NoError(t, err)
+			require.Len(t, changes, 3)
+
+			for index, expected := range []struct {
+				changeType string
+				uid        models.UID
+			}{
+				{changeType: models.ChangeTypeCreate, uid: "abc"},
+				{changeType: models.ChangeTypeCreate, uid: "def"},
+				{changeType: models.ChangeTypeUpdate, uid: "abc"},
+			} {
+				assert.Equal(t, int64(index+1), changes[index].Seq)
+				assert.Equal(t, expected.changeType, changes[index].Type)
+				assert.Equal(t, expected.uid, changes[index].ShortURL.UID)
+			}
+
+			assert.True(t, changes[2].ShortURL.IsDisabled)
+
+			// The feed is read after the given sequence number and is cut by the limit.
+			changes, err = rep.FindChanges(ctx, 1, 1)
+			require.NoError(t, err)
+			require.Len(t, changes, 1)
+			assert.Equal(t, int64(2), changes[0].Seq)
+
+			_, err = rep.FindChanges(ctx, 3, 10)
+			assert.ErrorIs(t, err, repositories.ErrNotFound)
+		})
+	}
+}
+
+func TestRepository_PublishOutbox(t *testing.T) {
+	t.Parallel()
+
+	for _, backend := range backends() {
+		backend := backend
+		t.Run(backend.name, func(t *testing.T) {
+			t.Parallel()
+
+			rep := backend.open(t)
+			ctx := context.Background()
+			userID := uuid.New()
+
+			for _, uid := range []models.UID{"abc", "def", "ghi"} {
+				shortURL := models.NewShortURL(0, models.URL("https://example.com/"+uid.String()), uid, userID)
+				require.NoError(t, rep.Save(ctx, shortURL, 0))
+			}
+
+			// Messages stay in the outbox if publishing fails.
+			published, err := rep.PublishOutbox(ctx, 2, func([]*models.OutboxMessage) error {
+				return errPublish
+			})
+			assert.ErrorIs(t, err, errPublish)
+			assert.Equal(t, 0, published)
+
+			assert.Equal(t, []models.UID{"abc", "def"}, publishUIDs(t, rep, 2))
+			assert.Equal(t, []models.UID{"ghi"}, publishUIDs(t, rep, 2))
+			assert.Empty(t, publishUIDs(t, rep, 2))
+		})
+	}
+}
+
+func TestFileRepository_PublishOutbox_Reopen(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.Background()
+	userID := uuid.New()
+
+	rep := repositories.NewFileRepository(path, logger.NewNop())
+
+	require.NoError(t, rep.Save(ctx, models.NewShortURL(0, "https://example.com/1", "abc", userID), 0))
+	require.NoError(t, rep.Save(ctx, models.NewShortURL(0, "https://example.com/2", "def", userID), 0))
+
+	assert.Equal(t, []models.UID{"abc"}, publishUIDs(t, rep, 1))
+	require.NoError(t, rep.Close())
+
+	// Published messages are not published again after a restart.
+	reopened := openFileRepository(t, path)
+
+	assert.Equal(t, []models.UID{"def"}, publishUIDs(t, reopened, 10))
+	assert.Empty(t, publishUIDs(t, reopened, 10))
+}
+
+// publishUIDs publishes up to limit messages of the outbox and returns UIDs of their links in order.
+func publishUIDs(t *testing.T, rep repositories.OutboxRepository, limit int) []models.UID {
+	t.Helper()
+
+	var uids []models.UID
+
+	_, err := rep.PublishOutbox(context.Background(), limit, func(messages []*models.OutboxMessage) error {
+		for _, message := range messages {
+			assert.Equal(t, models.WebhookEventLinkCreated, message.Event.Type)
+			uids = append(uids, message.Event.ShortURL.UID)
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	return uids
+}
@@ -1,15 +1,22 @@
 package app
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/tmitry/shorturl/api"
 	"github.com/tmitry/shorturl/internal/app/configs"
 	"github.com/tmitry/shorturl/internal/app/handlers"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/metrics"
 	"github.com/tmitry/shorturl/internal/app/middlewares"
 	"github.com/tmitry/shorturl/internal/app/repositories"
 	"github.com/tmitry/shorturl/internal/app/utils"
@@ -17,60 +24,421 @@ import (
 
 const (
 	ContextKeyUserID middlewares.ContextKey = "userID"
+	ContextKeyAPIKey middlewares.ContextKey = "apiKey"
 
 	jwtCookieName = "jwt"
+	apiPathPrefix = "/api/"
+
+	changeFeedPollInterval = 500 * time.Millisecond
 )
 
-func NewRouter(cfg *configs.Config) http.Handler {
-	router := chi.NewRouter()
-	router.Use(middleware.Compress(cfg.Server.CompressionLevel))
-	router.Use(middlewares.JWTAuth(cfg.Server.JWTSignatureKey, jwtCookieName, ContextKeyUserID))
+// Exit statuses of the server process.
+const (
+	ExitCodeOK             = 0
+	ExitCodeServerFailed   = 1
+	ExitCodeShutdownFailed = 2
+)
+
+/*
+Application is the router of the service with the background workers and the storage behind it.
+Shutdown stops the workers, so no accepted work is lost, and closes the storage.
+*/
+type Application struct {
+	Router            http.Handler
+	healthChecker     *utils.HealthChecker
+	deletionBuffer    *utils.BackgroundDeletionBuffer
+	outboxRelay       *utils.BackgroundOutboxRelay
+	webhookDispatcher *utils.BackgroundWebhookDispatcher
+	changeFeedHandler *handlers.ChangeFeedHandler
+	blocklist         *utils.FileBlocklist
+	certificate       *utils.FileCertificate
+	storage           io.Closer
+	log               *logger.Logger
+}
+
+func NewApplication(cfg *configs.Config, log *logger.Logger) *Application {
+	var (
+		storage        io.Closer
+		rep            repositories.Repository
+		apiKeyRep      repositories.APIKeyRepository
+		userRep        repositories.UserRepository
+		webhookRep     repositories.WebhookRepository
+		outboxRep      repositories.OutboxRepository
+		changeFeedRep  repositories.ChangeFeedRepository
+		deletionJobRep repositories.DeletionJobRepository
+		rateLimiter    utils.RateLimiter
+	)
+
+	appMetrics := metrics.NewMetrics()
 
-	var rep repositories.Repository
+	rateLimitPeriod := time.Duration(cfg.RateLimit.Period) * time.Second
+	rateLimiter = utils.NewMemoryRateLimiter(rateLimitPeriod)
+
+	healthChecker := utils.NewHealthChecker(time.Duration(cfg.Server.ReadinessTimeout) * time.Second)
 
 	switch {
 	case cfg.Database.DSN != "":
-		rep = repositories.NewDatabaseRepository(cfg.Database)
+		databaseRep := repositories.NewDatabaseRepository(cfg.Database, log)
+		storage = databaseRep
+		instrumentedRep := metrics.NewInstrumentedRepository(databaseRep, metrics.BackendDatabase, appMetrics)
+		rep = instrumentedRep
+		apiKeyRep = instrumentedRep
+		userRep = instrumentedRep
+		webhookRep = instrumentedRep
+		outboxRep = instrumentedRep
+		changeFeedRep = instrumentedRep
+		deletionJobRep = instrumentedRep
+
+		appMetrics.RegisterDBStats(databaseRep.Stats)
+
+		healthChecker.Register("database", databaseRep.Ping)
+		healthChecker.Register("migrations", databaseRep.CheckMigrations)
+
+		if cfg.RateLimit.Shared {
+			rateLimiter = utils.NewRepositoryRateLimiter(databaseRep, rateLimitPeriod)
+		}
 	case cfg.App.FileStoragePath != "":
-		rep = repositories.NewFileRepository(cfg.App.FileStoragePath)
+		fileStorage := repositories.NewFileRepository(cfg.App.FileStoragePath, log)
+		storage = fileStorage
+		fileRep := metrics.NewInstrumentedRepository(fileStorage, metrics.BackendFile, appMetrics)
+		rep = fileRep
+		apiKeyRep = fileRep
+		userRep = fileRep
+		webhookRep = fileRep
+		outboxRep = fileRep
+		changeFeedRep = fileRep
+		deletionJobRep = fileRep
+
+		healthChecker.Register("file_storage", fileStorage.CheckWritable)
 	default:
-		rep = repositories.NewMemoryRepository()
+		memoryRep := metrics.NewInstrumentedRepository(repositories.NewMemoryRepository(), metrics.BackendMemory, appMetrics)
+		rep = memoryRep
+		apiKeyRep = memoryRep
+		userRep = memoryRep
+		webhookRep = memoryRep
+		outboxRep = memoryRep
+		changeFeedRep = memoryRep
+		deletionJobRep = memoryRep
 	}
 
-	uidGenerator := utils.NewHashidsUIDGenerator(cfg.App.HashMinLength, cfg.App.HashSalt)
+	jwtKeyRing, jwtOptions := newJWTKeyRingAndOptions(cfg, log)
 
-	shortenerHandler := handlers.NewShortenerHandler(cfg, uidGenerator, rep, ContextKeyUserID)
+	uidGenerator := utils.NewHashidsUIDGenerator(cfg.App.HashMinLength, cfg.App.HashSalt, log)
 
-	deletionBuffer := utils.NewBackgroundDeletionBuffer(rep, cfg.App, uidGenerator)
+	blocklist := utils.NewFileBlocklist(
+		cfg.Policy.BlocklistPath,
+		time.Duration(cfg.Policy.BlocklistReloadInterval)*time.Second,
+		log,
+	)
 
-	shortenerAPIHandler := handlers.NewShortenerAPIHandler(cfg, uidGenerator, rep, ContextKeyUserID, deletionBuffer)
+	urlNormalizer := utils.NewConfigurableURLNormalizer(cfg.Policy)
 
-	router.Route("/", func(router chi.Router) {
-		router.Use(middleware.Logger)
-		router.Use(middleware.Recoverer)
-		router.Use(middleware.AllowContentType(handlers.ContentTypeText, handlers.ContentTypeGZIP))
-		router.Use(middleware.AllowContentEncoding(handlers.ContentEncodingGZIP))
-		router.Post("/", shortenerHandler.Shorten)
-		router.Get(fmt.Sprintf(
-			"/{%s:%s}",
-			handlers.ParameterNameUID,
-			uidGenerator.GetPattern(),
-		), shortenerHandler.Redirect)
-		router.Get("/ping", shortenerHandler.Ping)
-	})
+	webhookDispatcher := utils.NewBackgroundWebhookDispatcher(
+		webhookRep,
+		utils.NewWebhookHTTPClient(time.Duration(cfg.Webhook.Timeout)*time.Second, cfg.Webhook.AllowPrivateNetworks),
+		cfg.Server.BaseURL,
+		cfg.Webhook.MaxAttempts,
+		time.Duration(cfg.Webhook.RetryBackoff)*time.Second,
+		time.Duration(cfg.Webhook.MaxRetryBackoff)*time.Second,
+		log,
+	)
+
+	outboxRelay := utils.NewBackgroundOutboxRelay(
+		outboxRep,
+		webhookDispatcher,
+		cfg.Webhook.RelayBatchSize,
+		time.Duration(cfg.Webhook.RelayInterval)*time.Second,
+		log,
+	)
+
+	urlPolicy := utils.NewConfigurableURLPolicy(cfg.Policy, cfg.Server.BaseURL, blocklist)
+
+	quotaManager := utils.NewConfigQuotaManager(cfg.Quota, rep, log)
+
+	shortenerHandler := handlers.NewShortenerHandler(
+		cfg,
+		uidGenerator,
+		urlNormalizer,
+		urlPolicy,
+		blocklist,
+		quotaManager,
+		rep,
+		ContextKeyUserID,
+		webhookDispatcher,
+		appMetrics,
+		log,
+	)
+
+	deletionBuffer := utils.NewBackgroundDeletionBuffer(rep, deletionJobRep, cfg.App, uidGenerator, appMetrics, log)
+
+	healthChecker.Register("deletion_worker", deletionBuffer.Check)
+
+	shortenerAPIHandler := handlers.NewShortenerAPIHandler(
+		cfg,
+		uidGenerator,
+		urlNormalizer,
+		urlPolicy,
+		quotaManager,
+		rep,
+		ContextKeyUserID,
+		deletionBuffer,
+		log,
+	)
+
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyRep, ContextKeyUserID, log)
+
+	accountHandler := handlers.NewAccountHandler(
+		rep,
+		userRep,
+		quotaManager,
+		jwtKeyRing,
+		jwtOptions,
+		ContextKeyUserID,
+		log,
+	)
+
+	transferHandler := handlers.NewTransferHandler(rep, quotaManager, jwtKeyRing, ContextKeyUserID, log)
+
+	webhookHandler := handlers.NewWebhookHandler(webhookRep, cfg.Webhook.AllowPrivateNetworks, ContextKeyUserID, log)
+
+	adminHandler := handlers.NewAdminHandler(cfg, rep, webhookDispatcher, log)
+
+	changeFeedHandler := handlers.NewChangeFeedHandler(cfg, changeFeedRep, changeFeedPollInterval, log)
+
+	deletionJobHandler := handlers.NewDeletionJobHandler(deletionJobRep, ContextKeyUserID, log)
+
+	statsHandler := handlers.NewStatsHandler(rep, log)
+
+	metricsHandler := handlers.NewMetricsHandler(appMetrics.Registry, log)
+
+	healthHandler := handlers.NewHealthHandler(healthChecker, log)
 
-	router.Route("/api", func(router chi.Router) {
-		router.Use(middleware.Logger)
-		router.Use(middleware.Recoverer)
-		router.Use(middleware.AllowContentType(handlers.ContentTypeJSON, handlers.ContentTypeGZIP))
-		router.Use(middleware.AllowContentEncoding(handlers.ContentEncodingGZIP))
-		router.Post("/shorten", shortenerAPIHandler.Shorten)
-		router.Get("/user/urls", shortenerAPIHandler.UserUrls)
-		router.Post("/shorten/batch", shortenerAPIHandler.ShortenBatch)
-		router.Delete("/user/urls", shortenerAPIHandler.DeleteUserUrls)
+	openAPISpec, err := utils.NewOpenAPISpec(api.OpenAPI)
+	if err != nil {
+		log.Panic("failed to load OpenAPI document", logger.KeyError, err)
+	}
+
+	openAPIHandler := handlers.NewOpenAPIHandler(openAPISpec.Document(), log)
+
+	adminRole, err := middlewares.NewAdminRole(cfg.Admin.UserIDs, cfg.Admin.APIKeyIDs)
+	if err != nil {
+		log.Panic("incorrect admin role", logger.KeyError, err)
+	}
+
+	refuseBanned := middlewares.RefuseBanned(rep, ContextKeyUserID, log)
+
+	trustedProxies, err := middlewares.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		log.Panic("incorrect trusted proxies", logger.KeyError, err)
+	}
+
+	trustedSubnet, err := middlewares.ParseTrustedSubnet(cfg.Server.TrustedSubnet)
+	if err != nil {
+		log.Panic("incorrect trusted subnet", logger.KeyError, err)
+	}
+
+	metricsSubnet, err := middlewares.ParseTrustedSubnet(cfg.Metrics.TrustedSubnet)
+	if err != nil {
+		log.Panic("incorrect metrics trusted subnet", logger.KeyError, err)
+	}
+
+	rateLimit := func(class string, limit int) func(next http.Handler) http.Handler {
+		return middlewares.RateLimit(
+			rateLimiter,
+			class,
+			utils.NewRateLimitRule(limit, rateLimitPeriod),
+			trustedProxies,
+			ContextKeyUserID,
+			log,
+		)
+	}
+
+	loginRateLimit := middlewares.LoginRateLimit(
+		rateLimiter,
+		utils.NewRateLimitRule(cfg.RateLimit.LoginLimit, rateLimitPeriod),
+		trustedProxies,
+		log,
+	)
+
+	router := chi.NewRouter()
+	router.Use(middlewares.RequestID(log))
+	router.Use(middlewares.Metrics(appMetrics))
+
+	// Probes and metrics are served before authentication, so they never mint a JWT cookie.
+	router.Get("/healthz", healthHandler.Liveness)
+	router.Get("/readyz", healthHandler.Readiness)
+	router.With(middlewares.TrustedSubnet(metricsSubnet, trustedProxies)).Get("/metrics", metricsHandler.Metrics)
+
+	router.Group(func(router chi.Router) {
+		router.Use(middleware.Compress(cfg.Server.CompressionLevel))
+		router.Use(middlewares.ProblemDetails(apiPathPrefix))
+		router.Use(middlewares.APIKeyAuth(apiKeyRep, ContextKeyUserID, ContextKeyAPIKey, log))
+		router.Use(middlewares.JWTAuth(jwtKeyRing, jwtOptions, ContextKeyUserID, log))
+
+		router.Route("/", func(router chi.Router) {
+			router.Use(middlewares.AccessLog(log))
+			router.Use(middlewares.Recoverer(log))
+			router.Use(middleware.AllowContentType(handlers.ContentTypeText, handlers.ContentTypeGZIP))
+			router.Use(middleware.AllowContentEncoding(handlers.ContentEncodingGZIP))
+			router.With(rateLimit(middlewares.RateLimitClassCreate, cfg.RateLimit.CreateLimit), refuseBanned).
+				Post("/", shortenerHandler.Shorten)
+			router.With(rateLimit(middlewares.RateLimitClassRedirect, cfg.RateLimit.RedirectLimit)).
+				Get(fmt.Sprintf(
+					"/{%s:%s}",
+					handlers.ParameterNameUID,
+					uidGenerator.GetPattern(),
+				), shortenerHandler.Redirect)
+			router.Get("/ping", shortenerHandler.Ping)
+		})
+
+		router.Route("/api", func(router chi.Router) {
+			router.Use(middlewares.AccessLog(log))
+			router.Use(middlewares.Recoverer(log))
+			router.Use(middleware.AllowContentType(handlers.ContentTypeJSON, handlers.ContentTypeGZIP))
+			router.Use(middleware.AllowContentEncoding(handlers.ContentEncodingGZIP))
+			router.Use(middlewares.ValidateJSONBody(openAPISpec, int64(cfg.Server.MaxRequestBodySize), log))
+			router.Get("/openapi.json", openAPIHandler.Document)
+			router.With(rateLimit(middlewares.RateLimitClassCreate, cfg.RateLimit.CreateLimit), refuseBanned).
+				Post("/shorten", shortenerAPIHandler.Shorten)
+			router.Get("/user/urls", shortenerAPIHandler.UserUrls)
+			router.Get("/user/quota", shortenerAPIHandler.Quota)
+			router.With(loginRateLimit).Post("/user/register", accountHandler.Register)
+			router.With(loginRateLimit).Post("/user/login", accountHandler.Login)
+			router.Post("/user/logout", accountHandler.Logout)
+			router.With(refuseBanned).Post("/user/merge", accountHandler.Merge)
+			router.With(refuseBanned).Post("/user/transfers", transferHandler.Create)
+			router.With(refuseBanned).Post("/user/transfers/claim", transferHandler.Claim)
+			router.Post("/user/api-keys", apiKeyHandler.Create)
+			router.Get("/user/api-keys", apiKeyHandler.List)
+			router.Delete(fmt.Sprintf("/user/api-keys/{%s}", handlers.ParameterNameAPIKeyID), apiKeyHandler.Revoke)
+			router.Post("/user/webhooks", webhookHandler.Create)
+			router.Get("/user/webhooks", webhookHandler.List)
+			router.Delete(fmt.Sprintf("/user/webhooks/{%s}", handlers.ParameterNameWebhookID), webhookHandler.Delete)
+			router.Get(
+				fmt.Sprintf("/user/webhooks/{%s}/deliveries", handlers.ParameterNameWebhookID),
+				webhookHandler.Deliveries,
+			)
+			router.With(rateLimit(middlewares.RateLimitClassBatch, cfg.RateLimit.BatchLimit), refuseBanned).
+				Post("/shorten/batch", shortenerAPIHandler.ShortenBatch)
+			router.With(rateLimit(middlewares.RateLimitClassDelete, cfg.RateLimit.DeleteLimit)).
+				Delete("/user/urls", shortenerAPIHandler.DeleteUserUrls)
+			router.Get(fmt.Sprintf("/user/deletions/{%s}", handlers.ParameterNameDeletionJobID), deletionJobHandler.Get)
+
+			router.Route("/admin", func(router chi.Router) {
+				router.Use(middlewares.AdminOnly(adminRole, ContextKeyUserID, ContextKeyAPIKey))
+				router.Get("/urls", adminHandler.Search)
+				router.Post(fmt.Sprintf("/urls/{%s}/disable", handlers.ParameterNameUID), adminHandler.Disable)
+				router.Post(fmt.Sprintf("/urls/{%s}/enable", handlers.ParameterNameUID), adminHandler.Enable)
+				router.Put(fmt.Sprintf("/users/{%s}/ban", handlers.ParameterNameAdminUserID), adminHandler.Ban)
+				router.Delete(fmt.Sprintf("/users/{%s}/ban", handlers.ParameterNameAdminUserID), adminHandler.Unban)
+				router.Get("/counts", adminHandler.Counts)
+				router.Get("/changes", changeFeedHandler.Changes)
+			})
+
+			router.With(middlewares.TrustedSubnet(trustedSubnet, trustedProxies)).
+				Get("/internal/stats", statsHandler.Stats)
+		})
 	})
 
-	return router
+	return &Application{
+		Router:            router,
+		healthChecker:     healthChecker,
+		deletionBuffer:    deletionBuffer,
+		outboxRelay:       outboxRelay,
+		webhookDispatcher: webhookDispatcher,
+		changeFeedHandler: changeFeedHandler,
+		blocklist:         blocklist,
+		certificate:       nil,
+		storage:           storage,
+		log:               log,
+	}
+}
+
+// EndLongPolls answers held change feed requests, so the server does not wait for them to shut down.
+func (a *Application) EndLongPolls() {
+	a.changeFeedHandler.Shutdown()
+}
+
+/*
+BeginShutdown fails the readiness probe, so load balancers stop sending requests before the server stops.
+They notice it only if the server keeps serving for a while, see ServerConfig.ShutdownDrainDelay.
+*/
+func (a *Application) BeginShutdown() {
+	a.healthChecker.BeginShutdown()
+}
+
+/*
+Shutdown stops the outbox relay and the webhook deliveries, flushes the deletion buffer, stops watching the blocklist
+and the TLS certificate files, and closes the storage.
+Every step is made even if another one fails, the first error is returned.
+*/
+func (a *Application) Shutdown(ctx context.Context) error {
+	a.BeginShutdown()
+
+	var shutdownErr error
+
+	if err := a.outboxRelay.Shutdown(ctx); err != nil {
+		shutdownErr = err
+	}
+
+	if err := a.webhookDispatcher.Shutdown(ctx); err != nil && shutdownErr == nil {
+		shutdownErr = err
+	}
+
+	if err := a.deletionBuffer.Shutdown(ctx); err != nil && shutdownErr == nil {
+		shutdownErr = err
+	}
+
+	if err := a.blocklist.Close(); err != nil && shutdownErr == nil {
+		shutdownErr = err
+	}
+
+	if a.certificate != nil {
+		if err := a.certificate.Close(); err != nil && shutdownErr == nil {
+			shutdownErr = err
+		}
+	}
+
+	if a.storage != nil {
+		if err := a.storage.Close(); err != nil && shutdownErr == nil {
+			shutdownErr = fmt.Errorf("failed to close storage: %w", err)
+		}
+	}
+
+	return shutdownErr
+}
+
+func newJWTKeyRingAndOptions(cfg *configs.Config, log *logger.Logger) (*middlewares.JWTKeyRing, *middlewares.JWTOptions) {
+	verificationKeys, err := middlewares.ParseJWTVerificationKeys(cfg.JWT.VerificationKeys)
+	if err != nil {
+		log.Panic("incorrect JWT verification keys", logger.KeyError, err)
+	}
+
+	sameSite, err := middlewares.ParseSameSite(cfg.JWT.CookieSameSite)
+	if err != nil {
+		log.Panic("incorrect JWT cookie SameSite", logger.KeyError, err)
+	}
+
+	var legacyUntil time.Time
+	if cfg.JWT.LegacyTokensUntil != "" {
+		legacyUntil, err = time.Parse(time.RFC3339, cfg.JWT.LegacyTokensUntil)
+		if err != nil {
+			log.Panic("incorrect JWT legacy tokens cutoff", logger.KeyError, err)
+		}
+	}
+
+	keyRing := middlewares.NewJWTKeyRing(cfg.JWT.KeyID, cfg.Server.JWTSignatureKey, verificationKeys, legacyUntil)
+
+	options := middlewares.NewJWTOptions(
+		jwtCookieName,
+		time.Duration(cfg.JWT.Lifetime)*time.Second,
+		time.Duration(cfg.JWT.RenewBefore)*time.Second,
+		cfg.JWT.CookieHTTPOnly,
+		cfg.JWT.CookieSecure,
+		sameSite,
+	)
+
+	return keyRing, options
 }
 
 func NewServer(router http.Handler, serverCfg *configs.ServerConfig) *http.Server {
@@ -83,9 +451,122 @@ func NewServer(router http.Handler, serverCfg *configs.ServerConfig) *http.Serve
 	return server
 }
 
-func StartServer(cfg *configs.Config) {
-	router := NewRouter(cfg)
-	server := NewServer(router, cfg.Server)
+/*
+StartServer serves requests until SIGINT or SIGTERM and returns the exit status of the process.
+With TLS configured the server serves HTTPS, and the optional redirect listener sends plain HTTP clients to it.
+On a signal the readiness probe fails for ShutdownDrainDelay first, then the servers stop accepting connections
+and give in-flight requests ShutdownTimeout to finish, held change feed requests are answered at once.
+Then the application gets the same time to flush its background work.
+*/
+func StartServer(cfg *configs.Config, log *logger.Logger) int {
+	tlsConfig, certificate, err := NewTLSConfig(cfg.Server, log)
+	if err != nil {
+		log.Error("incorrect TLS config", logger.KeyError, err)
+
+		return ExitCodeServerFailed
+	}
+
+	warnOnBaseURLScheme(cfg.Server, tlsConfig != nil, log)
+
+	application := NewApplication(cfg, log)
+	application.certificate = certificate
+	server := NewServer(application.Router, cfg.Server)
+	server.TLSConfig = tlsConfig
+	server.RegisterOnShutdown(application.EndLongPolls)
+
+	servers := []*http.Server{server}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	defer signal.Stop(signals)
+
+	serverErrors := make(chan error, 2)
+
+	go func() {
+		if server.TLSConfig != nil {
+			serverErrors <- server.ListenAndServeTLS("", "")
+		} else {
+			serverErrors <- server.ListenAndServe()
+		}
+	}()
+
+	log.Info("server started", "address", cfg.Server.Address, "tls", tlsConfig != nil)
+
+	if tlsConfig != nil && cfg.Server.HTTPRedirectAddress != "" {
+		redirectServer := NewHTTPSRedirectServer(cfg.Server)
+		servers = append(servers, redirectServer)
+
+		go func() {
+			serverErrors <- redirectServer.ListenAndServe()
+		}()
+
+		log.Info("HTTPS redirect server started", "address", cfg.Server.HTTPRedirectAddress)
+	}
+
+	exitCode := ExitCodeOK
+
+	select {
+	case err := <-serverErrors:
+		log.Error("server failed", logger.KeyError, err)
+
+		exitCode = ExitCodeServerFailed
+	case receivedSignal := <-signals:
+		log.Info("shutting down", "signal", receivedSignal.String())
+
+		application.BeginShutdown()
+		drain(time.Duration(cfg.Server.ShutdownDrainDelay)*time.Second, signals, log)
+	}
+
+	shutdownTimeout := time.Duration(cfg.Server.ShutdownTimeout) * time.Second
+
+	serverCtx, cancelServer := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelServer()
+
+	for _, server := range servers {
+		if err := server.Shutdown(serverCtx); err != nil {
+			log.Error("failed to shut down server gracefully", "address", server.Addr, logger.KeyError, err)
+
+			if err := server.Close(); err != nil {
+				log.Error("failed to close server", "address", server.Addr, logger.KeyError, err)
+			}
+
+			if exitCode == ExitCodeOK {
+				exitCode = ExitCodeShutdownFailed
+			}
+		}
+	}
+
+	applicationCtx, cancelApplication := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelApplication()
+
+	if err := application.Shutdown(applicationCtx); err != nil {
+		log.Error("failed to shut down application", logger.KeyError, err)
+
+		if exitCode == ExitCodeOK {
+			exitCode = ExitCodeShutdownFailed
+		}
+	}
+
+	log.Info("server stopped", "exit_code", exitCode)
+
+	return exitCode
+}
+
+// drain keeps the server serving with the failing readiness probe for delay, another signal ends the wait.
+func drain(delay time.Duration, signals <-chan os.Signal, log *logger.Logger) {
+	if delay <= 0 {
+		return
+	}
 
-	log.Fatal(server.ListenAndServe())
+	log.Info("draining before shutdown", "delay", delay.String())
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case receivedSignal := <-signals:
+		log.Info("drain is interrupted", "signal", receivedSignal.String())
+	}
 }
@@ -0,0 +1,245 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/api"
+	"github.com/tmitry/shorturl/internal/app"
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/handlers"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/metrics"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+// chiParameterPattern matches the regexp of a chi URL parameter, e.g. ":[0-9a-zA-Z]{5,}" of "{uid:[0-9a-zA-Z]{5,}}".
+var chiParameterPattern = regexp.MustCompile(`\{(\w+):[^/]*\}`)
+
+func TestNewApplication_OpenAPI(t *testing.T) {
+	t.Parallel()
+
+	spec, err := utils.NewOpenAPISpec(api.OpenAPI)
+	require.NoError(t, err)
+
+	routes, ok := app.NewApplication(configs.NewDefaultConfig(), logger.NewNop()).Router.(chi.Routes)
+	require.True(t, ok)
+
+	var registered []string
+
+	err = chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = strings.ReplaceAll(route, "/*/", "/")
+		route = chiParameterPattern.ReplaceAllString(route, "{$1}")
+
+		registered = append(registered, method+" "+route)
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	for _, operation := range registered {
+		method, path, _ := strings.Cut(operation, " ")
+		assert.True(t, spec.HasOperation(method, path), "route %q is not described", operation)
+	}
+
+	documented := spec.Operations()
+
+	sort.Strings(registered)
+	sort.Strings(documented)
+	assert.Equal(t, registered, documented)
+}
+
+func TestNewApplication_OpenAPIDocument(t *testing.T) {
+	t.Parallel()
+
+	router := app.NewApplication(configs.NewDefaultConfig(), logger.NewNop()).Router
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	result := recorder.Result()
+
+	body, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	require.NoError(t, result.Body.Close())
+
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "application/json", result.Header.Get("Content-Type"))
+	assert.JSONEq(t, string(api.OpenAPI), string(body))
+
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":1}`))
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	result = recorder.Result()
+
+	body, err = io.ReadAll(result.Body)
+	require.NoError(t, err)
+	require.NoError(t, result.Body.Close())
+
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	assert.Equal(t, utils.ContentTypeProblemJSON, result.Header.Get("Content-Type"))
+
+	problem := utils.Problem{}
+	require.NoError(t, json.Unmarshal(body, &problem))
+	assert.Equal(t, utils.ProblemCodeValidationFailed, problem.Code)
+	assert.Equal(t, []utils.ProblemField{{Pointer: "/url", Detail: "must be a string"}}, problem.Errors)
+}
+
+func TestNewApplication_Metrics(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		trustedSubnet string
+		statusCode    int
+	}{
+		{
+			name:          "no trusted subnet - forbidden",
+			trustedSubnet: "",
+			statusCode:    http.StatusForbidden,
+		},
+		{
+			name:          "client in trusted subnet - allowed",
+			trustedSubnet: "192.0.2.0/24",
+			statusCode:    http.StatusOK,
+		},
+		{
+			name:          "client outside trusted subnet - forbidden",
+			trustedSubnet: "10.0.0.0/8",
+			statusCode:    http.StatusForbidden,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := configs.NewDefaultConfig()
+			cfg.Metrics.TrustedSubnet = testCase.trustedSubnet
+
+			router := app.NewApplication(cfg, logger.NewNop()).Router
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+			recorder = httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			result := recorder.Result()
+
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+
+			assert.Equal(t, testCase.statusCode, result.StatusCode)
+
+			// Metrics are served before authentication, so scraping never mints a JWT cookie.
+			assert.Empty(t, result.Cookies())
+
+			if testCase.statusCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, metrics.ContentTypeText, result.Header.Get("Content-Type"))
+			assert.Contains(t, string(body), `shorturl_http_requests_total{route="/ping",method="GET",status="200"} 1`)
+			assert.Contains(
+				t,
+				string(body),
+				`shorturl_repository_call_duration_seconds_count{backend="memory",method="Ping",status="ok"} 1`,
+			)
+		})
+	}
+}
+
+func TestApplication_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	cfg := configs.NewDefaultConfig()
+	cfg.App.FileStoragePath = filepath.Join(t.TempDir(), "storage.json")
+
+	application := app.NewApplication(cfg, logger.NewNop())
+
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://example.com/"))
+	request.Header.Set("Content-Type", handlers.ContentTypeText)
+
+	recorder := httptest.NewRecorder()
+	application.Router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusCreated, recorder.Code)
+
+	require.NoError(t, application.Shutdown(context.Background()))
+
+	// The storage is closed, links saved before shutdown are loaded by the next start.
+	restarted := app.NewApplication(cfg, logger.NewNop())
+	t.Cleanup(func() {
+		assert.NoError(t, restarted.Shutdown(context.Background()))
+	})
+
+	shortURL, err := url.Parse(recorder.Body.String())
+	require.NoError(t, err)
+
+	recorder = httptest.NewRecorder()
+	restarted.Router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, shortURL.Path, nil))
+	assert.Equal(t, http.StatusTemporaryRedirect, recorder.Code)
+}
+
+func TestNewApplication_HealthProbes(t *testing.T) {
+	t.Parallel()
+
+	cfg := configs.NewDefaultConfig()
+	cfg.App.FileStoragePath = filepath.Join(t.TempDir(), "storage.json")
+
+	application := app.NewApplication(cfg, logger.NewNop())
+	t.Cleanup(func() {
+		assert.NoError(t, application.Shutdown(context.Background()))
+	})
+
+	probe := func(path string) (int, *utils.HealthReport) {
+		recorder := httptest.NewRecorder()
+		application.Router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		result := recorder.Result()
+
+		body, err := io.ReadAll(result.Body)
+		require.NoError(t, err)
+		require.NoError(t, result.Body.Close())
+
+		// Probes are not authenticated, so they do not mint a JWT cookie.
+		assert.Empty(t, result.Cookies(), path)
+		assert.Equal(t, handlers.ContentTypeJSON, handlers.GetContentType(result), path)
+
+		report := &utils.HealthReport{}
+		require.NoError(t, json.Unmarshal(body, report))
+
+		return result.StatusCode, report
+	}
+
+	statusCode, report := probe("/healthz")
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, utils.HealthStatusOK, report.Status)
+
+	statusCode, report = probe("/readyz")
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, utils.HealthStatusOK, report.Status)
+	assert.Contains(t, report.Checks, "file_storage")
+	assert.Contains(t, report.Checks, "deletion_worker")
+
+	application.BeginShutdown()
+
+	statusCode, report = probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+	assert.Equal(t, utils.HealthStatusFail, report.Checks[utils.HealthCheckShutdown].Status)
+
+	statusCode, _ = probe("/healthz")
+	assert.Equal(t, http.StatusOK, statusCode)
+}
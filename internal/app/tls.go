@@ -0,0 +1,127 @@
+package app
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/handlers"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+var (
+	ErrTLSKeyPairIncomplete = errors.New("TLS certificate and key files must be set together")
+	ErrTLSSourceAmbiguous   = errors.New("TLS certificate files and self-signed certificate are exclusive")
+	ErrHTTPRedirectNoTLS    = errors.New("HTTP redirect listener requires TLS")
+)
+
+/*
+NewTLSConfig builds the TLS config of the listener from the server config, nil is returned if the server
+serves plain HTTP. Certificate files are watched for changes until the returned certificate is closed,
+it is nil for plain HTTP and a self-signed certificate. A self-signed certificate is generated
+for the host of the address, the base URL and localhost. Certificate files which can't be loaded are an error.
+*/
+func NewTLSConfig(
+	serverCfg *configs.ServerConfig,
+	log *logger.Logger,
+) (*tls.Config, *utils.FileCertificate, error) {
+	isFileCertificate := serverCfg.TLSCertFile != "" || serverCfg.TLSKeyFile != ""
+
+	switch {
+	case serverCfg.HTTPRedirectAddress != "" && !isFileCertificate && !serverCfg.TLSSelfSigned:
+		return nil, nil, ErrHTTPRedirectNoTLS
+	case !isFileCertificate && !serverCfg.TLSSelfSigned:
+		return nil, nil, nil
+	case isFileCertificate && serverCfg.TLSSelfSigned:
+		return nil, nil, ErrTLSSourceAmbiguous
+	case isFileCertificate && (serverCfg.TLSCertFile == "" || serverCfg.TLSKeyFile == ""):
+		return nil, nil, ErrTLSKeyPairIncomplete
+	}
+
+	minVersion, err := utils.ParseTLSVersion(serverCfg.TLSMinVersion)
+	if err != nil {
+		return nil, nil, fmt.Errorf("incorrect minimum TLS version: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: minVersion,
+	}
+
+	if isFileCertificate {
+		certificate, err := utils.NewFileCertificate(
+			serverCfg.TLSCertFile,
+			serverCfg.TLSKeyFile,
+			time.Duration(serverCfg.TLSReloadInterval)*time.Second,
+			log,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tlsConfig.GetCertificate = certificate.GetCertificate
+
+		return tlsConfig, certificate, nil
+	}
+
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+
+	if host, _, err := net.SplitHostPort(serverCfg.Address); err == nil && host != "" {
+		hosts = append(hosts, host)
+	}
+
+	if baseURL, err := url.Parse(serverCfg.BaseURL); err == nil && baseURL.Hostname() != "" {
+		hosts = append(hosts, baseURL.Hostname())
+	}
+
+	certificate, err := utils.NewSelfSignedCertificate(hosts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate self-signed certificate: %w", err)
+	}
+
+	log.Warn("serving a self-signed TLS certificate, use it for development only", "hosts", hosts)
+
+	tlsConfig.Certificates = []tls.Certificate{*certificate}
+
+	return tlsConfig, nil, nil
+}
+
+// NewHTTPSRedirectServer serves the listener which redirects plain HTTP requests to the HTTPS server.
+func NewHTTPSRedirectServer(serverCfg *configs.ServerConfig) *http.Server {
+	redirectHandler := handlers.NewHTTPSRedirectHandler(serverCfg.Address)
+
+	return &http.Server{
+		Addr:              serverCfg.HTTPRedirectAddress,
+		Handler:           http.HandlerFunc(redirectHandler.Redirect),
+		ReadHeaderTimeout: time.Duration(serverCfg.ReadHeaderTimeout) * time.Second,
+	}
+}
+
+// warnOnBaseURLScheme warns when short URLs point to a scheme the listener does not serve.
+func warnOnBaseURLScheme(serverCfg *configs.ServerConfig, isTLS bool, log *logger.Logger) {
+	if serverCfg.BaseURL == "" {
+		return
+	}
+
+	baseURL, err := url.Parse(serverCfg.BaseURL)
+	if err != nil {
+		log.Warn("incorrect base URL", "base_url", serverCfg.BaseURL, logger.KeyError, err)
+
+		return
+	}
+
+	switch {
+	case isTLS && baseURL.Scheme != "https":
+		log.Warn("base URL is not HTTPS while the server serves TLS", "base_url", serverCfg.BaseURL)
+	case !isTLS && baseURL.Scheme == "https":
+		log.Warn(
+			"base URL is HTTPS while the server serves plain HTTP, it is fine only behind a TLS-terminating proxy",
+			"base_url", serverCfg.BaseURL,
+		)
+	}
+}
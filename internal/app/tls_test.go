@@ -0,0 +1,116 @@
+package app_test
+
+import (
+	"crypto/tls"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app"
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/logger"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	tests := []struct {
+		name       string
+		configure  func(serverCfg *configs.ServerConfig)
+		isTLS      bool
+		minVersion uint16
+		err        error
+	}{
+		{
+			name:       "test case 1: plain HTTP",
+			configure:  func(*configs.ServerConfig) {},
+			isTLS:      false,
+			minVersion: 0,
+			err:        nil,
+		},
+		{
+			name: "test case 2: redirect without TLS",
+			configure: func(serverCfg *configs.ServerConfig) {
+				serverCfg.HTTPRedirectAddress = ":8081"
+			},
+			isTLS:      false,
+			minVersion: 0,
+			err:        app.ErrHTTPRedirectNoTLS,
+		},
+		{
+			name: "test case 3: certificate without key",
+			configure: func(serverCfg *configs.ServerConfig) {
+				serverCfg.TLSCertFile = "cert.pem"
+			},
+			isTLS:      false,
+			minVersion: 0,
+			err:        app.ErrTLSKeyPairIncomplete,
+		},
+		{
+			name: "test case 4: certificate files and self-signed certificate",
+			configure: func(serverCfg *configs.ServerConfig) {
+				serverCfg.TLSCertFile = "cert.pem"
+				serverCfg.TLSKeyFile = "key.pem"
+				serverCfg.TLSSelfSigned = true
+			},
+			isTLS:      false,
+			minVersion: 0,
+			err:        app.ErrTLSSourceAmbiguous,
+		},
+		{
+			name: "test case 5: self-signed certificate",
+			configure: func(serverCfg *configs.ServerConfig) {
+				serverCfg.TLSSelfSigned = true
+				serverCfg.TLSMinVersion = "1.3"
+			},
+			isTLS:      true,
+			minVersion: tls.VersionTLS13,
+			err:        nil,
+		},
+		{
+			name: "test case 6: missing certificate files",
+			configure: func(serverCfg *configs.ServerConfig) {
+				serverCfg.TLSCertFile = filepath.Join(dir, "cert.pem")
+				serverCfg.TLSKeyFile = filepath.Join(dir, "key.pem")
+			},
+			isTLS:      false,
+			minVersion: 0,
+			err:        fs.ErrNotExist,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			serverCfg := configs.NewDefaultServerConfig()
+			testCase.configure(serverCfg)
+
+			tlsConfig, certificate, err := app.NewTLSConfig(serverCfg, logger.NewNop())
+			if testCase.err != nil {
+				assert.ErrorIs(t, err, testCase.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			// Neither plain HTTP nor a self-signed certificate has files to watch.
+			assert.Nil(t, certificate)
+
+			if !testCase.isTLS {
+				assert.Nil(t, tlsConfig)
+
+				return
+			}
+
+			require.NotNil(t, tlsConfig)
+			assert.Equal(t, testCase.minVersion, tlsConfig.MinVersion)
+			assert.Len(t, tlsConfig.Certificates, 1)
+		})
+	}
+}
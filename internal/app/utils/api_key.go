@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	apiKeyPrefix        = "sk_"
+	apiKeySize          = 32
+	apiKeyDisplayLength = len(apiKeyPrefix) + 6
+)
+
+// GenerateAPIKey returns a new random API key and its prefix which is safe to display.
+func GenerateAPIKey() (string, string, error) {
+	buf := make([]byte, apiKeySize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	return key, key[:apiKeyDisplayLength], nil
+}
+
+// HashAPIKey returns the stored form of an API key. Keys are random, so a fast hash is enough.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
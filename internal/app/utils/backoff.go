@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"time"
+)
+
+/*
+exponentialBackoff returns the delay before the attempt following the given one: backoff after the first attempt,
+doubled with every next one up to maxBackoff.
+*/
+func exponentialBackoff(attempt int, backoff, maxBackoff time.Duration) time.Duration {
+	delay := backoff
+
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	return delay
+}
@@ -0,0 +1,48 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		attempt int
+		delay   time.Duration
+	}{
+		{
+			name:    "test case 1: first attempt",
+			attempt: 1,
+			delay:   time.Second,
+		},
+		{
+			name:    "test case 2: doubled delay",
+			attempt: 3,
+			delay:   4 * time.Second,
+		},
+		{
+			name:    "test case 3: capped delay",
+			attempt: 5,
+			delay:   10 * time.Second,
+		},
+		{
+			name:    "test case 4: many attempts do not overflow",
+			attempt: 1000,
+			delay:   10 * time.Second,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testCase.delay, utils.ExponentialBackoff(testCase.attempt, time.Second, 10*time.Second))
+		})
+	}
+}
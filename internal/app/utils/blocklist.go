@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	netUrl "net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/models"
+)
+
+const (
+	blocklistRegexPrefix = "regex:"
+
+	messageFailedToReloadBlocklist = "failed to reload blocklist"
+)
+
+type Blocklist interface {
+	// Match returns the blocklist entry matched by url.
+	Match(url models.URL) (string, bool)
+}
+
+/*
+FileBlocklist is a blocklist loaded from a local file. Each line holds one entry:
+- "example.com" blocks the domain itself;
+- "*.example.com" blocks the domain and all of its subdomains;
+- "regex:^https?://[^/]+/login" blocks URLs matching the regular expression.
+Empty lines and lines starting with "#" are ignored.
+The file is re-read when its modification time changes or the process receives SIGHUP, Close stops watching it.
+*/
+type FileBlocklist struct {
+	path           string
+	reloadInterval time.Duration
+	mu             sync.RWMutex
+	domains        map[string]struct{}
+	suffixes       []string
+	patterns       []*regexp.Regexp
+	modTime        time.Time
+	watcher        *FileWatcher
+	log            *logger.Logger
+}
+
+func NewFileBlocklist(path string, reloadInterval time.Duration, log *logger.Logger) *FileBlocklist {
+	blocklist := &FileBlocklist{
+		path:           path,
+		reloadInterval: reloadInterval,
+		mu:             sync.RWMutex{},
+		domains:        map[string]struct{}{},
+		suffixes:       nil,
+		patterns:       nil,
+		modTime:        time.Time{},
+		watcher:        nil,
+		log:            log,
+	}
+
+	if path == "" {
+		return blocklist
+	}
+
+	if err := blocklist.Reload(); err != nil {
+		log.Panic(messageFailedToReloadBlocklist, "path", path, logger.KeyError, err)
+	}
+
+	watcher, err := NewFileWatcher("blocklist", path, reloadInterval, blocklist.isModified, blocklist.Reload, log)
+	if err != nil {
+		log.Panic(messageFailedToReloadBlocklist, "path", path, logger.KeyError, err)
+	}
+
+	blocklist.watcher = watcher
+
+	return blocklist
+}
+
+// Close stops watching the blocklist file.
+func (b *FileBlocklist) Close() error {
+	if b.watcher != nil {
+		b.watcher.Stop()
+	}
+
+	return nil
+}
+
+func (b *FileBlocklist) Match(url models.URL) (string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if parsedURL, err := netUrl.Parse(url.String()); err == nil {
+		host := strings.TrimSuffix(strings.ToLower(parsedURL.Hostname()), ".")
+
+		if _, ok := b.domains[host]; ok {
+			return host, true
+		}
+
+		for _, suffix := range b.suffixes {
+			if host == suffix || strings.HasSuffix(host, "."+suffix) {
+				return "*." + suffix, true
+			}
+		}
+	}
+
+	for _, pattern := range b.patterns {
+		if pattern.MatchString(url.String()) {
+			return blocklistRegexPrefix + pattern.String(), true
+		}
+	}
+
+	return "", false
+}
+
+// Reload re-reads the blocklist file. The current entries are kept if the file can not be parsed.
+func (b *FileBlocklist) Reload() error {
+	file, err := os.Open(b.path)
+	if err != nil {
+		return fmt.Errorf("failed to open blocklist: %w", err)
+	}
+
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			b.log.Error(messageFailedToReloadBlocklist, "path", b.path, logger.KeyError, err)
+		}
+	}(file)
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat blocklist: %w", err)
+	}
+
+	domains := map[string]struct{}{}
+
+	var (
+		suffixes []string
+		patterns []*regexp.Regexp
+	)
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, blocklistRegexPrefix):
+			pattern, err := regexp.Compile(strings.TrimPrefix(line, blocklistRegexPrefix))
+			if err != nil {
+				return fmt.Errorf("failed to parse blocklist line %d: %w", lineNumber, err)
+			}
+
+			patterns = append(patterns, pattern)
+		case strings.HasPrefix(line, "*."):
+			suffixes = append(suffixes, strings.TrimSuffix(strings.ToLower(strings.TrimPrefix(line, "*.")), "."))
+		default:
+			domains[strings.TrimSuffix(strings.ToLower(line), ".")] = struct{}{}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read blocklist: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.domains = domains
+	b.suffixes = suffixes
+	b.patterns = patterns
+	b.modTime = stat.ModTime()
+
+	return nil
+}
+
+func (b *FileBlocklist) isModified() bool {
+	stat, err := os.Stat(b.path)
+	if err != nil {
+		return false
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return !stat.ModTime().Equal(b.modTime)
+}
@@ -0,0 +1,137 @@
+package utils_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+func writeBlocklist(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestFileBlocklist_Match(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklist(t, path, "# phishing\n\nEvil.example.\n*.malware.example\nregex:^https?://[^/]+/login\n", time.Now())
+
+	blocklist := utils.NewFileBlocklist(path, time.Hour, logger.NewNop())
+	t.Cleanup(func() { _ = blocklist.Close() })
+
+	tests := []struct {
+		name    string
+		url     models.URL
+		entry   string
+		isMatch bool
+	}{
+		{name: "test case 1: domain", url: "https://EVIL.example/a", entry: "evil.example", isMatch: true},
+		{name: "test case 2: subdomain of domain", url: "https://www.evil.example/a", entry: "", isMatch: false},
+		{name: "test case 3: wildcard itself", url: "http://malware.example/", entry: "*.malware.example", isMatch: true},
+		{name: "test case 4: wildcard subdomain", url: "http://a.b.malware.example./", entry: "*.malware.example", isMatch: true},
+		{name: "test case 5: regex", url: "https://bank.example/login", entry: "regex:^https?://[^/]+/login", isMatch: true},
+		{name: "test case 6: no match", url: "https://example.com/", entry: "", isMatch: false},
+		{name: "test case 7: comment is not an entry", url: "https://phishing/", entry: "", isMatch: false},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			entry, ok := blocklist.Match(testCase.url)
+
+			assert.Equal(t, testCase.isMatch, ok)
+			assert.Equal(t, testCase.entry, entry)
+		})
+	}
+}
+
+func TestFileBlocklist_Reload(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklist(t, path, "evil.example\n", time.Now().Add(-time.Hour))
+
+	blocklist := utils.NewFileBlocklist(path, time.Millisecond, logger.NewNop())
+	t.Cleanup(func() { _ = blocklist.Close() })
+
+	_, ok := blocklist.Match("https://other.example/")
+	assert.False(t, ok)
+
+	// The watcher notices the new modification time.
+	writeBlocklist(t, path, "other.example\n", time.Now())
+
+	assert.Eventually(t, func() bool {
+		_, ok := blocklist.Match("https://other.example/")
+
+		return ok
+	}, time.Second, time.Millisecond)
+
+	_, ok = blocklist.Match("https://evil.example/")
+	assert.False(t, ok)
+}
+
+func TestFileBlocklist_MalformedFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklist(t, path, "evil.example\n", time.Now())
+
+	blocklist := utils.NewFileBlocklist(path, time.Hour, logger.NewNop())
+	t.Cleanup(func() { _ = blocklist.Close() })
+
+	writeBlocklist(t, path, "other.example\nregex:(\n", time.Now())
+
+	assert.Error(t, blocklist.Reload())
+
+	// The current entries are kept.
+	_, ok := blocklist.Match("https://evil.example/")
+	assert.True(t, ok)
+
+	_, ok = blocklist.Match("https://other.example/")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() {
+		utils.NewFileBlocklist(path, time.Hour, logger.NewNop())
+	})
+}
+
+func TestNewFileBlocklist_IncorrectReloadInterval(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklist(t, path, "evil.example\n", time.Now())
+
+	assert.Panics(t, func() {
+		utils.NewFileBlocklist(path, -time.Second, logger.NewNop())
+	})
+}
+
+func TestFileBlocklist_Close(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklist(t, path, "evil.example\n", time.Now().Add(-time.Hour))
+
+	blocklist := utils.NewFileBlocklist(path, time.Millisecond, logger.NewNop())
+
+	require.NoError(t, blocklist.Close())
+	require.NoError(t, blocklist.Close())
+
+	// The file is not watched anymore.
+	writeBlocklist(t, path, "other.example\n", time.Now())
+	time.Sleep(20 * time.Millisecond)
+
+	_, ok := blocklist.Match("https://other.example/")
+	assert.False(t, ok)
+}
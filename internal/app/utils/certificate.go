@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/logger"
+)
+
+const (
+	selfSignedCertificateLifetime = 365 * 24 * time.Hour
+)
+
+func ParseTLSVersion(version string) (uint16, error) {
+	tlsVersion, ok := configs.TLSVersions[version]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q", version)
+	}
+
+	return tlsVersion, nil
+}
+
+/*
+FileCertificate is a TLS certificate loaded from PEM files of the certificate chain and the private key.
+The files are re-read when modification time of any of them changes or the process receives SIGHUP,
+so a renewed certificate is served without a restart. The current certificate is kept if the files can not be loaded,
+e.g. while the certificate is written and the key is not yet. Close stops watching the files.
+*/
+type FileCertificate struct {
+	certFile       string
+	keyFile        string
+	reloadInterval time.Duration
+	mu             sync.RWMutex
+	certificate    *tls.Certificate
+	modTimes       [2]time.Time
+	watcher        *FileWatcher
+	log            *logger.Logger
+}
+
+// NewFileCertificate loads the certificate and starts watching its files, it fails if the files can't be loaded.
+func NewFileCertificate(
+	certFile, keyFile string,
+	reloadInterval time.Duration,
+	log *logger.Logger,
+) (*FileCertificate, error) {
+	certificate := &FileCertificate{
+		certFile:       certFile,
+		keyFile:        keyFile,
+		reloadInterval: reloadInterval,
+		mu:             sync.RWMutex{},
+		certificate:    nil,
+		modTimes:       [2]time.Time{},
+		watcher:        nil,
+		log:            log,
+	}
+
+	if err := certificate.Reload(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := NewFileWatcher(
+		"TLS certificate",
+		certFile,
+		reloadInterval,
+		certificate.isModified,
+		certificate.Reload,
+		log,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate.watcher = watcher
+
+	return certificate, nil
+}
+
+// Close stops watching the certificate files.
+func (c *FileCertificate) Close() error {
+	if c.watcher != nil {
+		c.watcher.Stop()
+	}
+
+	return nil
+}
+
+// GetCertificate serves the current certificate, it is set as GetCertificate of tls.Config.
+func (c *FileCertificate) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.certificate, nil
+}
+
+func (c *FileCertificate) Reload() error {
+	modTimes, err := c.readModTimes()
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.certificate = &certificate
+	c.modTimes = modTimes
+
+	return nil
+}
+
+func (c *FileCertificate) readModTimes() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+
+	for index, path := range []string{c.certFile, c.keyFile} {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return modTimes, fmt.Errorf("failed to stat TLS certificate: %w", err)
+		}
+
+		modTimes[index] = stat.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+func (c *FileCertificate) isModified() bool {
+	modTimes, err := c.readModTimes()
+	if err != nil {
+		return false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return !modTimes[0].Equal(c.modTimes[0]) || !modTimes[1].Equal(c.modTimes[1])
+}
+
+/*
+NewSelfSignedCertificate generates a certificate for development which is valid for the hosts, IP addresses
+among them go to IP SANs. Clients do not trust it, so it must never be used in production.
+*/
+func NewSelfSignedCertificate(hosts []string) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	now := time.Now()
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{Organization: []string{"shorturl development"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedCertificateLifetime),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if host != "" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{certificate},
+		PrivateKey:  key,
+	}, nil
+}
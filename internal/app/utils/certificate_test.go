@@ -0,0 +1,100 @@
+package utils_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+func writeCertificate(t *testing.T, certificate *tls.Certificate, certFile, keyFile string) {
+	t.Helper()
+
+	key, ok := certificate.PrivateKey.(*ecdsa.PrivateKey)
+	require.True(t, ok)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+}
+
+func TestNewSelfSignedCertificate(t *testing.T) {
+	t.Parallel()
+
+	certificate, err := utils.NewSelfSignedCertificate([]string{"localhost", "127.0.0.1", "example.com", ""})
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"localhost", "example.com"}, leaf.DNSNames)
+	require.Len(t, leaf.IPAddresses, 1)
+	assert.True(t, leaf.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")))
+	assert.NoError(t, leaf.VerifyHostname("example.com"))
+	assert.True(t, leaf.NotAfter.After(time.Now()))
+}
+
+func TestFileCertificate_Reload(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	first, err := utils.NewSelfSignedCertificate([]string{"localhost"})
+	require.NoError(t, err)
+	writeCertificate(t, first, certFile, keyFile)
+
+	// The watcher stays idle, so the test reloads explicitly.
+	certificate, err := utils.NewFileCertificate(certFile, keyFile, time.Hour, logger.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = certificate.Close() })
+
+	served, err := certificate.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, first.Certificate, served.Certificate)
+
+	second, err := utils.NewSelfSignedCertificate([]string{"localhost"})
+	require.NoError(t, err)
+	writeCertificate(t, second, certFile, keyFile)
+
+	require.NoError(t, certificate.Reload())
+
+	served, err = certificate.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, second.Certificate, served.Certificate)
+
+	// A broken key keeps the current certificate.
+	require.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0o600))
+	assert.Error(t, certificate.Reload())
+
+	served, err = certificate.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, second.Certificate, served.Certificate)
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	t.Parallel()
+
+	version, err := utils.ParseTLSVersion("1.3")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), version)
+
+	_, err = utils.ParseTLSVersion("1.4")
+	assert.Error(t, err)
+}
@@ -3,73 +3,352 @@ package utils
 import (
 	"context"
 	"errors"
-	"log"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/metrics"
 	"github.com/tmitry/shorturl/internal/app/models"
 	"github.com/tmitry/shorturl/internal/app/repositories"
 )
 
+const messageFailedToDeleteBuffered = "failed to delete buffered links"
+
+// Reasons of failed deletion items shown to the user.
+const (
+	DeletionReasonIncorrectUID = "incorrect UID"
+	DeletionReasonNotFound     = "URL not found"
+	DeletionReasonInternal     = "internal error"
+)
+
+var (
+	ErrDeletionQueueFull      = errors.New("deletion queue is full")
+	ErrDeletionBufferShutdown = errors.New("deletion buffer is shut down")
+)
+
 type DeletionBuffer interface {
-	Push(uids []models.UID, userID uuid.UUID)
+	/*
+		Push saves the deletion job of the links and deletes them in the background. The job is saved before
+		Push returns, so its items are deleted even after a restart. Items with incorrect UIDs fail at once.
+		ErrDeletionQueueFull is returned and nothing is saved when the queue has no room for the job.
+		Log lines of the deletion carry the request ID of ctx.
+	*/
+	Push(ctx context.Context, uids []models.UID, userID uuid.UUID) (*models.DeletionJob, error)
 }
 
+// deletionItem is a buffered link with its job and the ID of the request which asked to delete it.
+type deletionItem struct {
+	shortURL  *models.ShortURL
+	jobID     uuid.UUID
+	requestID string
+}
+
+// queuedDeletionJob is a job waiting for a worker with the context of the request which pushed it.
+type queuedDeletionJob struct {
+	ctx context.Context
+	job *models.DeletionJob
+}
+
+/*
+BackgroundDeletionBuffer queues deletion jobs and deletes their links in the background.
+The queue holds at most queueSize jobs, a pool of workers takes jobs from it and finds their links.
+Found links are collected by the buffer and deleted with one batch when the buffer is full
+or bufferClearTimeout passes without new links. A failed batch is retried after retryBackoff,
+the delay doubles with every attempt up to retryMaxBackoff and retryMaxAttempts attempts.
+Items of jobs are resolved as links are deleted. Jobs left pending by the previous run are resumed on start.
+Shutdown stops accepting jobs, lets workers finish queued ones and flushes the buffer. When its context is done,
+the batch in progress is cancelled and its items stay pending until the next start.
+*/
 type BackgroundDeletionBuffer struct {
+	mu                 sync.Mutex
+	isShutdown         bool
+	pushes             sync.WaitGroup
+	workers            sync.WaitGroup
+	closing            chan struct{}
+	stopped            chan struct{}
+	ctx                context.Context
+	cancel             context.CancelFunc
+	slots              chan struct{}
+	queue              chan queuedDeletionJob
 	rep                repositories.Repository
+	jobRep             repositories.DeletionJobRepository
 	bufferMaxSize      int
 	bufferClearTimeout time.Duration
-	shortURLs          []*models.ShortURL
-	channel            chan *models.ShortURL
+	retryMaxAttempts   int
+	retryBackoff       time.Duration
+	retryMaxBackoff    time.Duration
+	wait               func(ctx context.Context, delay time.Duration) error
+	items              []deletionItem
+	channel            chan deletionItem
+	probes             chan chan struct{}
 	uidGenerator       UIDGenerator
+	metrics            *metrics.Metrics
+	log                *logger.Logger
 }
 
 func NewBackgroundDeletionBuffer(
 	rep repositories.Repository,
+	jobRep repositories.DeletionJobRepository,
 	appCfg *configs.AppConfig,
 	uidGenerator UIDGenerator,
+	appMetrics *metrics.Metrics,
+	log *logger.Logger,
 ) *BackgroundDeletionBuffer {
+	return newBackgroundDeletionBuffer(rep, jobRep, appCfg, uidGenerator, appMetrics, waitContext, log)
+}
+
+// newBackgroundDeletionBuffer makes the buffer which waits between retries of a batch with wait.
+func newBackgroundDeletionBuffer(
+	rep repositories.Repository,
+	jobRep repositories.DeletionJobRepository,
+	appCfg *configs.AppConfig,
+	uidGenerator UIDGenerator,
+	appMetrics *metrics.Metrics,
+	wait func(ctx context.Context, delay time.Duration) error,
+	log *logger.Logger,
+) *BackgroundDeletionBuffer {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	buf := &BackgroundDeletionBuffer{
+		mu:                 sync.Mutex{},
+		isShutdown:         false,
+		pushes:             sync.WaitGroup{},
+		workers:            sync.WaitGroup{},
+		closing:            make(chan struct{}),
+		stopped:            make(chan struct{}),
+		ctx:                ctx,
+		cancel:             cancel,
+		slots:              make(chan struct{}, appCfg.DeletionQueueSize),
+		queue:              make(chan queuedDeletionJob, appCfg.DeletionQueueSize),
 		rep:                rep,
+		jobRep:             jobRep,
 		bufferMaxSize:      appCfg.DeletionBufferMaxSize,
 		bufferClearTimeout: time.Duration(appCfg.DeletionBufferClearTimeout) * time.Second,
-		shortURLs:          []*models.ShortURL{},
-		channel:            make(chan *models.ShortURL),
+		retryMaxAttempts:   appCfg.DeletionRetryMaxAttempts,
+		retryBackoff:       time.Duration(appCfg.DeletionRetryBackoff) * time.Second,
+		retryMaxBackoff:    time.Duration(appCfg.DeletionRetryMaxBackoff) * time.Second,
+		wait:               wait,
+		items:              []deletionItem{},
+		channel:            make(chan deletionItem),
+		probes:             make(chan chan struct{}),
 		uidGenerator:       uidGenerator,
+		metrics:            appMetrics,
+		log:                log,
 	}
 
-	buf.newWorker()
+	buf.newFlusher()
+
+	for i := 0; i < appCfg.DeletionWorkers; i++ {
+		buf.newWorker()
+	}
+
+	// Pending jobs are found before Push is possible, so jobs pushed by this run are not resumed twice.
+	jobs := buf.findPending()
+
+	buf.pushes.Add(1)
+
+	go buf.resume(jobs)
 
 	return buf
 }
 
-func (buf *BackgroundDeletionBuffer) Push(uids []models.UID, userID uuid.UUID) {
-	go func() {
-		for _, uid := range uids {
-			isValid, err := buf.uidGenerator.IsValid(uid)
-			if err != nil || !isValid {
-				return
-			}
+func (buf *BackgroundDeletionBuffer) Push(
+	ctx context.Context,
+	uids []models.UID,
+	userID uuid.UUID,
+) (*models.DeletionJob, error) {
+	job := models.NewDeletionJob(userID, uids)
+	buf.resolveIncorrect(job)
+
+	if len(job.PendingUIDs()) == 0 {
+		if err := buf.jobRep.SaveDeletionJob(ctx, job); err != nil {
+			return nil, fmt.Errorf("failed to save deletion job: %w", err)
 		}
 
-		shortURLs, err := buf.rep.FindAllByUserIDAndUIDs(context.Background(), userID, uids)
-		if err != nil {
-			return
+		return job, nil
+	}
+
+	isQueued, err := buf.reserve()
+	if err != nil {
+		return nil, err
+	}
+
+	if isQueued {
+		defer buf.pushes.Done()
+	}
+
+	if err := buf.jobRep.SaveDeletionJob(ctx, job); err != nil {
+		if isQueued {
+			<-buf.slots
 		}
 
-		for _, shortURL := range shortURLs {
-			buf.channel <- shortURL
+		return nil, fmt.Errorf("failed to save deletion job: %w", err)
+	}
+
+	// The job is saved, so the next start resumes it.
+	if !isQueued {
+		buf.log.Ctx(ctx).Warn("deletion job is postponed: buffer is shut down", "job_id", job.ID)
+
+		return job, nil
+	}
+
+	buf.queue <- queuedDeletionJob{ctx: logger.Detach(ctx), job: job.Copy()}
+
+	return job, nil
+}
+
+/*
+reserve takes a place in the queue for a job. It returns false if the buffer is shut down,
+so the job is only saved, and ErrDeletionQueueFull if the queue has no room.
+*/
+func (buf *BackgroundDeletionBuffer) reserve() (bool, error) {
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	if buf.isShutdown {
+		return false, nil
+	}
+
+	select {
+	case buf.slots <- struct{}{}:
+		buf.pushes.Add(1)
+
+		return true, nil
+	default:
+		return false, ErrDeletionQueueFull
+	}
+}
+
+// resolveIncorrect resolves pending items with incorrect UIDs as failed.
+func (buf *BackgroundDeletionBuffer) resolveIncorrect(job *models.DeletionJob) {
+	var incorrectUIDs []models.UID
+
+	for _, uid := range job.PendingUIDs() {
+		isValid, err := buf.uidGenerator.IsValid(uid)
+		if err != nil || !isValid {
+			incorrectUIDs = append(incorrectUIDs, uid)
 		}
-	}()
+	}
+
+	job.Resolve(incorrectUIDs, models.DeletionStatusFailed, DeletionReasonIncorrectUID)
+}
+
+// process resolves items of the job which can not be deleted and sends the others to the buffer.
+func (buf *BackgroundDeletionBuffer) process(ctx context.Context, job *models.DeletionJob) {
+	shortURLs := buf.findDeletable(ctx, job)
+
+	if err := buf.jobRep.SaveDeletionJob(ctx, job); err != nil {
+		buf.log.Ctx(ctx).Error("failed to save deletion job", "job_id", job.ID, logger.KeyError, err)
+	}
+
+	for _, shortURL := range shortURLs {
+		buf.channel <- deletionItem{shortURL: shortURL, jobID: job.ID, requestID: logger.RequestID(ctx)}
+	}
+}
+
+// findDeletable resolves pending items with unknown UIDs as failed and finds links of the others.
+func (buf *BackgroundDeletionBuffer) findDeletable(ctx context.Context, job *models.DeletionJob) []*models.ShortURL {
+	uids := job.PendingUIDs()
+	if len(uids) == 0 {
+		return nil
+	}
+
+	shortURLs, err := buf.rep.FindAllByUserIDAndUIDs(ctx, job.UserID, uids)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		buf.log.Ctx(ctx).Error(messageFailedToDeleteBuffered, "job_id", job.ID, logger.KeyError, err)
+		job.Resolve(uids, models.DeletionStatusFailed, DeletionReasonInternal)
+
+		return nil
+	}
+
+	isFound := make(map[models.UID]bool, len(shortURLs))
+	for _, shortURL := range shortURLs {
+		isFound[shortURL.UID] = true
+	}
+
+	var unknownUIDs []models.UID
+
+	for _, uid := range uids {
+		if !isFound[uid] {
+			unknownUIDs = append(unknownUIDs, uid)
+		}
+	}
+
+	job.Resolve(unknownUIDs, models.DeletionStatusFailed, DeletionReasonNotFound)
+
+	return shortURLs
+}
+
+// findPending finds jobs which were pending when the previous run stopped.
+func (buf *BackgroundDeletionBuffer) findPending() []*models.DeletionJob {
+	jobs, err := buf.jobRep.FindAllPendingDeletionJobs(context.Background())
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		buf.log.Error("failed to resume deletion jobs", logger.KeyError, err)
+	}
+
+	return jobs
+}
+
+// resume queues the jobs of the previous run. It waits for room in the queue.
+func (buf *BackgroundDeletionBuffer) resume(jobs []*models.DeletionJob) {
+	defer buf.pushes.Done()
+
+	for _, job := range jobs {
+		if !buf.reserveResumed() {
+			// Jobs which are not queued stay pending until the next start.
+			return
+		}
+
+		buf.log.Info("deletion job is resumed", "job_id", job.ID)
+		buf.queue <- queuedDeletionJob{ctx: context.Background(), job: job}
+	}
+}
+
+// reserveResumed waits for a place in the queue. It returns false if the buffer is shut down while the queue is full.
+func (buf *BackgroundDeletionBuffer) reserveResumed() bool {
+	select {
+	case buf.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	select {
+	case buf.slots <- struct{}{}:
+		return true
+	case <-buf.closing:
+		return false
+	}
 }
 
 func (buf *BackgroundDeletionBuffer) newWorker() {
+	buf.workers.Add(1)
+
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
 				buf.newWorker()
-				log.Println(r)
+				buf.log.Error("deletion worker panicked", "panic", r)
+			}
+
+			buf.workers.Done()
+		}()
+
+		for queued := range buf.queue {
+			<-buf.slots
+			buf.process(queued.ctx, queued.job)
+		}
+	}()
+}
+
+func (buf *BackgroundDeletionBuffer) newFlusher() {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				buf.newFlusher()
+				buf.log.Error("deletion flusher panicked", "panic", r)
 			}
 		}()
 
@@ -77,29 +356,203 @@ func (buf *BackgroundDeletionBuffer) newWorker() {
 
 		for {
 			select {
-			case shortURL := <-buf.channel:
-				buf.shortURLs = append(buf.shortURLs, shortURL)
-				if len(buf.shortURLs) == buf.bufferMaxSize {
+			case item, ok := <-buf.channel:
+				if !ok {
+					ticker.Stop()
+					buf.flush()
+					buf.cancel()
+					close(buf.stopped)
+
+					return
+				}
+
+				buf.items = append(buf.items, item)
+				buf.metrics.DeletionQueueDepth.Set(float64(len(buf.items)))
+
+				if len(buf.items) == buf.bufferMaxSize {
 					buf.flush()
 				}
 
 				ticker.Reset(buf.bufferClearTimeout)
 			case <-ticker.C:
 				buf.flush()
+			case reply := <-buf.probes:
+				close(reply)
 			}
 		}
 	}()
 }
 
+/*
+Check reports an error if the buffer is shut down or its flusher does not answer before ctx is done,
+e.g. it has stopped or is stuck retrying a batch.
+*/
+func (buf *BackgroundDeletionBuffer) Check(ctx context.Context) error {
+	buf.mu.Lock()
+	isShutdown := buf.isShutdown
+	buf.mu.Unlock()
+
+	if isShutdown {
+		return ErrDeletionBufferShutdown
+	}
+
+	reply := make(chan struct{})
+
+	select {
+	case buf.probes <- reply:
+	case <-ctx.Done():
+		return fmt.Errorf("deletion flusher does not answer: %w", ctx.Err())
+	}
+
+	<-reply
+
+	return nil
+}
+
+/*
+Shutdown refuses new jobs, waits until workers finish queued jobs and flushes the buffer.
+It returns the error of ctx if the buffer is not flushed in time, the batch in progress is cancelled then.
+*/
+func (buf *BackgroundDeletionBuffer) Shutdown(ctx context.Context) error {
+	buf.mu.Lock()
+
+	if !buf.isShutdown {
+		buf.isShutdown = true
+		close(buf.closing)
+
+		go func() {
+			buf.pushes.Wait()
+			close(buf.queue)
+			buf.workers.Wait()
+			close(buf.channel)
+		}()
+	}
+
+	buf.mu.Unlock()
+
+	select {
+	case <-buf.stopped:
+		return nil
+	case <-ctx.Done():
+		buf.cancel()
+
+		return fmt.Errorf("failed to flush deletion buffer: %w", ctx.Err())
+	}
+}
+
 func (buf *BackgroundDeletionBuffer) flush() {
-	err := buf.rep.BatchDelete(context.Background(), buf.shortURLs)
-	if err != nil {
-		if !errors.Is(err, repositories.ErrNothingToDelete) {
-			log.Println(err.Error())
+	if len(buf.items) == 0 {
+		return
+	}
+
+	shortURLs := make([]*models.ShortURL, 0, len(buf.items))
+	for _, item := range buf.items {
+		shortURLs = append(shortURLs, item.shortURL)
+	}
+
+	start := time.Now()
+
+	err := buf.batchDelete(shortURLs)
+
+	switch {
+	case err != nil && buf.ctx.Err() != nil:
+		// Shutdown gave up on the batch, its jobs are resumed by the next start.
+		buf.log.Warn(
+			"deletion of buffered links is postponed: buffer is shut down",
+			"links", len(shortURLs),
+			"request_ids", buf.requestIDs(),
+		)
+	case err != nil:
+		buf.metrics.ObserveDeletionFlush(len(shortURLs), time.Since(start), err)
+		buf.log.Error(
+			messageFailedToDeleteBuffered,
+			"links", len(shortURLs),
+			"request_ids", buf.requestIDs(),
+			logger.KeyError, err,
+		)
+		buf.resolveItems(models.DeletionStatusFailed, DeletionReasonInternal)
+	default:
+		buf.metrics.ObserveDeletionFlush(len(shortURLs), time.Since(start), nil)
+		buf.log.Debug("buffered links are deleted", "links", len(shortURLs), "request_ids", buf.requestIDs())
+		buf.resolveItems(models.DeletionStatusCompleted, "")
+	}
+
+	buf.items = nil
+	buf.metrics.DeletionQueueDepth.Set(0)
+}
+
+/*
+batchDelete deletes the links, a failed attempt is retried after a delay until attempts are exhausted.
+It gives up at once when Shutdown cancels the batch.
+*/
+func (buf *BackgroundDeletionBuffer) batchDelete(shortURLs []*models.ShortURL) error {
+	for attempt := 1; ; attempt++ {
+		err := buf.rep.BatchDelete(buf.ctx, shortURLs)
+		if err == nil || errors.Is(err, repositories.ErrNothingToDelete) {
+			return nil
 		}
 
-		return
+		if attempt >= buf.retryMaxAttempts {
+			return fmt.Errorf("failed to delete links after %d attempts: %w", attempt, err)
+		}
+
+		buf.log.Warn(messageFailedToDeleteBuffered, "attempt", attempt, logger.KeyError, err)
+
+		if err := buf.wait(buf.ctx, exponentialBackoff(attempt, buf.retryBackoff, buf.retryMaxBackoff)); err != nil {
+			return fmt.Errorf("failed to delete links after %d attempts: %w", attempt, err)
+		}
+	}
+}
+
+// waitContext returns the error of ctx if it is done before delay passes.
+func waitContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to wait for retry: %w", ctx.Err())
+	}
+}
+
+// resolveItems sets the status of the buffered links in their jobs.
+func (buf *BackgroundDeletionBuffer) resolveItems(status, reason string) {
+	jobUIDs := map[uuid.UUID][]models.UID{}
+	for _, item := range buf.items {
+		jobUIDs[item.jobID] = append(jobUIDs[item.jobID], item.shortURL.UID)
+	}
+
+	for jobID, uids := range jobUIDs {
+		job, err := buf.jobRep.FindDeletionJobByID(context.Background(), jobID)
+		if err != nil {
+			buf.log.Error("failed to find deletion job", "job_id", jobID, logger.KeyError, err)
+
+			continue
+		}
+
+		job.Resolve(uids, status, reason)
+
+		if err := buf.jobRep.SaveDeletionJob(context.Background(), job); err != nil {
+			buf.log.Error("failed to save deletion job", "job_id", jobID, logger.KeyError, err)
+		}
+	}
+}
+
+// requestIDs returns distinct IDs of the requests which asked to delete the buffered links.
+func (buf *BackgroundDeletionBuffer) requestIDs() []string {
+	requestIDs := make([]string, 0, len(buf.items))
+	isSeen := map[string]bool{}
+
+	for _, item := range buf.items {
+		if item.requestID == "" || isSeen[item.requestID] {
+			continue
+		}
+
+		isSeen[item.requestID] = true
+		requestIDs = append(requestIDs, item.requestID)
 	}
 
-	buf.shortURLs = nil
+	return requestIDs
 }
@@ -0,0 +1,380 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/metrics"
+	"github.com/tmitry/shorturl/internal/app/mocks"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+// newIdleDeletionAppConfig makes a buffer which is neither full nor timed out, so only Shutdown flushes it.
+func newIdleDeletionAppConfig() *configs.AppConfig {
+	appCfg := configs.NewDefaultAppConfig()
+	appCfg.DeletionBufferMaxSize = 100
+	appCfg.DeletionBufferClearTimeout = 3600
+
+	return appCfg
+}
+
+func newDeletionUIDGenerator(ctrl *gomock.Controller) *mocks.MockUIDGenerator {
+	uidGenerator := mocks.NewMockUIDGenerator(ctrl)
+	uidGenerator.EXPECT().IsValid(gomock.Any()).DoAndReturn(func(uid models.UID) (bool, error) {
+		return uid != "bad!", nil
+	}).AnyTimes()
+
+	return uidGenerator
+}
+
+func TestBackgroundDeletionBuffer_Push(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	userID := uuid.New()
+	rep := repositories.NewMemoryRepository()
+	ctx := context.Background()
+	require.NoError(t, rep.Save(ctx, models.NewShortURL(1, "https://example.com/1", "abc", userID), 0))
+	require.NoError(t, rep.Save(ctx, models.NewShortURL(2, "https://example.com/2", "def", uuid.New()), 0))
+
+	buf := utils.NewBackgroundDeletionBuffer(
+		rep,
+		rep,
+		newIdleDeletionAppConfig(),
+		newDeletionUIDGenerator(ctrl),
+		metrics.NewMetrics(),
+		logger.NewNop(),
+	)
+
+	job, err := buf.Push(context.Background(), []models.UID{"abc", "def", "xyz", "bad!"}, userID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeletionStatusPending, job.Status())
+
+	require.NoError(t, buf.Shutdown(context.Background()))
+
+	savedJob, err := rep.FindDeletionJobByID(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeletionStatusFailed, savedJob.Status())
+	assert.Equal(t, []models.DeletionItem{
+		{UID: "abc", Status: models.DeletionStatusCompleted, Reason: ""},
+		{UID: "def", Status: models.DeletionStatusFailed, Reason: utils.DeletionReasonNotFound},
+		{UID: "xyz", Status: models.DeletionStatusFailed, Reason: utils.DeletionReasonNotFound},
+		{UID: "bad!", Status: models.DeletionStatusFailed, Reason: utils.DeletionReasonIncorrectUID},
+	}, savedJob.Items)
+
+	shortURL, err := rep.FindOneByUID(context.Background(), "abc")
+	require.NoError(t, err)
+	assert.True(t, shortURL.IsDeleted)
+
+	// The job pushed after shutdown is saved, so the next start deletes its links.
+	job, err = buf.Push(context.Background(), []models.UID{"abc"}, userID)
+	require.NoError(t, err)
+
+	pendingJobs, err := rep.FindAllPendingDeletionJobs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, pendingJobs, 1)
+	assert.Equal(t, job.ID, pendingJobs[0].ID)
+}
+
+func TestBackgroundDeletionBuffer_Resume(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	userID := uuid.New()
+	rep := repositories.NewMemoryRepository()
+	require.NoError(t, rep.Save(context.Background(), models.NewShortURL(1, "https://example.com/1", "abc", userID), 0))
+
+	job := models.NewDeletionJob(userID, []models.UID{"abc"})
+	require.NoError(t, rep.SaveDeletionJob(context.Background(), job))
+
+	buf := utils.NewBackgroundDeletionBuffer(
+		rep,
+		rep,
+		newIdleDeletionAppConfig(),
+		newDeletionUIDGenerator(ctrl),
+		metrics.NewMetrics(),
+		logger.NewNop(),
+	)
+	require.NoError(t, buf.Shutdown(context.Background()))
+
+	savedJob, err := rep.FindDeletionJobByID(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeletionStatusCompleted, savedJob.Status())
+}
+
+func TestBackgroundDeletionBuffer_ShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	userID := uuid.New()
+	block := make(chan struct{})
+
+	rep := mocks.NewMockRepository(ctrl)
+	rep.EXPECT().FindAllByUserIDAndUIDs(gomock.Any(), userID, gomock.Any()).
+		DoAndReturn(func(context.Context, uuid.UUID, []models.UID) ([]*models.ShortURL, error) {
+			<-block
+
+			return nil, repositories.ErrNotFound
+		})
+
+	buf := utils.NewBackgroundDeletionBuffer(
+		rep,
+		repositories.NewMemoryRepository(),
+		configs.NewDefaultAppConfig(),
+		newDeletionUIDGenerator(ctrl),
+		metrics.NewMetrics(),
+		logger.NewNop(),
+	)
+
+	_, err := buf.Push(context.Background(), []models.UID{"abc"}, userID)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, buf.Shutdown(ctx), context.Canceled)
+
+	close(block)
+	assert.NoError(t, buf.Shutdown(context.Background()))
+}
+
+func TestBackgroundDeletionBuffer_QueueFull(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	userID := uuid.New()
+	started := make(chan struct{})
+	block := make(chan struct{})
+
+	rep := mocks.NewMockRepository(ctrl)
+	gomock.InOrder(
+		rep.EXPECT().FindAllByUserIDAndUIDs(gomock.Any(), userID, gomock.Any()).
+			DoAndReturn(func(context.Context, uuid.UUID, []models.UID) ([]*models.ShortURL, error) {
+				close(started)
+				<-block
+
+				return nil, repositories.ErrNotFound
+			}),
+		rep.EXPECT().FindAllByUserIDAndUIDs(gomock.Any(), userID, gomock.Any()).Return(nil, repositories.ErrNotFound),
+	)
+
+	appCfg := newIdleDeletionAppConfig()
+	appCfg.DeletionWorkers = 1
+	appCfg.DeletionQueueSize = 1
+
+	jobRep := repositories.NewMemoryRepository()
+	buf := utils.NewBackgroundDeletionBuffer(
+		rep,
+		jobRep,
+		appCfg,
+		newDeletionUIDGenerator(ctrl),
+		metrics.NewMetrics(),
+		logger.NewNop(),
+	)
+
+	// The only worker is busy with the first job, the second one takes the only place in the queue.
+	_, err := buf.Push(context.Background(), []models.UID{"abc"}, userID)
+	require.NoError(t, err)
+	<-started
+
+	_, err = buf.Push(context.Background(), []models.UID{"def"}, userID)
+	require.NoError(t, err)
+
+	_, err = buf.Push(context.Background(), []models.UID{"ghi"}, userID)
+	assert.ErrorIs(t, err, utils.ErrDeletionQueueFull)
+
+	// Jobs without correct UIDs do not need the queue.
+	job, err := buf.Push(context.Background(), []models.UID{"bad!"}, userID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeletionStatusFailed, job.Status())
+
+	pendingJobs, err := jobRep.FindAllPendingDeletionJobs(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, pendingJobs, 2)
+
+	close(block)
+	require.NoError(t, buf.Shutdown(context.Background()))
+
+	_, err = jobRep.FindAllPendingDeletionJobs(context.Background())
+	assert.ErrorIs(t, err, repositories.ErrNotFound)
+}
+
+func TestBackgroundDeletionBuffer_Retry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		attempts int
+		failures int
+		delays   []time.Duration
+		status   string
+		reason   string
+	}{
+		{
+			name:     "test case 1: deleted with the first attempt",
+			attempts: 3,
+			failures: 0,
+			delays:   nil,
+			status:   models.DeletionStatusCompleted,
+			reason:   "",
+		},
+		{
+			name:     "test case 2: deleted with the last attempt",
+			attempts: 3,
+			failures: 2,
+			delays:   []time.Duration{time.Second, 2 * time.Second},
+			status:   models.DeletionStatusCompleted,
+			reason:   "",
+		},
+		{
+			name:     "test case 3: attempts are exhausted",
+			attempts: 3,
+			failures: 3,
+			delays:   []time.Duration{time.Second, 2 * time.Second},
+			status:   models.DeletionStatusFailed,
+			reason:   utils.DeletionReasonInternal,
+		},
+		{
+			name:     "test case 4: delay does not exceed the max backoff",
+			attempts: 6,
+			failures: 6,
+			delays:   []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second, 4 * time.Second},
+			status:   models.DeletionStatusFailed,
+			reason:   utils.DeletionReasonInternal,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			t.Cleanup(ctrl.Finish)
+
+			userID := uuid.New()
+			shortURL := models.NewShortURL(1, "https://example.com/1", "abc", userID)
+
+			rep := mocks.NewMockRepository(ctrl)
+			rep.EXPECT().FindAllByUserIDAndUIDs(gomock.Any(), userID, []models.UID{"abc"}).
+				Return([]*models.ShortURL{shortURL}, nil)
+
+			attempts := make([]*gomock.Call, 0, testCase.attempts)
+			for i := 0; i < testCase.attempts; i++ {
+				call := rep.EXPECT().BatchDelete(gomock.Any(), []*models.ShortURL{shortURL})
+
+				switch {
+				case i < testCase.failures:
+					call.Return(errors.New("connection reset"))
+				case i == testCase.failures:
+					call.Return(nil)
+				default:
+					call.Times(0)
+				}
+
+				attempts = append(attempts, call)
+			}
+			gomock.InOrder(attempts...)
+
+			appCfg := newIdleDeletionAppConfig()
+			appCfg.DeletionRetryMaxAttempts = testCase.attempts
+			appCfg.DeletionRetryBackoff = 1
+			appCfg.DeletionRetryMaxBackoff = 4
+
+			// The flusher is the only caller of wait, so delays need no lock.
+			var delays []time.Duration
+
+			jobRep := repositories.NewMemoryRepository()
+			buf := utils.NewBackgroundDeletionBufferWithWait(
+				rep,
+				jobRep,
+				appCfg,
+				newDeletionUIDGenerator(ctrl),
+				metrics.NewMetrics(),
+				func(_ context.Context, delay time.Duration) error {
+					delays = append(delays, delay)
+
+					return nil
+				},
+				logger.NewNop(),
+			)
+
+			job, err := buf.Push(context.Background(), []models.UID{"abc"}, userID)
+			require.NoError(t, err)
+			require.NoError(t, buf.Shutdown(context.Background()))
+
+			savedJob, err := jobRep.FindDeletionJobByID(context.Background(), job.ID)
+			require.NoError(t, err)
+			assert.Equal(t, []models.DeletionItem{
+				{UID: "abc", Status: testCase.status, Reason: testCase.reason},
+			}, savedJob.Items)
+			assert.Equal(t, testCase.delays, delays)
+		})
+	}
+}
+
+func TestBackgroundDeletionBuffer_ShutdownCancelsRetry(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	userID := uuid.New()
+	shortURL := models.NewShortURL(1, "https://example.com/1", "abc", userID)
+
+	rep := mocks.NewMockRepository(ctrl)
+	rep.EXPECT().FindAllByUserIDAndUIDs(gomock.Any(), userID, []models.UID{"abc"}).
+		Return([]*models.ShortURL{shortURL}, nil)
+	rep.EXPECT().BatchDelete(gomock.Any(), []*models.ShortURL{shortURL}).Return(errors.New("connection reset"))
+
+	appCfg := newIdleDeletionAppConfig()
+	appCfg.DeletionRetryMaxAttempts = 3
+
+	jobRep := repositories.NewMemoryRepository()
+	buf := utils.NewBackgroundDeletionBufferWithWait(
+		rep,
+		jobRep,
+		appCfg,
+		newDeletionUIDGenerator(ctrl),
+		metrics.NewMetrics(),
+		func(ctx context.Context, _ time.Duration) error {
+			<-ctx.Done()
+
+			return ctx.Err()
+		},
+		logger.NewNop(),
+	)
+
+	job, err := buf.Push(context.Background(), []models.UID{"abc"}, userID)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, buf.Shutdown(ctx), context.DeadlineExceeded)
+	require.NoError(t, buf.Shutdown(context.Background()))
+
+	// The cancelled batch is neither completed nor failed, so the next start resumes it.
+	savedJob, err := jobRep.FindDeletionJobByID(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []models.DeletionItem{
+		{UID: "abc", Status: models.DeletionStatusPending, Reason: ""},
+	}, savedJob.Items)
+}
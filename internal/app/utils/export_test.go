@@ -0,0 +1,7 @@
+package utils
+
+// NewBackgroundDeletionBufferWithWait makes the buffer with a wait between retries which tests control.
+var NewBackgroundDeletionBufferWithWait = newBackgroundDeletionBuffer
+
+// ExponentialBackoff is the retry delay shared by the deletion buffer and the webhook dispatcher.
+var ExponentialBackoff = exponentialBackoff
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/tmitry/shorturl/internal/app/logger"
+)
+
+var ErrIncorrectReloadInterval = errors.New("reload interval must be positive")
+
+/*
+FileWatcher reloads a file when isModified reports a change, checked every reload interval,
+or when the process receives SIGHUP. A panic of reload is logged and the watcher keeps going.
+Stop releases the ticker and the signal subscription.
+*/
+type FileWatcher struct {
+	name       string
+	path       string
+	isModified func() bool
+	reload     func() error
+	signals    chan os.Signal
+	ticker     *time.Ticker
+	stop       chan struct{}
+	stopped    chan struct{}
+	stopOnce   sync.Once
+	log        *logger.Logger
+}
+
+func NewFileWatcher(
+	name, path string,
+	reloadInterval time.Duration,
+	isModified func() bool,
+	reload func() error,
+	log *logger.Logger,
+) (*FileWatcher, error) {
+	if reloadInterval <= 0 {
+		return nil, ErrIncorrectReloadInterval
+	}
+
+	watcher := &FileWatcher{
+		name:       name,
+		path:       path,
+		isModified: isModified,
+		reload:     reload,
+		signals:    make(chan os.Signal, 1),
+		ticker:     time.NewTicker(reloadInterval),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+		stopOnce:   sync.Once{},
+		log:        log,
+	}
+
+	signal.Notify(watcher.signals, syscall.SIGHUP)
+
+	go watcher.watch()
+
+	return watcher, nil
+}
+
+// Stop stops watching and waits for a running reload to finish, it is safe to call it more than once.
+func (w *FileWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+
+	<-w.stopped
+}
+
+func (w *FileWatcher) watch() {
+	defer close(w.stopped)
+	defer w.ticker.Stop()
+	defer signal.Stop(w.signals)
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.signals:
+		case <-w.ticker.C:
+			if !w.isModified() {
+				continue
+			}
+		}
+
+		w.safeReload()
+	}
+}
+
+func (w *FileWatcher) safeReload() {
+	defer func() {
+		if r := recover(); r != nil {
+			w.log.Error(w.name+" watcher panicked", "path", w.path, "panic", r)
+		}
+	}()
+
+	if err := w.reload(); err != nil {
+		w.log.Error("failed to reload "+w.name, "path", w.path, logger.KeyError, err)
+
+		return
+	}
+
+	w.log.Info(w.name+" is reloaded", "path", w.path)
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+
+	// HealthCheckShutdown is the check which fails once the graceful shutdown begins.
+	HealthCheckShutdown = "shutdown"
+)
+
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// HealthCheck reports an error if the component can not serve requests. It must return once ctx is done.
+type HealthCheck func(ctx context.Context) error
+
+// HealthCheckReport is the result of one component check. Duration is in milliseconds.
+type HealthCheckReport struct {
+	Status   string `json:"status"`
+	Duration int64  `json:"duration_ms"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HealthReport is ok only if every check is ok.
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckReport `json:"checks"`
+}
+
+func (r HealthReport) IsOK() bool {
+	return r.Status == HealthStatusOK
+}
+
+/*
+HealthChecker aggregates checks of the components for the readiness probe. The checks run concurrently,
+each of them gets timeout to answer. Once the shutdown begins the report fails, so load balancers stop
+sending requests while in-flight ones finish.
+*/
+type HealthChecker struct {
+	mu             sync.RWMutex
+	isShuttingDown bool
+	names          []string
+	checks         map[string]HealthCheck
+	timeout        time.Duration
+}
+
+func NewHealthChecker(timeout time.Duration) *HealthChecker {
+	return &HealthChecker{
+		mu:             sync.RWMutex{},
+		isShuttingDown: false,
+		names:          []string{},
+		checks:         map[string]HealthCheck{},
+		timeout:        timeout,
+	}
+}
+
+// Register adds the check of the component. A check registered with the same name replaces the previous one.
+func (c *HealthChecker) Register(name string, check HealthCheck) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+
+	c.checks[name] = check
+}
+
+// BeginShutdown makes the following reports fail.
+func (c *HealthChecker) BeginShutdown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.isShuttingDown = true
+}
+
+func (c *HealthChecker) Check(ctx context.Context) *HealthReport {
+	c.mu.RLock()
+	isShuttingDown := c.isShuttingDown
+	names := append([]string(nil), c.names...)
+	checks := make(map[string]HealthCheck, len(c.checks))
+
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+
+	c.mu.RUnlock()
+
+	report := &HealthReport{
+		Status: HealthStatusOK,
+		Checks: make(map[string]HealthCheckReport, len(names)+1),
+	}
+
+	if isShuttingDown {
+		report.Status = HealthStatusFail
+		report.Checks[HealthCheckShutdown] = HealthCheckReport{
+			Status:   HealthStatusFail,
+			Duration: 0,
+			Error:    ErrShuttingDown.Error(),
+		}
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for _, name := range names {
+		name, check := name, checks[name]
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			checkReport := c.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Checks[name] = checkReport
+
+			if checkReport.Status != HealthStatusOK {
+				report.Status = HealthStatusFail
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+func (c *HealthChecker) run(ctx context.Context, check HealthCheck) HealthCheckReport {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+
+	checkReport := HealthCheckReport{
+		Status:   HealthStatusOK,
+		Duration: time.Since(start).Milliseconds(),
+		Error:    "",
+	}
+
+	if err != nil {
+		checkReport.Status = HealthStatusFail
+		checkReport.Error = err.Error()
+	}
+
+	return checkReport
+}
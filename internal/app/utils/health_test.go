@@ -0,0 +1,111 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+func TestHealthChecker_Check(t *testing.T) {
+	t.Parallel()
+
+	okCheck := func(context.Context) error {
+		return nil
+	}
+
+	failedCheck := func(context.Context) error {
+		return errors.New("connection refused")
+	}
+
+	// stuckCheck answers only when its timeout passes.
+	stuckCheck := func(ctx context.Context) error {
+		<-ctx.Done()
+
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		name            string
+		checks          map[string]utils.HealthCheck
+		isShuttingDown  bool
+		status          string
+		failedChecks    map[string]string
+		succeededChecks []string
+	}{
+		{
+			name:            "test case 1: no checks",
+			checks:          map[string]utils.HealthCheck{},
+			isShuttingDown:  false,
+			status:          utils.HealthStatusOK,
+			failedChecks:    map[string]string{},
+			succeededChecks: []string{},
+		},
+		{
+			name:            "test case 2: all checks succeed",
+			checks:          map[string]utils.HealthCheck{"database": okCheck, "deletion_worker": okCheck},
+			isShuttingDown:  false,
+			status:          utils.HealthStatusOK,
+			failedChecks:    map[string]string{},
+			succeededChecks: []string{"database", "deletion_worker"},
+		},
+		{
+			name:            "test case 3: one check fails",
+			checks:          map[string]utils.HealthCheck{"database": failedCheck, "deletion_worker": okCheck},
+			isShuttingDown:  false,
+			status:          utils.HealthStatusFail,
+			failedChecks:    map[string]string{"database": "connection refused"},
+			succeededChecks: []string{"deletion_worker"},
+		},
+		{
+			name:            "test case 4: check times out",
+			checks:          map[string]utils.HealthCheck{"database": stuckCheck},
+			isShuttingDown:  false,
+			status:          utils.HealthStatusFail,
+			failedChecks:    map[string]string{"database": context.DeadlineExceeded.Error()},
+			succeededChecks: []string{},
+		},
+		{
+			name:            "test case 5: shutting down",
+			checks:          map[string]utils.HealthCheck{"database": okCheck},
+			isShuttingDown:  true,
+			status:          utils.HealthStatusFail,
+			failedChecks:    map[string]string{utils.HealthCheckShutdown: utils.ErrShuttingDown.Error()},
+			succeededChecks: []string{"database"},
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			checker := utils.NewHealthChecker(time.Millisecond)
+
+			for name, check := range testCase.checks {
+				checker.Register(name, check)
+			}
+
+			if testCase.isShuttingDown {
+				checker.BeginShutdown()
+			}
+
+			report := checker.Check(context.Background())
+
+			assert.Equal(t, testCase.status, report.Status)
+			assert.Len(t, report.Checks, len(testCase.failedChecks)+len(testCase.succeededChecks))
+
+			for name, checkError := range testCase.failedChecks {
+				assert.Equal(t, utils.HealthStatusFail, report.Checks[name].Status, name)
+				assert.Equal(t, checkError, report.Checks[name].Error, name)
+			}
+
+			for _, name := range testCase.succeededChecks {
+				assert.Equal(t, utils.HealthStatusOK, report.Checks[name].Status, name)
+				assert.Empty(t, report.Checks[name].Error, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FieldError is a violation of the schema by the value at Pointer (RFC 6901), "" is the whole document.
+type FieldError struct {
+	Pointer string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	if e.Pointer == "" {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%s: %s", e.Pointer, e.Message)
+}
+
+// SchemaValidationError lists every violation of the schema found in the document.
+type SchemaValidationError struct {
+	Fields []FieldError
+}
+
+func (e *SchemaValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+
+	for _, field := range e.Fields {
+		messages = append(messages, field.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+/*
+JSONSchema is the subset of the OpenAPI 3.0 schema object which is used by the request bodies of the service:
+types, required and nested properties, items, enums, nullable values and bounds of numbers, strings and arrays.
+Other keywords are accepted and ignored.
+*/
+type JSONSchema struct {
+	Ref        string                 `json:"$ref"`
+	Type       string                 `json:"type"`
+	Properties map[string]*JSONSchema `json:"properties"`
+	Required   []string               `json:"required"`
+	Items      *JSONSchema            `json:"items"`
+	Enum       []interface{}          `json:"enum"`
+	Nullable   bool                   `json:"nullable"`
+	Minimum    *float64               `json:"minimum"`
+	Maximum    *float64               `json:"maximum"`
+	MinLength  *int                   `json:"minLength"`
+	MaxLength  *int                   `json:"maxLength"`
+	MinItems   *int                   `json:"minItems"`
+	MaxItems   *int                   `json:"maxItems"`
+}
+
+// ValidateJSON validates the document against the schema. References are resolved in definitions.
+func ValidateJSON(schema *JSONSchema, definitions map[string]*JSONSchema, document []byte) error {
+	decoder := json.NewDecoder(strings.NewReader(string(document)))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("failed to decode JSON: %w", err)
+	}
+
+	validator := schemaValidator{definitions: definitions, fields: nil}
+	validator.validate(schema, value, "")
+
+	if len(validator.fields) > 0 {
+		return &SchemaValidationError{Fields: validator.fields}
+	}
+
+	return nil
+}
+
+type schemaValidator struct {
+	definitions map[string]*JSONSchema
+	fields      []FieldError
+}
+
+func (v *schemaValidator) fail(pointer, format string, args ...interface{}) {
+	v.fields = append(v.fields, FieldError{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *schemaValidator) resolve(schema *JSONSchema) *JSONSchema {
+	for schema != nil && schema.Ref != "" {
+		schema = v.definitions[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+
+	return schema
+}
+
+func (v *schemaValidator) validate(schema *JSONSchema, value interface{}, pointer string) {
+	schema = v.resolve(schema)
+	if schema == nil {
+		return
+	}
+
+	if value == nil {
+		if !schema.Nullable && schema.Type != "" {
+			v.fail(pointer, "must not be null")
+		}
+
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		v.validateObject(schema, value, pointer)
+	case "array":
+		v.validateArray(schema, value, pointer)
+	case "string":
+		v.validateString(schema, value, pointer)
+	case "integer", "number":
+		v.validateNumber(schema, value, pointer)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.fail(pointer, "must be a boolean")
+		}
+	}
+
+	if len(schema.Enum) > 0 && !isEnumValue(schema.Enum, value) {
+		v.fail(pointer, "must be one of %s", formatEnum(schema.Enum))
+	}
+}
+
+func (v *schemaValidator) validateObject(schema *JSONSchema, value interface{}, pointer string) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		v.fail(pointer, "must be an object")
+
+		return
+	}
+
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			v.fail(pointer+"/"+escapePointer(name), "is required")
+		}
+	}
+
+	names := make([]string, 0, len(object))
+
+	for name := range object {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if property, ok := schema.Properties[name]; ok {
+			v.validate(property, object[name], pointer+"/"+escapePointer(name))
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(schema *JSONSchema, value interface{}, pointer string) {
+	items, ok := value.([]interface{})
+	if !ok {
+		v.fail(pointer, "must be an array")
+
+		return
+	}
+
+	if schema.MinItems != nil && len(items) < *schema.MinItems {
+		v.fail(pointer, "must have at least %d items", *schema.MinItems)
+	}
+
+	if schema.MaxItems != nil && len(items) > *schema.MaxItems {
+		v.fail(pointer, "must have at most %d items", *schema.MaxItems)
+	}
+
+	for index, item := range items {
+		v.validate(schema.Items, item, pointer+"/"+strconv.Itoa(index))
+	}
+}
+
+func (v *schemaValidator) validateString(schema *JSONSchema, value interface{}, pointer string) {
+	s, ok := value.(string)
+	if !ok {
+		v.fail(pointer, "must be a string")
+
+		return
+	}
+
+	length := len([]rune(s))
+
+	if schema.MinLength != nil && length < *schema.MinLength {
+		v.fail(pointer, "length must be at least %d", *schema.MinLength)
+	}
+
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		v.fail(pointer, "length must be at most %d", *schema.MaxLength)
+	}
+}
+
+func (v *schemaValidator) validateNumber(schema *JSONSchema, value interface{}, pointer string) {
+	number, ok := value.(json.Number)
+	if !ok {
+		v.fail(pointer, "must be a %s", schema.Type)
+
+		return
+	}
+
+	if schema.Type == "integer" {
+		if _, err := number.Int64(); err != nil {
+			v.fail(pointer, "must be an integer")
+
+			return
+		}
+	}
+
+	f, err := number.Float64()
+	if err != nil {
+		v.fail(pointer, "must be a number")
+
+		return
+	}
+
+	if schema.Minimum != nil && f < *schema.Minimum {
+		v.fail(pointer, "must be greater than or equal to %v", *schema.Minimum)
+	}
+
+	if schema.Maximum != nil && f > *schema.Maximum {
+		v.fail(pointer, "must be less than or equal to %v", *schema.Maximum)
+	}
+}
+
+func isEnumValue(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func formatEnum(enum []interface{}) string {
+	values := make([]string, 0, len(enum))
+
+	for _, value := range enum {
+		values = append(values, strconv.Quote(fmt.Sprint(value)))
+	}
+
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// escapePointer escapes a reference token of a JSON pointer.
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const openAPIContentTypeJSON = "application/json"
+
+type openAPIOperation struct {
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema *JSONSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+type openAPIDocument struct {
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]*JSONSchema `json:"schemas"`
+	} `json:"components"`
+}
+
+// OpenAPISpec finds operations of the OpenAPI document and validates request bodies against their schemas.
+type OpenAPISpec struct {
+	document []byte
+	parsed   *openAPIDocument
+}
+
+func NewOpenAPISpec(document []byte) (*OpenAPISpec, error) {
+	parsed := &openAPIDocument{}
+	if err := json.Unmarshal(document, parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+
+	return &OpenAPISpec{
+		document: document,
+		parsed:   parsed,
+	}, nil
+}
+
+func (s *OpenAPISpec) Document() []byte {
+	return s.document
+}
+
+// HasOperation reports whether the document describes the method of the path template, e.g. "/api/user/webhooks/{id}".
+func (s *OpenAPISpec) HasOperation(method, pathTemplate string) bool {
+	_, ok := s.parsed.Paths[pathTemplate][strings.ToLower(method)]
+
+	return ok
+}
+
+// Operations lists the described operations as "METHOD /path/template".
+func (s *OpenAPISpec) Operations() []string {
+	var operations []string
+
+	for pathTemplate, methods := range s.parsed.Paths {
+		for method := range methods {
+			operations = append(operations, strings.ToUpper(method)+" "+pathTemplate)
+		}
+	}
+
+	return operations
+}
+
+/*
+ValidateRequestBody validates the JSON body of a request to the path against the schema of the operation.
+A body of an operation without a JSON schema and an empty optional body are not validated.
+A violation of the schema is returned as *SchemaValidationError.
+*/
+func (s *OpenAPISpec) ValidateRequestBody(method, path string, body []byte) error {
+	operation := s.findOperation(method, path)
+	if operation == nil || operation.RequestBody == nil {
+		return nil
+	}
+
+	content, ok := operation.RequestBody.Content[openAPIContentTypeJSON]
+	if !ok || content.Schema == nil {
+		return nil
+	}
+
+	if len(strings.TrimSpace(string(body))) == 0 {
+		if operation.RequestBody.Required {
+			return &SchemaValidationError{Fields: []FieldError{{Pointer: "", Message: "request body is required"}}}
+		}
+
+		return nil
+	}
+
+	return ValidateJSON(content.Schema, s.parsed.Components.Schemas, body)
+}
+
+// findOperation matches the path against path templates, a literal segment is preferred to a parameter.
+func (s *OpenAPISpec) findOperation(method, path string) *openAPIOperation {
+	segments := strings.Split(path, "/")
+	method = strings.ToLower(method)
+
+	var (
+		found      *openAPIOperation
+		foundScore = -1
+	)
+
+	for pathTemplate, methods := range s.parsed.Paths {
+		operation, ok := methods[method]
+		if !ok {
+			continue
+		}
+
+		score, ok := matchPathTemplate(strings.Split(pathTemplate, "/"), segments)
+		if ok && score > foundScore {
+			found = operation
+			foundScore = score
+		}
+	}
+
+	return found
+}
+
+// matchPathTemplate returns the number of literal segments of the matched template.
+func matchPathTemplate(templateSegments, segments []string) (int, bool) {
+	if len(templateSegments) != len(segments) {
+		return 0, false
+	}
+
+	score := 0
+
+	for i, templateSegment := range templateSegments {
+		if strings.HasPrefix(templateSegment, "{") && strings.HasSuffix(templateSegment, "}") {
+			if segments[i] == "" {
+				return 0, false
+			}
+
+			continue
+		}
+
+		if templateSegment != segments[i] {
+			return 0, false
+		}
+
+		score++
+	}
+
+	return score, true
+}
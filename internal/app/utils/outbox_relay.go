@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+)
+
+/*
+BackgroundOutboxRelay publishes events recorded in the outbox to webhooks in the order they were recorded.
+A batch is removed from the outbox only after all its events are dispatched, otherwise the whole batch
+is published again, so an event may be delivered more than once. Receivers deduplicate events by their ID.
+*/
+type BackgroundOutboxRelay struct {
+	rep               repositories.OutboxRepository
+	webhookDispatcher WebhookDispatcher
+	batchSize         int
+	interval          time.Duration
+	log               *logger.Logger
+	stop              chan struct{}
+	stopped           chan struct{}
+	stopOnce          sync.Once
+}
+
+func NewBackgroundOutboxRelay(
+	rep repositories.OutboxRepository,
+	webhookDispatcher WebhookDispatcher,
+	batchSize int,
+	interval time.Duration,
+	log *logger.Logger,
+) *BackgroundOutboxRelay {
+	relay := &BackgroundOutboxRelay{
+		rep:               rep,
+		webhookDispatcher: webhookDispatcher,
+		batchSize:         batchSize,
+		interval:          interval,
+		log:               log,
+		stop:              make(chan struct{}),
+		stopped:           make(chan struct{}),
+		stopOnce:          sync.Once{},
+	}
+
+	relay.newWorker()
+
+	return relay
+}
+
+// PublishPending publishes batches until the outbox is empty and returns the number of published events.
+func (relay *BackgroundOutboxRelay) PublishPending(ctx context.Context) (int, error) {
+	total := 0
+
+	for {
+		published, err := relay.rep.PublishOutbox(ctx, relay.batchSize, func(messages []*models.OutboxMessage) error {
+			for _, message := range messages {
+				if err := relay.webhookDispatcher.Dispatch(ctx, &message.Event); err != nil {
+					return fmt.Errorf("failed to publish outbox message %d: %w", message.ID, err)
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return total, fmt.Errorf("failed to publish outbox: %w", err)
+		}
+
+		total += published
+
+		if published < relay.batchSize {
+			return total, nil
+		}
+	}
+}
+
+// Shutdown stops the worker after the batch in progress. Events left in the outbox are published on the next start.
+func (relay *BackgroundOutboxRelay) Shutdown(ctx context.Context) error {
+	relay.stopOnce.Do(func() {
+		close(relay.stop)
+	})
+
+	select {
+	case <-relay.stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to stop outbox relay: %w", ctx.Err())
+	}
+}
+
+func (relay *BackgroundOutboxRelay) newWorker() {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				relay.newWorker()
+				relay.log.Error("outbox relay panicked", "panic", r)
+			}
+		}()
+
+		ticker := time.NewTicker(relay.interval)
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := relay.PublishPending(context.Background()); err != nil {
+					relay.log.Error("failed to relay outbox", logger.KeyError, err)
+				}
+			case <-relay.stop:
+				ticker.Stop()
+				close(relay.stopped)
+
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,165 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/mocks"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+// testRelayInterval keeps the worker of the relay idle, so tests publish explicitly.
+const testRelayInterval = time.Hour
+
+type outboxTestRepository interface {
+	repositories.Repository
+	repositories.OutboxRepository
+}
+
+func TestBackgroundOutboxRelay_PublishPending(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		rep  func(t *testing.T) outboxTestRepository
+	}{
+		{
+			name: "memory repository",
+			rep: func(t *testing.T) outboxTestRepository {
+				t.Helper()
+
+				return repositories.NewMemoryRepository()
+			},
+		},
+		{
+			name: "file repository",
+			rep: func(t *testing.T) outboxTestRepository {
+				t.Helper()
+
+				return repositories.NewFileRepository(filepath.Join(t.TempDir(), "storage.json"), logger.NewNop())
+			},
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			t.Cleanup(ctrl.Finish)
+
+			rep := testCase.rep(t)
+			userID := uuid.New()
+
+			shortURL1 := models.NewShortURL(0, "https://example.com/1", "uid1", userID)
+			shortURL1.CanonicalURL = shortURL1.URL
+			require.NoError(t, rep.Save(context.Background(), shortURL1, 0))
+
+			duplicate := models.NewShortURL(0, "https://example.com/1", "uid2", userID)
+			duplicate.CanonicalURL = duplicate.URL
+			shortURL3 := models.NewShortURL(0, "https://example.com/3", "uid3", userID)
+			shortURL3.CanonicalURL = shortURL3.URL
+			require.NoError(t, rep.BatchSave(context.Background(), []*models.ShortURL{duplicate, shortURL3}, 0))
+
+			require.NoError(t, rep.BatchDelete(context.Background(), []*models.ShortURL{shortURL1}))
+
+			var events []models.WebhookEvent
+
+			webhookDispatcher := mocks.NewMockWebhookDispatcher(ctrl)
+			gomock.InOrder(
+				// The first attempt fails, so the whole batch is published again.
+				webhookDispatcher.EXPECT().Dispatch(gomock.Any(), gomock.Any()).Return(nil),
+				webhookDispatcher.EXPECT().Dispatch(gomock.Any(), gomock.Any()).Return(errors.New("unavailable")),
+				webhookDispatcher.EXPECT().Dispatch(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, event *models.WebhookEvent) error {
+						events = append(events, *event)
+
+						return nil
+					}).Times(3),
+			)
+
+			relay := utils.NewBackgroundOutboxRelay(rep, webhookDispatcher, 10, testRelayInterval, logger.NewNop())
+
+			_, err := relay.PublishPending(context.Background())
+			require.Error(t, err)
+
+			published, err := relay.PublishPending(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, 3, published)
+
+			require.Len(t, events, 3)
+			assert.Equal(t, models.WebhookEventLinkCreated, events[0].Type)
+			assert.Equal(t, models.UID("uid1"), events[0].ShortURL.UID)
+			assert.Equal(t, models.WebhookEventLinkCreated, events[1].Type)
+			assert.Equal(t, models.UID("uid3"), events[1].ShortURL.UID)
+			assert.Equal(t, models.WebhookEventLinkDeleted, events[2].Type)
+			assert.Equal(t, models.UID("uid1"), events[2].ShortURL.UID)
+			assert.True(t, events[2].ShortURL.IsDeleted)
+
+			published, err = relay.PublishPending(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, 0, published)
+		})
+	}
+}
+
+func TestFileRepository_OutboxSurvivesRestart(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	path := filepath.Join(t.TempDir(), "storage.json")
+	userID := uuid.New()
+
+	rep := repositories.NewFileRepository(path, logger.NewNop())
+
+	for _, uid := range []models.UID{"uid1", "uid2"} {
+		shortURL := models.NewShortURL(0, models.URL("https://example.com/"+string(uid)), uid, userID)
+		shortURL.CanonicalURL = shortURL.URL
+		require.NoError(t, rep.Save(context.Background(), shortURL, 0))
+	}
+
+	webhookDispatcher := mocks.NewMockWebhookDispatcher(ctrl)
+	webhookDispatcher.EXPECT().Dispatch(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, event *models.WebhookEvent) error {
+			assert.Equal(t, models.UID("uid1"), event.ShortURL.UID)
+
+			return nil
+		})
+	webhookDispatcher.EXPECT().Dispatch(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, event *models.WebhookEvent) error {
+			assert.Equal(t, models.UID("uid2"), event.ShortURL.UID)
+
+			return nil
+		})
+
+	// The first event is published before the restart, the second one is left pending.
+	published, err := rep.PublishOutbox(context.Background(), 1, func(messages []*models.OutboxMessage) error {
+		return webhookDispatcher.Dispatch(context.Background(), &messages[0].Event)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+
+	relay := utils.NewBackgroundOutboxRelay(
+		repositories.NewFileRepository(path, logger.NewNop()),
+		webhookDispatcher,
+		1,
+		testRelayInterval,
+		logger.NewNop(),
+	)
+
+	published, err = relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+}
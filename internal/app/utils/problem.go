@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+)
+
+const (
+	ContentTypeProblemJSON = "application/problem+json"
+
+	// ProblemTypePrefix prefixes the code of the problem in its type URI.
+	ProblemTypePrefix = "urn:shorturl:problem:"
+)
+
+// Codes of problems. They are stable, so clients can rely on them instead of detail messages.
+const (
+	ProblemCodeBadRequest         = "bad_request"
+	ProblemCodeIncorrectJSON      = "incorrect_json"
+	ProblemCodeValidationFailed   = "validation_failed"
+	ProblemCodeURLPolicyViolation = "url_policy_violation"
+	ProblemCodeBatchTooLarge      = "batch_too_large"
+	ProblemCodeRequestTooLarge    = "request_too_large"
+	ProblemCodeUnauthorized       = "unauthorized"
+	ProblemCodeForbidden          = "forbidden"
+	ProblemCodeQuotaExceeded      = "quota_exceeded"
+	ProblemCodeUserIsBanned       = "user_is_banned"
+	ProblemCodeNotFound           = "not_found"
+	ProblemCodeURLDuplicate       = "url_duplicate"
+	ProblemCodeConflict           = "conflict"
+	ProblemCodeTooManyRequests    = "too_many_requests"
+	ProblemCodeInternal           = "internal_error"
+	ProblemCodeUnavailable        = "service_unavailable"
+)
+
+type problemContextKey struct{}
+
+// ProblemField points to the invalid member of the request body with a JSON pointer (RFC 6901).
+type ProblemField struct {
+	Pointer string `json:"pointer"`
+	Detail  string `json:"detail"`
+}
+
+/*
+Problem is an error response in the format of RFC 7807 with the extension members: Code is the stable
+machine-readable code of the problem, Errors point to invalid members of the request body and ShortURL
+is the existing short URL of a duplicate. Result repeats ShortURL where the successful response has it,
+so clients reading the result of a conflict keep working.
+*/
+type Problem struct {
+	Type     string         `json:"type"`
+	Title    string         `json:"title"`
+	Status   int            `json:"status"`
+	Detail   string         `json:"detail,omitempty"`
+	Code     string         `json:"code"`
+	Errors   []ProblemField `json:"errors,omitempty"`
+	ShortURL models.URL     `json:"short_url,omitempty"`
+	Result   models.URL     `json:"result,omitempty"`
+}
+
+func NewProblem(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:     ProblemTypePrefix + code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Code:     code,
+		Errors:   nil,
+		ShortURL: "",
+		Result:   "",
+	}
+}
+
+// NewInternalProblem hides the cause of the failure from the client, the cause is logged by the caller.
+func NewInternalProblem() *Problem {
+	return NewProblem(http.StatusInternalServerError, ProblemCodeInternal, "")
+}
+
+// NewValidationProblem lists field errors of the request body.
+func NewValidationProblem(detail string, fields []FieldError) *Problem {
+	problem := NewProblem(http.StatusBadRequest, ProblemCodeValidationFailed, detail)
+
+	for _, field := range fields {
+		problem.Errors = append(problem.Errors, ProblemField{Pointer: field.Pointer, Detail: field.Message})
+	}
+
+	return problem
+}
+
+// ProblemCode maps errors of repositories and validation to codes of problems.
+func ProblemCode(err error) string {
+	var validationErr *SchemaValidationError
+
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		return ProblemCodeNotFound
+	case errors.Is(err, repositories.ErrURLDuplicate):
+		return ProblemCodeURLDuplicate
+	case errors.As(err, &validationErr):
+		return ProblemCodeValidationFailed
+	default:
+		return ProblemCodeInternal
+	}
+}
+
+// WithProblemDetails marks the request to be answered with problem details instead of plain text errors.
+func WithProblemDetails(ctx context.Context) context.Context {
+	return context.WithValue(ctx, problemContextKey{}, true)
+}
+
+func IsProblemDetails(ctx context.Context) bool {
+	isProblemDetails, _ := ctx.Value(problemContextKey{}).(bool)
+
+	return isProblemDetails
+}
+
+/*
+WriteError answers the request with the problem. Requests marked by WithProblemDetails get
+application/problem+json, others get the plain text "Title: detail" as before.
+*/
+func WriteError(writer http.ResponseWriter, request *http.Request, problem *Problem) {
+	if IsProblemDetails(request.Context()) {
+		WriteProblem(writer, request, problem)
+
+		return
+	}
+
+	http.Error(writer, problem.String(), problem.Status)
+}
+
+// WriteProblem answers the request with application/problem+json, failures are logged by the logger of the request.
+func WriteProblem(writer http.ResponseWriter, request *http.Request, problem *Problem) {
+	var buf bytes.Buffer
+	jsonEncoder := json.NewEncoder(&buf)
+	jsonEncoder.SetEscapeHTML(false)
+
+	if err := jsonEncoder.Encode(problem); err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		logger.FromContext(request.Context()).Error("failed to encode problem", logger.KeyError, err)
+
+		return
+	}
+
+	writer.Header().Set("Content-Type", ContentTypeProblemJSON)
+	writer.Header().Set("X-Content-Type-Options", "nosniff")
+	writer.WriteHeader(problem.Status)
+
+	if _, err := buf.WriteTo(writer); err != nil {
+		logger.FromContext(request.Context()).Error("failed to write problem", logger.KeyError, err)
+	}
+}
+
+// String formats the problem as the plain text error.
+func (p Problem) String() string {
+	text := p.Title
+	if p.Detail != "" {
+		text = fmt.Sprintf("%s: %s", text, p.Detail)
+	}
+
+	if len(p.Errors) > 0 {
+		fields := make([]string, 0, len(p.Errors))
+
+		for _, field := range p.Errors {
+			fields = append(fields, FieldError{Pointer: field.Pointer, Message: field.Detail}.Error())
+		}
+
+		text = fmt.Sprintf("%s: %s", text, strings.Join(fields, "; "))
+	}
+
+	if p.ShortURL != "" {
+		text = fmt.Sprintf("%s: %s", text, p.ShortURL)
+	}
+
+	return text
+}
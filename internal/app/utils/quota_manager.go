@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+)
+
+type QuotaManager interface {
+	GetPlan(userID uuid.UUID) *models.Plan
+
+	// GetQuota returns the plan of the user together with the number of the user's active links.
+	GetQuota(ctx context.Context, userID uuid.UUID) (*models.Quota, error)
+}
+
+// ConfigQuotaManager assigns plans to users according to QuotaConfig.
+type ConfigQuotaManager struct {
+	defaultPlan *models.Plan
+	userPlans   map[uuid.UUID]*models.Plan
+	rep         repositories.Repository
+}
+
+func NewConfigQuotaManager(
+	quotaCfg *configs.QuotaConfig,
+	rep repositories.Repository,
+	log *logger.Logger,
+) *ConfigQuotaManager {
+	plans := make(map[string]*models.Plan, len(quotaCfg.Plans))
+	for name, planCfg := range quotaCfg.Plans {
+		plans[name] = models.NewPlan(
+			name,
+			planCfg.MaxLinks,
+			planCfg.MaxBatchSize,
+			time.Duration(planCfg.MaxTTL)*time.Second,
+		)
+	}
+
+	defaultPlan, ok := plans[quotaCfg.DefaultPlan]
+	if !ok {
+		log.Panic("unknown default plan", "plan", quotaCfg.DefaultPlan)
+	}
+
+	userPlans := make(map[uuid.UUID]*models.Plan, len(quotaCfg.UserPlans))
+
+	for rawUserID, planName := range quotaCfg.UserPlans {
+		userID, err := uuid.Parse(rawUserID)
+		if err != nil {
+			log.Panic("incorrect user id in user plans", "user_id", rawUserID, logger.KeyError, err)
+		}
+
+		plan, ok := plans[planName]
+		if !ok {
+			log.Panic("unknown plan of user", "plan", planName, "user_id", rawUserID)
+		}
+
+		userPlans[userID] = plan
+	}
+
+	return &ConfigQuotaManager{
+		defaultPlan: defaultPlan,
+		userPlans:   userPlans,
+		rep:         rep,
+	}
+}
+
+func (m *ConfigQuotaManager) GetPlan(userID uuid.UUID) *models.Plan {
+	if plan, ok := m.userPlans[userID]; ok {
+		return plan
+	}
+
+	return m.defaultPlan
+}
+
+func (m *ConfigQuotaManager) GetQuota(ctx context.Context, userID uuid.UUID) (*models.Quota, error) {
+	usedLinks, err := m.rep.CountActiveByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count links: %w", err)
+	}
+
+	return models.NewQuota(m.GetPlan(userID), usedLinks), nil
+}
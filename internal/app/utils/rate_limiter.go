@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+)
+
+// RateLimitRule allows Limit requests per Period. The bucket holds up to Limit tokens and refills continuously.
+type RateLimitRule struct {
+	Limit  int
+	Period time.Duration
+}
+
+func NewRateLimitRule(limit int, period time.Duration) RateLimitRule {
+	return RateLimitRule{
+		Limit:  limit,
+		Period: period,
+	}
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+type RateLimiter interface {
+	/*
+		Take takes a token from each bucket of keys only if all of them have one, so a request rejected
+		by one bucket costs nothing in the others. The result describes the most restrictive bucket.
+	*/
+	Take(ctx context.Context, keys []string, rule RateLimitRule) (RateLimitResult, error)
+}
+
+// takeTokens refills buckets up to now and takes one token from each of them if all of them have one.
+func takeTokens(buckets []*models.RateLimitBucket, rule RateLimitRule, now time.Time) RateLimitResult {
+	capacity := float64(rule.Limit)
+	tokensPerSecond := capacity / rule.Period.Seconds()
+
+	isAllowed := true
+
+	for _, bucket := range buckets {
+		if bucket.UpdatedAt.IsZero() {
+			bucket.Tokens = capacity
+		} else if elapsed := now.Sub(bucket.UpdatedAt).Seconds(); elapsed > 0 {
+			bucket.Tokens = math.Min(capacity, bucket.Tokens+elapsed*tokensPerSecond)
+		}
+
+		bucket.UpdatedAt = now
+		isAllowed = isAllowed && bucket.Tokens >= 1
+	}
+
+	var restrictive *RateLimitResult
+
+	for _, bucket := range buckets {
+		result := RateLimitResult{
+			Allowed:    isAllowed,
+			Limit:      rule.Limit,
+			Remaining:  0,
+			RetryAfter: 0,
+			ResetAfter: 0,
+		}
+
+		if isAllowed {
+			bucket.Tokens--
+		} else if bucket.Tokens < 1 {
+			result.RetryAfter = secondsToDuration((1 - bucket.Tokens) / tokensPerSecond)
+		}
+
+		result.Remaining = int(math.Floor(bucket.Tokens))
+		result.ResetAfter = secondsToDuration((capacity - bucket.Tokens) / tokensPerSecond)
+
+		if restrictive == nil || result.RetryAfter > restrictive.RetryAfter ||
+			result.RetryAfter == restrictive.RetryAfter && result.Remaining < restrictive.Remaining {
+			restrictive = &result
+		}
+	}
+
+	return *restrictive
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
+
+// MemoryRateLimiter keeps buckets in the process memory. Buckets which are full again are pruned periodically.
+type MemoryRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*models.RateLimitBucket
+	periods   map[string]time.Duration
+	prunedAt  time.Time
+	pruneTime time.Duration
+}
+
+func NewMemoryRateLimiter(pruneTime time.Duration) *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		mu:        sync.Mutex{},
+		buckets:   map[string]*models.RateLimitBucket{},
+		periods:   map[string]time.Duration{},
+		prunedAt:  time.Now(),
+		pruneTime: pruneTime,
+	}
+}
+
+func (l *MemoryRateLimiter) Take(_ context.Context, keys []string, rule RateLimitRule) (RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(l.prunedAt) >= l.pruneTime {
+		l.prune(now)
+	}
+
+	buckets := make([]*models.RateLimitBucket, 0, len(keys))
+
+	for _, key := range keys {
+		bucket, ok := l.buckets[key]
+		if !ok {
+			bucket = models.NewRateLimitBucket(key)
+			l.buckets[key] = bucket
+		}
+
+		l.periods[key] = rule.Period
+		buckets = append(buckets, bucket)
+	}
+
+	return takeTokens(buckets, rule, now), nil
+}
+
+func (l *MemoryRateLimiter) prune(now time.Time) {
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.UpdatedAt) >= l.periods[key] {
+			delete(l.buckets, key)
+			delete(l.periods, key)
+		}
+	}
+
+	l.prunedAt = now
+}
+
+// RepositoryRateLimiter keeps buckets in a shared storage, so that several replicas enforce common limits.
+type RepositoryRateLimiter struct {
+	rep       repositories.RateLimitRepository
+	mu        sync.Mutex
+	prunedAt  time.Time
+	pruneTime time.Duration
+}
+
+func NewRepositoryRateLimiter(rep repositories.RateLimitRepository, pruneTime time.Duration) *RepositoryRateLimiter {
+	return &RepositoryRateLimiter{
+		rep:       rep,
+		mu:        sync.Mutex{},
+		prunedAt:  time.Now(),
+		pruneTime: pruneTime,
+	}
+}
+
+func (l *RepositoryRateLimiter) Take(ctx context.Context, keys []string, rule RateLimitRule) (RateLimitResult, error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	if now.Sub(l.prunedAt) >= l.pruneTime {
+		l.prunedAt = now
+
+		if err := l.rep.DeleteStaleRateLimitBuckets(ctx, now.Add(-l.pruneTime)); err != nil {
+			l.mu.Unlock()
+
+			return RateLimitResult{}, fmt.Errorf("failed to prune rate limit buckets: %w", err)
+		}
+	}
+	l.mu.Unlock()
+
+	var result RateLimitResult
+
+	err := l.rep.UpdateRateLimitBuckets(ctx, keys, func(buckets []*models.RateLimitBucket) {
+		result = takeTokens(buckets, rule, now)
+	})
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("failed to take token: %w", err)
+	}
+
+	return result, nil
+}
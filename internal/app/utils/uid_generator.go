@@ -2,12 +2,12 @@ package utils
 
 import (
 	"fmt"
-	"log"
 	"regexp"
 	"sync"
 	"time"
 
 	"github.com/speps/go-hashids/v2"
+	"github.com/tmitry/shorturl/internal/app/logger"
 	"github.com/tmitry/shorturl/internal/app/models"
 )
 
@@ -24,14 +24,14 @@ type HashidsUIDGenerator struct {
 	mu            sync.Mutex
 }
 
-func NewHashidsUIDGenerator(hashMinLength int, hashSalt string) *HashidsUIDGenerator {
+func NewHashidsUIDGenerator(hashMinLength int, hashSalt string, log *logger.Logger) *HashidsUIDGenerator {
 	hd := hashids.NewData()
 	hd.Salt = hashSalt
 	hd.MinLength = hashMinLength
 
 	encoder, err := hashids.NewWithData(hd)
 	if err != nil {
-		log.Panic(err)
+		log.Panic("failed to create uid generator", logger.KeyError, err)
 	}
 
 	return &HashidsUIDGenerator{
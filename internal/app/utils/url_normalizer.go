@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	netUrl "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"golang.org/x/net/idna"
+)
+
+type URLNormalizer interface {
+	Normalize(url models.URL) (models.URL, error)
+}
+
+/*
+ConfigurableURLNormalizer builds the canonical form of a URL which is used for deduplication.
+It lowercases scheme and host, converts internationalized host names to punycode, drops default ports,
+normalizes percent-encoding of the path, sorts query parameters and optionally strips tracking parameters and the fragment.
+*/
+type ConfigurableURLNormalizer struct {
+	stripTrackingParams bool
+	trackingParams      []string
+	stripFragment       bool
+}
+
+func NewConfigurableURLNormalizer(policyCfg *configs.PolicyConfig) *ConfigurableURLNormalizer {
+	return &ConfigurableURLNormalizer{
+		stripTrackingParams: policyCfg.StripTrackingParams,
+		trackingParams:      policyCfg.TrackingParams,
+		stripFragment:       policyCfg.StripFragment,
+	}
+}
+
+func (n *ConfigurableURLNormalizer) Normalize(url models.URL) (models.URL, error) {
+	parsedURL, err := netUrl.Parse(url.String())
+	if err != nil {
+		return "", fmt.Errorf("failed to parse url: %w", err)
+	}
+
+	parsedURL.Scheme = strings.ToLower(parsedURL.Scheme)
+
+	if parsedURL.Opaque != "" || parsedURL.Host == "" {
+		return models.URL(parsedURL.String()), nil
+	}
+
+	host, err := toASCIIHost(strings.TrimSuffix(parsedURL.Hostname(), "."))
+	if err != nil {
+		return "", err
+	}
+
+	port := parsedURL.Port()
+	if (parsedURL.Scheme == "http" && port == "80") || (parsedURL.Scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	switch {
+	case port != "":
+		parsedURL.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		parsedURL.Host = "[" + host + "]"
+	default:
+		parsedURL.Host = host
+	}
+
+	if parsedURL.Path == "" {
+		parsedURL.Path = "/"
+	}
+
+	rawPath := normalizePercentEncoding(parsedURL.EscapedPath())
+	if parsedURL.Path, err = netUrl.PathUnescape(rawPath); err != nil {
+		return "", fmt.Errorf("failed to parse url: %w", err)
+	}
+
+	parsedURL.RawPath = rawPath
+
+	parsedURL.RawQuery = n.normalizeQuery(parsedURL.Query())
+	parsedURL.ForceQuery = false
+
+	if n.stripFragment {
+		parsedURL.Fragment = ""
+		parsedURL.RawFragment = ""
+	}
+
+	return models.URL(parsedURL.String()), nil
+}
+
+// normalizeQuery encodes query parameters sorted by key, preserving the order of values within a key.
+func (n *ConfigurableURLNormalizer) normalizeQuery(query netUrl.Values) string {
+	if n.stripTrackingParams {
+		for key := range query {
+			if n.isTrackingParam(key) {
+				query.Del(key)
+			}
+		}
+	}
+
+	return query.Encode()
+}
+
+func (n *ConfigurableURLNormalizer) isTrackingParam(key string) bool {
+	key = strings.ToLower(key)
+
+	for _, param := range n.trackingParams {
+		if prefix := strings.TrimSuffix(param, "*"); prefix != param {
+			if strings.HasPrefix(key, strings.ToLower(prefix)) {
+				return true
+			}
+
+			continue
+		}
+
+		if key == strings.ToLower(param) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+hostProfile maps host names the way browsers look them up (UTS #46): case folding, NFC normalization
+and punycode for non-ASCII labels. Underscores, which are common in real host names, are allowed.
+*/
+var hostProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.Transitional(false),
+	idna.StrictDomainName(false),
+	idna.CheckHyphens(false),
+)
+
+var errEmptyHostLabel = errors.New("host has an empty label")
+
+/*
+toASCIIHost lowercases host and converts it to punycode if it is internationalized. ASCII host names are only
+lowercased, so names which are valid for DNS but not for IDNA (e.g. "r3---sn-abc") are kept.
+*/
+func toASCIIHost(host string) (string, error) {
+	if net.ParseIP(host) != nil {
+		return strings.ToLower(host), nil
+	}
+
+	asciiHost := strings.ToLower(host)
+
+	if !isASCII(host) {
+		var err error
+
+		if asciiHost, err = hostProfile.ToASCII(host); err != nil {
+			return "", fmt.Errorf("failed to convert host to ASCII: %w", err)
+		}
+	}
+
+	for _, label := range strings.Split(asciiHost, ".") {
+		if label == "" {
+			return "", errEmptyHostLabel
+		}
+	}
+
+	return asciiHost, nil
+}
+
+/*
+normalizePercentEncoding decodes percent-encoded unreserved characters and uppercases the rest of the escapes
+(RFC 3986, section 6.2.2.2), e.g. "/%7euser/a%2fb" becomes "/~user/a%2Fb".
+*/
+func normalizePercentEncoding(escaped string) string {
+	var builder strings.Builder
+
+	builder.Grow(len(escaped))
+
+	for index := 0; index < len(escaped); index++ {
+		if escaped[index] != '%' || index+2 >= len(escaped) {
+			builder.WriteByte(escaped[index])
+
+			continue
+		}
+
+		decoded, err := strconv.ParseUint(escaped[index+1:index+3], 16, 8)
+		if err != nil {
+			builder.WriteByte(escaped[index])
+
+			continue
+		}
+
+		if isUnreserved(byte(decoded)) {
+			builder.WriteByte(byte(decoded))
+		} else {
+			builder.WriteString("%" + strings.ToUpper(escaped[index+1:index+3]))
+		}
+
+		index += 2
+	}
+
+	return builder.String()
+}
+
+func isUnreserved(char byte) bool {
+	return 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || '0' <= char && char <= '9' ||
+		char == '-' || char == '.' || char == '_' || char == '~'
+}
+
+func isASCII(s string) bool {
+	for index := 0; index < len(s); index++ {
+		if s[index] >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,135 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+func TestConfigurableURLNormalizer_Normalize(t *testing.T) {
+	t.Parallel()
+
+	policyCfg := configs.NewDefaultPolicyConfig()
+	policyCfg.StripTrackingParams = true
+	policyCfg.StripFragment = true
+
+	normalizer := utils.NewConfigurableURLNormalizer(policyCfg)
+
+	tests := []struct {
+		name         string
+		url          models.URL
+		canonicalURL models.URL
+		isError      bool
+	}{
+		{
+			name:         "test case 1: mixed-case scheme and host",
+			url:          "HTTPS://ExAmple.COM/Path",
+			canonicalURL: "https://example.com/Path",
+			isError:      false,
+		},
+		{
+			name:         "test case 2: default ports",
+			url:          "http://example.com:80",
+			canonicalURL: "http://example.com/",
+			isError:      false,
+		},
+		{
+			name:         "test case 3: non-default port",
+			url:          "https://example.com:80/",
+			canonicalURL: "https://example.com:80/",
+			isError:      false,
+		},
+		{
+			name:         "test case 4: trailing dot",
+			url:          "https://example.com.:443/a",
+			canonicalURL: "https://example.com/a",
+			isError:      false,
+		},
+		{
+			name:         "test case 5: internationalized host",
+			url:          "http://Bücher.example/",
+			canonicalURL: "http://xn--bcher-kva.example/",
+			isError:      false,
+		},
+		{
+			name:         "test case 6: punycode host",
+			url:          "http://XN--BCHER-KVA.example/",
+			canonicalURL: "http://xn--bcher-kva.example/",
+			isError:      false,
+		},
+		{
+			name:         "test case 7: DNS name which is not a valid IDNA label",
+			url:          "https://r3---sn-abc.googlevideo.com/",
+			canonicalURL: "https://r3---sn-abc.googlevideo.com/",
+			isError:      false,
+		},
+		{
+			name:         "test case 8: percent-encoding",
+			url:          "http://example.com/%7euser/a%2fb/%e2%82%ac/Stra%C3%9Fe",
+			canonicalURL: "http://example.com/~user/a%2Fb/%E2%82%AC/Stra%C3%9Fe",
+			isError:      false,
+		},
+		{
+			name:         "test case 9: query and fragment",
+			url:          "https://example.com/?b=2&utm_source=x&a=1&a=0#top",
+			canonicalURL: "https://example.com/?a=1&a=0&b=2",
+			isError:      false,
+		},
+		{
+			name:         "test case 10: IPv6 host",
+			url:          "http://[::1]:80/",
+			canonicalURL: "http://[::1]/",
+			isError:      false,
+		},
+		{
+			name:         "test case 11: unclosed IPv6 host",
+			url:          "http://[::1/",
+			canonicalURL: "",
+			isError:      true,
+		},
+		{
+			name:         "test case 12: space in host",
+			url:          "http://exa mple.com/",
+			canonicalURL: "",
+			isError:      true,
+		},
+		{
+			name:         "test case 13: invalid bidirectional label",
+			url:          "http://aاb.example/",
+			canonicalURL: "",
+			isError:      true,
+		},
+		{
+			name:         "test case 14: empty label",
+			url:          "http://münchen..de/",
+			canonicalURL: "",
+			isError:      true,
+		},
+		{
+			name:         "test case 15: label mapped to nothing",
+			url:          "http://\u00ad.example/",
+			canonicalURL: "",
+			isError:      true,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			canonicalURL, err := normalizer.Normalize(testCase.url)
+
+			if testCase.isError {
+				assert.Error(t, err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.canonicalURL, canonicalURL)
+		})
+	}
+}
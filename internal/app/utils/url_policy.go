@@ -0,0 +1,279 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	netUrl "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/models"
+)
+
+const (
+	URLPolicyRuleMaxLength      = "max_length"
+	URLPolicyRuleScheme         = "scheme"
+	URLPolicyRulePrivateNetwork = "private_network"
+	URLPolicyRuleAllowedHosts   = "allowed_hosts"
+	URLPolicyRuleDeniedHosts    = "denied_hosts"
+	URLPolicyRuleSelfReference  = "self_reference"
+	URLPolicyRuleBlocklist      = "blocklist"
+)
+
+type URLPolicyError struct {
+	Rule    string
+	Message string
+}
+
+func (e *URLPolicyError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Rule, e.Message)
+}
+
+type URLPolicy interface {
+	// Check takes the URL as it is stored and its canonical form, host rules are checked against the latter.
+	Check(url, canonicalURL models.URL) error
+}
+
+/*
+ConfigurableURLPolicy decides whether a destination can be shortened.
+Rules are checked in the following order:
+length, scheme, self reference, denied hosts, allowed hosts, private network, blocklist.
+*/
+type ConfigurableURLPolicy struct {
+	allowedSchemes       map[string]struct{}
+	allowedHosts         []string
+	deniedHosts          []string
+	allowPrivateNetworks bool
+	maxURLLength         int
+	baseHost             string
+	blocklist            Blocklist
+}
+
+func NewConfigurableURLPolicy(
+	policyCfg *configs.PolicyConfig,
+	baseURL string,
+	blocklist Blocklist,
+) *ConfigurableURLPolicy {
+	allowedSchemes := make(map[string]struct{}, len(policyCfg.AllowedSchemes))
+	for _, scheme := range policyCfg.AllowedSchemes {
+		allowedSchemes[strings.ToLower(scheme)] = struct{}{}
+	}
+
+	baseHost := ""
+	if parsedBaseURL, err := netUrl.Parse(baseURL); err == nil && parsedBaseURL.Host != "" {
+		baseHost = normalizeHostPort(parsedBaseURL)
+	}
+
+	return &ConfigurableURLPolicy{
+		allowedSchemes:       allowedSchemes,
+		allowedHosts:         normalizeHostPatterns(policyCfg.AllowedHosts),
+		deniedHosts:          normalizeHostPatterns(policyCfg.DeniedHosts),
+		allowPrivateNetworks: policyCfg.AllowPrivateNetworks,
+		maxURLLength:         policyCfg.MaxURLLength,
+		baseHost:             baseHost,
+		blocklist:            blocklist,
+	}
+}
+
+func (p *ConfigurableURLPolicy) Check(url, canonicalURL models.URL) error {
+	// Both forms are stored, so neither of them can exceed the limit.
+	if p.maxURLLength > 0 && (len(url) > p.maxURLLength || len(canonicalURL) > p.maxURLLength) {
+		return &URLPolicyError{
+			Rule:    URLPolicyRuleMaxLength,
+			Message: fmt.Sprintf("URL is longer than %d characters", p.maxURLLength),
+		}
+	}
+
+	parsedURL, err := netUrl.Parse(canonicalURL.String())
+	if err != nil {
+		return &URLPolicyError{Rule: URLPolicyRuleScheme, Message: "URL can not be parsed"}
+	}
+
+	scheme := strings.ToLower(parsedURL.Scheme)
+	if _, ok := p.allowedSchemes[scheme]; !ok {
+		return &URLPolicyError{Rule: URLPolicyRuleScheme, Message: fmt.Sprintf("scheme %q is not allowed", scheme)}
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsedURL.Hostname()), ".")
+	if host == "" {
+		return &URLPolicyError{Rule: URLPolicyRuleScheme, Message: "URL has no host"}
+	}
+
+	if p.baseHost != "" && normalizeHostPort(parsedURL) == p.baseHost {
+		return &URLPolicyError{Rule: URLPolicyRuleSelfReference, Message: "URL points to this shortener"}
+	}
+
+	if matchHostPatterns(host, p.deniedHosts) {
+		return &URLPolicyError{Rule: URLPolicyRuleDeniedHosts, Message: fmt.Sprintf("host %q is denied", host)}
+	}
+
+	if len(p.allowedHosts) > 0 && !matchHostPatterns(host, p.allowedHosts) {
+		return &URLPolicyError{Rule: URLPolicyRuleAllowedHosts, Message: fmt.Sprintf("host %q is not allowed", host)}
+	}
+
+	if !p.allowPrivateNetworks && isPrivateHost(host) {
+		return &URLPolicyError{
+			Rule:    URLPolicyRulePrivateNetwork,
+			Message: fmt.Sprintf("host %q belongs to a private network", host),
+		}
+	}
+
+	if entry, ok := p.blocklist.Match(canonicalURL); ok {
+		return &URLPolicyError{Rule: URLPolicyRuleBlocklist, Message: fmt.Sprintf("URL matches entry %q", entry)}
+	}
+
+	return nil
+}
+
+// carrierGradeNAT is the shared address space of RFC 6598, it is not reachable from the public Internet.
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isPrivateHost(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return isPrivateIP(ip)
+	}
+
+	/*
+		Resolvers and browsers accept IPv4 addresses written as one to four decimal, octal or hexadecimal numbers,
+		e.g. 0x7f.1, 2130706433 and 017700000001 are 127.0.0.1. A numeric host which is not such an address
+		is not a host name either, so it is refused as well.
+	*/
+	if isNumericHost(host) {
+		ip, ok := parseIPv4Literal(host)
+
+		return !ok || isPrivateIP(ip)
+	}
+
+	// Single label and reserved names never resolve to a public destination.
+	if !strings.Contains(host, ".") {
+		return true
+	}
+
+	for _, suffix := range []string{".localhost", ".local", ".internal", ".lan", ".home.arpa"} {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() || carrierGradeNAT.Contains(ip)
+}
+
+// isNumericHost reports hosts whose last label is a number, the WHATWG URL standard parses them as IPv4 addresses.
+func isNumericHost(host string) bool {
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	last := labels[len(labels)-1]
+
+	if hex := strings.TrimPrefix(last, "0x"); hex != last {
+		return strings.Trim(hex, "0123456789abcdef") == ""
+	}
+
+	return last != "" && strings.Trim(last, "0123456789") == ""
+}
+
+// parseIPv4Literal parses IPv4 addresses in the forms accepted by inet_aton, e.g. 127.1 or 0x7f000001.
+func parseIPv4Literal(host string) (net.IP, bool) {
+	parts := strings.Split(strings.TrimSuffix(host, "."), ".")
+	if len(parts) > net.IPv4len {
+		return nil, false
+	}
+
+	numbers := make([]uint64, len(parts))
+
+	for index, part := range parts {
+		base := 10
+
+		switch {
+		case strings.HasPrefix(part, "0x"):
+			part, base = strings.TrimPrefix(part, "0x"), 16
+		case len(part) > 1 && strings.HasPrefix(part, "0"):
+			part, base = strings.TrimPrefix(part, "0"), 8
+		}
+
+		if part == "" && base == 16 {
+			part = "0"
+		}
+
+		number, err := strconv.ParseUint(part, base, 32)
+		if err != nil {
+			return nil, false
+		}
+
+		numbers[index] = number
+	}
+
+	// Every part but the last is a single byte, the last one fills the remaining bytes.
+	var address uint64
+
+	for _, number := range numbers[:len(numbers)-1] {
+		if number > 0xff {
+			return nil, false
+		}
+
+		address = address<<8 | number
+	}
+
+	remainingBits := 8 * (net.IPv4len - len(numbers) + 1)
+
+	last := numbers[len(numbers)-1]
+	if last >= 1<<remainingBits {
+		return nil, false
+	}
+
+	address = address<<remainingBits | last
+
+	return net.IPv4(byte(address>>24), byte(address>>16), byte(address>>8), byte(address)), true
+}
+
+func normalizeHostPort(parsedURL *netUrl.URL) string {
+	host := strings.TrimSuffix(strings.ToLower(parsedURL.Hostname()), ".")
+	port := parsedURL.Port()
+
+	if port == "" {
+		switch strings.ToLower(parsedURL.Scheme) {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+func normalizeHostPatterns(patterns []string) []string {
+	normalized := make([]string, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(pattern)), ".")
+		if pattern != "" {
+			normalized = append(normalized, pattern)
+		}
+	}
+
+	return normalized
+}
+
+// matchHostPatterns reports whether host equals one of patterns or is a subdomain of a "*." pattern.
+func matchHostPatterns(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if suffix := strings.TrimPrefix(pattern, "*."); suffix != pattern {
+			if host == suffix || strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+
+			continue
+		}
+
+		if host == pattern {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,107 @@
+package utils_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/configs"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+func TestConfigurableURLPolicy_Check(t *testing.T) {
+	t.Parallel()
+
+	policyCfg := configs.NewDefaultPolicyConfig()
+	policyCfg.MaxURLLength = 64
+	policyCfg.DeniedHosts = []string{"*.denied.example"}
+
+	policy := utils.NewConfigurableURLPolicy(
+		policyCfg,
+		"http://localhost:8080",
+		utils.NewFileBlocklist("", 0, logger.NewNop()),
+	)
+
+	tests := []struct {
+		name string
+		url  models.URL
+		rule string
+	}{
+		{name: "test case 1: public host", url: "https://example.com/path", rule: ""},
+		{name: "test case 2: public IPv4", url: "http://93.184.216.34/", rule: ""},
+		{name: "test case 3: too long", url: models.URL("https://example.com/" + strings.Repeat("a", 64)), rule: utils.URLPolicyRuleMaxLength},
+		{name: "test case 4: scheme", url: "ftp://example.com/", rule: utils.URLPolicyRuleScheme},
+		{name: "test case 5: no host", url: "http:///path", rule: utils.URLPolicyRuleScheme},
+		{name: "test case 6: self reference", url: "http://LOCALHOST:8080/abc", rule: utils.URLPolicyRuleSelfReference},
+		{name: "test case 7: denied host", url: "https://www.denied.example/", rule: utils.URLPolicyRuleDeniedHosts},
+		{name: "test case 8: loopback", url: "http://127.0.0.1/", rule: utils.URLPolicyRulePrivateNetwork},
+		{name: "test case 9: short IPv4", url: "http://127.1/", rule: utils.URLPolicyRulePrivateNetwork},
+		{name: "test case 10: hexadecimal IPv4", url: "http://0x7f.1/", rule: utils.URLPolicyRulePrivateNetwork},
+		{name: "test case 11: decimal IPv4", url: "http://2130706433/", rule: utils.URLPolicyRulePrivateNetwork},
+		{name: "test case 12: octal IPv4", url: "http://017700000001/", rule: utils.URLPolicyRulePrivateNetwork},
+		{name: "test case 13: dotted octal IPv4", url: "http://0177.0.0.01/", rule: utils.URLPolicyRulePrivateNetwork},
+		{name: "test case 14: hexadecimal private IPv4", url: "http://0xa000001/", rule: utils.URLPolicyRulePrivateNetwork},
+		{name: "test case 15: carrier-grade NAT", url: "http://100.64.0.1/", rule: utils.URLPolicyRulePrivateNetwork},
+		{name: "test case 16: next to carrier-grade NAT", url: "http://100.128.0.1/", rule: ""},
+		{name: "test case 17: metadata service", url: "http://169.254.169.254/latest", rule: utils.URLPolicyRulePrivateNetwork},
+		{name: "test case 18: IPv6 loopback", url: "http://[::1]/", rule: utils.URLPolicyRulePrivateNetwork},
+		{name: "test case 19: IPv4-mapped IPv6", url: "http://[::ffff:127.0.0.1]/", rule: utils.URLPolicyRulePrivateNetwork},
+		{name: "test case 20: numeric host out of range", url: "http://256.1.1.1/", rule: utils.URLPolicyRulePrivateNetwork},
+		{name: "test case 21: numeric host with 5 parts", url: "http://1.2.3.4.5/", rule: utils.URLPolicyRulePrivateNetwork},
+		{name: "test case 22: public decimal IPv4", url: "http://1572395042/", rule: ""},
+		{name: "test case 23: single label", url: "http://intranet/", rule: utils.URLPolicyRulePrivateNetwork},
+		{name: "test case 24: reserved suffix", url: "http://printer.local/", rule: utils.URLPolicyRulePrivateNetwork},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := policy.Check(testCase.url, testCase.url)
+
+			if testCase.rule == "" {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			var policyErr *utils.URLPolicyError
+
+			require.True(t, errors.As(err, &policyErr), err)
+			assert.Equal(t, testCase.rule, policyErr.Rule)
+		})
+	}
+}
+
+func TestConfigurableURLPolicy_Check_AllowPrivateNetworks(t *testing.T) {
+	t.Parallel()
+
+	policyCfg := configs.NewDefaultPolicyConfig()
+	policyCfg.AllowPrivateNetworks = true
+
+	policy := utils.NewConfigurableURLPolicy(policyCfg, "", utils.NewFileBlocklist("", 0, logger.NewNop()))
+
+	assert.NoError(t, policy.Check("http://0x7f.1/", "http://0x7f.1/"))
+	assert.NoError(t, policy.Check("http://100.64.0.1/", "http://100.64.0.1/"))
+}
+
+func TestConfigurableURLPolicy_Check_StoredURLLength(t *testing.T) {
+	t.Parallel()
+
+	policyCfg := configs.NewDefaultPolicyConfig()
+	policyCfg.MaxURLLength = 30
+
+	policy := utils.NewConfigurableURLPolicy(policyCfg, "", utils.NewFileBlocklist("", 0, logger.NewNop()))
+
+	// The canonical form fits the limit, the stored URL does not.
+	err := policy.Check("HTTP://EXAMPLE.COM:80/?utm_source=x", "http://example.com/")
+
+	var policyErr *utils.URLPolicyError
+
+	require.True(t, errors.As(err, &policyErr), err)
+	assert.Equal(t, utils.URLPolicyRuleMaxLength, policyErr.Rule)
+}
@@ -0,0 +1,466 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	netUrl "net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+)
+
+const (
+	WebhookHeaderSignature = "X-Shorturl-Signature"
+	WebhookHeaderEvent     = "X-Shorturl-Event"
+	WebhookHeaderDelivery  = "X-Shorturl-Delivery"
+
+	webhookSignaturePrefix = "sha256="
+	webhookSecretPrefix    = "whsec_"
+	webhookSecretSize      = 32
+)
+
+// ErrWebhookPrivateAddress is returned when a webhook receiver resolves to a private network address.
+var ErrWebhookPrivateAddress = errors.New("webhook receiver address belongs to a private network")
+
+// CheckWebhookURL refuses receivers which are not HTTP(S) or point to a private network unless it is allowed.
+func CheckWebhookURL(url models.URL, allowPrivateNetworks bool) error {
+	parsedURL, err := netUrl.Parse(url.String())
+	if err != nil {
+		return &URLPolicyError{Rule: URLPolicyRuleScheme, Message: "URL can not be parsed"}
+	}
+
+	scheme := strings.ToLower(parsedURL.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return &URLPolicyError{Rule: URLPolicyRuleScheme, Message: fmt.Sprintf("scheme %q is not allowed", scheme)}
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsedURL.Hostname()), ".")
+	if host == "" {
+		return &URLPolicyError{Rule: URLPolicyRuleScheme, Message: "URL has no host"}
+	}
+
+	if !allowPrivateNetworks && isPrivateHost(host) {
+		return &URLPolicyError{
+			Rule:    URLPolicyRulePrivateNetwork,
+			Message: fmt.Sprintf("host %q belongs to a private network", host),
+		}
+	}
+
+	return nil
+}
+
+/*
+NewWebhookHTTPClient returns the client which sends deliveries. A public host name can resolve to a private
+address later, so unless private networks are allowed the resolved address is checked again on every dial.
+Proxies are not used, as the proxy would dial the receiver instead of the client.
+*/
+func NewWebhookHTTPClient(timeout time.Duration, allowPrivateNetworks bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	if !allowPrivateNetworks {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+				return fmt.Errorf("%w: %s", ErrWebhookPrivateAddress, host)
+			}
+
+			return nil
+		}
+	}
+
+	transport, _ := http.DefaultTransport.(*http.Transport)
+	transport = transport.Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// GenerateWebhookSecret returns a new random secret which signs deliveries of a webhook.
+func GenerateWebhookSecret() (string, error) {
+	buf := make([]byte, webhookSecretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	return webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// SignWebhookPayload returns the value of the signature header: HMAC-SHA256 of payload keyed by secret.
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+type webhookPayloadJSON struct {
+	ID         uuid.UUID `json:"id"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       struct {
+		UID         models.UID `json:"uid"`
+		ShortURL    models.URL `json:"short_url"`
+		OriginalURL models.URL `json:"original_url"`
+		UserID      uuid.UUID  `json:"user_id"`
+		IsDeleted   bool       `json:"is_deleted"`
+		IsDisabled  bool       `json:"is_disabled"`
+		Clicks      int        `json:"clicks"`
+		ExpiresAt   *time.Time `json:"expires_at"`
+	} `json:"data"`
+}
+
+type WebhookDispatcher interface {
+	// Emit delivers the event to webhooks of the link owner which are subscribed to its type.
+	// Log lines of the delivery carry the request ID of ctx.
+	Emit(ctx context.Context, event *models.WebhookEvent)
+
+	// Dispatch records deliveries of the event before it returns, the deliveries are sent in the background.
+	Dispatch(ctx context.Context, event *models.WebhookEvent) error
+}
+
+/*
+BackgroundWebhookDispatcher delivers events in the background, so emitting never blocks a request.
+A failed attempt is retried after backoff, the delay doubles with every attempt up to maxBackoff.
+After maxAttempts failed attempts the delivery is dead-lettered. Every attempt is recorded in the delivery log.
+Deliveries which are still pending when the dispatcher is shut down are resumed on the next start.
+*/
+type BackgroundWebhookDispatcher struct {
+	mu          sync.Mutex
+	isShutdown  bool
+	deliveries  sync.WaitGroup
+	closing     chan struct{}
+	rep         repositories.WebhookRepository
+	client      *http.Client
+	baseURL     string
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	log         *logger.Logger
+}
+
+func NewBackgroundWebhookDispatcher(
+	rep repositories.WebhookRepository,
+	client *http.Client,
+	baseURL string,
+	maxAttempts int,
+	backoff, maxBackoff time.Duration,
+	log *logger.Logger,
+) *BackgroundWebhookDispatcher {
+	dispatcher := &BackgroundWebhookDispatcher{
+		mu:          sync.Mutex{},
+		isShutdown:  false,
+		deliveries:  sync.WaitGroup{},
+		closing:     make(chan struct{}),
+		rep:         rep,
+		client:      client,
+		baseURL:     baseURL,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		maxBackoff:  maxBackoff,
+		log:         log,
+	}
+
+	// Pending deliveries are found before Dispatch is possible, so deliveries of this run are not resumed twice.
+	for _, delivery := range dispatcher.findPending() {
+		delivery := delivery
+
+		dispatcher.start(func() {
+			dispatcher.resume(delivery)
+		})
+	}
+
+	return dispatcher
+}
+
+func (d *BackgroundWebhookDispatcher) Emit(ctx context.Context, event *models.WebhookEvent) {
+	ctx = logger.Detach(ctx)
+
+	d.start(func() {
+		if err := d.Dispatch(ctx, event); err != nil {
+			d.log.Ctx(ctx).Error("failed to dispatch webhook event", "event", event.Type, logger.KeyError, err)
+		}
+	})
+}
+
+/*
+Shutdown stops retrying and waits for attempts in progress. Deliveries which are not finished stay pending
+and are resumed on the next start.
+*/
+func (d *BackgroundWebhookDispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+
+	if !d.isShutdown {
+		d.isShutdown = true
+		close(d.closing)
+	}
+
+	d.mu.Unlock()
+
+	stopped := make(chan struct{})
+
+	go func() {
+		d.deliveries.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to stop webhook dispatcher: %w", ctx.Err())
+	}
+}
+
+// start runs fn in the background unless the dispatcher is shut down, Shutdown waits for it.
+func (d *BackgroundWebhookDispatcher) start(fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.isShutdown {
+		return
+	}
+
+	d.deliveries.Add(1)
+
+	go func() {
+		defer d.deliveries.Done()
+
+		fn()
+	}()
+}
+
+func (d *BackgroundWebhookDispatcher) Dispatch(ctx context.Context, event *models.WebhookEvent) error {
+	webhooks, err := d.rep.FindAllWebhooksByUserID(ctx, event.ShortURL.UserID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to find webhooks: %w", err)
+	}
+
+	payload, err := d.newPayload(event)
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.IsSubscribed(event.Type) {
+			continue
+		}
+
+		webhook := webhook
+
+		delivery := models.NewWebhookDelivery(webhook.ID, event.ID, event.Type, string(payload))
+		if err := d.rep.SaveWebhookDelivery(ctx, delivery); err != nil {
+			return fmt.Errorf("failed to save webhook delivery: %w", err)
+		}
+
+		// The delivery is saved, so it is resumed on the next start if it is not made in this run.
+		d.start(func() {
+			d.deliver(webhook, delivery, payload, 0)
+		})
+	}
+
+	return nil
+}
+
+func (d *BackgroundWebhookDispatcher) newPayload(event *models.WebhookEvent) ([]byte, error) {
+	payloadJSON := webhookPayloadJSON{
+		ID:         event.ID,
+		Type:       event.Type,
+		OccurredAt: event.OccurredAt,
+	}
+
+	payloadJSON.Data.UID = event.ShortURL.UID
+	payloadJSON.Data.ShortURL = event.ShortURL.GetShortURL(d.baseURL)
+	payloadJSON.Data.OriginalURL = event.ShortURL.URL
+	payloadJSON.Data.UserID = event.ShortURL.UserID
+	payloadJSON.Data.IsDeleted = event.ShortURL.IsDeleted
+	payloadJSON.Data.IsDisabled = event.ShortURL.IsDisabled
+	payloadJSON.Data.Clicks = event.ShortURL.Clicks
+
+	if !event.ShortURL.ExpiresAt.IsZero() {
+		expiresAt := event.ShortURL.ExpiresAt
+		payloadJSON.Data.ExpiresAt = &expiresAt
+	}
+
+	var buf bytes.Buffer
+	jsonEncoder := json.NewEncoder(&buf)
+	jsonEncoder.SetEscapeHTML(false)
+
+	if err := jsonEncoder.Encode(payloadJSON); err != nil {
+		return nil, fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// findPending finds deliveries which were pending when the previous run stopped.
+func (d *BackgroundWebhookDispatcher) findPending() []*models.WebhookDelivery {
+	deliveries, err := d.rep.FindAllPendingWebhookDeliveries(context.Background())
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		d.log.Error("failed to resume webhook deliveries", logger.KeyError, err)
+	}
+
+	return deliveries
+}
+
+// resume continues the delivery of the previous run after the retry delay of its last attempt.
+func (d *BackgroundWebhookDispatcher) resume(delivery *models.WebhookDelivery) {
+	webhook, err := d.rep.FindWebhookByID(context.Background(), delivery.WebhookID)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		d.log.Error("failed to resume webhook delivery", "delivery", delivery.ID, logger.KeyError, err)
+
+		return
+	}
+
+	if webhook == nil || webhook.IsDeleted() {
+		delivery.Status = models.WebhookDeliveryStatusDeadLetter
+		delivery.LastError = "webhook is deleted"
+		delivery.UpdatedAt = time.Now()
+
+		if err := d.rep.SaveWebhookDelivery(context.Background(), delivery); err != nil {
+			d.log.Error("failed to save webhook delivery", "delivery", delivery.ID, logger.KeyError, err)
+		}
+
+		return
+	}
+
+	var delay time.Duration
+	if delivery.Attempts > 0 {
+		delay = time.Until(delivery.UpdatedAt.Add(exponentialBackoff(delivery.Attempts, d.backoff, d.maxBackoff)))
+	}
+
+	d.log.Info("webhook delivery is resumed", "delivery", delivery.ID)
+	d.deliver(webhook, delivery, []byte(delivery.Payload), delay)
+}
+
+/*
+deliver makes attempts until the webhook accepts the delivery or attempts are exhausted. The first attempt is made
+after delay. On shutdown it stops waiting for the next attempt and leaves the delivery pending.
+*/
+func (d *BackgroundWebhookDispatcher) deliver(
+	webhook *models.Webhook,
+	delivery *models.WebhookDelivery,
+	payload []byte,
+	delay time.Duration,
+) {
+	for {
+		if !d.wait(delay) {
+			return
+		}
+
+		statusCode, err := d.send(webhook, delivery, payload)
+
+		delivery.Attempts++
+		delivery.LastStatusCode = statusCode
+		delivery.UpdatedAt = time.Now()
+
+		switch {
+		case err == nil:
+			delivery.Status = models.WebhookDeliveryStatusSucceeded
+			delivery.LastError = ""
+		case delivery.Attempts >= d.maxAttempts:
+			delivery.Status = models.WebhookDeliveryStatusDeadLetter
+			delivery.LastError = err.Error()
+		default:
+			delivery.LastError = err.Error()
+		}
+
+		if err := d.rep.SaveWebhookDelivery(context.Background(), delivery); err != nil {
+			d.log.Error("failed to save webhook delivery", "delivery", delivery.ID, logger.KeyError, err)
+		}
+
+		if delivery.Status != models.WebhookDeliveryStatusPending {
+			return
+		}
+
+		delay = exponentialBackoff(delivery.Attempts, d.backoff, d.maxBackoff)
+	}
+}
+
+// wait reports false if the dispatcher is shut down before delay passes.
+func (d *BackgroundWebhookDispatcher) wait(delay time.Duration) bool {
+	select {
+	case <-d.closing:
+		return false
+	default:
+	}
+
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-d.closing:
+		return false
+	}
+}
+
+func (d *BackgroundWebhookDispatcher) send(
+	webhook *models.Webhook,
+	delivery *models.WebhookDelivery,
+	payload []byte,
+) (int, error) {
+	request, err := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodPost,
+		webhook.URL.String(),
+		bytes.NewReader(payload),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookHeaderEvent, delivery.EventType)
+	request.Header.Set(WebhookHeaderDelivery, delivery.ID.String())
+	request.Header.Set(WebhookHeaderSignature, SignWebhookPayload(webhook.Secret, payload))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send webhook request: %w", err)
+	}
+
+	defer func(body io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, body)
+
+		if err := body.Close(); err != nil {
+			d.log.Error("failed to close webhook response", logger.KeyError, err)
+		}
+	}(response.Body)
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return response.StatusCode, fmt.Errorf("unexpected webhook response status: %d", response.StatusCode)
+	}
+
+	return response.StatusCode, nil
+}
@@ -0,0 +1,366 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmitry/shorturl/internal/app/logger"
+	"github.com/tmitry/shorturl/internal/app/models"
+	"github.com/tmitry/shorturl/internal/app/repositories"
+	"github.com/tmitry/shorturl/internal/app/utils"
+)
+
+const (
+	testWebhookMaxAttempts = 3
+	testWebhookBackoff     = time.Millisecond
+	testWebhookMaxBackoff  = 4 * time.Millisecond
+	testWebhookWaitFor     = 2 * time.Second
+	testWebhookTick        = 5 * time.Millisecond
+)
+
+func TestBackgroundWebhookDispatcher_Emit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		failures  int32 // Number of first requests answered with an error.
+		status    string
+		attempts  int
+		delivered int32
+	}{
+		{
+			name:      "delivered at first attempt",
+			failures:  0,
+			status:    models.WebhookDeliveryStatusSucceeded,
+			attempts:  1,
+			delivered: 1,
+		},
+		{
+			name:      "delivered after retries",
+			failures:  2,
+			status:    models.WebhookDeliveryStatusSucceeded,
+			attempts:  3,
+			delivered: 1,
+		},
+		{
+			name:      "dead-lettered after all attempts",
+			failures:  testWebhookMaxAttempts,
+			status:    models.WebhookDeliveryStatusDeadLetter,
+			attempts:  testWebhookMaxAttempts,
+			delivered: 0,
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			rep := repositories.NewMemoryRepository()
+			userID := uuid.New()
+
+			var requests, delivered int32
+
+			webhook := models.NewWebhook(userID, "", "whsec_test", nil)
+
+			receiver := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				body, err := io.ReadAll(request.Body)
+				assert.NoError(t, err)
+
+				assert.Equal(t, utils.SignWebhookPayload(webhook.Secret, body), request.Header.Get(utils.WebhookHeaderSignature))
+				assert.Equal(t, models.WebhookEventLinkCreated, request.Header.Get(utils.WebhookHeaderEvent))
+				assert.Contains(t, string(body), `"type":"link.created"`)
+				assert.Contains(t, string(body), `"short_url":"http://localhost:8080/abc"`)
+
+				if atomic.AddInt32(&requests, 1) <= testCase.failures {
+					writer.WriteHeader(http.StatusInternalServerError)
+
+					return
+				}
+
+				atomic.AddInt32(&delivered, 1)
+				writer.WriteHeader(http.StatusNoContent)
+			}))
+			t.Cleanup(receiver.Close)
+
+			webhook.URL = models.URL(receiver.URL)
+			require.NoError(t, rep.SaveWebhook(context.Background(), webhook))
+
+			dispatcher := utils.NewBackgroundWebhookDispatcher(
+				rep,
+				utils.NewWebhookHTTPClient(time.Second, true),
+				"http://localhost:8080",
+				testWebhookMaxAttempts,
+				testWebhookBackoff,
+				testWebhookMaxBackoff,
+				logger.NewNop(),
+			)
+
+			shortURL := models.NewShortURL(1, "https://example.com/", "abc", userID)
+			dispatcher.Emit(context.Background(), models.NewWebhookEvent(models.WebhookEventLinkCreated, shortURL))
+
+			var delivery *models.WebhookDelivery
+
+			require.Eventually(t, func() bool {
+				deliveries, err := rep.FindAllWebhookDeliveriesByWebhookID(context.Background(), webhook.ID, 0)
+				if err != nil {
+					return false
+				}
+
+				delivery = deliveries[0]
+
+				return delivery.Status != models.WebhookDeliveryStatusPending
+			}, testWebhookWaitFor, testWebhookTick)
+
+			assert.Equal(t, testCase.status, delivery.Status)
+			assert.Equal(t, testCase.attempts, delivery.Attempts)
+			assert.Equal(t, testCase.delivered, atomic.LoadInt32(&delivered))
+		})
+	}
+}
+
+func TestBackgroundWebhookDispatcher_EmitFiltersEventTypes(t *testing.T) {
+	t.Parallel()
+
+	rep := repositories.NewMemoryRepository()
+	userID := uuid.New()
+
+	var received int32
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&received, 1)
+		writer.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(receiver.Close)
+
+	// Webhooks are processed in the order of creation, so the unsubscribed one is checked first.
+	clicksWebhook := models.NewWebhook(userID, models.URL(receiver.URL), "secret", []string{models.WebhookEventLinkClicked})
+	require.NoError(t, rep.SaveWebhook(context.Background(), clicksWebhook))
+
+	allWebhook := models.NewWebhook(userID, models.URL(receiver.URL), "secret", nil)
+	allWebhook.CreatedAt = clicksWebhook.CreatedAt.Add(time.Second)
+	require.NoError(t, rep.SaveWebhook(context.Background(), allWebhook))
+
+	otherUserWebhook := models.NewWebhook(uuid.New(), models.URL(receiver.URL), "secret", nil)
+	require.NoError(t, rep.SaveWebhook(context.Background(), otherUserWebhook))
+
+	dispatcher := utils.NewBackgroundWebhookDispatcher(
+		rep,
+		utils.NewWebhookHTTPClient(time.Second, true),
+		"http://localhost:8080",
+		testWebhookMaxAttempts,
+		testWebhookBackoff,
+		testWebhookMaxBackoff,
+		logger.NewNop(),
+	)
+
+	shortURL := models.NewShortURL(1, "https://example.com/", "abc", userID)
+	dispatcher.Emit(context.Background(), models.NewWebhookEvent(models.WebhookEventLinkDeleted, shortURL))
+
+	require.Eventually(t, func() bool {
+		deliveries, err := rep.FindAllWebhookDeliveriesByWebhookID(context.Background(), allWebhook.ID, 0)
+
+		return err == nil && deliveries[0].Status == models.WebhookDeliveryStatusSucceeded
+	}, testWebhookWaitFor, testWebhookTick)
+
+	_, err := rep.FindAllWebhookDeliveriesByWebhookID(context.Background(), clicksWebhook.ID, 0)
+	assert.ErrorIs(t, err, repositories.ErrNotFound)
+
+	_, err = rep.FindAllWebhookDeliveriesByWebhookID(context.Background(), otherUserWebhook.ID, 0)
+	assert.ErrorIs(t, err, repositories.ErrNotFound)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&received))
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                 string
+		url                  models.URL
+		allowPrivateNetworks bool
+		rule                 string
+	}{
+		{
+			name:                 "test case 1: public host",
+			url:                  "https://crm.example.com/hook",
+			allowPrivateNetworks: false,
+			rule:                 "",
+		},
+		{
+			name:                 "test case 2: scheme",
+			url:                  "ftp://crm.example.com/hook",
+			allowPrivateNetworks: false,
+			rule:                 utils.URLPolicyRuleScheme,
+		},
+		{
+			name:                 "test case 3: loopback",
+			url:                  "http://127.0.0.1:8080/hook",
+			allowPrivateNetworks: false,
+			rule:                 utils.URLPolicyRulePrivateNetwork,
+		},
+		{
+			name:                 "test case 4: metadata endpoint",
+			url:                  "http://169.254.169.254/latest/meta-data",
+			allowPrivateNetworks: false,
+			rule:                 utils.URLPolicyRulePrivateNetwork,
+		},
+		{
+			name:                 "test case 5: loopback allowed",
+			url:                  "http://127.0.0.1:8080/hook",
+			allowPrivateNetworks: true,
+			rule:                 "",
+		},
+	}
+	for _, testCase := range tests {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := utils.CheckWebhookURL(testCase.url, testCase.allowPrivateNetworks)
+			if testCase.rule == "" {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			var policyErr *utils.URLPolicyError
+			require.ErrorAs(t, err, &policyErr)
+			assert.Equal(t, testCase.rule, policyErr.Rule)
+		})
+	}
+}
+
+func TestNewWebhookHTTPClient_RefusesPrivateAddresses(t *testing.T) {
+	t.Parallel()
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(receiver.Close)
+
+	// The receiver listens on loopback, so the address is refused when the connection is dialed.
+	response, err := utils.NewWebhookHTTPClient(time.Second, false).Get(receiver.URL)
+	if response != nil {
+		require.NoError(t, response.Body.Close())
+	}
+
+	assert.ErrorIs(t, err, utils.ErrWebhookPrivateAddress)
+
+	response, err = utils.NewWebhookHTTPClient(time.Second, true).Get(receiver.URL)
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+}
+
+func TestBackgroundWebhookDispatcher_ResumesPendingDeliveries(t *testing.T) {
+	t.Parallel()
+
+	rep := repositories.NewMemoryRepository()
+	userID := uuid.New()
+
+	var received int32
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&received, 1)
+		writer.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(receiver.Close)
+
+	webhook := models.NewWebhook(userID, models.URL(receiver.URL), "whsec_test", nil)
+	require.NoError(t, rep.SaveWebhook(context.Background(), webhook))
+
+	deletedWebhook := models.NewWebhook(userID, models.URL(receiver.URL), "whsec_test", nil)
+	require.NoError(t, rep.SaveWebhook(context.Background(), deletedWebhook))
+	require.NoError(t, rep.DeleteWebhook(context.Background(), userID, deletedWebhook.ID))
+
+	// Deliveries left pending by the previous run.
+	delivery := models.NewWebhookDelivery(webhook.ID, uuid.New(), models.WebhookEventLinkCreated, `{}`)
+	delivery.Attempts = 1
+	require.NoError(t, rep.SaveWebhookDelivery(context.Background(), delivery))
+
+	orphanedDelivery := models.NewWebhookDelivery(deletedWebhook.ID, uuid.New(), models.WebhookEventLinkCreated, `{}`)
+	require.NoError(t, rep.SaveWebhookDelivery(context.Background(), orphanedDelivery))
+
+	dispatcher := utils.NewBackgroundWebhookDispatcher(
+		rep,
+		utils.NewWebhookHTTPClient(time.Second, true),
+		"http://localhost:8080",
+		testWebhookMaxAttempts,
+		testWebhookBackoff,
+		testWebhookMaxBackoff,
+		logger.NewNop(),
+	)
+
+	require.Eventually(t, func() bool {
+		_, err := rep.FindAllPendingWebhookDeliveries(context.Background())
+
+		return errors.Is(err, repositories.ErrNotFound)
+	}, testWebhookWaitFor, testWebhookTick)
+
+	require.NoError(t, dispatcher.Shutdown(context.Background()))
+
+	deliveries, err := rep.FindAllWebhookDeliveriesByWebhookID(context.Background(), webhook.ID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookDeliveryStatusSucceeded, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+
+	deliveries, err = rep.FindAllWebhookDeliveriesByWebhookID(context.Background(), deletedWebhook.ID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookDeliveryStatusDeadLetter, deliveries[0].Status)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&received))
+}
+
+func TestBackgroundWebhookDispatcher_ShutdownLeavesRetriesPending(t *testing.T) {
+	t.Parallel()
+
+	rep := repositories.NewMemoryRepository()
+	userID := uuid.New()
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(receiver.Close)
+
+	webhook := models.NewWebhook(userID, models.URL(receiver.URL), "whsec_test", nil)
+	require.NoError(t, rep.SaveWebhook(context.Background(), webhook))
+
+	// The retry is not due before the test ends, so only shutdown can stop the delivery.
+	dispatcher := utils.NewBackgroundWebhookDispatcher(
+		rep,
+		utils.NewWebhookHTTPClient(time.Second, true),
+		"http://localhost:8080",
+		testWebhookMaxAttempts,
+		time.Hour,
+		time.Hour,
+		logger.NewNop(),
+	)
+
+	shortURL := models.NewShortURL(1, "https://example.com/", "abc", userID)
+	event := models.NewWebhookEvent(models.WebhookEventLinkCreated, shortURL)
+	require.NoError(t, dispatcher.Dispatch(context.Background(), event))
+
+	require.Eventually(t, func() bool {
+		deliveries, err := rep.FindAllWebhookDeliveriesByWebhookID(context.Background(), webhook.ID, 0)
+
+		return err == nil && deliveries[0].Attempts == 1
+	}, testWebhookWaitFor, testWebhookTick)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testWebhookWaitFor)
+	defer cancel()
+
+	require.NoError(t, dispatcher.Shutdown(ctx))
+
+	deliveries, err := rep.FindAllPendingWebhookDeliveries(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+}